@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command cosi-credential-helper prints the current credentials of a COSI
+// volume using exec credential delivery. The adapter projects it into the
+// volume and names it as the AWS credential_process, so SDKs run it whenever
+// they need credentials:
+//
+//	credential_process = /cosi/cosi-credential-helper --socket /cosi/credential-helper.sock
+//
+// It asks the adapter for the credentials over the volume's socket and prints
+// them as the credential_process JSON, version 1. It must stay small, since it
+// is copied into every exec delivery volume.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+)
+
+const timeout = 15 * time.Second
+
+func main() {
+	socket := flag.String("socket", adapter.CredentialHelperSocketName, "path of the volume's credential helper socket")
+	flag.Parse()
+
+	if err := run(*socket, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "cosi-credential-helper: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(socket string, out io.Writer) error {
+	c := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	// The host is ignored, every request goes to socket.
+	resp, err := c.Get("http://cosi" + adapter.CredentialHelperPath)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("adapter returned %s", resp.Status)
+	}
+	_, err = io.Copy(out, resp.Body)
+	return err
+}
@@ -17,15 +17,43 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	_ "k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/features"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/node"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/registration"
+)
+
+// Build information, injected with -ldflags "-X main.version=...". The
+// release tooling sets version from the git revision.
+var (
+	version   = "v0.0.1"
+	gitCommit = "unknown"
+	buildDate = "unknown"
 )
 
-var Version string
+// nodeNameEnv is set to the node name through the downward API.
+const nodeNameEnv = "KUBE_NODE_NAME"
+
+// podNameEnv and podNamespaceEnv are set to the name and namespace of the
+// adapter's pod through the downward API.
+const (
+	podNameEnv      = "POD_NAME"
+	podNamespaceEnv = "POD_NAMESPACE"
+)
+
+// discoveryCacheDirName is the directory in the data path caching discovery
+// data when --discovery-cache-dir is not set.
+const discoveryCacheDirName = ".discovery-cache"
 
 // flags
 var (
@@ -35,6 +63,59 @@ var (
 	listen      string
 	dataRoot    string
 	volumeLimit int64
+
+	failureVerbosityThreshold int
+
+	revocationWebhook  string
+	revocationAnnotate bool
+	revocationTimeout  time.Duration
+
+	allowPodScopedCredentials bool
+	credentialAccessLog       bool
+
+	loadSheddingLatency time.Duration
+
+	readinessTimeout time.Duration
+
+	metricsAddress string
+
+	featureGates string
+
+	kubeconfig string
+
+	discoveryCacheDir string
+
+	fsyncPolicy   string
+	fsyncInterval time.Duration
+
+	clockSkewThreshold time.Duration
+
+	hardened bool
+
+	memoryBackedVolumes bool
+
+	handoverSockets bool
+
+	credentialHelper string
+
+	defaultFormats []string
+
+	failureInjectionNamespaces []string
+
+	namespaceRateLimits []string
+
+	serviceAccountCheck string
+	kubeletDir          string
+
+	canaryBAR          string
+	canaryPod          string
+	canaryPodNamespace string
+	canaryInterval     time.Duration
+
+	otlpLogsEndpoint string
+
+	kubeletRegistrationPath string
+	pluginRegistrationDir   string
 )
 
 var driverCmd = &cobra.Command{
@@ -43,25 +124,56 @@ var driverCmd = &cobra.Command{
 	Long:         "This Container Storage Interface (CSI) driver provides the ability to reference Bucket and BucketAccess objects, extracting connection/credential information and writing it to the Pod's filesystem. This driver does not manage the lifecycle of the bucket or the backing of the objects themselves, it only acts as the middle-man.",
 	SilenceUsage: true,
 	RunE: func(c *cobra.Command, args []string) error {
-		return driver(args)
+		return driver(c.Context(), c, args)
 	},
 }
 
 func init() {
-	Version = "v0.0.1"
-
 	viper.AutomaticEnv()
 	// parse the go default flagset to get flags for klog and other packages in future
 	driverCmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
 	// defaulting this to true so that logs are printed to console
 	_ = flag.Set("logtostderr", "true")
 
-	driverCmd.PersistentFlags().StringVarP(&identity, "identity", "i", identity, "identity of this COSI CSI driver")
-	driverCmd.PersistentFlags().StringVarP(&nodeID, "node-id", "n", nodeID, "identity of the node in which COSI CSI driver is running")
+	driverCmd.PersistentFlags().StringVar(&identity, "driver-name", node.DefaultDriverName, "name of this COSI CSI driver, reported by GetPluginInfo and prefixing its finalizers; must match the CSIDriver object")
+	driverCmd.PersistentFlags().StringVarP(&identity, "identity", "i", node.DefaultDriverName, "identity of this COSI CSI driver")
+	_ = driverCmd.PersistentFlags().MarkDeprecated("identity", "use --driver-name instead")
+	driverCmd.PersistentFlags().StringVarP(&nodeID, "node-id", "n", os.Getenv(nodeNameEnv), "name of the node the COSI CSI driver is running on, defaults to $"+nodeNameEnv)
 	driverCmd.PersistentFlags().StringVarP(&listen, "listen", "l", listen, "address of the listening socket for the node server")
 	driverCmd.PersistentFlags().StringVarP(&protocol, "protocol", "p", protocol, "must be one of tcp, tcp4, tcp6, unix, unixpacket")
-	driverCmd.PersistentFlags().StringVarP(&dataRoot, "data-path", "d", protocol, "the path to the directory for storing secrets")
-	driverCmd.PersistentFlags().Int64VarP(&volumeLimit, "max-volumes", "m", volumeLimit, "the maximum amount of volumes which can be assigned to a node")
+	driverCmd.PersistentFlags().StringVarP(&dataRoot, "data-path", "d", "/cosi-secret-dir", "the path to the directory for storing secrets and volume state, may be on any partition")
+	driverCmd.PersistentFlags().Int64VarP(&volumeLimit, "max-volumes", "m", volumeLimit, "the maximum amount of volumes which can be assigned to a node, reported to kubelet as max_volumes_per_node; 0 is unlimited")
+	driverCmd.PersistentFlags().StringVar(&revocationWebhook, "revocation-webhook", revocationWebhook, "URL notified with a POST when a pod stops using its bucket credentials")
+	driverCmd.PersistentFlags().BoolVar(&revocationAnnotate, "revocation-annotate", revocationAnnotate, "annotate the BucketAccess when a pod stops using its bucket credentials")
+	driverCmd.PersistentFlags().DurationVar(&revocationTimeout, "revocation-timeout", 5*time.Second, "timeout for the revocation webhook call")
+	driverCmd.PersistentFlags().BoolVar(&allowPodScopedCredentials, "allow-pod-scoped-credentials", allowPodScopedCredentials, "allow volumes to request a BucketAccess minted for the pod alone")
+	driverCmd.PersistentFlags().BoolVar(&credentialAccessLog, "credential-access-log", credentialAccessLog, "audit which processes open the projected credential files (linux only, requires CAP_SYS_ADMIN)")
+	driverCmd.PersistentFlags().StringVar(&kubeletRegistrationPath, "kubelet-registration-path", kubeletRegistrationPath, "path of the CSI socket on the host; when set the adapter registers itself with kubelet instead of relying on node-driver-registrar")
+	driverCmd.PersistentFlags().StringVar(&pluginRegistrationDir, "plugin-registration-dir", registration.DefaultRegistrationDir, "directory kubelet watches for plugin registration sockets")
+	driverCmd.PersistentFlags().DurationVar(&loadSheddingLatency, "load-shedding-latency", loadSheddingLatency, "average API server latency above which optional work is skipped, 0 disables")
+	driverCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", kubeconfig, "path to a kubeconfig for running outside the cluster, defaults to $KUBECONFIG, then the in-cluster config")
+	driverCmd.PersistentFlags().StringVar(&featureGates, "feature-gates", featureGates, fmt.Sprintf("comma separated Feature=true|false pairs, known features: %s", strings.Join(features.Known(), ", ")))
+	driverCmd.PersistentFlags().StringVar(&metricsAddress, "metrics-address", metricsAddress, "address to serve Prometheus metrics on, e.g. :9090; empty disables the metrics server")
+	driverCmd.PersistentFlags().DurationVar(&readinessTimeout, "readiness-timeout", 30*time.Second, "how long publish waits for the BucketAccessRequest, BucketAccess and Bucket to become ready, bounded by the kubelet deadline")
+	driverCmd.PersistentFlags().StringVar(&fsyncPolicy, "fsync-policy", string(node.SyncOnCritical), "when files written to the data path are fsynced: always, interval, on-critical or never")
+	driverCmd.PersistentFlags().DurationVar(&fsyncInterval, "fsync-interval", 5*time.Second, "how often batched writes are fsynced under the interval fsync policy")
+	driverCmd.PersistentFlags().DurationVar(&clockSkewThreshold, "clock-skew-threshold", 30*time.Second, "offset of the node clock from the API server clock above which expiring credentials raise a warning, 0 disables")
+	driverCmd.PersistentFlags().BoolVar(&hardened, "hardened", hardened, "set a 0077 umask, create volume directories 0700 and fail publishing if any projected file is accessible to other users")
+	driverCmd.PersistentFlags().BoolVar(&memoryBackedVolumes, "memory-backed-volumes", memoryBackedVolumes, "mount a tmpfs for every volume before writing credentials, so they never touch persistent node storage")
+	driverCmd.PersistentFlags().BoolVar(&handoverSockets, "handover", handoverSockets, "take the listening sockets over from an adapter already running on the node instead of replacing them, for rolling upgrades without refused connections")
+	driverCmd.PersistentFlags().StringVar(&credentialHelper, "credential-helper", credentialHelper, "path of the cosi-credential-helper executable projected into volumes with credential-delivery exec; empty disables exec delivery")
+	driverCmd.PersistentFlags().StringSliceVar(&defaultFormats, "default-formats", defaultFormats, "formats rendered into volumes whose attributes and BucketClass select none; when unset, the protocol default applies")
+	driverCmd.PersistentFlags().StringVar(&otlpLogsEndpoint, "otlp-logs-endpoint", otlpLogsEndpoint, "OTLP/HTTP URL of an OpenTelemetry collector to export logs to in addition to stderr, e.g. http://otel-collector:4318; empty disables log export")
+	driverCmd.PersistentFlags().StringVar(&discoveryCacheDir, "discovery-cache-dir", discoveryCacheDir, "directory caching API discovery data between restarts, defaults to .discovery-cache in the data path; empty with the flag set disables the cache")
+	driverCmd.PersistentFlags().StringSliceVar(&failureInjectionNamespaces, "failure-injection-namespaces", failureInjectionNamespaces, "namespaces whose pods may request simulated failures with the "+node.FailureInjectionAnnotation+" annotation, with the FailureInjection feature gate")
+	driverCmd.PersistentFlags().StringVar(&canaryBAR, "canary-bar", canaryBAR, "name of a BucketAccessRequest in the namespace of the canary pod to publish a volume for periodically, checking the credentials flow end to end; empty disables the canary")
+	driverCmd.PersistentFlags().StringVar(&canaryPod, "canary-pod", os.Getenv(podNameEnv), "name of the pod the canary volume is published for, defaults to $"+podNameEnv)
+	driverCmd.PersistentFlags().StringVar(&canaryPodNamespace, "canary-pod-namespace", os.Getenv(podNamespaceEnv), "namespace of the canary pod and BucketAccessRequest, defaults to $"+podNamespaceEnv)
+	driverCmd.PersistentFlags().DurationVar(&canaryInterval, "canary-interval", node.DefaultCanaryInterval, "time between two canary publishes")
+	driverCmd.PersistentFlags().StringSliceVar(&namespaceRateLimits, "namespace-rate-limits", namespaceRateLimits, "token bucket limits on the publishes of each pod namespace, as namespace=qps:burst pairs; "+node.AnyNamespace+" applies to the namespaces not listed, unlisted namespaces are not limited without it")
+	driverCmd.PersistentFlags().StringVar(&serviceAccountCheck, "service-account-check", string(node.ServiceAccountCheckWarn), "what publish does when the pod does not run as the service account of its BucketAccessRequest: off, warn or enforce")
+	driverCmd.PersistentFlags().StringVar(&kubeletDir, "kubelet-dir", node.DefaultKubeletDir, "root directory of kubelet; publishes are refused unless their target path is below its pods directory, empty accepts any target path")
+	driverCmd.PersistentFlags().IntVar(&failureVerbosityThreshold, "failure-verbosity-threshold", 3, "number of consecutive publish failures after which logging for that volume is elevated, 0 disables")
 
 	_ = driverCmd.PersistentFlags().MarkHidden("alsologtostderr")
 	_ = driverCmd.PersistentFlags().MarkHidden("log_backtrace_at")
@@ -76,6 +188,6 @@ func init() {
 	_ = viper.BindPFlags(driverCmd.PersistentFlags())
 }
 
-func Execute() error {
-	return driverCmd.Execute()
+func Execute(ctx context.Context) error {
+	return driverCmd.ExecuteContext(ctx)
 }
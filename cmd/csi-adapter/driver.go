@@ -17,35 +17,318 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 
+	"github.com/container-storage-interface/spec/lib/go/csi"
 	csicommon "github.com/kubernetes-csi/drivers/pkg/csi-common"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"k8s.io/klog/v2"
 
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/audit"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/controller"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/features"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/handover"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/health"
 	id "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/identity"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/logexport"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/manager"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/node"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/registration"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
 )
 
-func driver(args []string) error {
-	if protocol == "unix" {
+// driver runs the node server. c is the command it runs for, whose flags tell
+// the unset flags from those set to their default.
+func driver(ctx context.Context, c *cobra.Command, args []string) error {
+	if nodeID == "" {
+		return util.ErrorNodeIDUnset
+	}
+
+	var logExporter *logexport.Exporter
+	if otlpLogsEndpoint != "" {
+		var err error
+		logExporter, err = logexport.New(otlpLogsEndpoint, map[string]string{
+			"service.name":    identity,
+			"service.version": version,
+			"host.name":       nodeID,
+		})
+		if err != nil {
+			return err
+		}
+		if err := logexport.Install(logExporter); err != nil {
+			return err
+		}
+	}
+
+	if hardened {
+		node.HardenUmask()
+	}
+
+	if protocol == "unix" && !handoverSockets {
 		if err := os.RemoveAll(listen); err != nil {
 			klog.Fatalf("could not prepare socket: %v", err)
 		}
 	}
 
-	idServer, err := id.NewIdentityServer(identity, Version, map[string]string{})
+	idServer, err := id.NewIdentityServer(identity, version, map[string]string{
+		id.ManifestGitCommit:      gitCommit,
+		id.ManifestBuildDate:      buildDate,
+		id.ManifestCOSIAPIVersion: v1alpha1.SchemeGroupVersion.String(),
+	})
+	if err != nil {
+		return err
+	}
+	klog.InfoS("identity server prepared", "version", version, "gitCommit", gitCommit, "buildDate", buildDate)
+
+	m := manager.New()
+	if logExporter != nil {
+		m.Add("log exporter", logExporter)
+	}
+
+	var accessMonitor node.AccessMonitor
+	if credentialAccessLog {
+		if accessMonitor, err = node.NewAccessMonitor(audit.LogSink{}); err != nil {
+			return err
+		}
+		m.Add("credential access monitor", accessMonitor)
+	}
+
+	gates, err := features.Parse(featureGates)
+	if err != nil {
+		return err
+	}
+
+	layout, err := node.EnsureLayout(dataRoot)
+	if err != nil {
+		return err
+	}
+	klog.InfoS("data directory prepared", "path", dataRoot, "layoutVersion", layout.Version)
+
+	syncPolicy, err := node.ParseSyncPolicy(fsyncPolicy)
+	if err != nil {
+		return err
+	}
+	fileSyncer := node.NewFileSyncer(node.FileSyncConfig{Policy: syncPolicy, Interval: fsyncInterval})
+	if fileSyncer != nil {
+		m.Add("file syncer", fileSyncer)
+	}
+	rateLimits, err := node.ParseNamespaceRateLimits(namespaceRateLimits)
+	if err != nil {
+		return err
+	}
+	saCheck, err := node.ParseServiceAccountCheck(serviceAccountCheck)
 	if err != nil {
 		return err
 	}
-	klog.InfoS("identity server prepared")
 
-	nodeServer := node.NewNodeServerOrDie(identity, nodeID, dataRoot, volumeLimit)
+	config, err := client.RESTConfig(kubeconfig)
+	if err != nil {
+		return err
+	}
+	// The cache lives in the data path unless placed elsewhere. Volume IDs are
+	// never dot files, so it is not mistaken for a volume.
+	cacheDir := discoveryCacheDir
+	if !c.Flags().Changed("discovery-cache-dir") {
+		cacheDir = filepath.Join(dataRoot, discoveryCacheDirName)
+	}
+	apiVersion, err := client.CheckAPIVersionsForConfig(config, cacheDir)
+	if err != nil {
+		return err
+	}
+	klog.InfoS("COSI API version checked", "version", apiVersion)
+
+	nodeLabels, err := client.NewNodeLabelWatcher(config, nodeID)
+	if err != nil {
+		return err
+	}
+	labels, err := nodeLabels.Labels(ctx)
+	if err != nil {
+		return err
+	}
+	labelGates, err := features.FromNodeLabels(labels)
+	if err != nil {
+		return err
+	}
+	gates = gates.Override(labelGates)
+	klog.InfoS("feature gates resolved", "gates", gates, "fromNodeLabels", labelGates)
+	// Features are wired up at startup, so a change of the labels restarts
+	// the adapter. With --handover no connection is refused meanwhile.
+	nodeLabels.OnChange(func(labels map[string]string) error {
+		changed, err := features.FromNodeLabels(labels)
+		if err != nil {
+			klog.ErrorS(err, "ignoring invalid feature labels", "node", nodeID)
+			return nil
+		}
+		if reflect.DeepEqual(changed, labelGates) {
+			return nil
+		}
+		return fmt.Errorf(util.ErrorTemplateNodeFeaturesChanged, nodeID)
+	})
+	m.Add("node feature labels", nodeLabels)
+
+	// Missing permissions are reported rather than fatal: most only break
+	// the feature that needs them.
+	missing, err := client.SelfCheckPermissions(ctx, config, client.PermissionNeeds{
+		BucketConsumerCount:  gates.Enabled(features.BucketConsumerCount),
+		PodScopedCredentials: allowPodScopedCredentials,
+		CredentialHotReload:  gates.Enabled(features.CredentialHotReload),
+	})
+	if err != nil {
+		klog.ErrorS(err, "skipping the RBAC self-check")
+	}
+	for _, p := range missing {
+		klog.ErrorS(nil, "missing RBAC permission", "permission", p.String(), "neededBy", p.Reason)
+		metrics.MissingPermissions.WithLabelValues(p.Verb, p.Resource).Set(1)
+	}
+	if err == nil {
+		klog.InfoS("RBAC self-check finished", "missing", len(missing))
+	}
+
+	// An empty --default-formats is a default of no formats, unlike leaving
+	// the flag unset.
+	var formats []string
+	if c.Flags().Changed("default-formats") {
+		formats = append([]string{}, defaultFormats...)
+	}
+
+	nodeServer, err := node.NewNodeServer(identity, nodeID, dataRoot, volumeLimit, config, node.Options{
+		FailureVerbosityThreshold: failureVerbosityThreshold,
+		Revocation: node.RevocationConfig{
+			WebhookURL: revocationWebhook,
+			Annotate:   revocationAnnotate,
+			Timeout:    revocationTimeout,
+		},
+		AllowPodScopedCredentials: allowPodScopedCredentials,
+		AccessMonitor:             accessMonitor,
+		LoadSheddingThreshold:     loadSheddingLatency,
+		ReadinessWait:             client.ReadinessWait{Timeout: readinessTimeout},
+		FileSyncer:                fileSyncer,
+		FeatureGates:              gates,
+		ClockSkewThreshold:        clockSkewThreshold,
+		Hardened:                  hardened,
+		MemoryBackedVolumes:       memoryBackedVolumes,
+		CredentialHelper:          credentialHelper,
+		DefaultFormats:            formats,
+
+		FailureInjectionNamespaces: failureInjectionNamespaces,
+		Canary: node.CanaryConfig{
+			BucketAccessRequest: canaryBAR,
+			PodName:             canaryPod,
+			PodNamespace:        canaryPodNamespace,
+			Interval:            canaryInterval,
+		},
+		NamespaceRateLimits: rateLimits,
+		ServiceAccountCheck: saCheck,
+		KubeletDir:          kubeletDir,
+	})
+	if err != nil {
+		return err
+	}
+	adopted, err := nodeServer.Adopt()
+	if err != nil {
+		return err
+	}
+	klog.InfoS("adopted published volumes", "count", adopted)
+	idServer.Manifest[id.ManifestCredentialDeliveries] = strings.Join(nodeServer.CredentialDeliveries(), ",")
+	m.Add("node server", nodeServer)
 	controllerServer, err := controller.NewControllerServer()
+	if err != nil {
+		return err
+	}
+
+	if metricsAddress != "" {
+		m.Add("metrics server", metrics.NewServer(metricsAddress))
+	}
+	check, err := client.NewHealthCheck(config)
+	if err != nil {
+		return err
+	}
+	checker := health.NewChecker(check, health.DefaultInterval)
+	idServer.Ready = checker.Serving
+	m.Add("health checker", checker)
+	var validationServer *node.ValidationServer
+	if gates.Enabled(features.VolumeContextValidation) {
+		validationServer = node.NewValidationServer(nodeServer)
+	}
+	m.Add("grpc server", grpcServer(idServer, controllerServer, nodeServer, validationServer, checker.Server()))
+	if kubeletRegistrationPath != "" {
+		m.Add("kubelet plugin registration", registration.NewRegistrar(identity, kubeletRegistrationPath, pluginRegistrationDir))
+	}
+	return m.Start(ctx)
+}
+
+// grpcServer serves the CSI services and the grpc.health.v1.Health service on
+// the listen address until ctx is done, then stops gracefully. With
+// --handover the socket is taken over from a running adapter, and serving
+// stops gracefully once a newer adapter takes it over in turn. A nil vs leaves
+// the validation service unregistered.
+func grpcServer(ids csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer, vs *node.ValidationServer, hs healthpb.HealthServer) manager.Runnable {
+	return manager.RunnableFunc(func(ctx context.Context) error {
+		proto, addr, err := csicommon.ParseEndpoint(listen)
+		if err != nil {
+			return err
+		}
+		if proto == "unix" {
+			addr = "/" + addr
+		}
+		l, err := listener(proto, addr)
+		if err != nil {
+			return err
+		}
+
+		s := grpc.NewServer()
+		csi.RegisterIdentityServer(s, ids)
+		csi.RegisterControllerServer(s, cs)
+		csi.RegisterNodeServer(s, ns)
+		healthpb.RegisterHealthServer(s, hs)
+		if vs != nil {
+			node.RegisterValidationServer(s, vs)
+		}
 
-	s := csicommon.NewNonBlockingGRPCServer()
-	s.Start(listen, idServer, controllerServer, nodeServer)
-	s.Wait()
+		served := make(chan error, 1)
+		go func() {
+			klog.InfoS("listening for connections", "address", l.Addr().String())
+			served <- s.Serve(l)
+		}()
+		if hl, ok := l.(*handover.Listener); ok {
+			go func() {
+				if hl.WaitSuperseded(ctx) {
+					klog.InfoS("socket taken over by a newer adapter, no longer serving", "address", addr)
+					s.GracefulStop()
+				}
+			}()
+		}
 
-	return nil
+		select {
+		case <-ctx.Done():
+			s.GracefulStop()
+			return nil
+		case err := <-served:
+			return err
+		}
+	})
+}
+
+func listener(proto, addr string) (net.Listener, error) {
+	if handoverSockets {
+		return handover.Listen(proto, addr)
+	}
+	if proto == "unix" {
+		if err := os.Remove(addr); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return net.Listen(proto, addr)
 }
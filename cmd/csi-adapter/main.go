@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"syscall"
@@ -25,15 +26,17 @@ import (
 )
 
 func main() {
+	ctx, cancel := context.WithCancel(context.Background())
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
 		s := <-sigs
 		klog.InfoS("Exiting on signal", "signal", s.String(), "value", s)
+		cancel()
 	}()
 
-	if err := Execute(); err != nil {
+	if err := Execute(ctx); err != nil {
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+
+	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/migrate"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+var migrateNamespace string
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Print patches moving pods that mount minted secrets onto adapter volumes",
+	Long: "Finds pods mounting the secrets minted for BucketAccesses as secret volumes and prints, for the workload owning each, " +
+		"a kubectl patch replacing those volumes with adapter volumes that project the same files. Nothing is changed in the cluster. " +
+		"Pods without a controller cannot change their volumes, so their patch is printed as a comment to apply to their manifest.",
+	SilenceUsage: true,
+	RunE: func(c *cobra.Command, args []string) error {
+		return runMigrate(c.Context(), c.OutOrStdout())
+	},
+}
+
+func init() {
+	migrateCmd.Flags().StringVar(&migrateNamespace, "namespace", migrateNamespace, "namespace to scan for pods, empty scans every namespace")
+	driverCmd.AddCommand(migrateCmd)
+}
+
+func runMigrate(ctx context.Context, out io.Writer) error {
+	config, err := client.RESTConfig(kubeconfig)
+	if err != nil {
+		return err
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorCreateClientFailed)
+	}
+	cosi, err := cs.NewForConfig(config)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorCreateClientFailed)
+	}
+
+	patches, err := migrate.NewPlanner(kube, cosi, identity).Plan(ctx, migrateNamespace)
+	if err != nil {
+		return err
+	}
+	for _, p := range patches {
+		w := p.Workload
+		fmt.Fprintf(out, "# %s: volumes %s\n", w, strings.Join(p.Volumes, ", "))
+		if w.Kind == "Pod" {
+			fmt.Fprintf(out, "# pod has no controller, apply to its manifest and recreate it:\n# %s\n", p.Data)
+			continue
+		}
+		fmt.Fprintf(out, "kubectl -n %s patch %s %s --type strategic -p '%s'\n", w.Namespace, strings.ToLower(w.Kind), w.Name, p.Data)
+	}
+	return nil
+}
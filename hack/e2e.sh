@@ -0,0 +1,68 @@
+#!/usr/bin/env bash
+
+# Copyright 2021 The Kubernetes Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Runs the e2e suite in test/e2e against a kind cluster running the COSI
+# controller, the sample provisioner and an adapter image built from this tree.
+#
+# The COSI components are installed from the kustomizations below; point the
+# variables at other refs or local checkouts to test against other versions.
+# Set KEEP_CLUSTER=true to leave the cluster running for debugging.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+ROOT=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+
+CLUSTER_NAME=${CLUSTER_NAME:-cosi-e2e}
+IMAGE=${IMAGE:-quay.io/containerobjectstorage/objectstorage-csi-adapter:e2e}
+COSI_API=${COSI_API:-github.com/kubernetes-sigs/container-object-storage-interface-api}
+COSI_CONTROLLER=${COSI_CONTROLLER:-github.com/kubernetes-sigs/container-object-storage-interface-controller}
+COSI_PROVISIONER=${COSI_PROVISIONER:-github.com/kubernetes-sigs/container-object-storage-interface-provisioner-sidecar}
+KEEP_CLUSTER=${KEEP_CLUSTER:-false}
+
+cleanup() {
+	if [[ "${KEEP_CLUSTER}" != "true" ]]; then
+		kind delete cluster --name "${CLUSTER_NAME}"
+	fi
+}
+
+kind create cluster --name "${CLUSTER_NAME}"
+trap cleanup EXIT
+export KUBECONFIG
+KUBECONFIG=$(mktemp)
+kind get kubeconfig --name "${CLUSTER_NAME}" >"${KUBECONFIG}"
+
+kubectl apply -k "${COSI_API}"
+kubectl apply -k "${COSI_CONTROLLER}"
+kubectl apply -k "${COSI_PROVISIONER}"
+
+docker build -t "${IMAGE}" "${ROOT}"
+kind load docker-image --name "${CLUSTER_NAME}" "${IMAGE}"
+
+kubectl apply -k "${ROOT}"
+# The pod scoped cases need the adapter to allow them.
+kubectl set image daemonset/objectstorage-csi-adapter objectstorage-csi-adapter="${IMAGE}"
+kubectl patch daemonset objectstorage-csi-adapter --type=json -p='[
+  {"op": "add", "path": "/spec/template/spec/containers/1/args/-", "value": "--allow-pod-scoped-credentials"},
+  {"op": "replace", "path": "/spec/template/spec/containers/1/imagePullPolicy", "value": "IfNotPresent"}
+]'
+kubectl rollout status daemonset/objectstorage-csi-adapter --timeout=5m
+
+kubectl apply -f "${ROOT}/sample/classes"
+
+cd "${ROOT}"
+go test -tags e2e -count=1 -timeout 30m ./test/e2e -args -pod-scoped "$@"
@@ -0,0 +1,198 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import "time"
+
+// Volume attributes set by kubelet when the CSIDriver has podInfoOnMount.
+const (
+	PodNameKey      = "csi.storage.k8s.io/pod.name"
+	PodNamespaceKey = "csi.storage.k8s.io/pod.namespace"
+)
+
+// ServiceAccountTokensKey is set by kubelet when the CSIDriver has
+// tokenRequests, to a JSON object of ServiceAccountToken by audience.
+const ServiceAccountTokensKey = "csi.storage.k8s.io/serviceAccount.tokens"
+
+// ServiceAccountToken is a token of the pod's service account requested by
+// kubelet.
+type ServiceAccountToken struct {
+	Token               string    `json:"token"`
+	ExpirationTimestamp time.Time `json:"expirationTimestamp"`
+}
+
+// RedactVolumeContext returns attrs without the service account tokens, for
+// logging.
+func RedactVolumeContext(attrs map[string]string) map[string]string {
+	if _, ok := attrs[ServiceAccountTokensKey]; !ok {
+		return attrs
+	}
+	redacted := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		redacted[k] = v
+	}
+	redacted[ServiceAccountTokensKey] = "[REDACTED]"
+	return redacted
+}
+
+// Volume attributes set in the pod's csi volume source.
+const (
+	// BucketAccessRequestNameKey names the BucketAccessRequest, in the pod's
+	// namespace, whose credentials are projected. Required.
+	BucketAccessRequestNameKey = "bar-name"
+
+	// CredentialScopeKey is a CredentialScope. Defaults to CredentialScopeShared.
+	CredentialScopeKey = "credential-scope"
+
+	// SubdirsKey is a comma separated list of directories, relative to the
+	// volume root, that receive a copy of every projected file.
+	SubdirsKey = "subdirs"
+
+	// FormatKey is a comma separated list of additional output formats.
+	FormatKey = "format"
+	// ProfileKey selects a curated set of formats for a common tool.
+	ProfileKey = "profile"
+	// ChecksumsKey selects the algorithm, "sha256" or "sha512", of an
+	// integrity file covering the projected files.
+	ChecksumsKey = "checksums"
+
+	// RotationKey is a RotationMode. Defaults to the node's default.
+	RotationKey = "rotation"
+	// RefreshIntervalKey is a Go duration, such as "15m", after which the files
+	// are re-rendered even if no change was observed. At least MinRefreshInterval.
+	RefreshIntervalKey = "refresh-interval"
+
+	// DirModeKey sets the permissions, in octal, of the volume's directories.
+	DirModeKey = "directory-mode"
+	// FileModeKey sets the permissions, in octal, of every projected file.
+	FileModeKey = "file-mode"
+
+	// CredentialDeliveryKey is a CredentialDelivery. Defaults to
+	// CredentialDeliveryFiles.
+	CredentialDeliveryKey = "credential-delivery"
+	// MountPathKey is the absolute path at which the containers mount the
+	// volume. Required with CredentialDeliveryExec, which writes it into the
+	// helper configuration, and by the boto format for service account keys.
+	MountPathKey = "mount-path"
+
+	// AWSProfileKey names the profile written by the aws and
+	// aws-credential-process formats. Defaults to "default"; applications
+	// select any other with AWS_PROFILE.
+	AWSProfileKey = "aws-profile"
+
+	// EnvKeyPrefixKey makes the env format export every key of the minted
+	// secret, its name upper cased and prefixed with the value: "COSI_"
+	// exports accessKeyID as COSI_ACCESS_KEY_ID. Keys only a provisioner
+	// knows of then reach the application too.
+	EnvKeyPrefixKey = "env-key-prefix"
+
+	// ProtocolFileNameKey renames the file holding the protocol of the Bucket,
+	// for applications expecting it at a fixed path. Defaults to
+	// ProtocolFileName.
+	ProtocolFileNameKey = "protocol-file-name"
+	// CredentialsFileNameKey renames the file holding the credentials with
+	// ProjectionJSON. Defaults to CredentialsFileName.
+	CredentialsFileNameKey = "credentials-file-name"
+
+	// ProjectionKey is a Projection. Defaults to ProjectionJSON.
+	ProjectionKey = "projection"
+	// KeyPathPrefix prefixes attributes renaming the file of a secret key with
+	// ProjectionKeys: "key.accessKeyID: access_key_id" projects the accessKeyID
+	// key as the file access_key_id.
+	KeyPathPrefix = "key."
+
+	// WebIdentityRoleARNKey is the role assumed with the pod's service account
+	// token through AssumeRoleWithWebIdentity. When set, the volume projects
+	// the temporary credentials issued by the STS instead of the minted ones.
+	WebIdentityRoleARNKey = "web-identity-role-arn"
+	// WebIdentityAudienceKey selects the service account token exchanged, by
+	// audience. Defaults to DefaultWebIdentityAudience.
+	WebIdentityAudienceKey = "web-identity-audience"
+	// STSEndpointKey is the URL of the STS issuing web identity credentials.
+	// Defaults to the S3 endpoint of the Bucket, where S3 compatible stores
+	// such as MinIO and Ceph RGW serve it.
+	STSEndpointKey = "sts-endpoint"
+
+	// AnonymousKey set to "true" publishes a bucket open to anonymous access:
+	// the minted secret is not read and the volume only holds the protocol
+	// file.
+	AnonymousKey = "anonymous"
+
+	// InitOnlyKey set to "true" marks credentials only needed by the init
+	// containers of the pod. Once the pod runs, the files holding them are
+	// removed from the volume, which stays mounted with the rest.
+	InitOnlyKey = "init-only"
+)
+
+// DefaultWebIdentityAudience is the audience of the token exchanged for web
+// identity credentials when the volume does not select one.
+const DefaultWebIdentityAudience = "sts.amazonaws.com"
+
+// CredentialScope selects whose credentials a volume projects.
+type CredentialScope string
+
+const (
+	// CredentialScopeShared projects the credentials of the BucketAccessRequest,
+	// shared by every pod using it.
+	CredentialScopeShared CredentialScope = "shared"
+	// CredentialScopePod projects credentials minted for the pod alone. Nodes
+	// must allow it explicitly.
+	CredentialScopePod CredentialScope = "pod"
+)
+
+// CredentialDelivery selects how a volume hands credentials to the pod.
+type CredentialDelivery string
+
+const (
+	// CredentialDeliveryFiles writes the credentials into the volume.
+	CredentialDeliveryFiles CredentialDelivery = "files"
+	// CredentialDeliveryExec writes no credentials into the volume. Instead it
+	// projects a credential helper, and an AWS config running it as its
+	// credential_process, which fetches the current credentials from the
+	// adapter each time the SDK needs them. Only S3 buckets are supported.
+	CredentialDeliveryExec CredentialDelivery = "exec"
+)
+
+// Projection selects how the minted secret is written into a volume.
+type Projection string
+
+const (
+	// ProjectionJSON writes the whole secret as a JSON object of strings into
+	// the credentials file.
+	ProjectionJSON Projection = "json"
+	// ProjectionKeys writes every key of the secret as a file of its own, like
+	// a Secret volume. Not available with CredentialDeliveryExec.
+	ProjectionKeys Projection = "keys"
+)
+
+// RotationMode selects when the files of a published volume are rewritten.
+type RotationMode string
+
+const (
+	// RotationEnabled rewrites files on any observed change and on every
+	// refresh interval.
+	RotationEnabled RotationMode = "enabled"
+	// RotationDisabled never rewrites files after publish, for applications
+	// that crash when their credentials change underneath them.
+	RotationDisabled RotationMode = "disabled"
+	// RotationOnChange rewrites files only when the minted secret changes,
+	// never on a timer.
+	RotationOnChange RotationMode = "on-change"
+)
+
+// MinRefreshInterval keeps a single volume from hammering the API server.
+const MinRefreshInterval = 30 * time.Second
@@ -0,0 +1,25 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package adapter is the stable interface of the COSI CSI adapter towards
+// everything outside it: the volume attributes a pod spec may set, and the
+// files the adapter writes into the volume. Chart authors, admission webhooks
+// and applications reading the mounted files should use these names and
+// types, and the validation helpers, rather than copying strings.
+//
+// Names are only ever added to this package. Changing or removing one is a
+// breaking change for every pod spec or application using it.
+package adapter
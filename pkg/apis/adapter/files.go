@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+// Files written to the root of every volume, and to each of its subdirs.
+const (
+	// CredentialsFileName holds Credentials as JSON.
+	CredentialsFileName = "credentials"
+	// ProtocolFileName holds the protocol of the Bucket as JSON: the
+	// objectstorage.k8s.io/v1alpha1 S3Protocol, AzureProtocol or GCSProtocol,
+	// whichever the bucket uses. A Bucket defining several has the first of
+	// them, in that order, written here.
+	ProtocolFileName = "protocolConn.json"
+	// BucketMetadataFileName holds BucketMetadata as JSON. It is only written
+	// when the bucket carries lifecycle hints or notification settings.
+	BucketMetadataFileName = "metadata.json"
+)
+
+// Files written when the Bucket defines more than one protocol, each holding
+// one protocol as ProtocolFileName would.
+const (
+	S3ProtocolFileName    = "s3.json"
+	AzureProtocolFileName = "azure.json"
+	GCSProtocolFileName   = "gcs.json"
+	// ProtocolManifestFileName holds ProtocolManifest as JSON.
+	ProtocolManifestFileName = "protocols.json"
+)
+
+// Files written by the combined format.
+const (
+	// ConnectionFileName holds Connection as JSON.
+	ConnectionFileName = "connection.json"
+)
+
+// Files written by the yaml format.
+const (
+	// CredentialsYAMLFileName holds Credentials as YAML.
+	CredentialsYAMLFileName = "credentials.yaml"
+	// ProtocolYAMLFileName holds the content of ProtocolFileName as YAML.
+	ProtocolYAMLFileName = "protocolConn.yaml"
+)
+
+// Files written to the root of volumes with CredentialDeliveryExec.
+const (
+	// CredentialHelperFileName is the credential helper executable. Run with
+	// --socket set to CredentialHelperSocketName, it prints ProcessCredentials.
+	CredentialHelperFileName = "cosi-credential-helper"
+	// CredentialHelperSocketName is the unix socket on which the adapter serves
+	// the volume's current ProcessCredentials over HTTP.
+	CredentialHelperSocketName = "credential-helper.sock"
+	// CredentialHelperPath is the HTTP path serving ProcessCredentials.
+	CredentialHelperPath = "/credentials"
+)
+
+// Credentials is the content of CredentialsFileName: every key of the minted
+// secret with its value.
+type Credentials map[string]string
+
+// ConnectionVersion is the current version of the Connection schema. Fields
+// are only ever added to a version.
+const ConnectionVersion = "v1"
+
+// Protocols of a Connection.
+const (
+	ConnectionProtocolS3        = "s3"
+	ConnectionProtocolAzureBlob = "azureBlob"
+	ConnectionProtocolGCS       = "gcs"
+)
+
+// Connection is the content of ConnectionFileName: how to reach the bucket and
+// the credentials to do so, in a schema that does not follow the COSI API
+// version of the Bucket.
+type Connection struct {
+	Version  string `json:"version"`
+	Protocol string `json:"protocol"`
+	// Bucket is the name of the bucket, or Azure container, in the store.
+	Bucket   string `json:"bucket"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Region   string `json:"region,omitempty"`
+	// Parameters hold the settings specific to the protocol: signatureVersion
+	// for s3, storageAccount for azureBlob and projectID for gcs.
+	Parameters  map[string]string `json:"parameters,omitempty"`
+	Credentials Credentials       `json:"credentials"`
+}
+
+// Lifecycle holds hints about how the bucket treats objects, so applications
+// can adapt (e.g. skip client side versioning) without read access to Buckets.
+type Lifecycle struct {
+	Versioning      string `json:"versioning,omitempty"`
+	RetentionClass  string `json:"retentionClass,omitempty"`
+	RetentionPeriod string `json:"retentionPeriod,omitempty"`
+}
+
+// ProtocolManifest is the content of ProtocolManifestFileName: the protocols
+// of the Bucket, in the order ProtocolFileName picks from.
+type ProtocolManifest struct {
+	Protocols []ProtocolManifestEntry `json:"protocols"`
+}
+
+// ProtocolManifestEntry names the file holding one protocol. Protocol is one
+// of the ConnectionProtocol constants.
+type ProtocolManifestEntry struct {
+	Protocol string `json:"protocol"`
+	File     string `json:"file"`
+}
+
+// Notifications describes where the bucket publishes its events, so event
+// driven applications can subscribe without a second discovery mechanism.
+type Notifications struct {
+	// Type is the kind of endpoint, such as sns, sqs or webhook.
+	Type string `json:"type,omitempty"`
+	// Endpoint is the URL events are delivered to or read from.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Topic identifies the topic or queue, e.g. the ARN of an SNS topic.
+	Topic string `json:"topic,omitempty"`
+	// Events are the event types published, e.g. s3:ObjectCreated:*.
+	Events []string `json:"events,omitempty"`
+}
+
+// BucketMetadata is the content of BucketMetadataFileName.
+type BucketMetadata struct {
+	Bucket        string         `json:"bucket"`
+	Lifecycle     *Lifecycle     `json:"lifecycle,omitempty"`
+	Notifications *Notifications `json:"notifications,omitempty"`
+}
+
+// ProcessCredentials is the output of an AWS credential_process, version 1.
+type ProcessCredentials struct {
+	Version         int    `json:"Version"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken,omitempty"`
+	// Expiration is RFC 3339. SDKs cache credentials without one until the
+	// process restarts.
+	Expiration string `json:"Expiration,omitempty"`
+}
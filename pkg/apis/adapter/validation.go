@@ -0,0 +1,359 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// ParseCredentialScope parses the value of CredentialScopeKey. An empty value
+// is CredentialScopeShared.
+func ParseCredentialScope(v string) (CredentialScope, error) {
+	switch scope := CredentialScope(v); scope {
+	case "", CredentialScopeShared:
+		return CredentialScopeShared, nil
+	case CredentialScopePod:
+		return scope, nil
+	default:
+		return "", fmt.Errorf(util.ErrorTemplateInvalidCredentialScope, v)
+	}
+}
+
+// ParseCredentialDelivery parses the value of CredentialDeliveryKey. An empty
+// value is CredentialDeliveryFiles.
+func ParseCredentialDelivery(v string) (CredentialDelivery, error) {
+	switch delivery := CredentialDelivery(v); delivery {
+	case "", CredentialDeliveryFiles:
+		return CredentialDeliveryFiles, nil
+	case CredentialDeliveryExec:
+		return delivery, nil
+	default:
+		return "", fmt.Errorf(util.ErrorTemplateInvalidCredentialDelivery, v)
+	}
+}
+
+// ParseMountPath parses the value of MountPathKey, which must be absolute.
+func ParseMountPath(v string) (string, error) {
+	if !path.IsAbs(v) {
+		return "", fmt.Errorf(util.ErrorTemplateInvalidMountPath, v)
+	}
+	return path.Clean(v), nil
+}
+
+// ParseProjection parses the values of ProjectionKey and the KeyPathPrefix
+// attributes in attrs. An empty projection is ProjectionJSON. The key paths map
+// secret keys to file names, which must not contain a slash or start with
+// "..", and are only allowed with ProjectionKeys.
+func ParseProjection(attrs map[string]string) (Projection, map[string]string, error) {
+	var projection Projection
+	switch p := Projection(attrs[ProjectionKey]); p {
+	case "", ProjectionJSON:
+		projection = ProjectionJSON
+	case ProjectionKeys:
+		projection = p
+	default:
+		return "", nil, fmt.Errorf(util.ErrorTemplateInvalidProjection, p)
+	}
+
+	var paths map[string]string
+	for k, name := range attrs {
+		if !strings.HasPrefix(k, KeyPathPrefix) {
+			continue
+		}
+		if projection != ProjectionKeys {
+			return "", nil, fmt.Errorf(util.ErrorTemplateKeyPathWithoutKeys, k)
+		}
+		key := strings.TrimPrefix(k, KeyPathPrefix)
+		if key == "" || !ValidKeyPath(name) {
+			return "", nil, fmt.Errorf(util.ErrorTemplateInvalidKeyPath, name, key)
+		}
+		if paths == nil {
+			paths = map[string]string{}
+		}
+		paths[key] = name
+	}
+	return projection, paths, nil
+}
+
+// ParseFileNames returns the names of the protocol and credentials files set by
+// ProtocolFileNameKey and CredentialsFileNameKey, defaulting to
+// ProtocolFileName and CredentialsFileName. Like key paths, the names must not
+// contain a slash or start with "..", and they must differ.
+func ParseFileNames(attrs map[string]string) (protocol, credentials string, err error) {
+	protocol, credentials = ProtocolFileName, CredentialsFileName
+	if v, ok := attrs[ProtocolFileNameKey]; ok {
+		if !ValidKeyPath(v) {
+			return "", "", fmt.Errorf(util.ErrorTemplateInvalidFileName, v, ProtocolFileNameKey)
+		}
+		protocol = v
+	}
+	if v, ok := attrs[CredentialsFileNameKey]; ok {
+		if !ValidKeyPath(v) {
+			return "", "", fmt.Errorf(util.ErrorTemplateInvalidFileName, v, CredentialsFileNameKey)
+		}
+		credentials = v
+	}
+	if protocol == credentials {
+		return "", "", fmt.Errorf(util.ErrorTemplateFileNameConflict, credentials, CredentialsFileNameKey)
+	}
+	return protocol, credentials, nil
+}
+
+// ValidKeyPath reports whether name can hold a secret key with
+// ProjectionKeys: a plain file name not reserved by the atomic writer, whose
+// entries start with "..".
+func ValidKeyPath(name string) bool {
+	return name != "" && name != "." && !strings.Contains(name, "/") && !strings.HasPrefix(name, "..")
+}
+
+// awsProfilePattern keeps profile names from breaking out of their INI section
+// header.
+var awsProfilePattern = regexp.MustCompile(`^[\w.@+-]+$`)
+
+// ParseAWSProfile parses the value of AWSProfileKey. An empty value is the
+// default profile.
+func ParseAWSProfile(v string) (string, error) {
+	if v == "" {
+		return "default", nil
+	}
+	if !awsProfilePattern.MatchString(v) {
+		return "", fmt.Errorf(util.ErrorTemplateInvalidAWSProfile, v)
+	}
+	return v, nil
+}
+
+// envKeyPrefixPattern matches the start of a POSIX environment variable name.
+var envKeyPrefixPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseEnvKeyPrefix parses the value of EnvKeyPrefixKey.
+func ParseEnvKeyPrefix(v string) (string, error) {
+	if !envKeyPrefixPattern.MatchString(v) {
+		return "", fmt.Errorf(util.ErrorTemplateInvalidEnvKeyPrefix, v)
+	}
+	return v, nil
+}
+
+// ParseServiceAccountTokens parses the value of ServiceAccountTokensKey.
+func ParseServiceAccountTokens(v string) (map[string]ServiceAccountToken, error) {
+	tokens := map[string]ServiceAccountToken{}
+	if err := json.Unmarshal([]byte(v), &tokens); err != nil {
+		return nil, fmt.Errorf(util.ErrorTemplateInvalidServiceAccountTokens, err)
+	}
+	return tokens, nil
+}
+
+// ParseWebIdentityRoleARN parses the value of WebIdentityRoleARNKey, an ARN
+// of six colon separated fields.
+func ParseWebIdentityRoleARN(v string) (string, error) {
+	fields := strings.SplitN(v, ":", 6)
+	if len(fields) != 6 || fields[0] != "arn" || fields[5] == "" {
+		return "", fmt.Errorf(util.ErrorTemplateInvalidWebIdentityRoleARN, v)
+	}
+	return v, nil
+}
+
+// ParseSTSEndpoint parses the value of STSEndpointKey.
+func ParseSTSEndpoint(v string) (string, error) {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf(util.ErrorTemplateInvalidSTSEndpoint, v)
+	}
+	return v, nil
+}
+
+// ParseAnonymous parses the value of AnonymousKey. An empty value is false.
+func ParseAnonymous(v string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+	anonymous, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf(util.ErrorTemplateInvalidAnonymous, v)
+	}
+	return anonymous, nil
+}
+
+// ParseInitOnly parses the value of InitOnlyKey. An empty value is false.
+func ParseInitOnly(v string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+	initOnly, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf(util.ErrorTemplateInvalidInitOnly, v)
+	}
+	return initOnly, nil
+}
+
+// ParseRotationMode parses the value of RotationKey.
+func ParseRotationMode(v string) (RotationMode, error) {
+	switch mode := RotationMode(v); mode {
+	case RotationEnabled, RotationDisabled, RotationOnChange:
+		return mode, nil
+	default:
+		return "", fmt.Errorf(util.ErrorTemplateInvalidRotationMode, v)
+	}
+}
+
+// ParseRefreshInterval parses the value of RefreshIntervalKey.
+func ParseRefreshInterval(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf(util.ErrorTemplateInvalidRefreshInterval, v, err)
+	}
+	if d < MinRefreshInterval {
+		return 0, fmt.Errorf(util.ErrorTemplateInvalidRefreshInterval, v, fmt.Sprintf("must be at least %s", MinRefreshInterval))
+	}
+	return d, nil
+}
+
+// ParseDirMode parses the value of DirModeKey. Only permission bits are allowed.
+func ParseDirMode(v string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || mode&^0777 != 0 {
+		return 0, fmt.Errorf(util.ErrorTemplateInvalidDirMode, v)
+	}
+	return os.FileMode(mode), nil
+}
+
+// ParseFileMode parses the value of FileModeKey. Only permission bits are allowed.
+func ParseFileMode(v string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || mode&^0777 != 0 {
+		return 0, fmt.Errorf(util.ErrorTemplateInvalidFileMode, v)
+	}
+	return os.FileMode(mode), nil
+}
+
+// ValidateVolumeAttributes checks that attrs, as written in a pod spec, are
+// well formed: the required attributes are set and every value parses. It
+// does not check anything depending on the node or the cluster, such as
+// whether pod scoped credentials are allowed, the formats and profiles
+// available, or whether the BucketAccessRequest exists.
+//
+// The attributes kubelet adds, PodNameKey and PodNamespaceKey, are not required.
+func ValidateVolumeAttributes(attrs map[string]string) error {
+	if _, err := util.ParseValue(BucketAccessRequestNameKey, attrs); err != nil {
+		return err
+	}
+	scope, err := ParseCredentialScope(attrs[CredentialScopeKey])
+	if err != nil {
+		return err
+	}
+	delivery, err := ParseCredentialDelivery(attrs[CredentialDeliveryKey])
+	if err != nil {
+		return err
+	}
+	if delivery == CredentialDeliveryExec {
+		if _, err := ParseMountPath(attrs[MountPathKey]); err != nil {
+			return err
+		}
+	}
+	if _, _, err := ParseFileNames(attrs); err != nil {
+		return err
+	}
+	projection, _, err := ParseProjection(attrs)
+	if err != nil {
+		return err
+	}
+	if projection == ProjectionKeys && delivery == CredentialDeliveryExec {
+		return util.ErrorExecKeysProjection
+	}
+	if _, err := ParseAWSProfile(attrs[AWSProfileKey]); err != nil {
+		return err
+	}
+	if v, ok := attrs[EnvKeyPrefixKey]; ok {
+		if _, err := ParseEnvKeyPrefix(v); err != nil {
+			return err
+		}
+	}
+	if v, ok := attrs[WebIdentityRoleARNKey]; ok {
+		if _, err := ParseWebIdentityRoleARN(v); err != nil {
+			return err
+		}
+		if delivery == CredentialDeliveryExec {
+			return util.ErrorWebIdentityExec
+		}
+	}
+	if v, ok := attrs[STSEndpointKey]; ok {
+		if _, err := ParseSTSEndpoint(v); err != nil {
+			return err
+		}
+	}
+	anonymous, err := ParseAnonymous(attrs[AnonymousKey])
+	if err != nil {
+		return err
+	}
+	if anonymous {
+		if err := ValidateAnonymous(attrs, scope, delivery); err != nil {
+			return err
+		}
+	}
+	initOnly, err := ParseInitOnly(attrs[InitOnlyKey])
+	if err != nil {
+		return err
+	}
+	if initOnly && delivery == CredentialDeliveryExec {
+		return util.ErrorInitOnlyExec
+	}
+	if v, ok := attrs[RotationKey]; ok {
+		if _, err := ParseRotationMode(v); err != nil {
+			return err
+		}
+	}
+	if v, ok := attrs[RefreshIntervalKey]; ok {
+		if _, err := ParseRefreshInterval(v); err != nil {
+			return err
+		}
+	}
+	if v, ok := attrs[DirModeKey]; ok {
+		if _, err := ParseDirMode(v); err != nil {
+			return err
+		}
+	}
+	if v, ok := attrs[FileModeKey]; ok {
+		if _, err := ParseFileMode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateAnonymous rejects the attributes of an anonymous volume that need
+// credentials.
+func ValidateAnonymous(attrs map[string]string, scope CredentialScope, delivery CredentialDelivery) error {
+	if scope == CredentialScopePod {
+		return util.ErrorAnonymousPodScoped
+	}
+	if delivery == CredentialDeliveryExec {
+		return util.ErrorAnonymousExec
+	}
+	if _, ok := attrs[WebIdentityRoleARNKey]; ok {
+		return util.ErrorAnonymousWebIdentity
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestValidateVolumeAttributes(t *testing.T) {
+	cases := map[string]struct {
+		attrs   map[string]string
+		wantErr error
+	}{
+		"Minimal": {
+			attrs: map[string]string{BucketAccessRequestNameKey: "bar"},
+		},
+		"Full": {
+			attrs: map[string]string{
+				BucketAccessRequestNameKey: "bar",
+				CredentialScopeKey:         string(CredentialScopePod),
+				RotationKey:                string(RotationEnabled),
+				RefreshIntervalKey:         "15m",
+				DirModeKey:                 "0750",
+				FormatKey:                  "aws",
+			},
+		},
+		"MissingBAR": {
+			attrs:   map[string]string{},
+			wantErr: fmt.Errorf(util.ErrorTemplateVolCtxUnset, BucketAccessRequestNameKey),
+		},
+		"InvalidScope": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", CredentialScopeKey: "node"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidCredentialScope, "node"),
+		},
+		"InvalidRotation": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", RotationKey: "sometimes"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidRotationMode, "sometimes"),
+		},
+		"RefreshTooShort": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", RefreshIntervalKey: "1s"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidRefreshInterval, "1s", fmt.Sprintf("must be at least %s", MinRefreshInterval)),
+		},
+		"InvalidDirMode": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", DirModeKey: "0999"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidDirMode, "0999"),
+		},
+		"InvalidFileMode": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", FileModeKey: "rw"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidFileMode, "rw"),
+		},
+		"ExecWithoutMountPath": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", CredentialDeliveryKey: "exec"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidMountPath, ""),
+		},
+		"InvalidAWSProfile": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", AWSProfileKey: "my profile"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidAWSProfile, "my profile"),
+		},
+		"InvalidEnvKeyPrefix": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", EnvKeyPrefixKey: "1COSI_"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidEnvKeyPrefix, "1COSI_"),
+		},
+		"WebIdentity": {
+			attrs: map[string]string{BucketAccessRequestNameKey: "bar", WebIdentityRoleARNKey: "arn:aws:iam::123456789012:role/reader", STSEndpointKey: "https://sts.amazonaws.com"},
+		},
+		"InvalidWebIdentityRoleARN": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", WebIdentityRoleARNKey: "reader"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidWebIdentityRoleARN, "reader"),
+		},
+		"WebIdentityExec": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", WebIdentityRoleARNKey: "arn:aws:iam::123456789012:role/reader", CredentialDeliveryKey: "exec", MountPathKey: "/cosi"},
+			wantErr: util.ErrorWebIdentityExec,
+		},
+		"InvalidSTSEndpoint": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", STSEndpointKey: "sts.amazonaws.com"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidSTSEndpoint, "sts.amazonaws.com"),
+		},
+		"Anonymous": {
+			attrs: map[string]string{BucketAccessRequestNameKey: "bar", AnonymousKey: "true"},
+		},
+		"InvalidAnonymous": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", AnonymousKey: "public"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidAnonymous, "public"),
+		},
+		"AnonymousPodScoped": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", AnonymousKey: "true", CredentialScopeKey: "pod"},
+			wantErr: util.ErrorAnonymousPodScoped,
+		},
+		"InitOnly": {
+			attrs: map[string]string{BucketAccessRequestNameKey: "bar", InitOnlyKey: "true"},
+		},
+		"InitOnlyExec": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", InitOnlyKey: "true", CredentialDeliveryKey: "exec", MountPathKey: "/cosi"},
+			wantErr: util.ErrorInitOnlyExec,
+		},
+		"AnonymousWebIdentity": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", AnonymousKey: "true", WebIdentityRoleARNKey: "arn:aws:iam::123456789012:role/reader"},
+			wantErr: util.ErrorAnonymousWebIdentity,
+		},
+		"KeysProjection": {
+			attrs: map[string]string{BucketAccessRequestNameKey: "bar", ProjectionKey: "keys", KeyPathPrefix + "accessKeyID": "access_key_id"},
+		},
+		"InvalidProjection": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", ProjectionKey: "ini"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidProjection, "ini"),
+		},
+		"KeyPathWithoutKeys": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", KeyPathPrefix + "accessKeyID": "access_key_id"},
+			wantErr: fmt.Errorf(util.ErrorTemplateKeyPathWithoutKeys, KeyPathPrefix+"accessKeyID"),
+		},
+		"KeyPathEscapes": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", ProjectionKey: "keys", KeyPathPrefix + "accessKeyID": "../id"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidKeyPath, "../id", "accessKeyID"),
+		},
+		"FileNames": {
+			attrs: map[string]string{BucketAccessRequestNameKey: "bar", ProtocolFileNameKey: "protocol.json", CredentialsFileNameKey: "aws.json"},
+		},
+		"FileNameEscapes": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", CredentialsFileNameKey: "../aws.json"},
+			wantErr: fmt.Errorf(util.ErrorTemplateInvalidFileName, "../aws.json", CredentialsFileNameKey),
+		},
+		"FileNamesCollide": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", CredentialsFileNameKey: ProtocolFileName},
+			wantErr: fmt.Errorf(util.ErrorTemplateFileNameConflict, ProtocolFileName, CredentialsFileNameKey),
+		},
+		"ExecKeysProjection": {
+			attrs:   map[string]string{BucketAccessRequestNameKey: "bar", CredentialDeliveryKey: "exec", MountPathKey: "/cosi", ProjectionKey: "keys"},
+			wantErr: util.ErrorExecKeysProjection,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateVolumeAttributes(tc.attrs)
+			if diff := cmp.Diff(tc.wantErr, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit records security relevant events about credential handling.
+package audit
+
+import (
+	"sort"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Event types recorded by the adapter.
+const (
+	CredentialFileOpened = "CredentialFileOpened"
+)
+
+// Event is a single audit record.
+type Event struct {
+	Time       time.Time
+	Type       string
+	VolumeID   string
+	Attributes map[string]string
+}
+
+// Sink receives audit events. Implementations must be safe for concurrent use.
+type Sink interface {
+	Record(e Event)
+}
+
+// LogSink writes audit events to the adapter log.
+type LogSink struct{}
+
+var _ Sink = LogSink{}
+
+func (LogSink) Record(e Event) {
+	keys := make([]string, 0, len(e.Attributes))
+	for k := range e.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kv := []interface{}{"type", e.Type, "time", e.Time.UTC().Format(time.RFC3339Nano), "volumeID", e.VolumeID}
+	for _, k := range keys {
+		kv = append(kv, k, e.Attributes[k])
+	}
+	klog.InfoS("audit", kv...)
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AnonymousAccessAnnotation set to "true" on a BucketAccessRequest or
+// BucketAccess marks a bucket open to anonymous access. Its minted secret,
+// if any, is not read.
+const AnonymousAccessAnnotation = "cosi.objectstorage.k8s.io/anonymous-access"
+
+type anonymousAccessKey struct{}
+
+// AnonymousAccess reports whether obj is marked for anonymous access, see
+// AnonymousAccessAnnotation.
+func AnonymousAccess(obj metav1.Object) bool {
+	return obj.GetAnnotations()[AnonymousAccessAnnotation] == "true"
+}
+
+// WithAnonymousAccess returns a context resolving volumes for anonymous
+// access, as if their BucketAccessRequest had AnonymousAccessAnnotation set.
+func WithAnonymousAccess(ctx context.Context) context.Context {
+	return context.WithValue(ctx, anonymousAccessKey{}, true)
+}
+
+// anonymousAccess reports whether ctx resolves volumes for anonymous access.
+func anonymousAccess(ctx context.Context) bool {
+	anonymous, _ := ctx.Value(anonymousAccessKey{}).(bool)
+	return anonymous
+}
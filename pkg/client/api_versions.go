@@ -0,0 +1,128 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// APISupport is how far this adapter supports a version of the COSI API.
+type APISupport string
+
+const (
+	// APISupported versions are served through their own code path.
+	APISupported APISupport = "Supported"
+	// APIDeprecated versions work but will be dropped by a later release.
+	APIDeprecated APISupport = "Deprecated"
+	// APIUnsupported versions are known, but this adapter cannot serve them.
+	APIUnsupported APISupport = "Unsupported"
+)
+
+// APIVersionMatrix is the support of every COSI API version this adapter knows
+// of. Versions missing from it are newer than the adapter and unsupported.
+var APIVersionMatrix = map[string]APISupport{
+	v1alpha1.SchemeGroupVersion.Version: APISupported,
+	"v1alpha2":                          APIUnsupported,
+}
+
+// CheckAPIVersions compares the COSI API versions the cluster serves with
+// APIVersionMatrix and returns the version the adapter should use: the first
+// one in the cluster's preference order that the adapter supports. Versions
+// served beside it, or deprecated ones, are logged as warnings. It fails with
+// a message naming both sides when the cluster serves no supported version.
+func CheckAPIVersions(dc discovery.ServerGroupsInterface) (string, error) {
+	groups, err := dc.ServerGroups()
+	if err != nil {
+		return "", errors.Wrap(err, util.WrapErrorDiscoverAPIFailed)
+	}
+
+	var served []string
+	for _, g := range groups.Groups {
+		if g.Name != v1alpha1.SchemeGroupVersion.Group {
+			continue
+		}
+		// Put the preferred version first, as kubectl would pick it.
+		served = append(served, g.PreferredVersion.Version)
+		for _, v := range g.Versions {
+			if v.Version != g.PreferredVersion.Version {
+				served = append(served, v.Version)
+			}
+		}
+	}
+	if len(served) == 0 {
+		return "", util.ErrorCOSIAPINotInstalled
+	}
+
+	use := ""
+	for _, v := range served {
+		if s := support(v); s == APISupported || s == APIDeprecated {
+			use = v
+			break
+		}
+	}
+	if use == "" {
+		return "", fmt.Errorf(util.ErrorTemplateCOSIAPIUnsupported, strings.Join(served, ", "), strings.Join(supportedAPIVersions(), ", "))
+	}
+
+	if support(use) == APIDeprecated {
+		klog.Warningf("COSI API version %s is deprecated, upgrade the COSI CRDs", use)
+	}
+	if served[0] != use {
+		klog.Warningf("the cluster prefers COSI API version %s, which this adapter does not support, using %s", served[0], use)
+	}
+	for _, v := range served {
+		if v != use {
+			klog.V(2).InfoS("ignoring COSI API version", "version", v, "support", support(v))
+		}
+	}
+	return use, nil
+}
+
+// CheckAPIVersionsForConfig runs CheckAPIVersions against the API server config
+// points at. With a cacheDir, the served groups are cached there for
+// DefaultDiscoveryCacheTTL. A check failing on cached groups is repeated
+// against the API server, as the COSI CRDs may have changed since.
+func CheckAPIVersionsForConfig(config *rest.Config, cacheDir string) (string, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return "", errors.Wrap(err, util.WrapErrorCreateClientFailed)
+	}
+	if cacheDir == "" {
+		return CheckAPIVersions(dc)
+	}
+
+	cached := NewDiscoveryCache(dc, cacheDir, config.Host, DefaultDiscoveryCacheTTL)
+	version, err := CheckAPIVersions(cached)
+	if err != nil {
+		cached.Invalidate()
+		return CheckAPIVersions(cached)
+	}
+	return version, nil
+}
+
+func support(version string) APISupport {
+	if s, ok := APIVersionMatrix[version]; ok {
+		return s
+	}
+	return APIUnsupported
+}
+
+func supportedAPIVersions() []string {
+	var versions []string
+	for v, s := range APIVersionMatrix {
+		if s != APIUnsupported {
+			versions = append(versions, v)
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestCheckAPIVersions(t *testing.T) {
+	type want struct {
+		version string
+		err     error
+	}
+
+	cases := map[string]struct {
+		// groupVersions are served in order, the first of a group preferred.
+		groupVersions []string
+		want
+	}{
+		"V1alpha1": {
+			groupVersions: []string{"objectstorage.k8s.io/v1alpha1"},
+			want:          want{version: "v1alpha1"},
+		},
+		"NewerPreferred": {
+			groupVersions: []string{"objectstorage.k8s.io/v1alpha2", "objectstorage.k8s.io/v1alpha1"},
+			want:          want{version: "v1alpha1"},
+		},
+		"OnlyUnsupported": {
+			groupVersions: []string{"objectstorage.k8s.io/v1alpha2", "objectstorage.k8s.io/v1beta1"},
+			want:          want{err: fmt.Errorf(util.ErrorTemplateCOSIAPIUnsupported, "v1alpha2, v1beta1", "v1alpha1")},
+		},
+		"NotInstalled": {
+			groupVersions: []string{"v1", "apps/v1"},
+			want:          want{err: util.ErrorCOSIAPINotInstalled},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dc := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+			for _, gv := range tc.groupVersions {
+				dc.Resources = append(dc.Resources, &metav1.APIResourceList{GroupVersion: gv})
+			}
+
+			version, err := CheckAPIVersions(dc)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.version, version); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
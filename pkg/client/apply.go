@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// FieldManager identifies the adapter's writes. Server-side applies are made
+// by FieldManager/<scope> managers, where the scope is a node, a finalizer or
+// an annotation, so fields the adapter owns are told apart from each other and
+// from those of the provisioner.
+const FieldManager = "cosi-csi-adapter"
+
+// scopedFieldManager returns the field manager owning the fields of scope.
+// Field manager names are limited to 128 characters, so long scopes are hashed.
+func scopedFieldManager(scope string) string {
+	if scope == "" {
+		return FieldManager
+	}
+	name := FieldManager + "/" + scope
+	if len(name) <= 128 {
+		return name
+	}
+	return fmt.Sprintf("%s/%x", FieldManager, sha256.Sum256([]byte(scope)))
+}
+
+// ApplyBucketAnnotations server-side applies annotations as the full set of
+// Bucket annotations owned by this node. Annotations the node applied before
+// and left out now are removed; those of other managers are untouched. A
+// Bucket that no longer exists is left alone rather than created by the apply.
+func (n *nodeClient) ApplyBucketAnnotations(ctx context.Context, bucketName string, annotations map[string]string) error {
+	current, err := n.getBucket(ctx, bucketName)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorGetBFailed)
+	}
+
+	data, err := metadataApply("Bucket", bucketName, current.UID, map[string]interface{}{"annotations": annotations})
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorApplyBucketFailed)
+	}
+
+	force := true
+	countWrite(ctx)
+	_, err = n.cosiClient.Buckets().Patch(ctx, bucketName, types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: n.fieldManager,
+		Force:        &force,
+	})
+	return errors.Wrap(err, util.WrapErrorApplyBucketFailed)
+}
+
+// metadataApply returns the body of a server-side apply setting only the given
+// metadata fields of the named object. Typed objects are not used as bodies:
+// their zero valued spec fields would be applied too, taking them over from
+// the provisioner. The UID pins the apply to the object it was read from, so
+// one deleted and recreated under the same name is not modified.
+func metadataApply(kind, name string, uid types.UID, fields map[string]interface{}) ([]byte, error) {
+	return objectMetadataApply(v1alpha1.SchemeGroupVersion.String(), kind, name, uid, fields)
+}
+
+// objectMetadataApply is metadataApply for an object of any API version.
+func objectMetadataApply(apiVersion, kind, name string, uid types.UID, fields map[string]interface{}) ([]byte, error) {
+	metadata := map[string]interface{}{"name": name}
+	if uid != "" {
+		metadata["uid"] = uid
+	}
+	for k, v := range fields {
+		metadata[k] = v
+	}
+	return json.Marshal(map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   metadata,
+	})
+}
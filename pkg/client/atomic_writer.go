@@ -0,0 +1,122 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// DataDirName is the symlink pointing at the directory holding the current
+	// version of the files of an atomically written directory.
+	DataDirName = "..data"
+
+	newDataDirName = "..data_tmp"
+	// tsDirPrefix starts the name of every timestamped directory, so they are
+	// hidden like DataDirName and never collide with a payload file.
+	tsDirPrefix = ".."
+)
+
+// AtomicFile is a file written by WriteAtomic, named relative to the directory
+// written to. Names may contain directories.
+type AtomicFile struct {
+	Path string
+	Data []byte
+	Mode os.FileMode
+}
+
+// WriteAtomic replaces the files in dir the way the kubelet secret volume
+// plugin does, so readers never observe a partially written set of files:
+//
+//  1. The files are written to a new timestamped directory in dir.
+//  2. A symlink to it is created and renamed over DataDirName, which swaps
+//     all files at once.
+//  3. Every top level entry of files is a symlink into DataDirName, created
+//     on first write. Entries no longer written are removed.
+//  4. The previous timestamped directory is removed.
+//
+// Files get their Mode and subdirectories the permissions of dir, whatever the
+// umask. Unless gid is negative, everything written is owned by group gid.
+func (p provisionerClient) WriteAtomic(dir string, files []AtomicFile, gid int) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+	}
+	dirMode := info.Mode().Perm()
+
+	oldTsDir, err := os.Readlink(filepath.Join(dir, DataDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+	}
+
+	tsDir, err := ioutil.TempDir(dir, tsDirPrefix+time.Now().UTC().Format("2006_01_02_15_04_05."))
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+	}
+	if err := p.SetOwnership(tsDir, dirMode, gid); err != nil {
+		return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+	}
+
+	top := map[string]bool{}
+	for _, f := range files {
+		path := filepath.Join(tsDir, f.Path)
+		if err := os.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+			return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+		}
+		for d := filepath.Dir(path); d != tsDir; d = filepath.Dir(d) {
+			if err := p.SetOwnership(d, dirMode, gid); err != nil {
+				return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+			}
+		}
+		if err := p.WriteFileWithMode(f.Data, path, f.Mode); err != nil {
+			return err
+		}
+		if err := p.SetOwnership(path, f.Mode, gid); err != nil {
+			return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+		}
+		top[strings.SplitN(filepath.ToSlash(f.Path), "/", 2)[0]] = true
+	}
+
+	newLink := filepath.Join(dir, newDataDirName)
+	if err := os.Remove(newLink); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+	}
+	if err := os.Symlink(filepath.Base(tsDir), newLink); err != nil {
+		return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+	}
+	if err := os.Rename(newLink, filepath.Join(dir, DataDirName)); err != nil {
+		return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+	}
+
+	for name := range top {
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); err == nil {
+			continue
+		}
+		if err := os.Symlink(filepath.Join(DataDirName, name), link); err != nil {
+			return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+		}
+	}
+
+	if oldTsDir == "" {
+		return nil
+	}
+	old, err := ioutil.ReadDir(filepath.Join(dir, oldTsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+	}
+	for _, entry := range old {
+		if !top[entry.Name()] {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+				return errors.Wrap(err, util.WrapErrorAtomicWriteFailed)
+			}
+		}
+	}
+	return errors.Wrap(os.RemoveAll(filepath.Join(dir, oldTsDir)), util.WrapErrorAtomicWriteFailed)
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	type want struct {
+		files   map[string]string
+		entries []string
+	}
+
+	cases := map[string]struct {
+		writes [][]AtomicFile
+		want
+	}{
+		"FirstWrite": {
+			writes: [][]AtomicFile{
+				{{Path: "credentials", Data: []byte("a"), Mode: 0400}, {Path: "protocolConn.json", Data: []byte("b"), Mode: 0400}},
+			},
+			want: want{
+				files:   map[string]string{"credentials": "a", "protocolConn.json": "b"},
+				entries: []string{DataDirName, "credentials", "protocolConn.json"},
+			},
+		},
+		"Subdirs": {
+			writes: [][]AtomicFile{
+				{{Path: "credentials", Data: []byte("a"), Mode: 0400}, {Path: "app/credentials", Data: []byte("a"), Mode: 0400}},
+			},
+			want: want{
+				files:   map[string]string{"credentials": "a", "app/credentials": "a"},
+				entries: []string{DataDirName, "app", "credentials"},
+			},
+		},
+		"Rewrite": {
+			writes: [][]AtomicFile{
+				{{Path: "credentials", Data: []byte("a"), Mode: 0400}, {Path: "protocolConn.json", Data: []byte("b"), Mode: 0400}},
+				{{Path: "credentials", Data: []byte("c"), Mode: 0400}, {Path: "protocolConn.json", Data: []byte("b"), Mode: 0400}},
+			},
+			want: want{
+				files:   map[string]string{"credentials": "c", "protocolConn.json": "b"},
+				entries: []string{DataDirName, "credentials", "protocolConn.json"},
+			},
+		},
+		"DroppedEntry": {
+			writes: [][]AtomicFile{
+				{{Path: "credentials", Data: []byte("a"), Mode: 0400}, {Path: "krb5.conf", Data: []byte("b"), Mode: 0400}},
+				{{Path: "credentials", Data: []byte("a"), Mode: 0400}},
+			},
+			want: want{
+				files:   map[string]string{"credentials": "a"},
+				entries: []string{DataDirName, "credentials"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "atomic")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			p := provisionerClient{}
+			for _, files := range tc.writes {
+				if err := p.WriteAtomic(dir, files, -1); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			files := map[string]string{}
+			for path := range tc.want.files {
+				data, err := ioutil.ReadFile(filepath.Join(dir, path))
+				if err != nil {
+					t.Fatal(err)
+				}
+				files[path] = string(data)
+			}
+			if diff := cmp.Diff(tc.want.files, files); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			infos, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var entries []string
+			tsDirs := 0
+			for _, info := range infos {
+				if info.IsDir() {
+					tsDirs++
+					continue
+				}
+				entries = append(entries, info.Name())
+			}
+			sort.Strings(entries)
+			if diff := cmp.Diff(tc.want.entries, entries); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(1, tsDirs); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+)
+
+const defaultCacheResync = 10 * time.Minute
+
+type resourceKind string
+
+const (
+	kindBAR    resourceKind = "bucketaccessrequests"
+	kindBA     resourceKind = "bucketaccesses"
+	kindBR     resourceKind = "bucketrequests"
+	kindBucket resourceKind = "buckets"
+	kindSecret resourceKind = "secrets"
+)
+
+// objectCache serves the COSI objects resolved on every publish from shared
+// informers, so a node running many pods does not issue a chain of live GETs
+// per volume. Secrets are not cached, as every node would hold every secret of
+// the cluster; they are read live, and watched by name for hot reload, see
+// secretWatches. Informers start on first use. Until they have synced, and for
+// objects they do not hold, callers fall back to a live GET.
+//
+// A nil *objectCache is valid and never hits.
+type objectCache struct {
+	informers map[resourceKind]cache.SharedIndexInformer
+	startOnce sync.Once
+	stop      chan struct{}
+
+	mu       sync.Mutex
+	observed map[resourceKind]map[string]observation
+}
+
+func newObjectCache(cosi cs.ObjectstorageV1alpha1Interface, resync time.Duration) *objectCache {
+	informer := func(obj runtime.Object, list func(context.Context, metav1.ListOptions) (runtime.Object, error), w func(context.Context, metav1.ListOptions) (watch.Interface, error)) cache.SharedIndexInformer {
+		lw := &cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				return list(context.Background(), opts)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				return w(context.Background(), opts)
+			},
+		}
+		return cache.NewSharedIndexInformer(lw, obj, resync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	}
+
+	c := &objectCache{
+		informers: map[resourceKind]cache.SharedIndexInformer{
+			kindBAR: informer(&v1alpha1.BucketAccessRequest{},
+				func(ctx context.Context, o metav1.ListOptions) (runtime.Object, error) {
+					return cosi.BucketAccessRequests(metav1.NamespaceAll).List(ctx, o)
+				}, cosi.BucketAccessRequests(metav1.NamespaceAll).Watch),
+			kindBA: informer(&v1alpha1.BucketAccess{},
+				func(ctx context.Context, o metav1.ListOptions) (runtime.Object, error) {
+					return cosi.BucketAccesses().List(ctx, o)
+				}, cosi.BucketAccesses().Watch),
+			kindBR: informer(&v1alpha1.BucketRequest{},
+				func(ctx context.Context, o metav1.ListOptions) (runtime.Object, error) {
+					return cosi.BucketRequests(metav1.NamespaceAll).List(ctx, o)
+				}, cosi.BucketRequests(metav1.NamespaceAll).Watch),
+			kindBucket: informer(&v1alpha1.Bucket{},
+				func(ctx context.Context, o metav1.ListOptions) (runtime.Object, error) {
+					return cosi.Buckets().List(ctx, o)
+				}, cosi.Buckets().Watch),
+		},
+		stop:     make(chan struct{}),
+		observed: map[resourceKind]map[string]observation{},
+	}
+	for kind, inf := range c.informers {
+		kind := kind
+		c.observed[kind] = map[string]observation{}
+		inf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.observe(kind, obj) },
+			UpdateFunc: func(_, obj interface{}) { c.observe(kind, obj) },
+			DeleteFunc: func(obj interface{}) { c.forget(kind, obj) },
+		})
+	}
+	return c
+}
+
+func (c *objectCache) start() {
+	c.startOnce.Do(func() {
+		for _, inf := range c.informers {
+			go inf.Run(c.stop)
+		}
+	})
+}
+
+// get returns a copy of the cached object, which callers are free to modify.
+func (c *objectCache) get(kind resourceKind, namespace, name string) (runtime.Object, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.start()
+
+	inf := c.informers[kind]
+	if !inf.HasSynced() {
+		metrics.CacheLookups.WithLabelValues(string(kind), "unsynced").Inc()
+		return nil, false
+	}
+
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	obj, exists, err := inf.GetStore().GetByKey(key)
+	if err != nil || !exists {
+		metrics.CacheLookups.WithLabelValues(string(kind), "miss").Inc()
+		return nil, false
+	}
+	metrics.CacheLookups.WithLabelValues(string(kind), "hit").Inc()
+	return obj.(runtime.Object).DeepCopyObject(), true
+}
+
+func (n *nodeClient) getBAR(ctx context.Context, namespace, name string) (*v1alpha1.BucketAccessRequest, error) {
+	if obj, ok := n.cache.get(kindBAR, namespace, name); ok {
+		return obj.(*v1alpha1.BucketAccessRequest), nil
+	}
+	obj, err := n.cosiClient.BucketAccessRequests(namespace).Get(ctx, name, metav1.GetOptions{})
+	return obj, countAPIError(string(kindBAR), err)
+}
+
+func (n *nodeClient) getBA(ctx context.Context, name string) (*v1alpha1.BucketAccess, error) {
+	if obj, ok := n.cache.get(kindBA, "", name); ok {
+		return obj.(*v1alpha1.BucketAccess), nil
+	}
+	obj, err := n.cosiClient.BucketAccesses().Get(ctx, name, metav1.GetOptions{})
+	return obj, countAPIError(string(kindBA), err)
+}
+
+func (n *nodeClient) getBR(ctx context.Context, namespace, name string) (*v1alpha1.BucketRequest, error) {
+	if obj, ok := n.cache.get(kindBR, namespace, name); ok {
+		return obj.(*v1alpha1.BucketRequest), nil
+	}
+	obj, err := n.cosiClient.BucketRequests(namespace).Get(ctx, name, metav1.GetOptions{})
+	return obj, countAPIError(string(kindBR), err)
+}
+
+func (n *nodeClient) getBucket(ctx context.Context, name string) (*v1alpha1.Bucket, error) {
+	if obj, ok := n.cache.get(kindBucket, "", name); ok {
+		return obj.(*v1alpha1.Bucket), nil
+	}
+	obj, err := n.cosiClient.Buckets().Get(ctx, name, metav1.GetOptions{})
+	return obj, countAPIError(string(kindBucket), err)
+}
+
+func (n *nodeClient) getSecret(ctx context.Context, namespace, name string) (*v1.Secret, error) {
+	obj, err := n.kubeClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	return obj, countAPIError(string(kindSecret), err)
+}
+
+// countAPIError counts a failed read of resource from the API server and
+// returns err unchanged.
+func countAPIError(resource string, err error) error {
+	if err != nil {
+		metrics.APIErrors.WithLabelValues(resource).Inc()
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	cosifake "sigs.k8s.io/container-object-storage-interface-api/clientset/fake"
+)
+
+func TestObjectCache(t *testing.T) {
+	cached := &v1alpha1.BucketAccessRequest{ObjectMeta: metav1.ObjectMeta{Name: "cached", Namespace: "ns"}}
+
+	cases := map[string]struct {
+		namespace, name string
+		wantHit         bool
+	}{
+		"Hit": {
+			namespace: "ns",
+			name:      "cached",
+			wantHit:   true,
+		},
+		"Miss": {
+			namespace: "ns",
+			name:      "missing",
+			wantHit:   false,
+		},
+		"OtherNamespace": {
+			namespace: "other",
+			name:      "cached",
+			wantHit:   false,
+		},
+	}
+
+	c := newObjectCache(cosifake.NewSimpleClientset(cached).ObjectstorageV1alpha1(), 0)
+	defer close(c.stop)
+
+	c.start()
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return c.informers[kindBAR].HasSynced(), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			obj, hit := c.get(kindBAR, tc.namespace, tc.name)
+
+			if diff := cmp.Diff(tc.wantHit, hit); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if !hit {
+				return
+			}
+
+			secret := obj.(*v1alpha1.BucketAccessRequest)
+			if diff := cmp.Diff(cached.Name, secret.Name); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			// Callers modify returned objects, which must not leak into the cache.
+			secret.Labels = map[string]string{"modified": "true"}
+			again, _ := c.get(kindBAR, tc.namespace, tc.name)
+			if diff := cmp.Diff(map[string]string(nil), again.(*v1alpha1.BucketAccessRequest).Labels); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+
+	var nilCache *objectCache
+	if _, hit := nilCache.get(kindBAR, "ns", "cached"); hit {
+		t.Errorf("nil cache returned a hit")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	cached := &v1alpha1.BucketAccessRequest{ObjectMeta: metav1.ObjectMeta{Name: "cached", Namespace: "ns", ResourceVersion: "1"}}
+
+	c := newObjectCache(cosifake.NewSimpleClientset(cached).ObjectstorageV1alpha1(), 0)
+	defer close(c.stop)
+
+	c.start()
+	if err := wait.PollImmediate(10*time.Millisecond, 10*time.Second, func() (bool, error) {
+		return c.informers[kindBAR].HasSynced(), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	n := &nodeClient{cache: c}
+
+	cases := map[string]struct {
+		obj        *v1alpha1.BucketAccessRequest
+		wantSource string
+	}{
+		"CachedVersion": {
+			obj:        cached,
+			wantSource: SnapshotCache,
+		},
+		"NewerVersion": {
+			obj:        &v1alpha1.BucketAccessRequest{ObjectMeta: metav1.ObjectMeta{Name: "cached", Namespace: "ns", ResourceVersion: "2"}},
+			wantSource: SnapshotLive,
+		},
+		"NotCached": {
+			obj:        &v1alpha1.BucketAccessRequest{ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "ns", ResourceVersion: "1"}},
+			wantSource: SnapshotLive,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := n.Snapshot(tc.obj)
+
+			if diff := cmp.Diff(tc.wantSource, s.Source); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.obj.ResourceVersion, s.ResourceVersion); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if s.AgeSeconds < 0 || (s.Source == SnapshotLive && s.AgeSeconds != 0) {
+				t.Errorf("unexpected age %v for a %s snapshot", s.AgeSeconds, s.Source)
+			}
+		})
+	}
+}
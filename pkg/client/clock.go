@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// NewServerClock returns a function reading the API server's clock from the
+// Date header of a /version request. The result is moved forward by half the
+// round trip so it estimates the server time at the moment the call returns.
+// The header has a resolution of one second, which is plenty for spotting
+// skew that matters to expiring credentials.
+func NewServerClock(config *rest.Config) (func(ctx context.Context) (time.Time, error), error) {
+	transport, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorCreateClientFailed)
+	}
+	base, _, err := rest.DefaultServerURL(config.Host, config.APIPath, schema.GroupVersion{}, rest.IsConfigTransportTLS(*config))
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorCreateClientFailed)
+	}
+	url := *base
+	url.Path = "/version"
+	httpClient := &http.Client{Transport: transport}
+
+	return func(ctx context.Context) (time.Time, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		sent := time.Now()
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return time.Time{}, err
+		}
+		defer resp.Body.Close()
+		rtt := time.Since(sent)
+
+		date, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			return time.Time{}, fmt.Errorf(util.ErrorTemplateNoServerDate, url.Path)
+		}
+		return date.Add(rtt / 2), nil
+	}, nil
+}
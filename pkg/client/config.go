@@ -0,0 +1,29 @@
+package client
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// KubeconfigEnv is read for the kubeconfig path when none is given.
+const KubeconfigEnv = "KUBECONFIG"
+
+// RESTConfig returns the config for reaching the API server: from kubeconfig,
+// falling back to $KUBECONFIG, for running the adapter outside the cluster
+// during development; from the pod's service account otherwise.
+func RESTConfig(kubeconfig string) (*rest.Config, error) {
+	if kubeconfig == "" {
+		kubeconfig = os.Getenv(KubeconfigEnv)
+	}
+	if kubeconfig != "" {
+		config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+		return config, errors.Wrap(err, util.WrapErrorLoadKubeconfigFailed)
+	}
+	config, err := rest.InClusterConfig()
+	return config, errors.Wrap(err, util.WrapErrorInClusterConfigFailed)
+}
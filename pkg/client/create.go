@@ -0,0 +1,68 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// tmpFilePrefix starts the name of the temporary files createFile falls back
+// to, hidden like the entries of WriteAtomic.
+const tmpFilePrefix = "..tmp_"
+
+// errNotSupported reports that the kernel or filesystem lacks a primitive
+// createFile prefers, which it then falls back from.
+var errNotSupported = errors.New("not supported")
+
+// createFile creates path with data and mode, failing if path exists. The file
+// only appears under path once fully written, so readers never observe it
+// partially written, whether or not it is swapped in by WriteAtomic:
+//
+//  1. On Linux, the data is written to an unnamed O_TMPFILE file, which is
+//     then linked as path.
+//  2. Where O_TMPFILE is not supported, it is written to a temporary file in
+//     the directory of path, renamed with RENAME_NOREPLACE on Linux and hard
+//     linked elsewhere.
+//
+// Both never replace an existing path, like O_EXCL.
+func createFile(path string, data []byte, mode os.FileMode) error {
+	if err := createTmpfile(path, data, mode); err != errNotSupported {
+		return err
+	}
+
+	tmp, err := writeTemp(path, data, mode)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	if err := renameNoReplace(tmp, path); err != errNotSupported {
+		return err
+	}
+	return os.Link(tmp, path)
+}
+
+// writeTemp writes data to a new temporary file next to path and returns its
+// name. Like path would be, the file is created with mode under the umask.
+func writeTemp(path string, data []byte, mode os.FileMode) (string, error) {
+	for {
+		name := filepath.Join(filepath.Dir(path), fmt.Sprintf("%s%s.%d.%d", tmpFilePrefix, filepath.Base(path), os.Getpid(), time.Now().UnixNano()))
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_EXCL, mode)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(name)
+			return "", err
+		}
+		return name, nil
+	}
+}
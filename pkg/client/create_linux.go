@@ -0,0 +1,58 @@
+//go:build linux
+// +build linux
+
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// createTmpfile writes data to an O_TMPFILE file in the directory of path and
+// links it as path. Kernels before 3.11 and some filesystems do not support
+// O_TMPFILE.
+func createTmpfile(path string, data []byte, mode os.FileMode) error {
+	fd, err := unix.Open(filepath.Dir(path), unix.O_TMPFILE|unix.O_WRONLY|unix.O_CLOEXEC, uint32(mode.Perm()))
+	switch err {
+	case nil:
+	case unix.EOPNOTSUPP, unix.EISDIR, unix.EINVAL:
+		return errNotSupported
+	default:
+		return &os.PathError{Op: "open", Path: filepath.Dir(path), Err: err}
+	}
+	f := os.NewFile(uintptr(fd), path)
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	// Linking the descriptor through /proc needs no capability, unlike
+	// AT_EMPTY_PATH. Without /proc, fall back.
+	err = unix.Linkat(unix.AT_FDCWD, fmt.Sprintf("/proc/self/fd/%d", fd), unix.AT_FDCWD, path, unix.AT_SYMLINK_FOLLOW)
+	if err == unix.ENOENT {
+		if _, statErr := os.Stat("/proc/self/fd"); statErr != nil {
+			return errNotSupported
+		}
+	}
+	if err != nil {
+		return &os.LinkError{Op: "linkat", Old: f.Name(), New: path, Err: err}
+	}
+	return nil
+}
+
+// renameNoReplace renames from to to, failing if to exists. Kernels before
+// 3.15 and some filesystems do not support RENAME_NOREPLACE.
+func renameNoReplace(from, to string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, from, unix.AT_FDCWD, to, unix.RENAME_NOREPLACE)
+	switch err {
+	case nil:
+		return nil
+	case unix.ENOSYS, unix.EINVAL:
+		return errNotSupported
+	default:
+		return &os.LinkError{Op: "renameat2", Old: from, New: to, Err: err}
+	}
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCreateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials")
+
+	if err := createFile(path, []byte("a"), 0400); err != nil {
+		t.Fatal(err)
+	}
+	if err := createFile(path, []byte("b"), 0400); !os.IsExist(err) {
+		t.Errorf("creating an existing file: want an exists error, got %v", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("a", string(data)); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(os.FileMode(0400), info.Mode().Perm()); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if diff := cmp.Diff([]string{"credentials"}, names); diff != "" {
+		t.Errorf("temporary files left behind: -want, +got:\n%s", diff)
+	}
+}
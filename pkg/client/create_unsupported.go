@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package client
+
+import "os"
+
+func createTmpfile(path string, data []byte, mode os.FileMode) error {
+	return errNotSupported
+}
+
+func renameNoReplace(from, to string) error {
+	return errNotSupported
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog/v2"
+)
+
+// DefaultDiscoveryCacheTTL is how long discovery data cached on disk is used
+// before the API server is asked again.
+const DefaultDiscoveryCacheTTL = 10 * time.Minute
+
+const serverGroupsFile = "servergroups.json"
+
+// DiscoveryCache serves the API groups of the cluster from a file kept between
+// restarts, so a restarting adapter does not wait for discovery, which takes
+// seconds on large clusters, before it is ready. Data older than the TTL is
+// refreshed from the delegate. Failing to read or write the cache is logged
+// and falls back to the delegate: the cache only ever saves time.
+type DiscoveryCache struct {
+	delegate discovery.ServerGroupsInterface
+	path     string
+	ttl      time.Duration
+	now      func() time.Time
+
+	invalidated bool
+}
+
+// NewDiscoveryCache returns a DiscoveryCache keeping the groups served by
+// delegate in dir. Clusters are told apart by host, so one dir may be shared.
+func NewDiscoveryCache(delegate discovery.ServerGroupsInterface, dir, host string, ttl time.Duration) *DiscoveryCache {
+	return &DiscoveryCache{
+		delegate: delegate,
+		path:     filepath.Join(dir, cacheDirName(host), serverGroupsFile),
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+// ServerGroups returns the cached groups while fresh, those of the delegate
+// otherwise, which are then cached.
+func (c *DiscoveryCache) ServerGroups() (*metav1.APIGroupList, error) {
+	if !c.invalidated {
+		if groups, ok := c.read(); ok {
+			return groups, nil
+		}
+	}
+
+	groups, err := c.delegate.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+	c.invalidated = false
+	if err := c.write(groups); err != nil {
+		klog.V(2).InfoS("failed to cache discovery data", "path", c.path, "err", err)
+	}
+	return groups, nil
+}
+
+// Invalidate makes the next ServerGroups ask the delegate, e.g. when the cached
+// groups are suspected to be stale.
+func (c *DiscoveryCache) Invalidate() {
+	c.invalidated = true
+}
+
+func (c *DiscoveryCache) read() (*metav1.APIGroupList, bool) {
+	info, err := os.Stat(c.path)
+	if err != nil || c.now().Sub(info.ModTime()) > c.ttl {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		klog.V(2).InfoS("failed to read cached discovery data", "path", c.path, "err", err)
+		return nil, false
+	}
+	groups := &metav1.APIGroupList{}
+	if err := json.Unmarshal(data, groups); err != nil {
+		klog.V(2).InfoS("ignoring unreadable cached discovery data", "path", c.path, "err", err)
+		return nil, false
+	}
+	return groups, true
+}
+
+func (c *DiscoveryCache) write(groups *metav1.APIGroupList) error {
+	data, err := json.Marshal(groups)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0750); err != nil {
+		return err
+	}
+	tmp, err := writeTemp(c.path, data, 0640)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	return os.Rename(tmp, c.path)
+}
+
+// cacheDirName turns the host of an API server into a directory name.
+func cacheDirName(host string) string {
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	return strings.NewReplacer("/", "_", ":", "_").Replace(host)
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDiscoveryCache(t *testing.T) {
+	type want struct {
+		group string
+		// calls to the delegate
+		calls int
+	}
+
+	cases := map[string]struct {
+		// cached is the file content, age its modification time.
+		cached     string
+		age        time.Duration
+		invalidate bool
+		want
+	}{
+		"Empty": {
+			want: want{group: "objectstorage.k8s.io", calls: 1},
+		},
+		"Fresh": {
+			cached: `{"groups":[{"name":"cached","versions":null,"preferredVersion":{"groupVersion":"","version":""}}]}`,
+			age:    time.Minute,
+			want:   want{group: "cached"},
+		},
+		"Stale": {
+			cached: `{"groups":[{"name":"cached","versions":null,"preferredVersion":{"groupVersion":"","version":""}}]}`,
+			age:    time.Hour,
+			want:   want{group: "objectstorage.k8s.io", calls: 1},
+		},
+		"Invalidated": {
+			cached:     `{"groups":[{"name":"cached","versions":null,"preferredVersion":{"groupVersion":"","version":""}}]}`,
+			age:        time.Minute,
+			invalidate: true,
+			want:       want{group: "objectstorage.k8s.io", calls: 1},
+		},
+		"Unreadable": {
+			cached: "{",
+			age:    time.Minute,
+			want:   want{group: "objectstorage.k8s.io", calls: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "discovery")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			dc := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+			dc.Resources = []*metav1.APIResourceList{{GroupVersion: "objectstorage.k8s.io/v1alpha1"}}
+			c := NewDiscoveryCache(dc, dir, "https://10.0.0.1:443", 10*time.Minute)
+			if tc.cached != "" {
+				if err := os.MkdirAll(filepath.Dir(c.path), 0750); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(c.path, []byte(tc.cached), 0640); err != nil {
+					t.Fatal(err)
+				}
+				mtime := time.Now().Add(-tc.age)
+				if err := os.Chtimes(c.path, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.invalidate {
+				c.Invalidate()
+			}
+
+			groups, err := c.ServerGroups()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := want{group: groups.Groups[0].Name, calls: len(dc.Actions())}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			// Whatever was asked of the delegate is now served from the cache.
+			again, err := NewDiscoveryCache(dc, dir, "https://10.0.0.1:443", 10*time.Minute).ServerGroups()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(groups, again); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.calls, len(dc.Actions())); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"k8s.io/client-go/tools/record"
 
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
@@ -19,17 +21,50 @@ type FakeNodeClient struct {
 	MockGetB   func(ctx context.Context, pod *v1.Pod, bName string) (*v1alpha1.Bucket, error)
 	MockGetPod func(ctx context.Context, podName, podNs string) (*v1.Pod, error)
 
+	MockGetNode        func(ctx context.Context, nodeName string) (*v1.Node, error)
+	MockGetBucketClass func(ctx context.Context, name string) (*v1alpha1.BucketClass, error)
+
 	MockGetResources func(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error)
 
-	MockAddBAFinalizer    func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error
-	MockRemoveBAFinalizer func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error
+	MockAddBAFinalizer    func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error
+	MockRemoveBAFinalizer func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error
+	MockAddBAAnnotation   func(ctx context.Context, ba *v1alpha1.BucketAccess, key, value string) (*v1alpha1.BucketAccess, error)
+	MockLookupBA          func(ctx context.Context, baName string) (*v1alpha1.BucketAccess, error)
+
+	MockAddSecretFinalizer    func(ctx context.Context, secret *v1.Secret, f client.Finalizer) error
+	MockRemoveSecretFinalizer func(ctx context.Context, namespace, name string, f client.Finalizer) error
+
+	MockApplyBucketAnnotations func(ctx context.Context, bucketName string, annotations map[string]string) error
+
+	MockEnsurePodBA  func(ctx context.Context, shared *v1alpha1.BucketAccess, pod *v1.Pod) (*v1alpha1.BucketAccess, error)
+	MockWaitForPodBA func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, *v1.Secret, error)
+	MockDeleteBA     func(ctx context.Context, baName string) error
+
+	MockSnapshot func(obj runtime.Object) client.Snapshot
+
+	MockOnSecretChange func(handler func(namespace, name string))
+	MockWatchSecrets   func(secrets []string)
 }
 
 func (f FakeNodeClient) GetPod(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
 	return f.MockGetPod(ctx, podName, podNs)
 }
 
-var fRecorder = record.NewFakeRecorder(10)
+func (f FakeNodeClient) GetNode(ctx context.Context, nodeName string) (*v1.Node, error) {
+	return f.MockGetNode(ctx, nodeName)
+}
+
+// GetBucketClass finds no BucketClass unless MockGetBucketClass is set, as
+// most tests do not depend on the class.
+func (f FakeNodeClient) GetBucketClass(ctx context.Context, name string) (*v1alpha1.BucketClass, error) {
+	if f.MockGetBucketClass == nil {
+		return nil, apierrors.NewNotFound(v1alpha1.SchemeGroupVersion.WithResource("bucketclasses").GroupResource(), name)
+	}
+	return f.MockGetBucketClass(ctx, name)
+}
+
+// fRecorder discards events, so tests emitting many never block on it.
+var fRecorder = &record.FakeRecorder{}
 
 func (f FakeNodeClient) Recorder() record.EventRecorder {
 	return fRecorder
@@ -55,10 +90,74 @@ func (f FakeNodeClient) GetResources(ctx context.Context, barName, podName, podN
 	return f.MockGetResources(ctx, barName, podName, podNs)
 }
 
-func (f FakeNodeClient) AddBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
+func (f FakeNodeClient) AddBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error {
 	return f.MockAddBAFinalizer(ctx, ba, BAFinalizer)
 }
 
-func (f FakeNodeClient) RemoveBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
+func (f FakeNodeClient) RemoveBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error {
 	return f.MockRemoveBAFinalizer(ctx, ba, BAFinalizer)
 }
+
+// AddSecretFinalizer succeeds unless MockAddSecretFinalizer is set, as most
+// tests do not depend on the protection of the minted secret.
+func (f FakeNodeClient) AddSecretFinalizer(ctx context.Context, secret *v1.Secret, finalizer client.Finalizer) error {
+	if f.MockAddSecretFinalizer == nil {
+		return nil
+	}
+	return f.MockAddSecretFinalizer(ctx, secret, finalizer)
+}
+
+// RemoveSecretFinalizer succeeds unless MockRemoveSecretFinalizer is set.
+func (f FakeNodeClient) RemoveSecretFinalizer(ctx context.Context, namespace, name string, finalizer client.Finalizer) error {
+	if f.MockRemoveSecretFinalizer == nil {
+		return nil
+	}
+	return f.MockRemoveSecretFinalizer(ctx, namespace, name, finalizer)
+}
+
+func (f FakeNodeClient) AddBAAnnotation(ctx context.Context, ba *v1alpha1.BucketAccess, key, value string) (*v1alpha1.BucketAccess, error) {
+	return f.MockAddBAAnnotation(ctx, ba, key, value)
+}
+
+func (f FakeNodeClient) LookupBA(ctx context.Context, baName string) (*v1alpha1.BucketAccess, error) {
+	return f.MockLookupBA(ctx, baName)
+}
+
+func (f FakeNodeClient) ApplyBucketAnnotations(ctx context.Context, bucketName string, annotations map[string]string) error {
+	return f.MockApplyBucketAnnotations(ctx, bucketName, annotations)
+}
+
+func (f FakeNodeClient) EnsurePodBA(ctx context.Context, shared *v1alpha1.BucketAccess, pod *v1.Pod) (*v1alpha1.BucketAccess, error) {
+	return f.MockEnsurePodBA(ctx, shared, pod)
+}
+
+func (f FakeNodeClient) WaitForPodBA(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, *v1.Secret, error) {
+	return f.MockWaitForPodBA(ctx, pod, baName)
+}
+
+func (f FakeNodeClient) DeleteBA(ctx context.Context, baName string) error {
+	return f.MockDeleteBA(ctx, baName)
+}
+
+// Snapshot describes every object as a live read unless MockSnapshot is set,
+// so publish tests need not mock it.
+func (f FakeNodeClient) Snapshot(obj runtime.Object) client.Snapshot {
+	if f.MockSnapshot == nil {
+		return client.Snapshot{Source: client.SnapshotLive}
+	}
+	return f.MockSnapshot(obj)
+}
+
+// OnSecretChange ignores handler unless MockOnSecretChange is set.
+func (f FakeNodeClient) OnSecretChange(handler func(namespace, name string)) {
+	if f.MockOnSecretChange != nil {
+		f.MockOnSecretChange(handler)
+	}
+}
+
+// WatchSecrets ignores secrets unless MockWatchSecrets is set.
+func (f FakeNodeClient) WatchSecrets(secrets []string) {
+	if f.MockWatchSecrets != nil {
+		f.MockWatchSecrets(secrets)
+	}
+}
@@ -13,6 +13,11 @@ type MockProvisionerClient struct {
 	MockRemoveAll func(path string) error
 	MockWriteFile func(data []byte, filepath string) error
 	MockReadFile  func(filename string) ([]byte, error)
+
+	MockWriteFileWithMode func(data []byte, filepath string, mode os.FileMode) error
+	MockReplaceFile       func(data []byte, filepath string) error
+	MockWriteAtomic       func(dir string, files []client.AtomicFile, gid int) error
+	MockSetOwnership      func(path string, mode os.FileMode, gid int) error
 }
 
 func (p MockProvisionerClient) ReadFile(filename string) ([]byte, error) {
@@ -30,3 +35,19 @@ func (p MockProvisionerClient) RemoveAll(path string) error {
 func (p MockProvisionerClient) WriteFile(data []byte, filepath string) error {
 	return p.MockWriteFile(data, filepath)
 }
+
+func (p MockProvisionerClient) WriteFileWithMode(data []byte, filepath string, mode os.FileMode) error {
+	return p.MockWriteFileWithMode(data, filepath, mode)
+}
+
+func (p MockProvisionerClient) ReplaceFile(data []byte, filepath string) error {
+	return p.MockReplaceFile(data, filepath)
+}
+
+func (p MockProvisionerClient) WriteAtomic(dir string, files []client.AtomicFile, gid int) error {
+	return p.MockWriteAtomic(dir, files, gid)
+}
+
+func (p MockProvisionerClient) SetOwnership(path string, mode os.FileMode, gid int) error {
+	return p.MockSetOwnership(path, mode, gid)
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
+)
+
+// Finalizer is the name of a finalizer the adapter places on a BucketAccess.
+type Finalizer string
+
+// FinalizerManager adds and removes BucketAccess finalizers with server-side
+// apply. Every finalizer is applied by a field manager of its own, so the
+// finalizers of different pods never release one another and a finalizer
+// is only removed from the object once nobody applies it any more. Adding a
+// finalizer that is already present or removing one that is absent does not
+// write at all, and removing from a deleted BucketAccess succeeds.
+//
+// Finalizers added before the adapter used server-side apply are owned by an
+// update rather than by the adapter's field managers; they are removed with an
+// update that is retried against the latest object on conflict.
+type FinalizerManager struct {
+	client  cs.BucketAccessInterface
+	backoff wait.Backoff
+}
+
+func NewFinalizerManager(client cs.BucketAccessInterface) *FinalizerManager {
+	return &FinalizerManager{
+		client:  client,
+		backoff: retry.DefaultRetry,
+	}
+}
+
+// Add ensures f is set on ba and returns the BucketAccess as stored.
+func (m *FinalizerManager) Add(ctx context.Context, ba *v1alpha1.BucketAccess, f Finalizer) (*v1alpha1.BucketAccess, error) {
+	if hasFinalizer(ba, f) {
+		return ba, nil
+	}
+	return m.apply(ctx, ba, f, true)
+}
+
+// Remove ensures f is not set on ba and returns the BucketAccess as stored, or
+// nil if it no longer exists.
+func (m *FinalizerManager) Remove(ctx context.Context, ba *v1alpha1.BucketAccess, f Finalizer) (*v1alpha1.BucketAccess, error) {
+	if !hasFinalizer(ba, f) {
+		return ba, nil
+	}
+
+	updated, err := m.apply(ctx, ba, f, false)
+	if err == nil && hasFinalizer(updated, f) {
+		updated, err = m.mutate(ctx, updated, func(ba *v1alpha1.BucketAccess) bool {
+			if !hasFinalizer(ba, f) {
+				return false
+			}
+			controllerutil.RemoveFinalizer(ba, string(f))
+			return true
+		})
+	}
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return updated, err
+}
+
+// apply sets or releases f as the only field of f's field manager.
+func (m *FinalizerManager) apply(ctx context.Context, ba *v1alpha1.BucketAccess, f Finalizer, set bool) (*v1alpha1.BucketAccess, error) {
+	fields := map[string]interface{}{}
+	if set {
+		fields["finalizers"] = []string{string(f)}
+	}
+	data, err := metadataApply("BucketAccess", ba.Name, ba.UID, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	force := true
+	countWrite(ctx)
+	return m.client.Patch(ctx, ba.Name, types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: scopedFieldManager(string(f)),
+		Force:        &force,
+	})
+}
+
+// mutate applies change to a copy of ba and updates it, refetching and
+// reapplying change whenever the update conflicts. change reports whether it
+// modified the object; if not, nothing is written.
+func (m *FinalizerManager) mutate(ctx context.Context, ba *v1alpha1.BucketAccess, change func(*v1alpha1.BucketAccess) bool) (*v1alpha1.BucketAccess, error) {
+	current := ba.DeepCopy()
+	err := retry.RetryOnConflict(m.backoff, func() error {
+		if !change(current) {
+			return nil
+		}
+
+		countWrite(ctx)
+		updated, err := m.client.Update(ctx, current, metav1.UpdateOptions{})
+		if apierrors.IsConflict(err) {
+			latest, getErr := m.client.Get(ctx, current.Name, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			current = latest
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		current = updated
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+func hasFinalizer(ba *v1alpha1.BucketAccess, f Finalizer) bool {
+	for _, existing := range ba.Finalizers {
+		if existing == string(f) {
+			return true
+		}
+	}
+	return false
+}
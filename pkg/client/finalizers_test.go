@@ -0,0 +1,212 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	cosifake "sigs.k8s.io/container-object-storage-interface-api/clientset/fake"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+var errBoom = errors.New("boom")
+
+func TestFinalizerManager(t *testing.T) {
+	const (
+		ours   Finalizer = "cosi.objectstorage.k8s.io/bucketaccess-protection-ns-pod"
+		theirs           = "example.com/other-writer"
+	)
+	baResource := v1alpha1.SchemeGroupVersion.WithResource("bucketaccesses")
+
+	newBA := func(finalizers ...string) *v1alpha1.BucketAccess {
+		return &v1alpha1.BucketAccess{ObjectMeta: metav1.ObjectMeta{Name: "ba", UID: "uid", Finalizers: finalizers}}
+	}
+
+	// serverSideApply emulates the API server applying finalizers: ours is
+	// only removed when it was added by an apply, not by an update. The fake
+	// clientset does not pass on the field manager, so the emulation only
+	// tracks ours.
+	serverSideApply := func(cosi *cosifake.Clientset, applied bool) {
+		cosi.PrependReactor("patch", "bucketaccesses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			patch := action.(k8stesting.PatchAction)
+			if patch.GetPatchType() != types.ApplyPatchType {
+				return true, nil, errBoom
+			}
+			var cfg v1alpha1.BucketAccess
+			if err := json.Unmarshal(patch.GetPatch(), &cfg); err != nil {
+				return true, nil, err
+			}
+
+			stored, err := cosi.Tracker().Get(baResource, "", patch.GetName())
+			if err != nil {
+				return true, nil, err
+			}
+			ba := stored.(*v1alpha1.BucketAccess).DeepCopy()
+			if cfg.UID != ba.UID {
+				return true, nil, apierrors.NewConflict(baResource.GroupResource(), ba.Name, errBoom)
+			}
+			switch {
+			case len(cfg.Finalizers) > 0:
+				controllerutil.AddFinalizer(ba, cfg.Finalizers[0])
+				applied = true
+			case applied:
+				controllerutil.RemoveFinalizer(ba, string(ours))
+				applied = false
+			}
+			return true, ba, cosi.Tracker().Update(baResource, ba, "")
+		})
+	}
+
+	// conflictOnce makes the first update lose a race against another writer
+	// that adds its own finalizer in the meantime.
+	conflictOnce := func(cosi *cosifake.Clientset) {
+		raced := false
+		cosi.PrependReactor("update", "bucketaccesses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if raced {
+				return false, nil, nil
+			}
+			raced = true
+			stored, err := cosi.Tracker().Get(baResource, "", "ba")
+			if err != nil {
+				return true, nil, err
+			}
+			other := stored.(*v1alpha1.BucketAccess).DeepCopy()
+			other.Finalizers = append(other.Finalizers, theirs)
+			if err := cosi.Tracker().Update(baResource, other, ""); err != nil {
+				return true, nil, err
+			}
+			return true, nil, apierrors.NewConflict(baResource.GroupResource(), "ba", errBoom)
+		})
+	}
+
+	type want struct {
+		finalizers []string
+		patches    int
+		updates    int
+		err        error
+	}
+
+	cases := map[string]struct {
+		stored  *v1alpha1.BucketAccess
+		applied bool
+		inject  func(cosi *cosifake.Clientset)
+		remove  bool
+		want
+	}{
+		"Add": {
+			stored: newBA(theirs),
+			want:   want{finalizers: []string{theirs, string(ours)}, patches: 1},
+		},
+		"AddDuplicate": {
+			stored:  newBA(string(ours)),
+			applied: true,
+			want:    want{finalizers: []string{string(ours)}},
+		},
+		"AddFailure": {
+			stored: newBA(),
+			inject: func(cosi *cosifake.Clientset) {
+				cosi.PrependReactor("patch", "bucketaccesses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errBoom
+				})
+			},
+			want: want{finalizers: nil, patches: 1, err: errBoom},
+		},
+		"Remove": {
+			stored:  newBA(theirs, string(ours)),
+			applied: true,
+			remove:  true,
+			want:    want{finalizers: []string{theirs}, patches: 1},
+		},
+		"RemoveAbsent": {
+			stored: newBA(theirs),
+			remove: true,
+			want:   want{finalizers: []string{theirs}},
+		},
+		"RemoveAddedByUpdate": {
+			stored: newBA(theirs, string(ours)),
+			remove: true,
+			want:   want{finalizers: []string{theirs}, patches: 1, updates: 1},
+		},
+		"RemoveAddedByUpdateConflict": {
+			stored: newBA(string(ours)),
+			inject: conflictOnce,
+			remove: true,
+			want:   want{finalizers: []string{theirs}, patches: 1, updates: 2},
+		},
+		"RemoveDeleted": {
+			stored: nil,
+			remove: true,
+			want:   want{patches: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cosi := cosifake.NewSimpleClientset()
+			ba := newBA(string(ours))
+			if tc.stored != nil {
+				ba = tc.stored
+				if err := cosi.Tracker().Add(tc.stored.DeepCopy()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			serverSideApply(cosi, tc.applied)
+			if tc.inject != nil {
+				tc.inject(cosi)
+			}
+
+			m := NewFinalizerManager(cosi.ObjectstorageV1alpha1().BucketAccesses())
+			wctx, writes := WithWriteCount(ctx)
+			var err error
+			if tc.remove {
+				_, err = m.Remove(wctx, ba, ours)
+			} else {
+				_, err = m.Add(wctx, ba, ours)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			patches, updates := 0, 0
+			for _, action := range cosi.Actions() {
+				switch action.GetVerb() {
+				case "patch":
+					patches++
+				case "update":
+					updates++
+				}
+			}
+			if diff := cmp.Diff(tc.want.patches, patches); diff != "" {
+				t.Errorf("patches: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.updates, updates); diff != "" {
+				t.Errorf("updates: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(patches+updates, writes()); diff != "" {
+				t.Errorf("writes: -want, +got:\n%s", diff)
+			}
+
+			if tc.stored == nil {
+				return
+			}
+			stored, err := cosi.Tracker().Get(baResource, "", "ba")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.finalizers, stored.(*v1alpha1.BucketAccess).Finalizers); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -2,31 +2,34 @@ package client
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
 	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
 
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
 )
 
 const (
-	PodNameKey      = "csi.storage.k8s.io/pod.name"
-	PodNamespaceKey = "csi.storage.k8s.io/pod.namespace"
+	PodNameKey      = adapter.PodNameKey
+	PodNamespaceKey = adapter.PodNamespaceKey
 
-	BarNameKey = "bar-name"
+	BarNameKey = adapter.BucketAccessRequestNameKey
 )
 
 var _ NodeClient = &nodeClient{}
@@ -43,6 +46,11 @@ type nodeClient struct {
 	cosiClient cs.ObjectstorageV1alpha1Interface
 	kubeClient kubernetes.Interface
 	recorder   record.EventRecorder
+	cache      *objectCache
+	secrets    *secretWatches
+	readiness  ReadinessWait
+
+	fieldManager string
 }
 
 type NodeClient interface {
@@ -51,27 +59,72 @@ type NodeClient interface {
 	GetBR(ctx context.Context, pod *v1.Pod, brName, brNs string) (*v1alpha1.BucketRequest, error)
 	GetB(ctx context.Context, pod *v1.Pod, bName string) (*v1alpha1.Bucket, error)
 	GetPod(ctx context.Context, podName, podNs string) (*v1.Pod, error)
+	GetNode(ctx context.Context, nodeName string) (*v1.Node, error)
+	GetBucketClass(ctx context.Context, name string) (*v1alpha1.BucketClass, error)
 
+	// GetResources returns an empty secret for a BucketAccess granting
+	// identity access without minted credentials, and for anonymous access.
 	GetResources(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error)
 
-	AddBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error
-	RemoveBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error
+	AddBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer Finalizer) error
+	RemoveBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer Finalizer) error
+	// AddSecretFinalizer and RemoveSecretFinalizer protect a minted secret
+	// in lockstep with the finalizer of its BucketAccess.
+	AddSecretFinalizer(ctx context.Context, secret *v1.Secret, f Finalizer) error
+	RemoveSecretFinalizer(ctx context.Context, namespace, name string, f Finalizer) error
+	AddBAAnnotation(ctx context.Context, ba *v1alpha1.BucketAccess, key, value string) (*v1alpha1.BucketAccess, error)
+	// LookupBA returns a BucketAccess as is, without checking it is usable.
+	LookupBA(ctx context.Context, baName string) (*v1alpha1.BucketAccess, error)
+	ApplyBucketAnnotations(ctx context.Context, bucketName string, annotations map[string]string) error
+
+	EnsurePodBA(ctx context.Context, shared *v1alpha1.BucketAccess, pod *v1.Pod) (*v1alpha1.BucketAccess, error)
+	WaitForPodBA(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, *v1.Secret, error)
+	DeleteBA(ctx context.Context, baName string) error
+
+	// Snapshot describes the version of an object returned by the client.
+	Snapshot(obj runtime.Object) Snapshot
+
+	// OnSecretChange calls handler with the namespace and name of every
+	// watched secret whose data changes from then on.
+	OnSecretChange(handler func(namespace, name string))
+	// WatchSecrets watches exactly secrets, given as "namespace/name", for
+	// OnSecretChange.
+	WatchSecrets(secrets []string)
 
 	Recorder() record.EventRecorder
 }
 
-func NewClientOrDie(driverName, nodeId string) NodeClient {
-	config, err := rest.InClusterConfig()
+// NewClient returns a NodeClient for the cluster config points at.
+// GetResources waits up to readiness for the bucket resources to be granted.
+func NewClient(driverName, nodeId string, config *rest.Config, readiness ReadinessWait) (NodeClient, error) {
+	client, err := cs.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorCreateClientFailed)
+	}
+	kube, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		panic(err.Error())
+		return nil, errors.Wrap(err, util.WrapErrorCreateClientFailed)
 	}
-	// The following function calls may panic based on the config
-	client := cs.NewForConfigOrDie(config)
-	kube := kubernetes.NewForConfigOrDie(config)
 	return &nodeClient{
 		cosiClient: client,
 		kubeClient: kube,
 		recorder:   newRecorder(kube, driverName, nodeId),
+		cache:      newObjectCache(client, defaultCacheResync),
+		secrets:    newSecretWatches(kube),
+		readiness:  readiness,
+
+		fieldManager: scopedFieldManager(nodeId),
+	}, nil
+}
+
+// NewNodeClient returns a NodeClient using the given clients, e.g. fakes in tests.
+func NewNodeClient(kube kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface, recorder record.EventRecorder) NodeClient {
+	return &nodeClient{
+		cosiClient: cosi,
+		kubeClient: kube,
+		recorder:   recorder,
+
+		fieldManager: FieldManager,
 	}
 }
 
@@ -92,7 +145,7 @@ func ParseVolumeContext(volCtx map[string]string) (barname, podname, podns strin
 
 func (n *nodeClient) GetBAR(ctx context.Context, pod *v1.Pod, barName, barNs string) (*v1alpha1.BucketAccessRequest, error) {
 	klog.Infof("getting bucketAccessRequest %q", fmt.Sprintf("%s/%s", barNs, barName))
-	bar, err := n.cosiClient.BucketAccessRequests(barNs).Get(ctx, barName, metav1.GetOptions{})
+	bar, err := n.getBAR(ctx, barNs, barName)
 	if err != nil {
 		return nil, util.LogErr(errors.Wrap(err, util.WrapErrorGetBARFailed))
 	}
@@ -112,9 +165,21 @@ func (n *nodeClient) GetBAR(ctx context.Context, pod *v1.Pod, barName, barNs str
 	return bar, nil
 }
 
+// IdentityAccessAnnotation set to "true" on a BucketAccess marks access granted
+// to the workload's identity, such as an IAM role bound to its service account
+// or GCP workload identity, rather than through minted credentials. Such a
+// BucketAccess may have no minted secret.
+const IdentityAccessAnnotation = "cosi.objectstorage.k8s.io/identity-access"
+
+// IdentityAccess reports whether ba grants access through the workload's
+// identity, see IdentityAccessAnnotation.
+func IdentityAccess(ba *v1alpha1.BucketAccess) bool {
+	return ba.Annotations[IdentityAccessAnnotation] == "true"
+}
+
 func (n *nodeClient) GetBA(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
 	klog.Infof("getting bucketAccess %q", fmt.Sprintf("%s", baName))
-	ba, err := n.cosiClient.BucketAccesses().Get(ctx, baName, metav1.GetOptions{})
+	ba, err := n.getBA(ctx, baName)
 	if err != nil {
 		return nil, util.LogErr(errors.Wrap(err, util.WrapErrorGetBAFailed))
 	}
@@ -122,7 +187,7 @@ func (n *nodeClient) GetBA(ctx context.Context, pod *v1.Pod, baName string) (*v1
 		util.EmitWarningEvent(n.recorder, pod, util.BAAccessNotGranted)
 		return nil, util.LogErr(util.ErrorBANoAccess)
 	}
-	if ba.Status.MintedSecret == nil {
+	if ba.Status.MintedSecret == nil && !IdentityAccess(ba) && !AnonymousAccess(ba) && !anonymousAccess(ctx) {
 		util.EmitWarningEvent(n.recorder, pod, util.BAMintedSecretNotSet)
 		return nil, util.LogErr(util.ErrorBANoMintedSecret)
 	}
@@ -131,7 +196,7 @@ func (n *nodeClient) GetBA(ctx context.Context, pod *v1.Pod, baName string) (*v1
 
 func (n *nodeClient) GetBR(ctx context.Context, pod *v1.Pod, brName, brNs string) (*v1alpha1.BucketRequest, error) {
 	klog.Infof("getting bucketRequest %q", brName)
-	br, err := n.cosiClient.BucketRequests(brNs).Get(ctx, brName, metav1.GetOptions{})
+	br, err := n.getBR(ctx, brNs, brName)
 	if err != nil {
 		return nil, util.LogErr(errors.Wrap(err, util.WrapErrorGetBRFailed))
 	}
@@ -149,7 +214,7 @@ func (n *nodeClient) GetBR(ctx context.Context, pod *v1.Pod, brName, brNs string
 func (n *nodeClient) GetB(ctx context.Context, pod *v1.Pod, bName string) (*v1alpha1.Bucket, error) {
 	klog.Infof("getting bucket %q", bName)
 	// is BucketInstanceName the correct field, or should it be BucketClass
-	bkt, err := n.cosiClient.Buckets().Get(ctx, bName, metav1.GetOptions{})
+	bkt, err := n.getBucket(ctx, bName)
 	if err != nil {
 		return nil, util.LogErr(errors.Wrap(err, util.WrapErrorGetBFailed))
 	}
@@ -161,7 +226,18 @@ func (n *nodeClient) GetB(ctx context.Context, pod *v1.Pod, bName string) (*v1al
 }
 
 func (n *nodeClient) GetPod(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
-	return n.kubeClient.CoreV1().Pods(podNs).Get(ctx, podName, metav1.GetOptions{})
+	pod, err := n.kubeClient.CoreV1().Pods(podNs).Get(ctx, podName, metav1.GetOptions{})
+	return pod, countAPIError("pods", err)
+}
+
+func (n *nodeClient) GetNode(ctx context.Context, nodeName string) (*v1.Node, error) {
+	node, err := n.kubeClient.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	return node, countAPIError("nodes", err)
+}
+
+func (n *nodeClient) GetBucketClass(ctx context.Context, name string) (*v1alpha1.BucketClass, error) {
+	class, err := n.cosiClient.BucketClasses().Get(ctx, name, metav1.GetOptions{})
+	return class, countAPIError("bucketclasses", err)
 }
 
 func (n *nodeClient) GetResources(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
@@ -171,19 +247,33 @@ func (n *nodeClient) GetResources(ctx context.Context, barName, podName, podNs s
 		return
 	}
 
-	if bar, err = n.GetBAR(ctx, pod, barName, podNs); err != nil {
+	// Until the provisioner has granted access the chain below fails with
+	// not ready errors; wait for it rather than have kubelet retry publish.
+	err = n.readiness.wait(ctx, func() (err error) {
+		if bar, err = n.GetBAR(ctx, pod, barName, podNs); err != nil {
+			return
+		}
+		if AnonymousAccess(bar) {
+			ctx = WithAnonymousAccess(ctx)
+		}
+		if ba, err = n.GetBA(ctx, pod, bar.Status.BucketAccessName); err != nil {
+			return
+		}
+		bkt, err = n.GetB(ctx, pod, ba.Spec.BucketName)
 		return
-	}
-
-	if ba, err = n.GetBA(ctx, pod, bar.Status.BucketAccessName); err != nil {
+	})
+	if err != nil {
 		return
 	}
 
-	if bkt, err = n.GetB(ctx, pod, ba.Spec.BucketName); err != nil {
+	// Identity and anonymous access need no minted credentials: the secret is
+	// empty.
+	if ba.Status.MintedSecret == nil || AnonymousAccess(ba) || anonymousAccess(ctx) {
+		secret = &v1.Secret{}
+		util.EmitNormalEvent(n.recorder, pod, util.AllResourcesReady)
 		return
 	}
-
-	if secret, err = n.kubeClient.CoreV1().Secrets(ba.Status.MintedSecret.Namespace).Get(ctx, ba.Status.MintedSecret.Name, metav1.GetOptions{}); err != nil {
+	if secret, err = n.getSecret(ctx, ba.Status.MintedSecret.Namespace, ba.Status.MintedSecret.Name); err != nil {
 		util.EmitWarningEvent(n.recorder, pod, util.MintedSecretNotFound)
 		err = errors.Wrap(err, util.WrapErrorGetSecretFailed)
 		return
@@ -194,49 +284,75 @@ func (n *nodeClient) GetResources(ctx context.Context, barName, podName, podNs s
 
 func GetProtocol(bkt *v1alpha1.Bucket) ([]byte, error) {
 	klog.Infof("bucket protocol %+v", bkt.Spec.Protocol)
-	var (
-		data               []byte
-		err                error
-		protocolConnection interface{}
-	)
-
-	switch {
-	case bkt.Spec.Protocol.S3 != nil:
-		protocolConnection = bkt.Spec.Protocol.S3
-	case bkt.Spec.Protocol.AzureBlob != nil:
-		protocolConnection = bkt.Spec.Protocol.AzureBlob
-	case bkt.Spec.Protocol.GCS != nil:
-		protocolConnection = bkt.Spec.Protocol.GCS
-	default:
-		err = util.ErrorInvalidProtocol
+	block, err := protocol.First(bkt)
+	if err != nil {
+		return nil, util.LogErr(err)
 	}
-
+	data, err := block.Marshal()
 	if err != nil {
 		return nil, util.LogErr(err)
 	}
+	return data, nil
+}
 
-	if data, err = json.Marshal(protocolConnection); err != nil {
-		return nil, util.LogErr(errors.Wrap(err, util.WrapErrorMarshalProtocolFailed))
+func (n *nodeClient) AddBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer Finalizer) error {
+	_, err := NewFinalizerManager(n.cosiClient.BucketAccesses()).Add(ctx, ba, BAFinalizer)
+	if err != nil {
+		metrics.FinalizerUpdateFailures.WithLabelValues("add").Inc()
 	}
-	return data, nil
+	return err
 }
 
-func (n *nodeClient) AddBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
-	controllerutil.AddFinalizer(ba, BAFinalizer)
-	if _, err := n.cosiClient.BucketAccesses().Update(ctx, ba, metav1.UpdateOptions{}); err != nil {
-		return err
+func (n *nodeClient) RemoveBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer Finalizer) error {
+	_, err := NewFinalizerManager(n.cosiClient.BucketAccesses()).Remove(ctx, ba, BAFinalizer)
+	if err != nil {
+		metrics.FinalizerUpdateFailures.WithLabelValues("remove").Inc()
 	}
-	return nil
+	return err
 }
 
-func (n *nodeClient) RemoveBAFinalizer(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
-	controllerutil.RemoveFinalizer(ba, BAFinalizer)
-	if _, err := n.cosiClient.BucketAccesses().Update(ctx, ba, metav1.UpdateOptions{}); err != nil {
-		return err
+func (n *nodeClient) AddBAAnnotation(ctx context.Context, ba *v1alpha1.BucketAccess, key, value string) (*v1alpha1.BucketAccess, error) {
+	data, err := metadataApply("BucketAccess", ba.Name, ba.UID, map[string]interface{}{
+		"annotations": map[string]string{key: value},
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	// Annotations are applied by a field manager per key so that applying one
+	// never releases another.
+	force := true
+	countWrite(ctx)
+	return n.cosiClient.BucketAccesses().Patch(ctx, ba.Name, types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: scopedFieldManager(key),
+		Force:        &force,
+	})
+}
+
+func (n *nodeClient) LookupBA(ctx context.Context, baName string) (*v1alpha1.BucketAccess, error) {
+	return n.getBA(ctx, baName)
+}
+
+func (n *nodeClient) OnSecretChange(handler func(namespace, name string)) {
+	n.secrets.onChange(handler)
+}
+
+func (n *nodeClient) WatchSecrets(secrets []string) {
+	n.secrets.set(secrets)
 }
 
 func (n *nodeClient) Recorder() record.EventRecorder {
 	return n.recorder
 }
+
+// NewHealthCheck returns a check that the API server config points at is
+// reachable and serves the COSI API group.
+func NewHealthCheck(config *rest.Config) (func(ctx context.Context) error, error) {
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorCreateClientFailed)
+	}
+	return func(ctx context.Context) error {
+		return kube.Discovery().RESTClient().Get().AbsPath("/apis", v1alpha1.SchemeGroupVersion.String()).Do(ctx).Error()
+	}, nil
+}
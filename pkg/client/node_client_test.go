@@ -138,6 +138,24 @@ func TestGetBAR(t *testing.T) {
 	}
 }
 
+// identityAccessBA returns a BucketAccess granting identity access, without a
+// minted secret.
+func identityAccessBA() *v1alpha1.BucketAccess {
+	ba := testutils.GetBA()
+	ba.Annotations = map[string]string{IdentityAccessAnnotation: "true"}
+	ba.Status.MintedSecret = nil
+	return ba
+}
+
+// anonymousAccessBA returns a BucketAccess open to anonymous access, without a
+// minted secret.
+func anonymousAccessBA() *v1alpha1.BucketAccess {
+	ba := testutils.GetBA()
+	ba.Annotations = map[string]string{AnonymousAccessAnnotation: "true"}
+	ba.Status.MintedSecret = nil
+	return ba
+}
+
 func TestGetBA(t *testing.T) {
 	type args struct {
 		prepare func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface)
@@ -205,6 +223,28 @@ func TestGetBA(t *testing.T) {
 				err: util.ErrorBANoMintedSecret,
 			},
 		},
+		"IdentityAccess": {
+			args: args{
+				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
+					_, _ = cosi.BucketAccesses().Create(ctx, identityAccessBA(), metav1.CreateOptions{})
+				},
+				baName: "bucketAccessName",
+			},
+			want: want{
+				ba: identityAccessBA(),
+			},
+		},
+		"AnonymousAccess": {
+			args: args{
+				prepare: func(cs kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface) {
+					_, _ = cosi.BucketAccesses().Create(ctx, anonymousAccessBA(), metav1.CreateOptions{})
+				},
+				baName: "bucketAccessName",
+			},
+			want: want{
+				ba: anonymousAccessBA(),
+			},
+		},
 	}
 
 	for name, tc := range cases {
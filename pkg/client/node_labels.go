@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// NodeLabelWatcher reads the labels of the adapter's node and, once started,
+// hands every change of them to a callback.
+type NodeLabelWatcher struct {
+	kube     kubernetes.Interface
+	name     string
+	onChange func(labels map[string]string) error
+}
+
+// NewNodeLabelWatcher returns a NodeLabelWatcher of the named node.
+func NewNodeLabelWatcher(config *rest.Config, name string) (*NodeLabelWatcher, error) {
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorCreateClientFailed)
+	}
+	return &NodeLabelWatcher{kube: kube, name: name}, nil
+}
+
+// Labels returns the current labels of the node.
+func (w *NodeLabelWatcher) Labels(ctx context.Context) (map[string]string, error) {
+	node, err := w.kube.CoreV1().Nodes().Get(ctx, w.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(countAPIError("nodes", err), util.WrapErrorGetNodeFailed)
+	}
+	return node.Labels, nil
+}
+
+// OnChange sets the callback receiving the labels of the node whenever they
+// change. An error returned by f stops the watcher with it.
+func (w *NodeLabelWatcher) OnChange(f func(labels map[string]string) error) {
+	w.onChange = f
+}
+
+// Start watches the node until ctx is done or the callback fails.
+func (w *NodeLabelWatcher) Start(ctx context.Context) error {
+	changes := make(chan map[string]string, 1)
+	lw := cache.NewListWatchFromClient(w.kube.CoreV1().RESTClient(), "nodes", "", fields.OneTermEqualSelector("metadata.name", w.name))
+	_, informer := cache.NewInformer(lw, &v1.Node{}, 0, cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			old, node := oldObj.(*v1.Node), newObj.(*v1.Node)
+			if labelsEqual(old.Labels, node.Labels) {
+				return
+			}
+			// Only the latest labels matter.
+			select {
+			case <-changes:
+			default:
+			}
+			changes <- node.Labels
+		},
+	})
+	go informer.Run(ctx.Done())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case labels := <-changes:
+			if w.onChange == nil {
+				continue
+			}
+			if err := w.onChange(labels); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func labelsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// CredentialScopeKey selects whether the pod shares the BucketAccessRequest's
+	// credentials (the default) or gets its own pod-scoped BucketAccess.
+	CredentialScopeKey = adapter.CredentialScopeKey
+
+	CredentialScopeShared = string(adapter.CredentialScopeShared)
+	CredentialScopePod    = string(adapter.CredentialScopePod)
+
+	PodUIDLabel             = "cosi.objectstorage.k8s.io/pod-uid"
+	SharedBucketAccessLabel = "cosi.objectstorage.k8s.io/shared-bucket-access"
+
+	podBAPollInterval = time.Second
+)
+
+// PodBAName returns the name of the BucketAccess minted for a single pod.
+func PodBAName(shared *v1alpha1.BucketAccess, pod *v1.Pod) string {
+	return fmt.Sprintf("%s-%s", shared.Name, pod.UID)
+}
+
+// EnsurePodBA creates a BucketAccess scoped to pod, copied from the shared
+// BucketAccess granted to the pod's BucketAccessRequest. An existing pod-scoped
+// BucketAccess is returned as is so retried publishes are idempotent.
+//
+// The pod is recorded as the controller of the BucketAccess, so it is
+// collected with the pod when no unpublish deletes it.
+func (n *nodeClient) EnsurePodBA(ctx context.Context, shared *v1alpha1.BucketAccess, pod *v1.Pod) (*v1alpha1.BucketAccess, error) {
+	name := PodBAName(shared, pod)
+	klog.Infof("ensuring pod scoped bucketAccess %q", name)
+
+	controller := true
+	ba := &v1alpha1.BucketAccess{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				PodUIDLabel:             string(pod.UID),
+				SharedBucketAccessLabel: shared.Name,
+			},
+			// Blocking the deletion of the pod would need the permission to
+			// update its finalizers.
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       pod.Name,
+				UID:        pod.UID,
+				Controller: &controller,
+			}},
+		},
+		Spec: shared.DeepCopy().Spec,
+	}
+
+	countWrite(ctx)
+	created, err := n.cosiClient.BucketAccesses().Create(ctx, ba, metav1.CreateOptions{FieldManager: n.fieldManager})
+	if apierrors.IsAlreadyExists(err) {
+		created, err = n.cosiClient.BucketAccesses().Get(ctx, name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil, util.LogErr(errors.Wrap(err, util.WrapErrorCreatePodBAFailed))
+	}
+	return created, nil
+}
+
+// WaitForPodBA blocks until the provisioner has granted access and minted a
+// secret for the pod-scoped BucketAccess, or ctx is done. The secret is empty
+// for identity access, see IdentityAccessAnnotation.
+func (n *nodeClient) WaitForPodBA(ctx context.Context, pod *v1.Pod, baName string) (ba *v1alpha1.BucketAccess, secret *v1.Secret, err error) {
+	err = wait.PollImmediateUntil(podBAPollInterval, func() (bool, error) {
+		ba, err = n.cosiClient.BucketAccesses().Get(ctx, baName, metav1.GetOptions{})
+		if err != nil {
+			return false, errors.Wrap(err, util.WrapErrorGetBAFailed)
+		}
+		return ba.Status.AccessGranted && (ba.Status.MintedSecret != nil || IdentityAccess(ba)), nil
+	}, ctx.Done())
+	if err != nil {
+		util.EmitWarningEvent(n.recorder, pod, util.PodBANotGranted)
+		return nil, nil, util.LogErr(errors.Wrap(err, util.WrapErrorWaitPodBAFailed))
+	}
+	if ba.Status.MintedSecret == nil {
+		return ba, &v1.Secret{}, nil
+	}
+
+	secret, err = n.kubeClient.CoreV1().Secrets(ba.Status.MintedSecret.Namespace).Get(ctx, ba.Status.MintedSecret.Name, metav1.GetOptions{})
+	if err != nil {
+		util.EmitWarningEvent(n.recorder, pod, util.MintedSecretNotFound)
+		return nil, nil, errors.Wrap(err, util.WrapErrorGetSecretFailed)
+	}
+	return ba, secret, nil
+}
+
+// DeleteBA deletes a pod-scoped BucketAccess. A missing object is not an error.
+func (n *nodeClient) DeleteBA(ctx context.Context, baName string) error {
+	klog.Infof("deleting pod scoped bucketAccess %q", baName)
+	err := n.cosiClient.BucketAccesses().Delete(ctx, baName, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
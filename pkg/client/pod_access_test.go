@@ -0,0 +1,31 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	cosifake "sigs.k8s.io/container-object-storage-interface-api/clientset/fake"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestEnsurePodBAOwner(t *testing.T) {
+	pod := testutils.GetPod()
+	pod.UID = "uid"
+	shared := &v1alpha1.BucketAccess{ObjectMeta: metav1.ObjectMeta{Name: "ba"}}
+	nc := &nodeClient{cosiClient: cosifake.NewSimpleClientset().ObjectstorageV1alpha1()}
+
+	ba, err := nc.EnsurePodBA(ctx, shared, pod)
+	if err != nil {
+		t.Fatalf("EnsurePodBA(...): %v", err)
+	}
+
+	controller := true
+	want := []metav1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: pod.Name, UID: pod.UID, Controller: &controller}}
+	if diff := cmp.Diff(want, ba.OwnerReferences); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
@@ -12,6 +12,10 @@ type ProvisionerClient interface {
 	MkdirAll(path string, perm os.FileMode) error
 	RemoveAll(path string) error
 	WriteFile(data []byte, filepath string) error
+	WriteFileWithMode(data []byte, filepath string, mode os.FileMode) error
+	ReplaceFile(data []byte, filepath string) error
+	WriteAtomic(dir string, files []AtomicFile, gid int) error
+	SetOwnership(path string, mode os.FileMode, gid int) error
 	ReadFile(filename string) ([]byte, error)
 }
 
@@ -36,15 +40,37 @@ func (p provisionerClient) RemoveAll(path string) error {
 }
 
 func (p provisionerClient) WriteFile(data []byte, filepath string) error {
-	file, err := os.OpenFile(filepath, os.O_CREATE|os.O_WRONLY|os.O_EXCL, os.FileMode(0440))
-	if err != nil {
+	return p.WriteFileWithMode(data, filepath, os.FileMode(0440))
+}
+
+// WriteFileWithMode creates filepath with data, failing if it exists. The file
+// never appears partially written.
+func (p provisionerClient) WriteFileWithMode(data []byte, filepath string, mode os.FileMode) error {
+	if err := createFile(filepath, data, mode); err != nil {
 		return util.LogErr(errors.Wrap(err, util.WrapErrorCreatingFile))
 	}
+	return nil
+}
 
-	defer file.Close()
-	_, err = file.Write(data)
+// ReplaceFile writes data to filepath, replacing it if it exists. Readers see
+// either the old or the new content.
+func (p provisionerClient) ReplaceFile(data []byte, filepath string) error {
+	tmp, err := writeTemp(filepath, data, os.FileMode(0440))
 	if err != nil {
-		return util.LogErr(errors.Wrap(err, util.WrapErrorWritingToFile))
+		return util.LogErr(errors.Wrap(err, util.WrapErrorCreatingFile))
 	}
-	return nil
+	defer os.Remove(tmp)
+	return util.LogErr(errors.Wrap(os.Rename(tmp, filepath), util.WrapErrorCreatingFile))
+}
+
+// SetOwnership sets the permissions of path to mode, whatever the umask, and
+// its group to gid unless gid is negative.
+func (p provisionerClient) SetOwnership(path string, mode os.FileMode, gid int) error {
+	if err := os.Chmod(path, mode); err != nil {
+		return util.LogErr(errors.Wrap(err, util.WrapErrorSetOwnershipFailed))
+	}
+	if gid < 0 {
+		return nil
+	}
+	return util.LogErr(errors.Wrap(os.Lchown(path, -1, gid), util.WrapErrorSetOwnershipFailed))
 }
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/rest"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Permission is an API access the adapter needs, cluster wide.
+type Permission struct {
+	Verb     string
+	Group    string
+	Resource string
+	// Reason says what needs the permission, for the report of missing ones.
+	Reason string
+}
+
+func (p Permission) String() string {
+	if p.Group == "" {
+		return fmt.Sprintf("%s %s", p.Verb, p.Resource)
+	}
+	return fmt.Sprintf("%s %s.%s", p.Verb, p.Resource, p.Group)
+}
+
+// PermissionNeeds selects the optional behaviour whose permissions are
+// required.
+type PermissionNeeds struct {
+	// BucketConsumerCount patches Buckets with their consumer count.
+	BucketConsumerCount bool
+	// PodScopedCredentials creates and deletes BucketAccesses for pods.
+	PodScopedCredentials bool
+	// CredentialHotReload watches the minted secrets of published volumes.
+	CredentialHotReload bool
+}
+
+// RequiredPermissions returns the permissions the adapter needs with needs.
+func RequiredPermissions(needs PermissionNeeds) []Permission {
+	cosi := v1alpha1.SchemeGroupVersion.Group
+	var perms []Permission
+	add := func(group, resource, reason string, verbs ...string) {
+		for _, verb := range verbs {
+			perms = append(perms, Permission{Verb: verb, Group: group, Resource: resource, Reason: reason})
+		}
+	}
+
+	add("", "pods", "publish resolves the pod of a volume", "get")
+	add("", "nodes", "topology and feature labels of the node", "get", "list", "watch")
+	add("", "secrets", "publish reads the minted secret of a volume", "get")
+	add("", "secrets", "finalizers of minted secrets", "patch")
+	add("", "events", "events are emitted on pods", "create", "patch")
+	add(cosi, "bucketaccessrequests", "publish resolves the bucket access request of a volume", "get", "list", "watch")
+	add(cosi, "bucketrequests", "bucket requests are cached", "get", "list", "watch")
+	add(cosi, "buckets", "publish resolves the bucket of a volume", "get", "list", "watch")
+	add(cosi, "bucketclasses", "default formats of bucket classes", "get")
+	add(cosi, "bucketaccesses", "publish resolves the bucket access of a volume", "get", "list", "watch")
+	add(cosi, "bucketaccesses", "finalizers and annotations of bucket accesses", "patch", "update")
+	if needs.CredentialHotReload {
+		add("", "secrets", "feature gate CredentialHotReload", "list", "watch")
+	}
+	if needs.BucketConsumerCount {
+		add(cosi, "buckets", "feature gate BucketConsumerCount", "patch")
+	}
+	if needs.PodScopedCredentials {
+		add(cosi, "bucketaccesses", "--allow-pod-scoped-credentials", "create", "delete")
+	}
+	return perms
+}
+
+// CheckPermissions reviews every permission of perms with a
+// SelfSubjectAccessReview and returns those denied.
+func CheckPermissions(ctx context.Context, reviews authorizationclient.SelfSubjectAccessReviewInterface, perms []Permission) ([]Permission, error) {
+	var denied []Permission
+	for _, p := range perms {
+		review, err := reviews.Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:     p.Verb,
+					Group:    p.Group,
+					Resource: p.Resource,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, util.WrapErrorPermissionReviewFailed)
+		}
+		if !review.Status.Allowed {
+			denied = append(denied, p)
+		}
+	}
+	return denied, nil
+}
+
+// SelfCheckPermissions returns the permissions of needs denied to the
+// identity config authenticates as.
+func SelfCheckPermissions(ctx context.Context, config *rest.Config, needs PermissionNeeds) ([]Permission, error) {
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorCreateClientFailed)
+	}
+	return CheckPermissions(ctx, kube.AuthorizationV1().SelfSubjectAccessReviews(), RequiredPermissions(needs))
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	type want struct {
+		denied []string
+		err    bool
+	}
+
+	perms := []Permission{
+		{Verb: "get", Resource: "pods"},
+		{Verb: "patch", Group: "objectstorage.k8s.io", Resource: "buckets"},
+		{Verb: "delete", Group: "objectstorage.k8s.io", Resource: "bucketaccesses"},
+	}
+
+	cases := map[string]struct {
+		allowed   map[string]bool
+		reviewErr error
+		want
+	}{
+		"AllAllowed": {
+			allowed: map[string]bool{"get pods": true, "patch buckets.objectstorage.k8s.io": true, "delete bucketaccesses.objectstorage.k8s.io": true},
+		},
+		"SomeDenied": {
+			allowed: map[string]bool{"get pods": true},
+			want: want{denied: []string{
+				"patch buckets.objectstorage.k8s.io",
+				"delete bucketaccesses.objectstorage.k8s.io",
+			}},
+		},
+		"ReviewFailed": {
+			reviewErr: errBoom,
+			want:      want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kube := k8sfake.NewSimpleClientset()
+			kube.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if tc.reviewErr != nil {
+					return true, nil, tc.reviewErr
+				}
+				review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+				attrs := review.Spec.ResourceAttributes
+				p := Permission{Verb: attrs.Verb, Group: attrs.Group, Resource: attrs.Resource}
+				review.Status.Allowed = tc.allowed[p.String()]
+				return true, review, nil
+			})
+
+			denied, err := CheckPermissions(context.Background(), kube.AuthorizationV1().SelfSubjectAccessReviews(), perms)
+
+			got := want{err: err != nil}
+			for _, p := range denied {
+				got.denied = append(got.denied, p.String())
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
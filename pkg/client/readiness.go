@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	defaultReadinessInitialInterval = 500 * time.Millisecond
+	defaultReadinessMaxInterval     = 10 * time.Second
+)
+
+// ReadinessWait bounds how long GetResources waits for the BucketAccessRequest,
+// BucketAccess and Bucket of a volume to become ready before failing. The wait
+// never outlives the RPC context. A zero Timeout fails on the first attempt.
+type ReadinessWait struct {
+	Timeout         time.Duration
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+}
+
+// notReadyErrors are returned while the provisioner has yet to act on a
+// request. Anything else is terminal and returned without waiting.
+var notReadyErrors = []error{
+	util.ErrorBARNoAccess,
+	util.ErrorBARUnsetBA,
+	util.ErrorBANoAccess,
+	util.ErrorBANoMintedSecret,
+	util.ErrorBRNotAvailable,
+	util.ErrorBRUnsetBucketName,
+	util.ErrorBNotAvailable,
+}
+
+func notReady(err error) bool {
+	for _, e := range notReadyErrors {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	// The objects may be created after the pod that references them.
+	return apierrors.IsNotFound(errors.Cause(err))
+}
+
+// wait calls resolve until it succeeds, fails with an error other than
+// notReady, or the wait times out, backing off exponentially between calls.
+// The last error from resolve is returned.
+func (w ReadinessWait) wait(ctx context.Context, resolve func() error) error {
+	err := resolve()
+	if err == nil || w.Timeout <= 0 || !notReady(err) {
+		return err
+	}
+
+	interval, maxInterval := w.InitialInterval, w.MaxInterval
+	if interval <= 0 {
+		interval = defaultReadinessInitialInterval
+	}
+	if maxInterval <= 0 {
+		maxInterval = defaultReadinessMaxInterval
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, w.Timeout)
+	defer cancel()
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-waitCtx.Done():
+			klog.InfoS("gave up waiting for bucket resources to become ready", "timeout", w.Timeout)
+			return err
+		case <-timer.C:
+		}
+
+		klog.V(4).InfoS("bucket resources not ready, retrying", "err", err.Error())
+		if err = resolve(); err == nil || !notReady(err) {
+			return err
+		}
+
+		if interval *= 2; interval > maxInterval {
+			interval = maxInterval
+		}
+		timer.Reset(interval)
+	}
+}
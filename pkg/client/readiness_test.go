@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestReadinessWait(t *testing.T) {
+	errBoom := errors.New("boom")
+	notFound := apierrors.NewNotFound(schema.GroupResource{Resource: "bucketaccessrequests"}, "bar")
+
+	type want struct {
+		err   error
+		calls int
+	}
+
+	cases := map[string]struct {
+		timeout time.Duration
+		results []error
+		want
+	}{
+		"ReadyImmediately": {
+			timeout: time.Second,
+			results: []error{nil},
+			want:    want{calls: 1},
+		},
+		"NoWait": {
+			timeout: 0,
+			results: []error{util.ErrorBARNoAccess, nil},
+			want:    want{err: util.ErrorBARNoAccess, calls: 1},
+		},
+		"BecomesReady": {
+			timeout: time.Minute,
+			results: []error{util.ErrorBARNoAccess, errors.Wrap(notFound, util.WrapErrorGetBAFailed), util.ErrorBNotAvailable, nil},
+			want:    want{calls: 4},
+		},
+		"TerminalError": {
+			timeout: time.Minute,
+			results: []error{util.ErrorBARNoAccess, util.ErrorBARUnsetBR, nil},
+			want:    want{err: util.ErrorBARUnsetBR, calls: 2},
+		},
+		"OtherError": {
+			timeout: time.Minute,
+			results: []error{errBoom, nil},
+			want:    want{err: errBoom, calls: 1},
+		},
+		"TimesOut": {
+			timeout: 20 * time.Millisecond,
+			results: []error{util.ErrorBANoAccess},
+			want:    want{err: util.ErrorBANoAccess},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := ReadinessWait{Timeout: tc.timeout, InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond}
+
+			calls := 0
+			err := w.wait(context.Background(), func() error {
+				r := tc.results[len(tc.results)-1]
+				if calls < len(tc.results) {
+					r = tc.results[calls]
+				}
+				calls++
+				return r
+			})
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if tc.want.calls > 0 {
+				if diff := cmp.Diff(tc.want.calls, calls); diff != "" {
+					t.Errorf("r: -want, +got:\n%s", diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// AddSecretFinalizer ensures f is set on the minted secret, so it outlives its
+// BucketAccess until every volume projecting it is unpublished. Like those of
+// BucketAccesses, the finalizer is applied by a field manager of its own.
+func (n *nodeClient) AddSecretFinalizer(ctx context.Context, secret *v1.Secret, f Finalizer) error {
+	if hasObjectFinalizer(secret, f) {
+		return nil
+	}
+	err := n.applySecretFinalizer(ctx, secret.Namespace, secret.Name, secret.UID, f, true)
+	if err != nil {
+		metrics.FinalizerUpdateFailures.WithLabelValues("add").Inc()
+	}
+	return err
+}
+
+// RemoveSecretFinalizer ensures f is not set on the secret namespace/name. A
+// secret that no longer exists is left alone. The secret is read from the API
+// server rather than the cache, which may not show the finalizer yet.
+func (n *nodeClient) RemoveSecretFinalizer(ctx context.Context, namespace, name string, f Finalizer) error {
+	secret, err := n.kubeClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(countAPIError(string(kindSecret), err), util.WrapErrorGetSecretFailed)
+	}
+	if !hasObjectFinalizer(secret, f) {
+		return nil
+	}
+	err = n.applySecretFinalizer(ctx, namespace, name, secret.UID, f, false)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		metrics.FinalizerUpdateFailures.WithLabelValues("remove").Inc()
+	}
+	return err
+}
+
+// applySecretFinalizer sets or releases f as the only field of f's field
+// manager on the secret with uid.
+func (n *nodeClient) applySecretFinalizer(ctx context.Context, namespace, name string, uid types.UID, f Finalizer, set bool) error {
+	fields := map[string]interface{}{"namespace": namespace}
+	if set {
+		fields["finalizers"] = []string{string(f)}
+	}
+	data, err := objectMetadataApply("v1", "Secret", name, uid, fields)
+	if err != nil {
+		return err
+	}
+
+	force := true
+	countWrite(ctx)
+	_, err = n.kubeClient.CoreV1().Secrets(namespace).Patch(ctx, name, types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: scopedFieldManager(string(f)),
+		Force:        &force,
+	})
+	return err
+}
+
+func hasObjectFinalizer(obj metav1.Object, f Finalizer) bool {
+	for _, existing := range obj.GetFinalizers() {
+		if existing == string(f) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// secretWatches watches the minted secrets of the volumes on the node, each
+// by its name, so the node neither lists nor holds any other secret.
+//
+// A nil *secretWatches watches nothing.
+type secretWatches struct {
+	kube kubernetes.Interface
+
+	mu      sync.Mutex
+	handler func(namespace, name string)
+	stops   map[string]chan struct{}
+}
+
+func newSecretWatches(kube kubernetes.Interface) *secretWatches {
+	return &secretWatches{kube: kube, stops: map[string]chan struct{}{}}
+}
+
+// onChange calls handler for every watched secret whose data changes.
+func (w *secretWatches) onChange(handler func(namespace, name string)) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handler = handler
+}
+
+// set watches exactly secrets, given as "namespace/name", starting the
+// watches of new ones and stopping those no longer listed.
+func (w *secretWatches) set(secrets []string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	wanted := map[string]bool{}
+	for _, secret := range secrets {
+		parts := strings.SplitN(secret, "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		wanted[secret] = true
+		if _, ok := w.stops[secret]; ok {
+			continue
+		}
+		stop := make(chan struct{})
+		w.stops[secret] = stop
+		go w.informer(parts[0], parts[1]).Run(stop)
+	}
+	for secret, stop := range w.stops {
+		if !wanted[secret] {
+			close(stop)
+			delete(w.stops, secret)
+		}
+	}
+}
+
+// informer returns an informer of the single secret namespace/name.
+func (w *secretWatches) informer(namespace, name string) cache.SharedIndexInformer {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = selector
+			return w.kube.CoreV1().Secrets(namespace).List(context.Background(), opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = selector
+			return w.kube.CoreV1().Secrets(namespace).Watch(context.Background(), opts)
+		},
+	}
+	inf := cache.NewSharedIndexInformer(lw, &v1.Secret{}, 0, cache.Indexers{})
+	inf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, obj interface{}) {
+			before, ok := old.(*v1.Secret)
+			after, ok2 := obj.(*v1.Secret)
+			if !ok || !ok2 || reflect.DeepEqual(before.Data, after.Data) {
+				return
+			}
+			w.mu.Lock()
+			handler := w.handler
+			w.mu.Unlock()
+			if handler != nil {
+				handler(after.Namespace, after.Name)
+			}
+		},
+	})
+	return inf
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSecretWatches(t *testing.T) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "minted", Namespace: "ns"}}
+	kube := k8sfake.NewSimpleClientset(secret)
+
+	changed := make(chan string, 10)
+	w := newSecretWatches(kube)
+	w.onChange(func(namespace, name string) { changed <- namespace + "/" + name })
+	w.set([]string{"ns/minted", "invalid"})
+	defer w.set(nil)
+
+	if diff := cmp.Diff(1, len(w.stops)); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	// Changes made before the watch is established are not seen, so the
+	// secret is rotated until one is.
+	rotations := 0
+	var got string
+	if err := wait.PollImmediate(50*time.Millisecond, 10*time.Second, func() (bool, error) {
+		select {
+		case got = <-changed:
+			return true, nil
+		default:
+		}
+		rotations++
+		rotated := secret.DeepCopy()
+		rotated.Data = map[string][]byte{"key": []byte(fmt.Sprint(rotations))}
+		_, err := kube.CoreV1().Secrets("ns").Update(ctx, rotated, metav1.UpdateOptions{})
+		return false, err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("ns/minted", got); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	w.set(nil)
+	if diff := cmp.Diff(0, len(w.stops)); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	var none *secretWatches
+	none.onChange(func(namespace, name string) {})
+	none.set([]string{"ns/minted"})
+}
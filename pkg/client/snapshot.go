@@ -0,0 +1,116 @@
+package client
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+)
+
+// Snapshot sources.
+const (
+	// SnapshotCache is a version the informer cache held when it was used.
+	SnapshotCache = "cache"
+	// SnapshotLive is a version read from the API server and not held by the
+	// cache, such as a newer one or one the cache has not synced yet.
+	SnapshotLive = "live"
+)
+
+// Snapshot describes the version of an object a publish was resolved from, so
+// stale cached data can be ruled in or out when investigating an incident.
+type Snapshot struct {
+	ResourceVersion string `json:"resourceVersion"`
+	Source          string `json:"source"`
+	// AgeSeconds is how long before use the cache observed this version. It
+	// is zero for live reads.
+	AgeSeconds float64 `json:"ageSeconds"`
+}
+
+// observation is when the cache first saw a version of an object.
+type observation struct {
+	resourceVersion string
+	at              time.Time
+}
+
+// observe records obj as seen now, unless the cache already holds its version:
+// resyncs redeliver unchanged objects, which must not reset their age.
+func (c *objectCache) observe(kind resourceKind, obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if o, ok := c.observed[kind][key]; ok && o.resourceVersion == m.GetResourceVersion() {
+		return
+	}
+	c.observed[kind][key] = observation{resourceVersion: m.GetResourceVersion(), at: time.Now()}
+}
+
+func (c *objectCache) forget(kind resourceKind, obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.observed[kind], key)
+}
+
+// observedAt returns when the cache saw the given version of an object, if it
+// holds that version.
+func (c *objectCache) observedAt(kind resourceKind, key, resourceVersion string) (time.Time, bool) {
+	if c == nil {
+		return time.Time{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	o, ok := c.observed[kind][key]
+	if !ok || o.resourceVersion != resourceVersion {
+		return time.Time{}, false
+	}
+	return o.at, true
+}
+
+// Snapshot describes the version of obj, a BucketAccessRequest, BucketRequest,
+// BucketAccess, Bucket or Secret returned by this client. Secrets are always
+// read live.
+func (n *nodeClient) Snapshot(obj runtime.Object) Snapshot {
+	m, err := meta.Accessor(obj)
+	if err != nil {
+		return Snapshot{}
+	}
+	s := Snapshot{ResourceVersion: m.GetResourceVersion(), Source: SnapshotLive}
+
+	var kind resourceKind
+	switch obj.(type) {
+	case *v1alpha1.BucketAccessRequest:
+		kind = kindBAR
+	case *v1alpha1.BucketRequest:
+		kind = kindBR
+	case *v1alpha1.BucketAccess:
+		kind = kindBA
+	case *v1alpha1.Bucket:
+		kind = kindBucket
+	default:
+		return s
+	}
+
+	key := m.GetName()
+	if m.GetNamespace() != "" {
+		key = m.GetNamespace() + "/" + key
+	}
+	if at, ok := n.cache.observedAt(kind, key, s.ResourceVersion); ok {
+		s.Source = SnapshotCache
+		s.AgeSeconds = time.Since(at).Seconds()
+	}
+	return s
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// DefaultSTSTimeout bounds an AssumeRoleWithWebIdentity call.
+	DefaultSTSTimeout = 10 * time.Second
+
+	stsAPIVersion = "2011-06-15"
+	// maxSTSResponseSize bounds the response read from an STS, which is a few
+	// kilobytes at most.
+	maxSTSResponseSize = 1 << 20
+)
+
+// STSCredentials are temporary credentials issued by an STS.
+type STSCredentials struct {
+	AccessKeyID     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey"`
+	SessionToken    string    `xml:"SessionToken"`
+	Expiration      time.Time `xml:"Expiration"`
+}
+
+type assumeRoleWithWebIdentityResponse struct {
+	Result struct {
+		Credentials STSCredentials `xml:"Credentials"`
+	} `xml:"AssumeRoleWithWebIdentityResult"`
+}
+
+type stsErrorResponse struct {
+	Error struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+}
+
+// STSClient exchanges web identity tokens for temporary credentials with the
+// AssumeRoleWithWebIdentity action of AWS STS, also served by S3 compatible
+// stores. The action is not signed: the token is the proof of identity.
+type STSClient struct {
+	httpClient *http.Client
+}
+
+func NewSTSClient(timeout time.Duration) *STSClient {
+	return &STSClient{httpClient: &http.Client{Timeout: timeout}}
+}
+
+// AssumeRoleWithWebIdentity assumes roleARN at endpoint with token, naming the
+// session sessionName.
+func (c *STSClient) AssumeRoleWithWebIdentity(ctx context.Context, endpoint, roleARN, sessionName, token string) (*STSCredentials, error) {
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {stsAPIVersion},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {token},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorAssumeRoleFailed)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorAssumeRoleFailed)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSTSResponseSize))
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorAssumeRoleFailed)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e stsErrorResponse
+		if xml.Unmarshal(body, &e) == nil && e.Error.Code != "" {
+			return nil, fmt.Errorf(util.ErrorTemplateSTSRejected, e.Error.Code, e.Error.Message)
+		}
+		return nil, fmt.Errorf(util.ErrorTemplateSTSStatus, resp.StatusCode)
+	}
+
+	var r assumeRoleWithWebIdentityResponse
+	if err := xml.Unmarshal(body, &r); err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorAssumeRoleFailed)
+	}
+	creds := r.Result.Credentials
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, util.ErrorSTSNoCredentials
+	}
+	return &creds, nil
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestAssumeRoleWithWebIdentity(t *testing.T) {
+	type want struct {
+		creds *STSCredentials
+		err   error
+	}
+
+	expiration := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		status int
+		body   string
+		want
+	}{
+		"Issued": {
+			status: http.StatusOK,
+			body: `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>AKIATEMP</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>session</SessionToken>
+      <Expiration>2021-06-01T12:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`,
+			want: want{creds: &STSCredentials{AccessKeyID: "AKIATEMP", SecretAccessKey: "secret", SessionToken: "session", Expiration: expiration}},
+		},
+		"Rejected": {
+			status: http.StatusBadRequest,
+			body:   `<ErrorResponse><Error><Code>InvalidIdentityToken</Code><Message>token expired</Message></Error></ErrorResponse>`,
+			want:   want{err: fmt.Errorf(util.ErrorTemplateSTSRejected, "InvalidIdentityToken", "token expired")},
+		},
+		"Unavailable": {
+			status: http.StatusServiceUnavailable,
+			want:   want{err: fmt.Errorf(util.ErrorTemplateSTSStatus, http.StatusServiceUnavailable)},
+		},
+		"NoCredentials": {
+			status: http.StatusOK,
+			body:   `<AssumeRoleWithWebIdentityResponse></AssumeRoleWithWebIdentityResponse>`,
+			want:   want{err: util.ErrorSTSNoCredentials},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Error(err)
+				}
+				if diff := cmp.Diff("AssumeRoleWithWebIdentity", r.PostForm.Get("Action")); diff != "" {
+					t.Errorf("r: -want, +got:\n%s", diff)
+				}
+				if diff := cmp.Diff("token", r.PostForm.Get("WebIdentityToken")); diff != "" {
+					t.Errorf("r: -want, +got:\n%s", diff)
+				}
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			creds, err := NewSTSClient(time.Second).AssumeRoleWithWebIdentity(context.Background(), srv.URL, "arn:aws:iam::123456789012:role/reader", "cosi-ns-pod", "token")
+
+			got := want{creds: creds, err: err}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{}), util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type writeCountKey struct{}
+
+// WithWriteCount returns a context counting the API writes the client makes
+// with it, and a function returning the count so far. NodePublishVolume uses
+// it to observe its write budget: it applies the finalizer as the only write
+// to a shared BucketAccess, skipping it when the finalizer is already set, and
+// creates a pod scoped BucketAccess. Bucket annotations are applied in batches
+// outside of publishes.
+func WithWriteCount(ctx context.Context) (context.Context, func() int) {
+	n := new(int32)
+	return context.WithValue(ctx, writeCountKey{}, n), func() int {
+		return int(atomic.LoadInt32(n))
+	}
+}
+
+// countWrite counts a write made with ctx, if it counts writes.
+func countWrite(ctx context.Context) {
+	if n, ok := ctx.Value(writeCountKey{}).(*int32); ok {
+		atomic.AddInt32(n, 1)
+	}
+}
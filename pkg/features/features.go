@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package features holds the feature gates of the adapter. Gates guard
+// behaviour that is new or has a cost operators should opt into.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Feature is the name of a feature gate.
+type Feature string
+
+const (
+	// BucketConsumerCount annotates Buckets with the number of volumes on
+	// each node consuming them. Every change costs a write to the Bucket.
+	BucketConsumerCount Feature = "BucketConsumerCount"
+	// VolumeContextValidation serves the ValidateVolumeContext dry run on the
+	// CSI socket, for controllers to validate pod specs before scheduling.
+	VolumeContextValidation Feature = "VolumeContextValidation"
+	// ProtocolDefaultFormats renders the formats of the native tools of a
+	// Bucket's protocol into volumes that no other setting selects formats
+	// for. Existing volumes gain files on their next publish.
+	ProtocolDefaultFormats Feature = "ProtocolDefaultFormats"
+	// CredentialHotReload rewrites the files of published volumes when their
+	// minted secret changes, unless their rotation policy is disabled, and on
+	// their refresh interval, which renews temporary credentials before they
+	// expire. It watches every secret in the cluster.
+	CredentialHotReload Feature = "CredentialHotReload"
+	// FailureInjection honors the failure injection annotation of pods in
+	// the namespaces allowed with --failure-injection-namespaces, for game
+	// days exercising how applications cope with credential failures.
+	FailureInjection Feature = "FailureInjection"
+)
+
+// defaults lists every known feature and whether it is enabled by default.
+var defaults = map[Feature]bool{
+	BucketConsumerCount:     false,
+	VolumeContextValidation: false,
+	ProtocolDefaultFormats:  false,
+	CredentialHotReload:     false,
+	FailureInjection:        false,
+}
+
+// Gates records which features are enabled. The zero value has every feature
+// at its default.
+type Gates map[Feature]bool
+
+// Enabled reports whether f is enabled.
+func (g Gates) Enabled(f Feature) bool {
+	if enabled, ok := g[f]; ok {
+		return enabled
+	}
+	return defaults[f]
+}
+
+// Parse reads a comma separated list of Feature=bool pairs, as passed to
+// --feature-gates. Unknown features are rejected.
+func Parse(s string) (Gates, error) {
+	g := Gates{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		f := Feature(strings.TrimSpace(kv[0]))
+		if _, ok := defaults[f]; !ok {
+			return nil, fmt.Errorf(util.ErrorTemplateUnknownFeature, f, Known())
+		}
+		if len(kv) != 2 {
+			return nil, fmt.Errorf(util.ErrorTemplateInvalidFeatureGate, pair)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf(util.ErrorTemplateInvalidFeatureGate, pair)
+		}
+		g[f] = enabled
+	}
+	return g, nil
+}
+
+// Known returns the names of every feature gate, sorted.
+func Known() []string {
+	names := make([]string, 0, len(defaults))
+	for f := range defaults {
+		names = append(names, string(f))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NodeLabelPrefix prefixes node labels enabling or disabling a feature on the
+// labelled node alone, such as
+// features.csi-adapter.objectstorage.k8s.io/BucketConsumerCount=false. They
+// take precedence over --feature-gates, so one DaemonSet can run differently
+// on different kinds of nodes.
+const NodeLabelPrefix = "features.csi-adapter.objectstorage.k8s.io/"
+
+// FromNodeLabels returns the gates set by the NodeLabelPrefix labels of a node.
+// Other labels are ignored; unknown features are rejected.
+func FromNodeLabels(labels map[string]string) (Gates, error) {
+	g := Gates{}
+	for k, v := range labels {
+		if !strings.HasPrefix(k, NodeLabelPrefix) {
+			continue
+		}
+		f := Feature(strings.TrimPrefix(k, NodeLabelPrefix))
+		if _, ok := defaults[f]; !ok {
+			return nil, fmt.Errorf(util.ErrorTemplateUnknownFeature, f, Known())
+		}
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf(util.ErrorTemplateInvalidFeatureLabel, v, k)
+		}
+		g[f] = enabled
+	}
+	return g, nil
+}
+
+// Override returns the gates of g with those set in overrides replaced.
+func (g Gates) Override(overrides Gates) Gates {
+	merged := make(Gates, len(g)+len(overrides))
+	for f, enabled := range g {
+		merged[f] = enabled
+	}
+	for f, enabled := range overrides {
+		merged[f] = enabled
+	}
+	return merged
+}
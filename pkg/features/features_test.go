@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package features
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestParse(t *testing.T) {
+	type want struct {
+		enabled bool
+		err     error
+	}
+
+	cases := map[string]struct {
+		gates string
+		want
+	}{
+		"Default": {
+			gates: "",
+			want:  want{enabled: false},
+		},
+		"Enabled": {
+			gates: "BucketConsumerCount=true",
+			want:  want{enabled: true},
+		},
+		"Disabled": {
+			gates: " BucketConsumerCount = false ",
+			want:  want{enabled: false},
+		},
+		"Unknown": {
+			gates: "Teleport=true",
+			want:  want{err: fmt.Errorf(util.ErrorTemplateUnknownFeature, "Teleport", Known())},
+		},
+		"NotABool": {
+			gates: "BucketConsumerCount=maybe",
+			want:  want{err: fmt.Errorf(util.ErrorTemplateInvalidFeatureGate, "BucketConsumerCount=maybe")},
+		},
+		"MissingValue": {
+			gates: "BucketConsumerCount",
+			want:  want{err: fmt.Errorf(util.ErrorTemplateInvalidFeatureGate, "BucketConsumerCount")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g, err := Parse(tc.gates)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.enabled, g.Enabled(BucketConsumerCount)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFromNodeLabels(t *testing.T) {
+	type want struct {
+		gates Gates
+		err   error
+	}
+
+	cases := map[string]struct {
+		flags  Gates
+		labels map[string]string
+		want
+	}{
+		"NoLabels": {
+			flags:  Gates{BucketConsumerCount: true},
+			labels: map[string]string{"kubernetes.io/hostname": "node"},
+			want:   want{gates: Gates{BucketConsumerCount: true}},
+		},
+		"LabelOverridesFlag": {
+			flags: Gates{BucketConsumerCount: true, VolumeContextValidation: true},
+			labels: map[string]string{
+				NodeLabelPrefix + "BucketConsumerCount":    "false",
+				NodeLabelPrefix + "ProtocolDefaultFormats": "true",
+			},
+			want: want{gates: Gates{BucketConsumerCount: false, VolumeContextValidation: true, ProtocolDefaultFormats: true}},
+		},
+		"Unknown": {
+			labels: map[string]string{NodeLabelPrefix + "Teleport": "true"},
+			want:   want{err: fmt.Errorf(util.ErrorTemplateUnknownFeature, "Teleport", Known())},
+		},
+		"NotABool": {
+			labels: map[string]string{NodeLabelPrefix + "BucketConsumerCount": "edge"},
+			want:   want{err: fmt.Errorf(util.ErrorTemplateInvalidFeatureLabel, "edge", NodeLabelPrefix+"BucketConsumerCount")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g, err := FromNodeLabels(tc.labels)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.gates, tc.flags.Override(g)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
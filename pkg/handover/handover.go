@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package handover lets a new adapter process take over the sockets of the
+// process it replaces on the same node, so rolling upgrades have no window in
+// which connections are refused.
+package handover
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const supersededPollInterval = 2 * time.Second
+
+// Listener is a listener bound by Listen. For unix sockets it remembers the
+// socket it bound, so the process can tell when a newer one has taken the
+// path over.
+type Listener struct {
+	net.Listener
+	path  string
+	bound os.FileInfo
+}
+
+// Listen listens on addr without disturbing a process already serving it.
+//
+// Unix sockets are bound at a unique path next to addr and renamed over it.
+// The rename is atomic: connections made before it reach the previous
+// process, connections made after it reach this one, and none are refused.
+// TCP sockets set SO_REUSEPORT so both processes can be bound during the
+// handover.
+func Listen(proto, addr string) (*Listener, error) {
+	if proto != "unix" {
+		lc := net.ListenConfig{Control: reusePort}
+		l, err := lc.Listen(context.Background(), proto, addr)
+		if err != nil {
+			return nil, errors.Wrap(err, util.WrapErrorHandoverListenFailed)
+		}
+		return &Listener{Listener: l}, nil
+	}
+
+	tmp := fmt.Sprintf("%s.%s", addr, strconv.FormatInt(time.Now().UnixNano(), 36))
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorHandoverListenFailed)
+	}
+	// The socket only ever lives at addr once renamed, and must stay there
+	// for the next process when this one closes it.
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	bound, err := os.Stat(tmp)
+	if err == nil {
+		err = os.Rename(tmp, addr)
+	}
+	if err != nil {
+		l.Close()
+		os.Remove(tmp)
+		return nil, errors.Wrap(err, util.WrapErrorHandoverListenFailed)
+	}
+	return &Listener{Listener: l, path: addr, bound: bound}, nil
+}
+
+// Superseded reports whether a newer process has taken the socket path over.
+// It is always false for TCP listeners, which share the port instead.
+func (l *Listener) Superseded() bool {
+	if l.bound == nil {
+		return false
+	}
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(l.bound, info)
+}
+
+// WaitSuperseded blocks until a newer process has taken the socket path over,
+// returning true, or ctx is done, returning false.
+func (l *Listener) WaitSuperseded(ctx context.Context) bool {
+	t := time.NewTicker(supersededPollInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-t.C:
+			if l.Superseded() {
+				return true
+			}
+		}
+	}
+}
+
+// Remove deletes the socket path, unless a newer process has taken it over.
+func (l *Listener) Remove() error {
+	if l.bound == nil || l.Superseded() {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package handover
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListenUnix(t *testing.T) {
+	type want struct {
+		oldSuperseded bool
+		newSuperseded bool
+		exists        bool
+	}
+
+	cases := map[string]struct {
+		handover bool
+		remove   string
+		want
+	}{
+		"NoHandover": {
+			want: want{
+				oldSuperseded: false,
+				exists:        true,
+			},
+		},
+		"NoHandoverRemove": {
+			remove: "old",
+			want: want{
+				oldSuperseded: false,
+				exists:        false,
+			},
+		},
+		"Handover": {
+			handover: true,
+			want: want{
+				oldSuperseded: true,
+				newSuperseded: false,
+				exists:        true,
+			},
+		},
+		"HandoverOldRemoveKeepsPath": {
+			handover: true,
+			remove:   "old",
+			want: want{
+				oldSuperseded: true,
+				newSuperseded: false,
+				exists:        true,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			addr := filepath.Join(t.TempDir(), "csi.sock")
+
+			old, err := Listen("unix", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer old.Close()
+
+			current := old
+			if tc.handover {
+				current, err = Listen("unix", addr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer current.Close()
+			}
+
+			if diff := cmp.Diff(tc.want.oldSuperseded, old.Superseded()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.newSuperseded, current.Superseded()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			// New connections reach the process that bound the path last.
+			accepted := make(chan struct{})
+			go func() {
+				if c, err := current.Accept(); err == nil {
+					c.Close()
+					close(accepted)
+				}
+			}()
+			c, err := net.Dial("unix", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Close()
+			<-accepted
+
+			if tc.remove == "old" {
+				if err := old.Remove(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err = os.Stat(addr)
+			if diff := cmp.Diff(tc.want.exists, err == nil); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
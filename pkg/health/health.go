@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package health reports whether the adapter can reach the API server through
+// the standard grpc.health.v1.Health service.
+package health
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DefaultInterval is how often the check runs.
+	DefaultInterval = 10 * time.Second
+
+	checkTimeout = 5 * time.Second
+)
+
+// Check returns nil if the adapter is healthy.
+type Check func(ctx context.Context) error
+
+// Checker runs a Check periodically and serves its last outcome. Until the
+// first check completes the adapter is reported as not serving.
+type Checker struct {
+	check    Check
+	interval time.Duration
+	server   *health.Server
+	serving  int32
+}
+
+func NewChecker(check Check, interval time.Duration) *Checker {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	server := health.NewServer()
+	server.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	return &Checker{
+		check:    check,
+		interval: interval,
+		server:   server,
+	}
+}
+
+// Server returns the grpc.health.v1.Health service to register on the CSI
+// gRPC server.
+func (c *Checker) Server() healthpb.HealthServer {
+	return c.server
+}
+
+// Serving reports the outcome of the last check.
+func (c *Checker) Serving() bool {
+	return atomic.LoadInt32(&c.serving) == 1
+}
+
+// Start runs the check every interval until ctx is done.
+func (c *Checker) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.runCheck(ctx)
+		select {
+		case <-ctx.Done():
+			c.server.Shutdown()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Checker) runCheck(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	err := c.check(ctx)
+	status, serving := healthpb.HealthCheckResponse_SERVING, int32(1)
+	if err != nil {
+		status, serving = healthpb.HealthCheckResponse_NOT_SERVING, 0
+	}
+	if atomic.SwapInt32(&c.serving, serving) != serving {
+		klog.InfoS("health changed", "status", status.String(), "err", err)
+	}
+	c.server.SetServingStatus("", status)
+}
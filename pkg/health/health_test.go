@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestChecker(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	type want struct {
+		status  healthpb.HealthCheckResponse_ServingStatus
+		serving bool
+	}
+
+	cases := map[string]struct {
+		results []error
+		want
+	}{
+		"NotChecked": {
+			want: want{status: healthpb.HealthCheckResponse_NOT_SERVING},
+		},
+		"Healthy": {
+			results: []error{nil},
+			want:    want{status: healthpb.HealthCheckResponse_SERVING, serving: true},
+		},
+		"Unhealthy": {
+			results: []error{errBoom},
+			want:    want{status: healthpb.HealthCheckResponse_NOT_SERVING},
+		},
+		"Recovered": {
+			results: []error{errBoom, nil},
+			want:    want{status: healthpb.HealthCheckResponse_SERVING, serving: true},
+		},
+		"Lost": {
+			results: []error{nil, errBoom},
+			want:    want{status: healthpb.HealthCheckResponse_NOT_SERVING},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var next error
+			c := NewChecker(func(context.Context) error { return next }, 0)
+
+			for _, r := range tc.results {
+				next = r
+				c.runCheck(context.Background())
+			}
+
+			resp, err := c.Server().Check(context.Background(), &healthpb.HealthCheckRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.status, resp.GetStatus()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.serving, c.Serving()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -22,9 +22,20 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/golang/protobuf/ptypes/wrappers"
 )
 
-func NewIdentityServer(ident, version string, manifest map[string]string) (csi.IdentityServer, error) {
+// Keys of the manifest returned by GetPluginInfo.
+const (
+	ManifestGitCommit      = "gitCommit"
+	ManifestBuildDate      = "buildDate"
+	ManifestCOSIAPIVersion = "cosiAPIVersion"
+	// ManifestCredentialDeliveries lists the credential deliveries available
+	// on the node, comma separated.
+	ManifestCredentialDeliveries = "credentialDeliveries"
+)
+
+func NewIdentityServer(ident, version string, manifest map[string]string) (*IdentityServer, error) {
 	return &IdentityServer{
 		Identity: ident,
 		Version:  version,
@@ -36,6 +47,8 @@ type IdentityServer struct {
 	Identity string
 	Version  string
 	Manifest map[string]string
+	// Ready, when set, reports whether the driver can serve requests.
+	Ready func() bool
 }
 
 func (i *IdentityServer) GetPluginInfo(ctx context.Context, req *csi.GetPluginInfoRequest) (*csi.GetPluginInfoResponse, error) {
@@ -50,11 +63,15 @@ func (i *IdentityServer) GetPluginInfo(ctx context.Context, req *csi.GetPluginIn
 	return &csi.GetPluginInfoResponse{
 		Name:          i.Identity,
 		VendorVersion: i.Version,
+		Manifest:      i.Manifest,
 	}, nil
 }
 
 func (i *IdentityServer) Probe(ctx context.Context, req *csi.ProbeRequest) (*csi.ProbeResponse, error) {
-	return &csi.ProbeResponse{}, nil
+	if i.Ready == nil {
+		return &csi.ProbeResponse{}, nil
+	}
+	return &csi.ProbeResponse{Ready: &wrappers.BoolValue{Value: i.Ready()}}, nil
 }
 
 func (i *IdentityServer) GetPluginCapabilities(ctx context.Context, req *csi.GetPluginCapabilitiesRequest) (*csi.GetPluginCapabilitiesResponse, error) {
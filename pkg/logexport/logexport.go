@@ -0,0 +1,274 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logexport exports the adapter's klog output to an OpenTelemetry
+// collector, in addition to stderr, so logs can be aggregated without scraping
+// the node.
+//
+// Records are sent in batches as OTLP/HTTP with the JSON encoding, which any
+// collector with the otlp receiver accepts on its HTTP port (4318 by default).
+// Values that look like credentials are redacted before a record is queued.
+package logexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// DefaultPath is where collectors receive OTLP/HTTP logs.
+	DefaultPath = "/v1/logs"
+
+	scopeName = "sigs.k8s.io/container-object-storage-interface-csi-adapter"
+
+	batchSize     = 512
+	maxPending    = 8192
+	flushInterval = 5 * time.Second
+	exportTimeout = 10 * time.Second
+)
+
+// OTLP severity numbers of the klog severities.
+var severities = map[byte]struct {
+	number int
+	text   string
+}{
+	'I': {9, "INFO"},
+	'W': {13, "WARN"},
+	'E': {17, "ERROR"},
+	'F': {21, "FATAL"},
+}
+
+// redactions rewrite values that look like credentials. Keys are matched in
+// klog's key="value" and key=value forms as well as in "key: value" messages.
+var redactions = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)\b([\w.-]*(?:secret|token|password|passwd|credential|accesskey|access_key|privatekey|private_key|connectionstring)[\w.-]*)(=|:\s*)("(?:[^"\\]|\\.)*"|[^\s,]+)`), `${1}${2}"[REDACTED]"`},
+	{regexp.MustCompile(`(?i)\bbearer\s+[\w.~+/=-]+`), `Bearer [REDACTED]`},
+	{regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), `[REDACTED]`},
+}
+
+// Redact returns line with every value that looks like a credential replaced.
+func Redact(line string) string {
+	for _, r := range redactions {
+		line = r.re.ReplaceAllString(line, r.repl)
+	}
+	return line
+}
+
+// Exporter is an io.Writer receiving klog output and a manager.Runnable
+// exporting it. Records are dropped, oldest first, while the collector cannot
+// keep up, rather than blocking logging.
+type Exporter struct {
+	endpoint string
+	resource []keyValue
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending []logRecord
+	flush   chan struct{}
+	failing bool
+}
+
+// New returns an Exporter sending to endpoint, an http(s) URL. An endpoint
+// without a path is sent to DefaultPath. Resource attributes, such as
+// service.name, are attached to every batch.
+func New(endpoint string, resource map[string]string) (*Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf(util.ErrorTemplateInvalidLogEndpoint, endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = DefaultPath
+	}
+
+	e := &Exporter{
+		endpoint: u.String(),
+		client:   &http.Client{Timeout: exportTimeout},
+		flush:    make(chan struct{}, 1),
+	}
+	for _, k := range sortedKeys(resource) {
+		e.resource = append(e.resource, stringAttribute(k, resource[k]))
+	}
+	return e, nil
+}
+
+// Install sends klog output to e in addition to stderr. It must be called
+// before any goroutine logs, as it changes klog's flags.
+func Install(e *Exporter) error {
+	for name, value := range map[string]string{"logtostderr": "false", "alsologtostderr": "true"} {
+		if err := flag.Set(name, value); err != nil {
+			return err
+		}
+	}
+	// klog writes every record to the file of its own severity and those of
+	// all lower ones, so only the INFO output sees each record exactly once.
+	klog.SetOutputBySeverity("INFO", e)
+	for _, s := range []string{"WARNING", "ERROR", "FATAL"} {
+		klog.SetOutputBySeverity(s, ioutil.Discard)
+	}
+	return nil
+}
+
+// Write queues the klog formatted line p. It never fails, so that logging
+// never does.
+func (e *Exporter) Write(p []byte) (int, error) {
+	r := parse(string(p), time.Now())
+
+	e.mu.Lock()
+	if len(e.pending) >= maxPending {
+		e.pending = e.pending[1:]
+		metrics.LogRecordsDropped.Inc()
+	}
+	e.pending = append(e.pending, r)
+	full := len(e.pending) >= batchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Start exports the queued records every flush interval, or as soon as a
+// batch is full, until ctx is done, then exports what is left.
+func (e *Exporter) Start(ctx context.Context) error {
+	t := time.NewTicker(flushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), exportTimeout)
+			defer cancel()
+			e.export(final)
+			return nil
+		case <-t.C:
+		case <-e.flush:
+		}
+		e.export(ctx)
+	}
+}
+
+// export sends the queued records in batches. A failed batch is dropped: the
+// records are on stderr regardless, and retrying would only grow the queue.
+func (e *Exporter) export(ctx context.Context) {
+	for {
+		e.mu.Lock()
+		n := len(e.pending)
+		if n > batchSize {
+			n = batchSize
+		}
+		batch := e.pending[:n:n]
+		e.pending = e.pending[n:]
+		e.mu.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+
+		err := e.send(ctx, batch)
+		if err != nil {
+			metrics.LogRecordsDropped.Add(float64(len(batch)))
+		}
+		// Only transitions are logged, since the message is exported too.
+		if failing := err != nil; failing != e.failing {
+			e.failing = failing
+			if failing {
+				klog.ErrorS(err, "failed to export logs, dropping records until the collector recovers", "endpoint", e.endpoint)
+			} else {
+				klog.InfoS("log export recovered", "endpoint", e.endpoint)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (e *Exporter) send(ctx context.Context, batch []logRecord) error {
+	body, err := json.Marshal(exportRequest{ResourceLogs: []resourceLogs{{
+		Resource:  resource{Attributes: e.resource},
+		ScopeLogs: []scopeLogs{{Scope: scope{Name: scopeName}, LogRecords: batch}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorLogExportFailed)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Wrap(fmt.Errorf("collector returned %s", resp.Status), util.WrapErrorLogExportFailed)
+	}
+	return nil
+}
+
+// klogHeader matches the header klog writes before each message:
+// Lmmdd hh:mm:ss.uuuuuu threadid file:line]
+var klogHeader = regexp.MustCompile(`^([IWEF])\d{4} [\d:.]+\s+\d+ ([^:\s]+):(\d+)\] `)
+
+// parse turns a klog line into a log record, redacting its message. Lines
+// without a klog header, which klog never writes itself, are exported whole at
+// INFO.
+func parse(line string, now time.Time) logRecord {
+	line = strings.TrimRight(line, "\n")
+	r := logRecord{
+		TimeUnixNano:   strconv.FormatInt(now.UnixNano(), 10),
+		SeverityNumber: severities['I'].number,
+		SeverityText:   severities['I'].text,
+	}
+
+	m := klogHeader.FindStringSubmatch(line)
+	if m == nil {
+		r.Body = anyValue{StringValue: Redact(line)}
+		return r
+	}
+	s := severities[m[1][0]]
+	r.SeverityNumber, r.SeverityText = s.number, s.text
+	r.Body = anyValue{StringValue: Redact(line[len(m[0]):])}
+	r.Attributes = []keyValue{stringAttribute("code.filepath", m[2])}
+	if lineno, err := strconv.ParseInt(m[3], 10, 64); err == nil {
+		r.Attributes = append(r.Attributes, keyValue{Key: "code.lineno", Value: anyValue{IntValue: strconv.FormatInt(lineno, 10)}})
+	}
+	return r
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package manager coordinates the long running subsystems of the adapter.
+package manager
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+)
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// Runnable is a subsystem that runs until its context is cancelled. Returning
+// nil before that means the subsystem finished its work; returning an error
+// stops the whole manager.
+type Runnable interface {
+	Start(ctx context.Context) error
+}
+
+// RunnableFunc adapts a function to a Runnable.
+type RunnableFunc func(ctx context.Context) error
+
+func (f RunnableFunc) Start(ctx context.Context) error {
+	return f(ctx)
+}
+
+type namedRunnable struct {
+	name string
+	Runnable
+}
+
+// Manager starts runnables in the order they were added and stops them in the
+// reverse order, waiting for each to return before stopping the next. The
+// first error returned by any runnable shuts everything down and is returned
+// from Start.
+type Manager struct {
+	runnables       []namedRunnable
+	shutdownTimeout time.Duration
+}
+
+func New() *Manager {
+	return &Manager{
+		shutdownTimeout: defaultShutdownTimeout,
+	}
+}
+
+// Add registers r under name. It must be called before Start.
+func (m *Manager) Add(name string, r Runnable) {
+	m.runnables = append(m.runnables, namedRunnable{name: name, Runnable: r})
+}
+
+// Start runs every runnable and blocks until ctx is done or a runnable fails.
+func (m *Manager) Start(ctx context.Context) error {
+	type running struct {
+		name   string
+		cancel context.CancelFunc
+		done   chan struct{}
+	}
+
+	errCh := make(chan error, len(m.runnables))
+	started := make([]running, 0, len(m.runnables))
+
+	for _, r := range m.runnables {
+		// Every runnable gets its own context so they can be stopped in order.
+		rctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func(r namedRunnable) {
+			defer close(done)
+			if err := r.Start(rctx); err != nil {
+				errCh <- errors.Wrapf(err, "%s failed", r.name)
+				return
+			}
+			klog.V(4).InfoS("runnable returned", "name", r.name)
+		}(r)
+		klog.InfoS("started", "name", r.name)
+		started = append(started, running{name: r.name, cancel: cancel, done: done})
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		klog.InfoS("shutting down")
+	case err = <-errCh:
+		klog.ErrorS(err, "shutting down after failure")
+	}
+
+	for i := len(started) - 1; i >= 0; i-- {
+		r := started[i]
+		r.cancel()
+		select {
+		case <-r.done:
+			klog.InfoS("stopped", "name", r.name)
+		case <-time.After(m.shutdownTimeout):
+			klog.InfoS("timed out waiting to stop", "name", r.name, "timeout", m.shutdownTimeout)
+		}
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+var errBoom = errors.New("boom")
+
+func TestManager(t *testing.T) {
+	type want struct {
+		stopOrder []string
+		err       error
+	}
+
+	cases := map[string]struct {
+		failing string
+		want
+	}{
+		"ReverseStopOrder": {
+			want: want{
+				stopOrder: []string{"third", "second", "first"},
+			},
+		},
+		"ErrorStopsAll": {
+			failing: "second",
+			want: want{
+				stopOrder: []string{"third", "first"},
+				err:       errors.Wrap(errBoom, "second failed"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				stopped []string
+				ready   sync.WaitGroup
+			)
+
+			m := New()
+			for _, n := range []string{"first", "second", "third"} {
+				n := n
+				ready.Add(1)
+				m.Add(n, RunnableFunc(func(ctx context.Context) error {
+					ready.Done()
+					if n == tc.failing {
+						return errBoom
+					}
+					<-ctx.Done()
+					mu.Lock()
+					stopped = append(stopped, n)
+					mu.Unlock()
+					return nil
+				}))
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.failing == "" {
+				go func() {
+					ready.Wait()
+					cancel()
+				}()
+			}
+
+			err := m.Start(ctx)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.stopOrder, stopped); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics holds the Prometheus metrics exported by the adapter.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	namespace = "cosi"
+	subsystem = "csi_adapter"
+)
+
+var (
+	// Registry holds every metric of the adapter.
+	Registry = prometheus.NewRegistry()
+
+	// UnpublishUnknownVolume counts NodeUnpublishVolume calls for volumes this
+	// node has no record of publishing. A steady rate hints at kubelet and
+	// adapter state diverging.
+	UnpublishUnknownVolume = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "unpublish_unknown_volume_total",
+		Help:      "Number of NodeUnpublishVolume calls for volumes that were never published on this node.",
+	})
+
+	// DegradedMode is 1 while API server latency is high enough that optional
+	// work is being shed.
+	DegradedMode = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "degraded_mode",
+		Help:      "Whether optional work is being shed because of high API server latency.",
+	})
+
+	// ShedWork counts optional work skipped in degraded mode, by kind.
+	ShedWork = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "shed_work_total",
+		Help:      "Number of optional operations skipped while in degraded mode.",
+	}, []string{"kind"})
+
+	// MaterializedBuckets, MaterializedBucketAccesses and MaterializedSecrets
+	// count the distinct objects whose credentials are projected on the node.
+	MaterializedBuckets = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "materialized_buckets",
+		Help:      "Number of distinct buckets with credentials projected on this node.",
+	})
+	MaterializedBucketAccesses = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "materialized_bucket_accesses",
+		Help:      "Number of distinct BucketAccesses with credentials projected on this node.",
+	})
+	MaterializedSecrets = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "materialized_secrets",
+		Help:      "Number of distinct minted secrets projected on this node.",
+	})
+
+	// MaterializedByNamespace breaks the materialized objects down by the
+	// namespace of the consuming pods.
+	MaterializedByNamespace = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "materialized_objects_by_namespace",
+		Help:      "Number of distinct buckets, BucketAccesses and minted secrets projected on this node, by pod namespace.",
+	}, []string{"namespace", "kind"})
+
+	// CacheLookups counts informer cache lookups by resource and result (hit,
+	// miss or unsynced). Misses and unsynced lookups fall back to a live GET.
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "cache_lookups_total",
+		Help:      "Number of informer cache lookups by resource and result.",
+	}, []string{"resource", "result"})
+
+	// NodeOperations counts CSI node calls by operation and gRPC status code.
+	NodeOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "node_operations_total",
+		Help:      "Number of CSI node operations by operation and gRPC status code.",
+	}, []string{"operation", "code"})
+
+	// NodeOperationDuration observes the latency of CSI node calls.
+	NodeOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "node_operation_duration_seconds",
+		Help:      "Latency of CSI node operations by operation.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"operation"})
+
+	// APIErrors counts failed reads of COSI and core objects from the API
+	// server, by resource.
+	APIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "api_errors_total",
+		Help:      "Number of failed API server reads by resource.",
+	}, []string{"resource"})
+
+	// PublishedVolumes is the number of volumes with a bucket mounted on the
+	// node.
+	PublishedVolumes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "published_volumes",
+		Help:      "Number of volumes with a bucket mounted on this node.",
+	})
+
+	// FinalizerUpdateFailures counts BucketAccess finalizer updates that
+	// failed after retries, by operation (add or remove).
+	FinalizerUpdateFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "finalizer_update_failures_total",
+		Help:      "Number of BucketAccess finalizer updates that failed, by operation.",
+	}, []string{"operation"})
+
+	// PublishAPIWrites observes the number of API server writes made by each
+	// NodePublishVolume call.
+	PublishAPIWrites = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "publish_api_writes",
+		Help:      "Number of API server writes made by each NodePublishVolume call.",
+		Buckets:   []float64{0, 1, 2, 3, 4, 6, 8},
+	})
+
+	// ClockSkew is the last measured offset of the node clock from the API
+	// server clock, positive when the node is ahead.
+	ClockSkew = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "clock_skew_seconds",
+		Help:      "Offset of the node clock from the API server clock, positive when the node is ahead.",
+	})
+
+	// SnapshotAge observes how long before a publish the adapter learned of
+	// the version of each object it used, by kind and by source, cache or
+	// live. Live reads are observed as zero.
+	SnapshotAge = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "publish_snapshot_age_seconds",
+		Help:      "Age of the BucketAccess, Bucket and minted secret versions used by publishes, by kind and source.",
+		Buckets:   []float64{1, 10, 60, 300, 600, 1800, 3600, 21600, 86400},
+	}, []string{"kind", "source"})
+
+	// CredentialRefreshes counts rewrites of the files of published volumes
+	// after their minted secret changed, by result.
+	CredentialRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "credential_refreshes_total",
+		Help:      "Number of rewrites of published volumes after their minted secret changed, by result.",
+	}, []string{"result"})
+
+	// LogRecordsDropped counts log records that were not exported to the
+	// OpenTelemetry collector, because it failed or could not keep up.
+	LogRecordsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "log_records_dropped_total",
+		Help:      "Number of log records dropped instead of exported to the OpenTelemetry collector.",
+	})
+
+	// CanaryProbes counts the synthetic publishes of the canary volume, by
+	// result (success or failure).
+	CanaryProbes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "canary_probes_total",
+		Help:      "Number of synthetic publish, read and unpublish probes of the canary volume, by result.",
+	}, []string{"result"})
+
+	// CanaryProbeDuration observes how long a canary probe took end to end.
+	CanaryProbeDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "canary_probe_duration_seconds",
+		Help:      "Latency of synthetic publish, read and unpublish probes of the canary volume.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	})
+
+	// CanaryLastSuccess is the Unix time of the last successful canary probe.
+	CanaryLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "canary_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful probe of the canary volume.",
+	})
+
+	// ThrottledPublishes counts publishes refused by the rate limit of the
+	// namespace of their pod.
+	ThrottledPublishes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "throttled_publishes_total",
+		Help:      "Number of NodePublishVolume calls refused by the rate limit of the pod namespace, by namespace.",
+	}, []string{"namespace"})
+
+	// MissingPermissions is 1 for every API permission the adapter needs but
+	// was denied by the startup RBAC self-check.
+	MissingPermissions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "missing_permissions",
+		Help:      "API permissions the adapter needs but was denied at startup, by verb and resource.",
+	}, []string{"verb", "resource"})
+)
+
+// Collectors returns every metric of the adapter.
+func Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		UnpublishUnknownVolume,
+		DegradedMode,
+		ShedWork,
+		MaterializedBuckets,
+		MaterializedBucketAccesses,
+		MaterializedSecrets,
+		MaterializedByNamespace,
+		CacheLookups,
+		NodeOperations,
+		NodeOperationDuration,
+		APIErrors,
+		PublishedVolumes,
+		FinalizerUpdateFailures,
+		PublishAPIWrites,
+		ClockSkew,
+		SnapshotAge,
+		CredentialRefreshes,
+		LogRecordsDropped,
+		CanaryProbes,
+		CanaryProbeDuration,
+		CanaryLastSuccess,
+		ThrottledPublishes,
+		MissingPermissions,
+	}
+}
+
+func init() {
+	Registry.MustRegister(Collectors()...)
+}
+
+// ObserveNodeOperation records a CSI node call that started at start and
+// returned err.
+func ObserveNodeOperation(operation string, start time.Time, err error) {
+	NodeOperations.WithLabelValues(operation, status.Code(err).String()).Inc()
+	NodeOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Path is where metrics are served.
+	Path = "/metrics"
+
+	shutdownTimeout = 5 * time.Second
+)
+
+// Server serves Registry over HTTP.
+type Server struct {
+	addr string
+}
+
+// NewServer returns a Server listening on addr, e.g. ":9090".
+func NewServer(addr string) *Server {
+	return &Server{addr: addr}
+}
+
+// Start serves metrics until ctx is done.
+func (s *Server) Start(ctx context.Context) error {
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.serve(ctx, l)
+}
+
+func (s *Server) serve(ctx context.Context, l net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Handler: mux}
+
+	errs := make(chan error, 1)
+	go func() {
+		klog.InfoS("serving metrics", "address", l.Addr().String(), "path", Path)
+		errs <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package migrate finds pods that mount the secrets minted by COSI provisioners
+// directly, and computes the patches replacing those secret volumes with
+// adapter volumes projecting the same files.
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Workload is the object owning the pod template to patch: a Deployment,
+// StatefulSet, DaemonSet, Job or CronJob, or a Pod that has no controller.
+type Workload struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (w Workload) String() string {
+	return fmt.Sprintf("%s/%s/%s", w.Namespace, strings.ToLower(w.Kind), w.Name)
+}
+
+// Patch replaces the minted secret volumes of a Workload with adapter volumes.
+type Patch struct {
+	Workload Workload
+	// Volumes are the names of the volumes replaced.
+	Volumes []string
+	// Data is a strategic merge patch for the Workload. Pod volumes cannot be
+	// changed, so for a Pod it is the change to make before recreating it.
+	Data []byte
+}
+
+// Planner computes the Patches of a cluster.
+type Planner struct {
+	kube kubernetes.Interface
+	cosi cs.ObjectstorageV1alpha1Interface
+	// driverName is the name the adapter is registered under.
+	driverName string
+}
+
+// NewPlanner returns a Planner that reads the cluster through kube and cosi
+// and writes volumes for the adapter registered as driverName.
+func NewPlanner(kube kubernetes.Interface, cosi cs.ObjectstorageV1alpha1Interface, driverName string) *Planner {
+	return &Planner{kube: kube, cosi: cosi, driverName: driverName}
+}
+
+// mintedSecret identifies a secret minted for a BucketAccess.
+type mintedSecret struct {
+	namespace, name string
+}
+
+// Plan returns a Patch for every workload in namespace, or in every namespace
+// if it is empty, with a pod mounting a minted secret as a volume. Workloads
+// are listed once however many of their pods do. Secrets of pod scoped
+// BucketAccesses, which only the adapter creates, are not considered.
+func (p *Planner) Plan(ctx context.Context, namespace string) ([]Patch, error) {
+	bas, err := p.cosi.BucketAccesses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorMigrationListFailed)
+	}
+	bars := map[mintedSecret]string{}
+	for _, ba := range bas.Items {
+		if _, podScoped := ba.Labels[client.PodUIDLabel]; podScoped {
+			continue
+		}
+		secret, bar := ba.Status.MintedSecret, ba.Spec.BucketAccessRequest
+		if secret == nil || bar == nil {
+			continue
+		}
+		if secret.Namespace != bar.Namespace {
+			// The pods of the request cannot mount a secret in another namespace.
+			continue
+		}
+		bars[mintedSecret{namespace: secret.Namespace, name: secret.Name}] = bar.Name
+	}
+
+	pods, err := p.kube.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorMigrationListFailed)
+	}
+
+	seen := map[Workload]bool{}
+	var patches []Patch
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		var volumes []interface{}
+		var names []string
+		for _, vol := range pod.Spec.Volumes {
+			if vol.Secret == nil {
+				continue
+			}
+			bar, ok := bars[mintedSecret{namespace: pod.Namespace, name: vol.Secret.SecretName}]
+			if !ok {
+				continue
+			}
+			volumes = append(volumes, p.volume(vol.Name, bar, vol.Secret))
+			names = append(names, vol.Name)
+		}
+		if len(volumes) == 0 {
+			continue
+		}
+
+		w, err := p.workload(ctx, pod)
+		if err != nil {
+			return nil, err
+		}
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+
+		data, err := json.Marshal(templatePatch(w.Kind, map[string]interface{}{"volumes": volumes}))
+		if err != nil {
+			return nil, err
+		}
+		patches = append(patches, Patch{Workload: w, Volumes: names, Data: data})
+	}
+
+	sort.Slice(patches, func(i, j int) bool {
+		return patches[i].Workload.String() < patches[j].Workload.String()
+	})
+	return patches, nil
+}
+
+// volume returns the strategic merge patch of a volume replacing a secret
+// volume with an adapter volume. The secret keys are projected one file each,
+// as the secret volume did, and items are carried over as key paths. Keys left
+// out of items are projected too, since the adapter projects every key.
+func (p *Planner) volume(name, bar string, secret *v1.SecretVolumeSource) map[string]interface{} {
+	attrs := map[string]string{
+		adapter.BucketAccessRequestNameKey: bar,
+		adapter.ProjectionKey:              string(adapter.ProjectionKeys),
+	}
+	for _, item := range secret.Items {
+		if item.Path != item.Key {
+			attrs[adapter.KeyPathPrefix+item.Key] = item.Path
+		}
+	}
+	if secret.DefaultMode != nil {
+		attrs[adapter.FileModeKey] = fmt.Sprintf("%04o", *secret.DefaultMode)
+	}
+	return map[string]interface{}{
+		"name": name,
+		// A null source removes the secret from the merged volume.
+		"secret": nil,
+		"csi": map[string]interface{}{
+			"driver":           p.driverName,
+			"readOnly":         true,
+			"volumeAttributes": attrs,
+		},
+	}
+}
+
+// workload follows the controller references of pod up to the object holding
+// its template. ReplicaSets are followed to their Deployment and Jobs to their
+// CronJob.
+func (p *Planner) workload(ctx context.Context, pod *v1.Pod) (Workload, error) {
+	w := Workload{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+	owner := metav1.GetControllerOf(pod)
+	for owner != nil {
+		w = Workload{Kind: owner.Kind, Namespace: pod.Namespace, Name: owner.Name}
+		switch owner.Kind {
+		case "ReplicaSet":
+			rs, err := p.kube.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+			if err != nil {
+				return Workload{}, errors.Wrap(err, util.WrapErrorMigrationOwnerFailed)
+			}
+			owner = metav1.GetControllerOf(rs)
+		case "Job":
+			job, err := p.kube.BatchV1().Jobs(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+			if err != nil {
+				return Workload{}, errors.Wrap(err, util.WrapErrorMigrationOwnerFailed)
+			}
+			owner = metav1.GetControllerOf(job)
+		default:
+			owner = nil
+		}
+	}
+	switch w.Kind {
+	case "Pod", "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job", "CronJob":
+	default:
+		klog.InfoS("pod is controlled by an unknown kind, the patch assumes it has a pod template at spec.template", "pod", klog.KObj(pod), "kind", w.Kind)
+	}
+	return w, nil
+}
+
+// templatePatch nests the pod spec patch podSpec where kind holds its pod spec.
+func templatePatch(kind string, podSpec map[string]interface{}) map[string]interface{} {
+	spec := map[string]interface{}{"spec": podSpec}
+	switch kind {
+	case "Pod":
+		return spec
+	case "CronJob":
+		return map[string]interface{}{"spec": map[string]interface{}{
+			"jobTemplate": map[string]interface{}{"spec": map[string]interface{}{"template": spec}},
+		}}
+	default:
+		return map[string]interface{}{"spec": map[string]interface{}{"template": spec}}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/testutil"
+)
+
+func TestPlan(t *testing.T) {
+	controller := true
+	secretVolume := func(secret string, items ...corev1.KeyToPath) testutil.GraphOption {
+		return func(g *testutil.Graph) {
+			g.Pod.Spec.Volumes = []corev1.Volume{{
+				Name:         "creds",
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: secret, Items: items}},
+			}}
+		}
+	}
+	ownedBy := func(kind, name string) testutil.GraphOption {
+		return func(g *testutil.Graph) {
+			g.Pod.OwnerReferences = []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &controller}}
+		}
+	}
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:            "app-5d4f",
+		Namespace:       "ns",
+		OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "app", Controller: &controller}},
+	}}
+
+	cases := map[string]struct {
+		graph *testutil.Graph
+		want  []Patch
+	}{
+		"Deployment": {
+			graph: testutil.NewGraph("ns", "app", secretVolume("app", corev1.KeyToPath{Key: "accessKeyID", Path: "id"}), ownedBy("ReplicaSet", rs.Name)),
+			want: []Patch{{
+				Workload: Workload{Kind: "Deployment", Namespace: "ns", Name: "app"},
+				Volumes:  []string{"creds"},
+				Data:     []byte(`{"spec":{"template":{"spec":{"volumes":[{"csi":{"driver":"objectstorage.k8s.io","readOnly":true,"volumeAttributes":{"bar-name":"app","key.accessKeyID":"id","projection":"keys"}},"name":"creds","secret":null}]}}}}`),
+			}},
+		},
+		"BarePod": {
+			graph: testutil.NewGraph("ns", "app", secretVolume("app")),
+			want: []Patch{{
+				Workload: Workload{Kind: "Pod", Namespace: "ns", Name: "app"},
+				Volumes:  []string{"creds"},
+				Data:     []byte(`{"spec":{"volumes":[{"csi":{"driver":"objectstorage.k8s.io","readOnly":true,"volumeAttributes":{"bar-name":"app","projection":"keys"}},"name":"creds","secret":null}]}}`),
+			}},
+		},
+		"OtherSecret": {
+			graph: testutil.NewGraph("ns", "app", secretVolume("tls")),
+		},
+		"PodScopedBucketAccess": {
+			graph: testutil.NewGraph("ns", "app", secretVolume("app"), func(g *testutil.Graph) {
+				g.BA.Labels = map[string]string{client.PodUIDLabel: "uid"}
+			}),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kube, cosi := testutil.FakeClients(tc.graph)
+			for _, obj := range []runtime.Object{rs} {
+				if err := kube.Tracker().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			patches, err := NewPlanner(kube, cosi.ObjectstorageV1alpha1(), "objectstorage.k8s.io").Plan(context.Background(), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, patches); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package node
+
+import (
+	"context"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/audit"
+)
+
+// AccessMonitor records which processes open the credential files of published
+// volumes. It is only available on Linux.
+type AccessMonitor interface {
+	// Start processes access events until ctx is done.
+	Start(ctx context.Context) error
+	Watch(volID, dir string) error
+	Unwatch(volID, dir string) error
+}
+
+// NewAccessMonitor returns an AccessMonitor reporting to sink.
+func NewAccessMonitor(sink audit.Sink) (AccessMonitor, error) {
+	m, err := newAccessMonitor(sink)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
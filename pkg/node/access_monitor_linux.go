@@ -0,0 +1,159 @@
+//go:build linux
+// +build linux
+
+package node
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/audit"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const fanotifyPollTimeout = time.Second
+
+// fanotifyMonitor reports every open of a file in a watched directory to the
+// audit sink, along with the opening process and its cgroup so the container
+// can be identified. It requires CAP_SYS_ADMIN.
+type fanotifyMonitor struct {
+	fd   int
+	sink audit.Sink
+
+	mu   sync.RWMutex
+	dirs map[string]string // directory -> volume ID
+}
+
+func newAccessMonitor(sink audit.Sink) (*fanotifyMonitor, error) {
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK, unix.O_RDONLY|unix.O_LARGEFILE)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorFanotifyInitFailed)
+	}
+	return &fanotifyMonitor{
+		fd:   fd,
+		sink: sink,
+		dirs: map[string]string{},
+	}, nil
+}
+
+// Watch marks the directory dir resolves to, so a ..data symlink is followed
+// to the timestamped directory holding the files at the time of the call.
+func (m *fanotifyMonitor) Watch(volID, dir string) error {
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	if err := unix.FanotifyMark(m.fd, unix.FAN_MARK_ADD, unix.FAN_OPEN|unix.FAN_EVENT_ON_CHILD, unix.AT_FDCWD, dir); err != nil {
+		return errors.Wrap(err, util.WrapErrorFanotifyMarkFailed)
+	}
+	m.mu.Lock()
+	m.dirs[dir] = volID
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *fanotifyMonitor) Unwatch(volID, dir string) error {
+	m.mu.Lock()
+	for d, id := range m.dirs {
+		if id == volID {
+			dir = d
+			delete(m.dirs, d)
+		}
+	}
+	m.mu.Unlock()
+	err := unix.FanotifyMark(m.fd, unix.FAN_MARK_REMOVE, unix.FAN_OPEN|unix.FAN_EVENT_ON_CHILD, unix.AT_FDCWD, dir)
+	if err != nil && err != unix.ENOENT {
+		return errors.Wrap(err, util.WrapErrorFanotifyMarkFailed)
+	}
+	return nil
+}
+
+// Start reads events until ctx is done.
+func (m *fanotifyMonitor) Start(ctx context.Context) error {
+	defer unix.Close(m.fd)
+
+	buf := make([]byte, 4096)
+	fds := []unix.PollFd{{Fd: int32(m.fd), Events: unix.POLLIN}}
+	for ctx.Err() == nil {
+		n, err := unix.Poll(fds, int(fanotifyPollTimeout/time.Millisecond))
+		if err == unix.EINTR || n == 0 {
+			continue
+		}
+		if err != nil {
+			return errors.Wrap(err, util.WrapErrorFanotifyReadFailed)
+		}
+
+		read, err := unix.Read(m.fd, buf)
+		if err == unix.EAGAIN {
+			continue
+		}
+		if err != nil {
+			return errors.Wrap(err, util.WrapErrorFanotifyReadFailed)
+		}
+		m.handleEvents(buf[:read])
+	}
+	return nil
+}
+
+func (m *fanotifyMonitor) handleEvents(buf []byte) {
+	size := int(unsafe.Sizeof(unix.FanotifyEventMetadata{}))
+	for len(buf) >= size {
+		event := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buf[0]))
+		if event.Vers != unix.FANOTIFY_METADATA_VERSION || int(event.Event_len) < size || int(event.Event_len) > len(buf) {
+			klog.Error("unexpected fanotify event, dropping buffer")
+			return
+		}
+		if event.Fd >= 0 {
+			m.record(event)
+			unix.Close(int(event.Fd))
+		}
+		buf = buf[event.Event_len:]
+	}
+}
+
+func (m *fanotifyMonitor) record(event *unix.FanotifyEventMetadata) {
+	path, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", event.Fd))
+	if err != nil {
+		klog.V(4).ErrorS(err, "failed to resolve path of fanotify event")
+		return
+	}
+
+	m.mu.RLock()
+	volID, ok := m.dirs[filepath.Dir(path)]
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	pid := strconv.Itoa(int(event.Pid))
+	m.sink.Record(audit.Event{
+		Time:     time.Now(),
+		Type:     audit.CredentialFileOpened,
+		VolumeID: volID,
+		Attributes: map[string]string{
+			"path":    path,
+			"pid":     pid,
+			"command": procFile(pid, "comm"),
+			"cgroup":  procFile(pid, "cgroup"),
+		},
+	})
+}
+
+func procFile(pid, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join("/proc", pid, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package node
+
+import (
+	"context"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/audit"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+type unsupportedMonitor struct{}
+
+func newAccessMonitor(sink audit.Sink) (*unsupportedMonitor, error) {
+	return nil, util.ErrorAccessMonitorUnsupported
+}
+
+func (unsupportedMonitor) Watch(volID, dir string) error   { return nil }
+func (unsupportedMonitor) Unwatch(volID, dir string) error { return nil }
+func (unsupportedMonitor) Start(ctx context.Context) error { return nil }
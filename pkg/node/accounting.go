@@ -0,0 +1,160 @@
+package node
+
+import (
+	"sync"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+)
+
+// materialized identifies the objects whose credentials a volume holds on
+// this node.
+type materialized struct {
+	namespace    string
+	bucket       string
+	bucketAccess string
+	secret       string
+}
+
+// accounting keeps the gauges of distinct buckets, BucketAccesses and minted
+// secrets materialized on the node up to date. Several volumes commonly share
+// a bucket or secret, so the gauges are recomputed from the full set of
+// volumes rather than incremented per publish.
+//
+// Volumes published before the adapter started are counted once adopted, see
+// NodeServer.Adopt.
+type accounting struct {
+	mu      sync.Mutex
+	volumes map[string]materialized
+	// watch, when set, is passed the minted secrets held on the node
+	// whenever they may have changed.
+	watch func(secrets []string)
+}
+
+func newAccounting() *accounting {
+	return &accounting{volumes: map[string]materialized{}}
+}
+
+func (a *accounting) add(volID string, m materialized) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.volumes[volID] = m
+	a.update()
+}
+
+func (a *accounting) remove(volID string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.volumes, volID)
+	a.update()
+}
+
+// watchSecrets passes the minted secrets held on the node to watch now and
+// after every change.
+func (a *accounting) watchSecrets(watch func(secrets []string)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.watch = watch
+	a.update()
+}
+
+// bucketConsumers returns the number of volumes consuming each bucket.
+func (a *accounting) bucketConsumers() map[string]int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	consumers := map[string]int{}
+	for _, m := range a.volumes {
+		consumers[m.bucket]++
+	}
+	return consumers
+}
+
+// secretConsumers returns the volumes holding the credentials of secret,
+// given as "namespace/name".
+func (a *accounting) secretConsumers(secret string) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var volumes []string
+	for volID, m := range a.volumes {
+		if m.secret == secret {
+			volumes = append(volumes, volID)
+		}
+	}
+	return volumes
+}
+
+type accountingCounts struct {
+	buckets, bucketAccesses, secrets int
+}
+
+// counts returns the distinct objects on the node and per pod namespace.
+func (a *accounting) counts() (accountingCounts, map[string]accountingCounts) {
+	type sets struct {
+		buckets, bucketAccesses, secrets map[string]bool
+	}
+	newSets := func() *sets {
+		return &sets{buckets: map[string]bool{}, bucketAccesses: map[string]bool{}, secrets: map[string]bool{}}
+	}
+
+	node := newSets()
+	namespaces := map[string]*sets{}
+	for _, m := range a.volumes {
+		ns, ok := namespaces[m.namespace]
+		if !ok {
+			ns = newSets()
+			namespaces[m.namespace] = ns
+		}
+		for _, s := range []*sets{node, ns} {
+			s.buckets[m.bucket] = true
+			s.bucketAccesses[m.bucketAccess] = true
+			if m.secret != "" {
+				s.secrets[m.secret] = true
+			}
+		}
+	}
+
+	toCounts := func(s *sets) accountingCounts {
+		return accountingCounts{buckets: len(s.buckets), bucketAccesses: len(s.bucketAccesses), secrets: len(s.secrets)}
+	}
+	byNamespace := map[string]accountingCounts{}
+	for name, s := range namespaces {
+		byNamespace[name] = toCounts(s)
+	}
+	return toCounts(node), byNamespace
+}
+
+func (a *accounting) update() {
+	node, byNamespace := a.counts()
+
+	if a.watch != nil {
+		secrets := map[string]bool{}
+		for _, m := range a.volumes {
+			if m.secret != "" {
+				secrets[m.secret] = true
+			}
+		}
+		watched := make([]string, 0, len(secrets))
+		for secret := range secrets {
+			watched = append(watched, secret)
+		}
+		a.watch(watched)
+	}
+
+	metrics.MaterializedBuckets.Set(float64(node.buckets))
+	metrics.MaterializedBucketAccesses.Set(float64(node.bucketAccesses))
+	metrics.MaterializedSecrets.Set(float64(node.secrets))
+	metrics.PublishedVolumes.Set(float64(len(a.volumes)))
+
+	// Reset drops namespaces whose last volume was removed.
+	metrics.MaterializedByNamespace.Reset()
+	for ns, c := range byNamespace {
+		metrics.MaterializedByNamespace.WithLabelValues(ns, "bucket").Set(float64(c.buckets))
+		metrics.MaterializedByNamespace.WithLabelValues(ns, "bucketaccess").Set(float64(c.bucketAccesses))
+		metrics.MaterializedByNamespace.WithLabelValues(ns, "secret").Set(float64(c.secrets))
+	}
+}
@@ -0,0 +1,71 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAccounting(t *testing.T) {
+	type want struct {
+		node        accountingCounts
+		byNamespace map[string]accountingCounts
+	}
+
+	cases := map[string]struct {
+		add    map[string]materialized
+		remove []string
+		want
+	}{
+		"Empty": {
+			want: want{byNamespace: map[string]accountingCounts{}},
+		},
+		"SharedAcrossVolumes": {
+			add: map[string]materialized{
+				"vol-1": {namespace: "a", bucket: "b1", bucketAccess: "ba1", secret: "a/s1"},
+				"vol-2": {namespace: "a", bucket: "b1", bucketAccess: "ba1", secret: "a/s1"},
+				"vol-3": {namespace: "b", bucket: "b1", bucketAccess: "ba2", secret: "b/s2"},
+			},
+			want: want{
+				node: accountingCounts{buckets: 1, bucketAccesses: 2, secrets: 2},
+				byNamespace: map[string]accountingCounts{
+					"a": {buckets: 1, bucketAccesses: 1, secrets: 1},
+					"b": {buckets: 1, bucketAccesses: 1, secrets: 1},
+				},
+			},
+		},
+		"Removed": {
+			add: map[string]materialized{
+				"vol-1": {namespace: "a", bucket: "b1", bucketAccess: "ba1", secret: "a/s1"},
+				"vol-2": {namespace: "b", bucket: "b2", bucketAccess: "ba2", secret: "b/s2"},
+			},
+			remove: []string{"vol-2", "vol-unknown"},
+			want: want{
+				node: accountingCounts{buckets: 1, bucketAccesses: 1, secrets: 1},
+				byNamespace: map[string]accountingCounts{
+					"a": {buckets: 1, bucketAccesses: 1, secrets: 1},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			a := newAccounting()
+			for volID, m := range tc.add {
+				a.add(volID, m)
+			}
+			for _, volID := range tc.remove {
+				a.remove(volID)
+			}
+
+			node, byNamespace := a.counts()
+			if diff := cmp.Diff(tc.want.node, node, cmp.AllowUnexported(accountingCounts{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.byNamespace, byNamespace, cmp.AllowUnexported(accountingCounts{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// materialized returns what the volume described by m holds on the node.
+func (m Metadata) materialized() materialized {
+	return materialized{
+		namespace:    m.PodNamespace,
+		bucket:       m.Bucket,
+		bucketAccess: m.BaName,
+		secret:       m.Secret,
+	}
+}
+
+// Adopt takes over the volumes published by a previous adapter on this node,
+// whether it exited or is handing its sockets over to this one. The metadata
+// file of each volume is the record of what was published, so adopting it
+// restores the in-memory state the previous adapter kept: the volume's
+// accounting, the monitoring of its credential files and, for exec delivery
+// volumes, the credential helper socket. It returns the
+// number of volumes adopted and must be called before serving.
+//
+// Volumes without a readable metadata file are left alone; unpublishing them
+// is handled as for any unknown volume.
+func (n *NodeServer) Adopt() (int, error) {
+	entries, err := ioutil.ReadDir(n.provisioner.dataPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, util.WrapErrorFailedToAdoptVolumes)
+	}
+
+	adopted := 0
+	for _, entry := range entries {
+		volID := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(volID, ".") {
+			continue
+		}
+
+		data, err := n.provisioner.readFileFromVolume(volID, metadataFilename)
+		if err != nil {
+			klog.V(4).InfoS("not adopting volume without metadata", "volumeID", volID, "err", err)
+			continue
+		}
+		meta := Metadata{}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			klog.ErrorS(err, "not adopting volume with unreadable metadata", "volumeID", volID)
+			continue
+		}
+
+		n.accounting.add(volID, meta.materialized())
+		if meta.CredentialDelivery == adapter.CredentialDeliveryExec {
+			if err := n.helper.serve(volID, n.provisioner.bucketPath(volID), meta.credentialSource(), meta.mountGroup()); err != nil {
+				klog.ErrorS(err, "failed to serve credential helper of adopted volume", "volumeID", volID)
+			}
+		}
+		if n.accessMonitor != nil {
+			if err := n.accessMonitor.Watch(volID, filepath.Join(n.provisioner.bucketPath(volID), client.DataDirName)); err != nil {
+				klog.ErrorS(err, "failed to monitor credential access", "volumeID", volID)
+			}
+		}
+		if n.refresher != nil && meta.refreshable() && meta.Rotation != nil {
+			n.scheduleRefresh(volID, *meta.Rotation, meta.Expiration)
+		}
+		if meta.InitOnly && !meta.CredentialsWiped {
+			n.initOnly.watch(volID, meta.PodNamespace, meta.PodName)
+		}
+		adopted++
+	}
+	return adopted, nil
+}
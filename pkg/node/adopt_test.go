@@ -0,0 +1,94 @@
+package node
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+)
+
+func TestAdopt(t *testing.T) {
+	type want struct {
+		adopted int
+		volumes map[string]materialized
+	}
+
+	meta := Metadata{BaName: "ba", PodName: "pod", PodNamespace: "ns", Bucket: "bucket", Secret: "ns/secret"}
+
+	cases := map[string]struct {
+		volumes map[string]*Metadata
+		want
+	}{
+		"Empty": {
+			want: want{
+				adopted: 0,
+				volumes: map[string]materialized{},
+			},
+		},
+		"Published": {
+			volumes: map[string]*Metadata{"vol-1": &meta, "vol-2": &meta},
+			want: want{
+				adopted: 2,
+				volumes: map[string]materialized{
+					"vol-1": meta.materialized(),
+					"vol-2": meta.materialized(),
+				},
+			},
+		},
+		"SkipsVolumeWithoutMetadata": {
+			volumes: map[string]*Metadata{"vol-1": &meta, "vol-2": nil},
+			want: want{
+				adopted: 1,
+				volumes: map[string]materialized{
+					"vol-1": meta.materialized(),
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dataRoot := t.TempDir()
+			if _, err := EnsureLayout(dataRoot); err != nil {
+				t.Fatal(err)
+			}
+			for volID, m := range tc.volumes {
+				if err := os.MkdirAll(filepath.Join(dataRoot, volID, "bucket"), 0750); err != nil {
+					t.Fatal(err)
+				}
+				if m == nil {
+					continue
+				}
+				data, err := json.Marshal(m)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(dataRoot, volID, metadataFilename), data, 0640); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			n := &NodeServer{
+				provisioner: NewProvisioner(dataRoot, mount.NewFakeMounter(nil), client.NewProvisionerClient()),
+				accounting:  newAccounting(),
+			}
+			adopted, err := n.Adopt()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tc.want.adopted, adopted); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.volumes, n.accounting.volumes, cmp.AllowUnexported(materialized{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package node
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// DefaultCanaryInterval is the time between two canary probes.
+	DefaultCanaryInterval = 5 * time.Minute
+
+	// canaryVolumeID and canaryTargetName are dot files, so the canary volume
+	// is never adopted as a volume published by kubelet.
+	canaryVolumeID   = ".canary"
+	canaryTargetName = ".canary-target"
+)
+
+// CanaryConfig selects the BucketAccessRequest the canary publishes.
+type CanaryConfig struct {
+	// BucketAccessRequest is the name of the canary BucketAccessRequest, in the
+	// namespace of the pod. Empty disables the canary.
+	BucketAccessRequest string
+	// PodName and PodNamespace name the pod the canary volume is published
+	// for, usually the adapter's own. Events of the canary are emitted on it.
+	PodName      string
+	PodNamespace string
+	// Interval is the time between two probes, DefaultCanaryInterval if zero.
+	Interval time.Duration
+}
+
+// canary periodically publishes a volume for the canary BucketAccessRequest
+// through the same path as kubelet, reads its files back from the mount and
+// unpublishes it, so a regression in the credentials flow shows in the
+// canary metrics before user pods fail to start.
+type canary struct {
+	n          *NodeServer
+	volCtx     map[string]string
+	targetPath string
+	interval   time.Duration
+
+	// published is set while the canary volume may be left published, by a
+	// failed unpublish or a previous run of the adapter.
+	published bool
+}
+
+// newCanary returns nil, which probes nothing, unless config names a
+// BucketAccessRequest.
+func newCanary(n *NodeServer, dataRoot string, config CanaryConfig) *canary {
+	if config.BucketAccessRequest == "" {
+		return nil
+	}
+	interval := config.Interval
+	if interval <= 0 {
+		interval = DefaultCanaryInterval
+	}
+	return &canary{
+		n: n,
+		volCtx: map[string]string{
+			client.BarNameKey:      config.BucketAccessRequest,
+			client.PodNameKey:      config.PodName,
+			client.PodNamespaceKey: config.PodNamespace,
+		},
+		targetPath: filepath.Join(dataRoot, canaryTargetName),
+		interval:   interval,
+		published:  true,
+	}
+}
+
+func (c *canary) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.probe(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe runs one probe and records its outcome. A probe never outlives the
+// interval, so a hung API server shows as failures rather than no probes.
+func (c *canary) probe(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.interval)
+	defer cancel()
+
+	start := time.Now()
+	err := c.run(ctx)
+	metrics.CanaryProbeDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.CanaryProbes.WithLabelValues("failure").Inc()
+		klog.ErrorS(err, "canary probe failed", "bucketAccessRequest", c.volCtx[client.BarNameKey])
+		return
+	}
+	metrics.CanaryProbes.WithLabelValues("success").Inc()
+	metrics.CanaryLastSuccess.SetToCurrentTime()
+	klog.V(4).InfoS("canary probe succeeded", "duration", time.Since(start))
+}
+
+// run publishes the canary volume, checks its files are readable through the
+// mount and unpublishes it again.
+func (c *canary) run(ctx context.Context) (err error) {
+	if c.published {
+		if err := c.unpublish(ctx); err != nil {
+			return err
+		}
+	}
+	_, err = c.n.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+		VolumeId:      canaryVolumeID,
+		TargetPath:    c.targetPath,
+		VolumeContext: c.volCtx,
+		Readonly:      true,
+	})
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorCanaryPublishFailed)
+	}
+	c.published = true
+	defer func() {
+		if uerr := c.unpublish(ctx); err == nil {
+			err = uerr
+		}
+	}()
+
+	mounted, err := c.n.provisioner.isMounted(c.targetPath)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorCanaryReadFailed)
+	}
+	if !mounted {
+		return util.ErrorCanaryNotMounted
+	}
+	for _, name := range []string{protocolFileName, credsFileName} {
+		if _, err := c.n.provisioner.pclient.ReadFile(filepath.Join(c.targetPath, name)); err != nil {
+			return errors.Wrap(err, util.WrapErrorCanaryReadFailed)
+		}
+	}
+	return nil
+}
+
+func (c *canary) unpublish(ctx context.Context) error {
+	_, err := c.n.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   canaryVolumeID,
+		TargetPath: c.targetPath,
+	})
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorCanaryUnpublishFailed)
+	}
+	c.published = false
+	return nil
+}
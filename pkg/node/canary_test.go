@@ -0,0 +1,107 @@
+package node
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+// boundClient reads the files of source through target, as the bind mount of
+// a volume would.
+type boundClient struct {
+	client.ProvisionerClient
+	target, source string
+}
+
+func (b boundClient) ReadFile(name string) ([]byte, error) {
+	if strings.HasPrefix(name, b.target) {
+		name = b.source + strings.TrimPrefix(name, b.target)
+	}
+	return b.ProvisionerClient.ReadFile(name)
+}
+
+func TestCanaryRun(t *testing.T) {
+	type want struct {
+		failed bool
+		// published is whether the canary volume was left published.
+		published bool
+	}
+
+	cases := map[string]struct {
+		getResourcesErr error
+		leftPublished   bool
+		want
+	}{
+		"Succeeded": {},
+		"PreviousRunLeftPublished": {
+			leftPublished: true,
+		},
+		"PublishFailed": {
+			getResourcesErr: errBoom,
+			want:            want{failed: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dataRoot := t.TempDir()
+			n := &NodeServer{
+				name:   name,
+				nodeID: nodeId,
+				cosiClient: &fake.FakeNodeClient{
+					MockGetResources: func(ctx context.Context, barName, podName, podNs string) (*v1alpha1.Bucket, *v1alpha1.BucketAccess, *v1.Secret, *v1.Pod, error) {
+						if tc.getResourcesErr != nil {
+							return nil, nil, nil, nil, tc.getResourcesErr
+						}
+						return testutils.GetB(), testutils.GetBA(), testutils.GetSecret(), testutils.GetPod(), nil
+					},
+					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+						return testutils.GetPod(), nil
+					},
+					MockGetBA: func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
+						return testutils.GetBA(), nil
+					},
+					MockAddBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, f client.Finalizer) error {
+						return nil
+					},
+					MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, f client.Finalizer) error {
+						return nil
+					},
+				},
+			}
+			c := newCanary(n, dataRoot, CanaryConfig{
+				BucketAccessRequest: testutils.GetBAR().Name,
+				PodName:             testutils.GetPod().Name,
+				PodNamespace:        testutils.GetPod().Namespace,
+			})
+			n.provisioner = NewProvisioner(dataRoot, mount.NewFakeMounter(nil), boundClient{
+				ProvisionerClient: client.NewProvisionerClient(),
+				target:            c.targetPath,
+				source:            filepath.Join(dataRoot, canaryVolumeID, "bucket"),
+			})
+			c.published = tc.leftPublished
+
+			err := c.run(context.Background())
+
+			got := want{failed: err != nil}
+			if _, err := os.Stat(n.provisioner.volPath(canaryVolumeID)); err == nil {
+				got.published = true
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
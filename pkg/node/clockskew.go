@@ -0,0 +1,126 @@
+package node
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/rotation"
+)
+
+const (
+	defaultClockSkewInterval = 5 * time.Minute
+
+	// expiryMargin is kept between the refresh of expiring credentials and
+	// their expiry, on top of any skew, to absorb the time a refresh takes.
+	expiryMargin = time.Minute
+)
+
+// clockSkew periodically compares the node clock with the API server clock.
+// Temporary credentials carry absolute expiry times set by the issuer's clock,
+// so a node clock running ahead makes them look closer to expiry than they are
+// and one running behind keeps them in use after they stopped working.
+type clockSkew struct {
+	serverTime func(ctx context.Context) (time.Time, error)
+	threshold  time.Duration
+	interval   time.Duration
+
+	mu   sync.RWMutex
+	skew time.Duration
+}
+
+// newClockSkew returns nil, which never reports skew, if threshold is zero.
+func newClockSkew(serverTime func(ctx context.Context) (time.Time, error), threshold time.Duration) *clockSkew {
+	if serverTime == nil || threshold <= 0 {
+		return nil
+	}
+	return &clockSkew{
+		serverTime: serverTime,
+		threshold:  threshold,
+		interval:   defaultClockSkewInterval,
+	}
+}
+
+func (c *clockSkew) Start(ctx context.Context) error {
+	c.measure(ctx)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			c.measure(ctx)
+		}
+	}
+}
+
+// measure records the offset of the node clock from the API server clock,
+// positive when the node is ahead. Failures keep the last measurement.
+func (c *clockSkew) measure(ctx context.Context) {
+	server, err := c.serverTime(ctx)
+	if err != nil {
+		klog.ErrorS(err, "failed to read API server time")
+		return
+	}
+	skew := time.Since(server)
+	metrics.ClockSkew.Set(skew.Seconds())
+	if abs(skew) > c.threshold {
+		klog.InfoS("node clock is skewed against the API server", "skew", skew, "threshold", c.threshold)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.skew = skew
+}
+
+// current returns the last measured skew.
+func (c *clockSkew) current() time.Duration {
+	if c == nil {
+		return 0
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.skew
+}
+
+// check reports whether credentials expiring at expiry, fetched just now, show
+// significant skew: either the measured skew exceeds the threshold, or the
+// credentials already look expired, which for freshly minted credentials means
+// the node clock runs ahead of the issuer's.
+func (c *clockSkew) check(expiry time.Time) bool {
+	if c == nil {
+		return false
+	}
+	return abs(c.current()) > c.threshold || !time.Now().Before(expiry)
+}
+
+// padRefresh shortens the refresh interval of p so credentials expiring at
+// expiry are refreshed before they expire, allowing for skew in either
+// direction and expiryMargin. Only policies that rotate on a timer are changed;
+// the interval never drops below rotation.MinRefreshInterval.
+func padRefresh(p rotation.Policy, expiry, now time.Time, skew time.Duration) rotation.Policy {
+	if p.Mode != rotation.ModeEnabled {
+		return p
+	}
+	refresh := expiry.Sub(now) - abs(skew) - expiryMargin
+	if refresh < rotation.MinRefreshInterval {
+		refresh = rotation.MinRefreshInterval
+	}
+	if interval, ok := p.Refreshes(); ok && interval <= refresh {
+		return p
+	}
+	p.RefreshInterval.Duration = refresh
+	return p
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,121 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/rotation"
+)
+
+func TestClockSkewCheck(t *testing.T) {
+	cases := map[string]struct {
+		skew       *clockSkew
+		serverTime func(ctx context.Context) (time.Time, error)
+		expiry     time.Duration
+		want       bool
+	}{
+		"Disabled": {
+			expiry: -time.Hour,
+			want:   false,
+		},
+		"InSync": {
+			serverTime: func(ctx context.Context) (time.Time, error) { return time.Now(), nil },
+			expiry:     time.Hour,
+			want:       false,
+		},
+		"NodeAhead": {
+			serverTime: func(ctx context.Context) (time.Time, error) { return time.Now().Add(-5 * time.Minute), nil },
+			expiry:     time.Hour,
+			want:       true,
+		},
+		"NodeBehind": {
+			serverTime: func(ctx context.Context) (time.Time, error) { return time.Now().Add(5 * time.Minute), nil },
+			expiry:     time.Hour,
+			want:       true,
+		},
+		"AlreadyExpired": {
+			serverTime: func(ctx context.Context) (time.Time, error) { return time.Now(), nil },
+			expiry:     -time.Minute,
+			want:       true,
+		},
+		"ServerTimeFailed": {
+			serverTime: func(ctx context.Context) (time.Time, error) { return time.Time{}, errBoom },
+			expiry:     time.Hour,
+			want:       false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := newClockSkew(tc.serverTime, 30*time.Second)
+			if c != nil {
+				c.measure(context.Background())
+			}
+
+			got := c.check(time.Now().Add(tc.expiry))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPadRefresh(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	policy := func(mode rotation.Mode, interval time.Duration) rotation.Policy {
+		return rotation.Policy{Mode: mode, RefreshInterval: metav1.Duration{Duration: interval}}
+	}
+
+	cases := map[string]struct {
+		policy rotation.Policy
+		expiry time.Time
+		skew   time.Duration
+		want   rotation.Policy
+	}{
+		"RefreshBeforeExpiry": {
+			policy: policy(rotation.ModeEnabled, 10*time.Minute),
+			expiry: now.Add(time.Hour),
+			want:   policy(rotation.ModeEnabled, 10*time.Minute),
+		},
+		"NoTimer": {
+			policy: policy(rotation.ModeEnabled, 0),
+			expiry: now.Add(time.Hour),
+			want:   policy(rotation.ModeEnabled, 59*time.Minute),
+		},
+		"PaddedBySkew": {
+			policy: policy(rotation.ModeEnabled, time.Hour),
+			expiry: now.Add(time.Hour),
+			skew:   -5 * time.Minute,
+			want:   policy(rotation.ModeEnabled, 54*time.Minute),
+		},
+		"MinimumInterval": {
+			policy: policy(rotation.ModeEnabled, time.Hour),
+			expiry: now.Add(time.Minute),
+			skew:   time.Minute,
+			want:   policy(rotation.ModeEnabled, rotation.MinRefreshInterval),
+		},
+		"OnChange": {
+			policy: policy(rotation.ModeOnChange, 0),
+			expiry: now.Add(time.Hour),
+			want:   policy(rotation.ModeOnChange, 0),
+		},
+		"Disabled": {
+			policy: policy(rotation.ModeDisabled, 0),
+			expiry: now.Add(time.Hour),
+			want:   policy(rotation.ModeDisabled, 0),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := padRefresh(tc.policy, tc.expiry, now, tc.skew)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
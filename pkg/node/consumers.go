@@ -0,0 +1,112 @@
+package node
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+)
+
+const (
+	// ConsumersAnnotationPrefix prefixes the Bucket annotation holding the
+	// number of volumes on one node that consume the bucket. The node name
+	// completes the key, so every node owns a key of its own and the total is
+	// the sum over all keys.
+	ConsumersAnnotationPrefix = "consumers.cosi.objectstorage.k8s.io/"
+
+	defaultConsumersInterval = time.Minute
+
+	// maxConsumerApplies bounds the Bucket writes per sync. Buckets left over
+	// are written on the next sync.
+	maxConsumerApplies = 20
+)
+
+// consumersAnnotation returns the annotation key of nodeID. Annotation names
+// are limited to 63 characters, so long node names are shortened with a hash.
+func consumersAnnotation(nodeID string) string {
+	if len(nodeID) <= 63 {
+		return ConsumersAnnotationPrefix + nodeID
+	}
+	return fmt.Sprintf("%s%s-%x", ConsumersAnnotationPrefix, nodeID[:54], sha256.Sum256([]byte(nodeID)))[:len(ConsumersAnnotationPrefix)+63]
+}
+
+// consumerCounter periodically applies the number of volumes consuming each
+// bucket on this node to the Bucket. Counts are approximate: they cover the
+// volumes published since the adapter started and lag by up to an interval.
+type consumerCounter struct {
+	client     client.NodeClient
+	accounting *accounting
+	key        string
+	interval   time.Duration
+
+	// applied is the count last written per bucket.
+	applied map[string]int
+}
+
+func newConsumerCounter(nc client.NodeClient, a *accounting, nodeID string) *consumerCounter {
+	return &consumerCounter{
+		client:     nc,
+		accounting: a,
+		key:        consumersAnnotation(nodeID),
+		interval:   defaultConsumersInterval,
+		applied:    map[string]int{},
+	}
+}
+
+func (c *consumerCounter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			c.sync(ctx)
+		}
+	}
+}
+
+// sync applies the counts that changed since the last sync. A bucket whose
+// last consumer left has the node's annotation removed.
+func (c *consumerCounter) sync(ctx context.Context) {
+	current := c.accounting.bucketConsumers()
+
+	var changed []string
+	for bucket, n := range current {
+		if c.applied[bucket] != n {
+			changed = append(changed, bucket)
+		}
+	}
+	for bucket := range c.applied {
+		if _, ok := current[bucket]; !ok {
+			changed = append(changed, bucket)
+		}
+	}
+	sort.Strings(changed)
+	if len(changed) > maxConsumerApplies {
+		changed = changed[:maxConsumerApplies]
+	}
+
+	for _, bucket := range changed {
+		n := current[bucket]
+		annotations := map[string]string{}
+		if n > 0 {
+			annotations[c.key] = strconv.Itoa(n)
+		}
+		if err := c.client.ApplyBucketAnnotations(ctx, bucket, annotations); err != nil {
+			klog.ErrorS(err, "failed to apply bucket consumer count", "bucket", bucket)
+			continue
+		}
+		if n > 0 {
+			c.applied[bucket] = n
+		} else {
+			delete(c.applied, bucket)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package node
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+)
+
+func TestConsumerCounter(t *testing.T) {
+	type step struct {
+		add    map[string]string
+		remove []string
+		want   map[string]map[string]string
+	}
+
+	const key = ConsumersAnnotationPrefix + "node"
+
+	cases := map[string]struct {
+		steps []step
+	}{
+		"Unchanged": {
+			steps: []step{
+				{want: map[string]map[string]string{}},
+			},
+		},
+		"CountsVolumesPerBucket": {
+			steps: []step{
+				{
+					add:  map[string]string{"vol1": "a", "vol2": "a", "vol3": "b"},
+					want: map[string]map[string]string{"a": {key: "2"}, "b": {key: "1"}},
+				},
+				{
+					want: map[string]map[string]string{},
+				},
+			},
+		},
+		"LastConsumerLeaves": {
+			steps: []step{
+				{
+					add:  map[string]string{"vol1": "a", "vol2": "a"},
+					want: map[string]map[string]string{"a": {key: "2"}},
+				},
+				{
+					remove: []string{"vol1"},
+					want:   map[string]map[string]string{"a": {key: "1"}},
+				},
+				{
+					remove: []string{"vol2"},
+					want:   map[string]map[string]string{"a": {}},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got map[string]map[string]string
+			nc := fake.FakeNodeClient{
+				MockApplyBucketAnnotations: func(ctx context.Context, bucketName string, annotations map[string]string) error {
+					got[bucketName] = annotations
+					return nil
+				},
+			}
+			a := newAccounting()
+			c := newConsumerCounter(nc, a, "node")
+
+			for _, s := range tc.steps {
+				for vol, bucket := range s.add {
+					a.add(vol, materialized{namespace: "ns", bucket: bucket})
+				}
+				for _, vol := range s.remove {
+					a.remove(vol)
+				}
+
+				got = map[string]map[string]string{}
+				c.sync(context.Background())
+
+				if diff := cmp.Diff(s.want, got); diff != "" {
+					t.Errorf("r: -want, +got:\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestConsumersAnnotation(t *testing.T) {
+	long := strings.Repeat("n", 100)
+
+	key := consumersAnnotation(long)
+	name := strings.TrimPrefix(key, ConsumersAnnotationPrefix)
+	if len(name) != 63 {
+		t.Errorf("annotation name %q is %d characters, want 63", name, len(name))
+	}
+	if key == consumersAnnotation(long+"x") {
+		t.Errorf("long node names sharing a prefix map to the same annotation %q", key)
+	}
+}
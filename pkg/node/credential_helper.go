@@ -0,0 +1,246 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/handover"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	credentialHelperMode         os.FileMode = 0555
+	hardenedCredentialHelperMode os.FileMode = 0550
+
+	credentialSocketMode         os.FileMode = 0666
+	hardenedCredentialSocketMode os.FileMode = 0660
+
+	// credentialHelperTimeout bounds a single credential fetch. SDKs run the
+	// credential_process synchronously, so a slow answer stalls the workload.
+	credentialHelperTimeout = 10 * time.Second
+)
+
+// credentialSource identifies where the credentials of an exec delivery volume
+// are read from on every request.
+type credentialSource struct {
+	barName      string
+	podName      string
+	podNamespace string
+	// baName and podScoped select the pod's own BucketAccess, which is not
+	// reachable from the BucketAccessRequest.
+	baName    string
+	podScoped bool
+}
+
+func (s credentialSource) secret(ctx context.Context, nc client.NodeClient) (*v1.Secret, error) {
+	if !s.podScoped {
+		_, _, secret, _, err := nc.GetResources(ctx, s.barName, s.podName, s.podNamespace)
+		return secret, err
+	}
+	pod, err := nc.GetPod(ctx, s.podName, s.podNamespace)
+	if err != nil {
+		return nil, err
+	}
+	_, secret, err := nc.WaitForPodBA(ctx, pod, s.baName)
+	return secret, err
+}
+
+// credentialHelper implements exec credential delivery. It projects a helper
+// executable into each volume and serves the volume's current credentials on a
+// unix socket next to it, so the minted secret is read from the API server
+// whenever the workload needs it and never written to the node.
+type credentialHelper struct {
+	binaryPath string
+	hardened   bool
+	nc         client.NodeClient
+	pclient    client.ProvisionerClient
+
+	// unusable is why the helper cannot be projected, as found by check.
+	unusable error
+
+	mu      sync.Mutex
+	servers map[string]*http.Server
+	sockets map[string]*handover.Listener
+}
+
+// newCredentialHelper returns nil, disabling exec delivery, if binaryPath is
+// empty.
+func newCredentialHelper(binaryPath string, hardened bool, nc client.NodeClient, pc client.ProvisionerClient) *credentialHelper {
+	if binaryPath == "" {
+		return nil
+	}
+	return &credentialHelper{
+		binaryPath: binaryPath,
+		hardened:   hardened,
+		nc:         nc,
+		pclient:    pc,
+		servers:    map[string]*http.Server{},
+		sockets:    map[string]*handover.Listener{},
+	}
+}
+
+// execFormats returns the formats of an exec delivery volume: those requested,
+// which must not write the minted secret, and always FormatAWSProcess.
+func execFormats(requested []string) ([]string, error) {
+	for _, name := range requested {
+		if !render.CredentialFree(name) {
+			return nil, fmt.Errorf(util.ErrorTemplateExecFormat, name)
+		}
+		if name == render.FormatAWSProcess {
+			return requested, nil
+		}
+	}
+	return append(requested, render.FormatAWSProcess), nil
+}
+
+// check verifies once, at startup, that the helper executable exists and runs
+// on the node, so exec delivery volumes fail to publish with the reason rather
+// than failing in the workload.
+func (h *credentialHelper) check() {
+	if h == nil {
+		return
+	}
+	data, err := h.pclient.ReadFile(h.binaryPath)
+	if err == nil {
+		err = checkExecutable(h.binaryPath, data)
+	}
+	if err != nil {
+		klog.ErrorS(err, "credential helper is unusable, exec credential delivery is disabled", "path", h.binaryPath)
+		h.unusable = err
+	}
+}
+
+// usable returns why exec credential delivery is unavailable, if it is.
+func (h *credentialHelper) usable() error {
+	if h == nil {
+		return util.ErrorCredentialHelperUnset
+	}
+	return h.unusable
+}
+
+// binary returns the helper executable to project into a volume. It is checked
+// again, as the file may have changed since startup.
+func (h *credentialHelper) binary() (render.File, error) {
+	data, err := h.pclient.ReadFile(h.binaryPath)
+	if err != nil {
+		return render.File{}, errors.Wrap(err, util.WrapErrorCredentialHelperFailed)
+	}
+	if err := checkExecutable(h.binaryPath, data); err != nil {
+		return render.File{}, err
+	}
+	mode := credentialHelperMode
+	if h.hardened {
+		mode = hardenedCredentialHelperMode
+	}
+	return render.File{Name: adapter.CredentialHelperFileName, Data: data, Mode: mode}, nil
+}
+
+// serve starts serving the credentials of src on the socket of the volume in
+// dir, replacing any server already running for the volume. The socket is
+// taken over from a previous adapter still serving it, so adopted volumes
+// never refuse a request.
+func (h *credentialHelper) serve(volID, dir string, src credentialSource, gid int) error {
+	if h == nil {
+		return util.ErrorCredentialHelperUnset
+	}
+	h.stop(volID)
+
+	path := filepath.Join(dir, adapter.CredentialHelperSocketName)
+	l, err := handover.Listen("unix", path)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorCredentialHelperFailed)
+	}
+	mode := credentialSocketMode
+	if h.hardened || gid != noGroup {
+		mode = hardenedCredentialSocketMode
+	}
+	if err := h.pclient.SetOwnership(path, mode, gid); err != nil {
+		l.Close()
+		return errors.Wrap(err, util.WrapErrorCredentialHelperFailed)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(adapter.CredentialHelperPath, h.handler(volID, src))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: credentialHelperTimeout}
+	go func() {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			klog.ErrorS(err, "credential helper socket failed", "volumeID", volID)
+		}
+	}()
+
+	h.mu.Lock()
+	h.servers[volID] = srv
+	h.sockets[volID] = l
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *credentialHelper) handler(volID string, src credentialSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), credentialHelperTimeout)
+		defer cancel()
+
+		secret, err := src.secret(ctx, h.nc)
+		if err != nil {
+			klog.ErrorS(err, "credential helper failed to read the minted secret", "volumeID", volID)
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		creds, err := render.ProcessCredentials(secret)
+		if err != nil {
+			klog.ErrorS(err, "minted secret has no credentials for the credential helper", "volumeID", volID)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(creds)
+	}
+}
+
+// stop stops serving the volume and removes its socket.
+func (h *credentialHelper) stop(volID string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	srv, l := h.servers[volID], h.sockets[volID]
+	delete(h.servers, volID)
+	delete(h.sockets, volID)
+	h.mu.Unlock()
+
+	if srv == nil {
+		return
+	}
+	_ = srv.Close()
+	if err := l.Remove(); err != nil {
+		klog.ErrorS(err, "failed to remove credential helper socket", "volumeID", volID)
+	}
+}
+
+// close stops serving every volume but leaves the sockets in place, for the
+// next adapter to take over when it adopts the volumes.
+func (h *credentialHelper) close() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for volID, srv := range h.servers {
+		_ = srv.Close()
+		delete(h.servers, volID)
+		delete(h.sockets, volID)
+	}
+}
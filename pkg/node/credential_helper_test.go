@@ -0,0 +1,154 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestExecFormats(t *testing.T) {
+	type want struct {
+		formats []string
+		err     error
+	}
+
+	cases := map[string]struct {
+		requested []string
+		want
+	}{
+		"Default": {
+			want: want{
+				formats: []string{render.FormatAWSProcess},
+			},
+		},
+		"AlreadyRequested": {
+			requested: []string{render.FormatAWSProcess},
+			want: want{
+				formats: []string{render.FormatAWSProcess},
+			},
+		},
+		"CredentialFormat": {
+			requested: []string{render.FormatAWS},
+			want: want{
+				err: fmt.Errorf(util.ErrorTemplateExecFormat, render.FormatAWS),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			formats, err := execFormats(tc.requested)
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.formats, formats); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckExecutable(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	native, err := ioutil.ReadFile(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		data    []byte
+		wantErr error
+	}{
+		"Native": {
+			data: native,
+		},
+		"Script": {
+			data: []byte("#!/bin/sh\necho {}\n"),
+		},
+		"NotExecutable": {
+			data:    []byte("helper"),
+			wantErr: fmt.Errorf(util.ErrorTemplateNotExecutable, "helper", runtime.GOARCH),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if runtime.GOOS != "linux" && name == "Native" {
+				t.Skip("test binaries are only ELF on linux")
+			}
+			err := checkExecutable("helper", tc.data)
+			if diff := cmp.Diff(tc.wantErr, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCredentialHelperServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	nc := &fake.FakeNodeClient{
+		MockGetResources: func(ctx context.Context, barName, podName, podNs string) (*v1alpha1.Bucket, *v1alpha1.BucketAccess, *v1.Secret, *v1.Pod, error) {
+			secret := &v1.Secret{Data: map[string][]byte{
+				"accessKeyID":     []byte("AKIAEXAMPLE"),
+				"accessSecretKey": []byte("secret"),
+			}}
+			return nil, nil, secret, nil, nil
+		},
+	}
+	h := newCredentialHelper("/cosi-credential-helper", false, nc, client.NewProvisionerClient())
+	if err := h.serve("vol", dir, credentialSource{barName: "bar"}, noGroup); err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(dir, adapter.CredentialHelperSocketName)
+	c := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := c.Get("http://cosi" + adapter.CredentialHelperPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var got adapter.ProcessCredentials
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := adapter.ProcessCredentials{Version: 1, AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	h.stop("vol")
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket not removed after stop: %v", err)
+	}
+}
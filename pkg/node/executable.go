@@ -0,0 +1,43 @@
+package node
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"runtime"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// elfMachines maps the architectures images are built for to the ELF machine
+// of their executables.
+var elfMachines = map[string]elf.Machine{
+	"386":     elf.EM_386,
+	"amd64":   elf.EM_X86_64,
+	"arm":     elf.EM_ARM,
+	"arm64":   elf.EM_AARCH64,
+	"ppc64le": elf.EM_PPC64,
+	"s390x":   elf.EM_S390,
+}
+
+// checkExecutable verifies that data, the content of the executable name, runs
+// on the node's architecture, so a multi-arch image that ships the wrong build
+// fails here rather than with an exec format error in the workload. Scripts
+// and architectures missing from elfMachines are not checked.
+func checkExecutable(name string, data []byte) error {
+	if bytes.HasPrefix(data, []byte("#!")) {
+		return nil
+	}
+	want, ok := elfMachines[runtime.GOARCH]
+	if !ok {
+		return nil
+	}
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf(util.ErrorTemplateNotExecutable, name, runtime.GOARCH)
+	}
+	if f.Machine != want {
+		return fmt.Errorf(util.ErrorTemplateExecutableArch, name, f.Machine, runtime.GOARCH)
+	}
+	return nil
+}
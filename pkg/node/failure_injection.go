@@ -0,0 +1,162 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// FailureInjectionAnnotation on a pod lists failures the adapter simulates for
+// its volumes, comma separated:
+//
+//	slow-publish=<duration>  delays NodePublishVolume
+//	fail-publish             fails NodePublishVolume with Unavailable
+//	revoke-after=<duration>  replaces the credentials in the volume with
+//	                         invalid ones that long after publish
+//
+// It is honored only with the FailureInjection feature gate, for pods in the
+// namespaces allowed by Options.FailureInjectionNamespaces.
+const FailureInjectionAnnotation = "cosi.objectstorage.k8s.io/inject-failures"
+
+// revokedValue replaces every credential of a volume revoked by failure
+// injection.
+const revokedValue = "revoked-by-failure-injection"
+
+// injectedFailures are the failures simulated for the volumes of a pod.
+type injectedFailures struct {
+	publishDelay time.Duration
+	failPublish  bool
+	revokeAfter  time.Duration
+}
+
+// parseInjectedFailures parses the value of FailureInjectionAnnotation.
+func parseInjectedFailures(v string) (injectedFailures, error) {
+	var f injectedFailures
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		var err error
+		switch {
+		case kv[0] == "fail-publish" && len(kv) == 1:
+			f.failPublish = true
+		case kv[0] == "slow-publish" && len(kv) == 2:
+			f.publishDelay, err = time.ParseDuration(kv[1])
+		case kv[0] == "revoke-after" && len(kv) == 2:
+			f.revokeAfter, err = time.ParseDuration(kv[1])
+		default:
+			err = errors.New("unknown failure")
+		}
+		if err != nil || f.publishDelay < 0 || f.revokeAfter < 0 {
+			return injectedFailures{}, fmt.Errorf(util.ErrorTemplateInvalidInjectedFailure, item)
+		}
+	}
+	return f, nil
+}
+
+// injectedFailures returns the failures to simulate for the volumes of pod,
+// none unless failure injection is enabled for its namespace or pod is nil.
+func (n *NodeServer) injectedFailures(pod *v1.Pod) (injectedFailures, error) {
+	if pod == nil {
+		return injectedFailures{}, nil
+	}
+	v, ok := pod.Annotations[FailureInjectionAnnotation]
+	if !ok || !n.failureInjection[pod.Namespace] {
+		return injectedFailures{}, nil
+	}
+	klog.InfoS("injecting failures", "pod", klog.KObj(pod), "failures", v)
+	return parseInjectedFailures(v)
+}
+
+// beforePublish simulates the failures of a publish.
+func (f injectedFailures) beforePublish(ctx context.Context) error {
+	if f.publishDelay > 0 {
+		select {
+		case <-ctx.Done():
+			return status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+		case <-time.After(f.publishDelay):
+		}
+	}
+	if f.failPublish {
+		return status.Error(codes.Unavailable, util.ErrorPublishFailureInjected.Error())
+	}
+	return nil
+}
+
+// revokeInjected replaces every value of the minted secret in the files of
+// volID with revokedValue, as if the provisioner had revoked the credentials
+// while the pod uses them. The digests of the revoked files are recorded, so
+// the next refresh finds them stale and restores the credentials.
+func (n *NodeServer) revokeInjected(ctx context.Context, volID string) error {
+	defer n.locks.lock(volID)()
+
+	data, err := n.provisioner.readFileFromVolume(volID, metadataFilename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToReadMetadataFile)
+	}
+	meta := Metadata{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToUnmarshalMetadata)
+	}
+	if !meta.refreshable() {
+		return nil
+	}
+
+	plan, err := n.resolve(ctx, volID, meta.VolumeContext, true)
+	if err != nil {
+		return err
+	}
+	creds, err := plan.credentialFiles()
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToParseSecret)
+	}
+	projected := plan.withRendered(append([]render.File{{Name: plan.protocolFile, Data: plan.protocolConnection}}, creds...))
+	for i := range projected {
+		revoked := projected[i].Data
+		for _, value := range plan.secret.Data {
+			if len(value) > 0 {
+				revoked = bytes.ReplaceAll(revoked, value, []byte(revokedValue))
+			}
+		}
+		projected[i].Data = revoked
+	}
+
+	dirs := append([]string{""}, plan.subdirs...)
+	prev, err := n.provisioner.readManifest(volID)
+	if err != nil {
+		return err
+	}
+	if err := n.provisioner.writeProjected(volID, atomicFiles(dirs, projected), plan.fileMode, plan.gid); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWriteProjected)
+	}
+	meta.Files = fileDigests(dirs, projected)
+	if err := n.recordManifest(volID, prev, meta.Files); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(meta); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToMarshalMetadata)
+	}
+	if err := n.provisioner.replaceFileInVolume(data, volID, metadataFilename); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWriteMetadata)
+	}
+	klog.InfoS("revoked the credentials of volume by failure injection", "volumeID", volID)
+	return nil
+}
@@ -0,0 +1,51 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestParseInjectedFailures(t *testing.T) {
+	type want struct {
+		failures injectedFailures
+		err      error
+	}
+
+	cases := map[string]struct {
+		value string
+		want
+	}{
+		"Empty": {},
+		"All": {
+			value: "slow-publish=30s, fail-publish,revoke-after=10m",
+			want:  want{failures: injectedFailures{publishDelay: 30 * time.Second, failPublish: true, revokeAfter: 10 * time.Minute}},
+		},
+		"UnknownFailure": {
+			value: "fail-unpublish",
+			want:  want{err: fmt.Errorf(util.ErrorTemplateInvalidInjectedFailure, "fail-unpublish")},
+		},
+		"MissingDuration": {
+			value: "slow-publish",
+			want:  want{err: fmt.Errorf(util.ErrorTemplateInvalidInjectedFailure, "slow-publish")},
+		},
+		"NegativeDuration": {
+			value: "revoke-after=-1m",
+			want:  want{err: fmt.Errorf(util.ErrorTemplateInvalidInjectedFailure, "revoke-after=-1m")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			failures, err := parseInjectedFailures(tc.value)
+			got := want{failures: failures, err: err}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{}, injectedFailures{}), util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package node
+
+import (
+	"sync"
+
+	"k8s.io/klog/v2"
+)
+
+// failureTracker counts consecutive failures per volume. Once a volume crosses
+// the threshold its logging is elevated to the default level until the next
+// success, so a single misbehaving volume can be debugged without turning up
+// verbosity for the whole node.
+type failureTracker struct {
+	mu        sync.Mutex
+	threshold int
+	streaks   map[string]int
+}
+
+// newFailureTracker returns nil when threshold is not positive, which disables
+// elevation entirely. All methods are safe to call on a nil tracker.
+func newFailureTracker(threshold int) *failureTracker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &failureTracker{
+		threshold: threshold,
+		streaks:   map[string]int{},
+	}
+}
+
+// observe records the outcome of an operation on volID. It returns true only for
+// the failure that first crosses the threshold, so callers can capture a one-shot
+// snapshot of the volume's state.
+func (f *failureTracker) observe(volID string, err error) bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		if f.streaks[volID] >= f.threshold {
+			klog.InfoS("volume recovered, dropping back to default verbosity", "volumeID", volID)
+		}
+		delete(f.streaks, volID)
+		return false
+	}
+
+	f.streaks[volID]++
+	return f.streaks[volID] == f.threshold
+}
+
+// forget drops the streak of volID, which was unpublished.
+func (f *failureTracker) forget(volID string) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.streaks, volID)
+}
+
+func (f *failureTracker) elevated(volID string) bool {
+	if f == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.streaks[volID] >= f.threshold
+}
+
+// v returns a logger for volID at the requested level, or at level 0 while the
+// volume is failing repeatedly.
+func (f *failureTracker) v(volID string, level klog.Level) klog.Verbose {
+	if f.elevated(volID) {
+		return klog.V(0)
+	}
+	return klog.V(level)
+}
@@ -0,0 +1,85 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFailureTracker(t *testing.T) {
+	type want struct {
+		snapshots []bool
+		elevated  bool
+	}
+
+	cases := map[string]struct {
+		threshold int
+		outcomes  []error
+		want
+	}{
+		"Disabled": {
+			threshold: 0,
+			outcomes:  []error{errBoom, errBoom, errBoom},
+			want: want{
+				snapshots: []bool{false, false, false},
+				elevated:  false,
+			},
+		},
+		"BelowThreshold": {
+			threshold: 3,
+			outcomes:  []error{errBoom, errBoom},
+			want: want{
+				snapshots: []bool{false, false},
+				elevated:  false,
+			},
+		},
+		"SnapshotOncePerStreak": {
+			threshold: 2,
+			outcomes:  []error{errBoom, errBoom, errBoom, errBoom},
+			want: want{
+				snapshots: []bool{false, true, false, false},
+				elevated:  true,
+			},
+		},
+		"SuccessResetsStreak": {
+			threshold: 2,
+			outcomes:  []error{errBoom, errBoom, nil, errBoom},
+			want: want{
+				snapshots: []bool{false, true, false, false},
+				elevated:  false,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := newFailureTracker(tc.threshold)
+
+			var snapshots []bool
+			for _, err := range tc.outcomes {
+				snapshots = append(snapshots, f.observe(volumeId, err))
+			}
+
+			if diff := cmp.Diff(tc.want.snapshots, snapshots); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.elevated, f.elevated(volumeId)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFailureTrackerForget(t *testing.T) {
+	f := newFailureTracker(1)
+	f.observe(volumeId, errBoom)
+	f.forget(volumeId)
+
+	if diff := cmp.Diff(0, len(f.streaks)); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+
+	var none *failureTracker
+	none.forget(volumeId)
+}
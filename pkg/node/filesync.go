@@ -0,0 +1,164 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// SyncPolicy selects when files written to the data directory are fsynced.
+type SyncPolicy string
+
+const (
+	// SyncAlways fsyncs every file, and its directory, as it is written.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval batches written files and fsyncs them every interval.
+	SyncInterval SyncPolicy = "interval"
+	// SyncOnCritical fsyncs only the volume metadata, which unpublish relies
+	// on; projected files are rewritten by the next publish if lost.
+	SyncOnCritical SyncPolicy = "on-critical"
+	// SyncNever leaves flushing to the kernel.
+	SyncNever SyncPolicy = "never"
+
+	defaultSyncInterval = 5 * time.Second
+
+	// maxDirty bounds the files waiting for an interval flush. Beyond it the
+	// writer flushes synchronously, pushing back on publish churn instead of
+	// letting unsynced state grow without bound.
+	maxDirty = 256
+)
+
+// ParseSyncPolicy validates the name of a SyncPolicy.
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch p := SyncPolicy(s); p {
+	case SyncAlways, SyncInterval, SyncOnCritical, SyncNever:
+		return p, nil
+	}
+	return "", fmt.Errorf(util.ErrorTemplateUnknownSyncPolicy, s)
+}
+
+// FileSyncConfig configures durability of the data directory.
+type FileSyncConfig struct {
+	Policy   SyncPolicy
+	Interval time.Duration
+}
+
+// FileSyncer applies a SyncPolicy to files written to the data directory. A
+// nil *FileSyncer never syncs. Under SyncInterval it must be started to flush
+// its batches.
+type FileSyncer struct {
+	policy   SyncPolicy
+	interval time.Duration
+	fsync    func(path string) error
+
+	mu    sync.Mutex
+	dirty map[string]bool
+}
+
+// NewFileSyncer returns a FileSyncer for config, or nil if nothing is synced.
+func NewFileSyncer(config FileSyncConfig) *FileSyncer {
+	if config.Policy == "" || config.Policy == SyncNever {
+		return nil
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultSyncInterval
+	}
+	return &FileSyncer{
+		policy:   config.Policy,
+		interval: config.Interval,
+		fsync:    fsyncFile,
+		dirty:    map[string]bool{},
+	}
+}
+
+// written records that path was written. critical marks writes that must be
+// durable before the caller proceeds under the on-critical policy.
+func (s *FileSyncer) written(path string, critical bool) error {
+	if s == nil {
+		return nil
+	}
+
+	switch s.policy {
+	case SyncAlways:
+		return s.syncWithDir(path)
+	case SyncOnCritical:
+		if critical {
+			return s.syncWithDir(path)
+		}
+	case SyncInterval:
+		s.mu.Lock()
+		s.dirty[path] = true
+		s.dirty[filepath.Dir(path)] = true
+		full := len(s.dirty) >= maxDirty
+		s.mu.Unlock()
+		if full {
+			s.flush()
+		}
+	}
+	return nil
+}
+
+func (s *FileSyncer) syncWithDir(path string) error {
+	if err := s.fsync(path); err != nil {
+		return err
+	}
+	return s.fsync(filepath.Dir(path))
+}
+
+// flush fsyncs every batched path. Paths removed since they were written are
+// skipped.
+func (s *FileSyncer) flush() {
+	s.mu.Lock()
+	paths := make([]string, 0, len(s.dirty))
+	for p := range s.dirty {
+		paths = append(paths, p)
+	}
+	s.dirty = map[string]bool{}
+	s.mu.Unlock()
+
+	// Files before the directories holding them.
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	for _, p := range paths {
+		if err := s.fsync(p); err != nil && !os.IsNotExist(err) {
+			klog.ErrorS(err, "failed to fsync", "path", p)
+		}
+	}
+}
+
+// Start flushes batched writes every interval until ctx is done, then once
+// more.
+func (s *FileSyncer) Start(ctx context.Context) error {
+	if s.policy != SyncInterval {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.flush()
+			return nil
+		case <-ticker.C:
+			s.flush()
+		}
+	}
+}
+
+func fsyncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
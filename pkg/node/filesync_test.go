@@ -0,0 +1,72 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileSyncer(t *testing.T) {
+	type write struct {
+		path     string
+		critical bool
+	}
+
+	cases := map[string]struct {
+		policy SyncPolicy
+		writes []write
+		flush  bool
+		want   []string
+	}{
+		"Never": {
+			policy: SyncNever,
+			writes: []write{{path: "/data/vol/metadata.json", critical: true}},
+		},
+		"Always": {
+			policy: SyncAlways,
+			writes: []write{{path: "/data/vol/bucket/credentials"}, {path: "/data/vol/metadata.json", critical: true}},
+			want:   []string{"/data/vol/bucket/credentials", "/data/vol/bucket", "/data/vol/metadata.json", "/data/vol"},
+		},
+		"OnCritical": {
+			policy: SyncOnCritical,
+			writes: []write{{path: "/data/vol/bucket/credentials"}, {path: "/data/vol/metadata.json", critical: true}},
+			want:   []string{"/data/vol/metadata.json", "/data/vol"},
+		},
+		"IntervalBeforeFlush": {
+			policy: SyncInterval,
+			writes: []write{{path: "/data/vol/bucket/credentials"}, {path: "/data/vol/metadata.json", critical: true}},
+		},
+		"IntervalFlush": {
+			policy: SyncInterval,
+			writes: []write{{path: "/data/vol/bucket/credentials"}, {path: "/data/vol/bucket/protocolConn.json"}},
+			flush:  true,
+			want:   []string{"/data/vol/bucket/protocolConn.json", "/data/vol/bucket/credentials", "/data/vol/bucket"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var synced []string
+			s := NewFileSyncer(FileSyncConfig{Policy: tc.policy})
+			if s != nil {
+				s.fsync = func(path string) error {
+					synced = append(synced, path)
+					return nil
+				}
+			}
+
+			for _, w := range tc.writes {
+				if err := s.written(w.path, w.critical); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.flush {
+				s.flush()
+			}
+
+			if diff := cmp.Diff(tc.want, synced); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package node
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// releaseSecretFinalizer removes the finalizer of the volume described by meta
+// from secret, the "namespace/name" of a minted secret, if protected says the
+// volume placed it there.
+func (n *NodeServer) releaseSecretFinalizer(ctx context.Context, meta Metadata, secret string, protected bool) error {
+	if !protected || secret == "" {
+		return nil
+	}
+	parts := strings.SplitN(secret, "/", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+	return n.cosiClient.RemoveSecretFinalizer(ctx, parts[0], parts[1], meta.finalizer())
+}
+
+// finalizerErrorCode returns the code of a failure to add or remove the
+// finalizer of a volume. kubelet retries publishes and unpublishes whatever the
+// code, but the code tells a transient failure apart from one that needs an
+// administrator.
+func finalizerErrorCode(err error) codes.Code {
+	switch {
+	case apierrors.IsConflict(err):
+		// The BucketAccess was replaced since it was read, or kept changing
+		// until the retries ran out.
+		return codes.Aborted
+	case apierrors.IsNotFound(err):
+		return codes.NotFound
+	case apierrors.IsForbidden(err):
+		return codes.PermissionDenied
+	case apierrors.IsInvalid(err):
+		// No finalizer can be added to a BucketAccess being deleted.
+		return codes.FailedPrecondition
+	case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err), apierrors.IsTooManyRequests(err), apierrors.IsServiceUnavailable(err):
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
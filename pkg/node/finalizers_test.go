@@ -0,0 +1,168 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/mount-utils"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestReleaseSecretFinalizer(t *testing.T) {
+	meta := Metadata{PodName: "pod", PodNamespace: "ns", Finalizer: podFinalizer(finalizer, "uid")}
+
+	cases := map[string]struct {
+		secret    string
+		protected bool
+		want      []string
+	}{
+		"Protected": {
+			secret:    "ns/minted",
+			protected: true,
+			want:      []string{"ns/minted/" + string(meta.finalizer())},
+		},
+		"NotProtected": {
+			secret: "ns/minted",
+		},
+		"NoSecret": {
+			protected: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var removed []string
+			n := &NodeServer{cosiClient: &fake.FakeNodeClient{
+				MockRemoveSecretFinalizer: func(ctx context.Context, namespace, name string, f client.Finalizer) error {
+					removed = append(removed, namespace+"/"+name+"/"+string(f))
+					return nil
+				},
+			}}
+			if err := n.releaseSecretFinalizer(context.Background(), meta, tc.secret, tc.protected); err != nil {
+				t.Fatalf("releaseSecretFinalizer(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want, removed); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFinalizerErrorCode(t *testing.T) {
+	bucketAccesses := schema.GroupResource{Group: "objectstorage.k8s.io", Resource: "bucketaccesses"}
+
+	cases := map[string]struct {
+		err  error
+		want codes.Code
+	}{
+		"Conflict": {
+			err:  apierrors.NewConflict(bucketAccesses, "ba", errBoom),
+			want: codes.Aborted,
+		},
+		"NotFound": {
+			err:  apierrors.NewNotFound(bucketAccesses, "ba"),
+			want: codes.NotFound,
+		},
+		"Forbidden": {
+			err:  apierrors.NewForbidden(bucketAccesses, "ba", errBoom),
+			want: codes.PermissionDenied,
+		},
+		"BeingDeleted": {
+			err: apierrors.NewInvalid(schema.GroupKind{Group: "objectstorage.k8s.io", Kind: "BucketAccess"}, "ba", field.ErrorList{
+				field.Forbidden(field.NewPath("metadata", "finalizers"), "no new finalizers can be added if the object is being deleted"),
+			}),
+			want: codes.FailedPrecondition,
+		},
+		"TooManyRequests": {
+			err:  apierrors.NewTooManyRequests("slow down", 1),
+			want: codes.Unavailable,
+		},
+		"Other": {
+			err:  errBoom,
+			want: codes.Internal,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, finalizerErrorCode(tc.err)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnpublishRetriesFinalizerRemoval(t *testing.T) {
+	dataRoot := t.TempDir()
+	if _, err := EnsureLayout(dataRoot); err != nil {
+		t.Fatal(err)
+	}
+	const volID = "vol"
+	meta := Metadata{BaName: "ba", PodName: "pod", PodNamespace: "ns", Finalizer: podFinalizer(finalizer, "uid")}
+	if err := os.MkdirAll(filepath.Join(dataRoot, volID, "bucket"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataRoot, volID, metadataFilename), data, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	conflicts := 1
+	var removed []client.Finalizer
+	n := &NodeServer{
+		provisioner: NewProvisioner(dataRoot, mount.NewFakeMounter(nil), client.NewProvisionerClient()),
+		cosiClient: &fake.FakeNodeClient{
+			MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+				return testutils.GetPod(), nil
+			},
+			MockGetBA: func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
+				return testutils.GetBA(), nil
+			},
+			MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, f client.Finalizer) error {
+				if conflicts > 0 {
+					conflicts--
+					return apierrors.NewConflict(v1alpha1.SchemeGroupVersion.WithResource("bucketaccesses").GroupResource(), ba.Name, errBoom)
+				}
+				removed = append(removed, f)
+				return nil
+			},
+		},
+	}
+	request := &csi.NodeUnpublishVolumeRequest{VolumeId: volID, TargetPath: filepath.Join(dataRoot, "target")}
+
+	if _, err := n.NodeUnpublishVolume(ctx, request); status.Code(err) != codes.Aborted {
+		t.Errorf("NodeUnpublishVolume(...): want code %v, got %v", codes.Aborted, err)
+	}
+	if _, err := os.Stat(filepath.Join(dataRoot, volID, metadataFilename)); err != nil {
+		t.Errorf("metadata after a failed unpublish: %v", err)
+	}
+
+	if _, err := n.NodeUnpublishVolume(ctx, request); err != nil {
+		t.Fatalf("retried NodeUnpublishVolume(...): %v", err)
+	}
+	if diff := cmp.Diff([]client.Finalizer{meta.finalizer()}, removed); diff != "" {
+		t.Errorf("removed finalizers: -want, +got:\n%s", diff)
+	}
+	if _, err := os.Stat(filepath.Join(dataRoot, volID)); !os.IsNotExist(err) {
+		t.Errorf("volume after the retried unpublish: want it removed, got %v", err)
+	}
+}
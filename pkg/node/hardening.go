@@ -0,0 +1,61 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// DirModeKey sets the permissions, in octal, of the directories created for a
+// volume, such as "0750" for a pod whose containers share a supplemental group.
+const DirModeKey = adapter.DirModeKey
+
+const (
+	defaultDirMode  os.FileMode = 0750
+	hardenedDirMode os.FileMode = 0700
+
+	// hardenedUmask strips group and world permissions from everything the
+	// adapter creates, whatever mode the code asks for.
+	hardenedUmask = 0077
+)
+
+// parseDirMode returns the directory permissions requested by the volume
+// context. In hardened mode the default is hardenedDirMode and modes granting
+// access to other users are rejected.
+func parseDirMode(volCtx map[string]string, hardened bool) (os.FileMode, error) {
+	value, ok := volCtx[DirModeKey]
+	if !ok {
+		if hardened {
+			return hardenedDirMode, nil
+		}
+		return defaultDirMode, nil
+	}
+
+	mode, err := adapter.ParseDirMode(value)
+	if err != nil {
+		return 0, err
+	}
+	if hardened && mode&0007 != 0 {
+		return 0, fmt.Errorf(util.ErrorTemplateHardenedDirMode, value)
+	}
+	return mode, nil
+}
+
+// verifyNotWorldAccessible walks root and fails on the first file readable, or
+// directory accessible, by other users. Symlinks are not followed.
+func verifyNotWorldAccessible(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		perm := info.Mode().Perm()
+		switch {
+		case info.IsDir() && perm&0007 != 0, info.Mode().IsRegular() && perm&0004 != 0:
+			return fmt.Errorf(util.ErrorTemplateWorldAccessible, path, perm)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,124 @@
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestParseDirMode(t *testing.T) {
+	type want struct {
+		mode os.FileMode
+		err  error
+	}
+
+	cases := map[string]struct {
+		volCtx   map[string]string
+		hardened bool
+		want
+	}{
+		"Default": {
+			want: want{mode: defaultDirMode},
+		},
+		"HardenedDefault": {
+			hardened: true,
+			want:     want{mode: hardenedDirMode},
+		},
+		"Attribute": {
+			volCtx: map[string]string{DirModeKey: "0770"},
+			want:   want{mode: 0770},
+		},
+		"NotOctal": {
+			volCtx: map[string]string{DirModeKey: "rwx"},
+			want:   want{err: fmt.Errorf(util.ErrorTemplateInvalidDirMode, "rwx")},
+		},
+		"SpecialBits": {
+			volCtx: map[string]string{DirModeKey: "4750"},
+			want:   want{err: fmt.Errorf(util.ErrorTemplateInvalidDirMode, "4750")},
+		},
+		"WorldAccessible": {
+			volCtx: map[string]string{DirModeKey: "0755"},
+			want:   want{mode: 0755},
+		},
+		"HardenedWorldAccessible": {
+			volCtx:   map[string]string{DirModeKey: "0755"},
+			hardened: true,
+			want:     want{err: fmt.Errorf(util.ErrorTemplateHardenedDirMode, "0755")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mode, err := parseDirMode(tc.volCtx, tc.hardened)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.mode, mode); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestVerifyNotWorldAccessible(t *testing.T) {
+	cases := map[string]struct {
+		dirMode  os.FileMode
+		fileMode os.FileMode
+		wantErr  bool
+	}{
+		"Private": {
+			dirMode:  0700,
+			fileMode: 0400,
+		},
+		"GroupReadable": {
+			dirMode:  0750,
+			fileMode: 0440,
+		},
+		"WorldReadableFile": {
+			dirMode:  0700,
+			fileMode: 0444,
+			wantErr:  true,
+		},
+		"WorldAccessibleDir": {
+			dirMode:  0711,
+			fileMode: 0400,
+			wantErr:  true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "bucket")
+			if err := os.Mkdir(dir, 0700); err != nil {
+				t.Fatal(err)
+			}
+			file := filepath.Join(dir, credsFileName)
+			if err := ioutil.WriteFile(file, []byte("secret"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			// Chmod is not subject to the umask of the test process.
+			if err := os.Chmod(file, tc.fileMode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(dir, tc.dirMode); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(root, 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			err := verifyNotWorldAccessible(root)
+			if diff := cmp.Diff(tc.wantErr, err != nil); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
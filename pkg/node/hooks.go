@@ -0,0 +1,40 @@
+package node
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+)
+
+// ErrorTranslator rewrites the errors the NodeServer returns to kubelet, e.g.
+// to attach the support codes of a distribution embedding the adapter. err is
+// a gRPC status error and method the CSI method that returned it. The result
+// should remain a gRPC status error so kubelet can act on its code.
+type ErrorTranslator interface {
+	TranslateError(method string, err error) error
+}
+
+// translateError passes non-nil errors through t, if any.
+func translateError(t ErrorTranslator, method string, err error) error {
+	if t == nil || err == nil {
+		return err
+	}
+	return t.TranslateError(method, err)
+}
+
+// registerMetrics registers the adapter metrics with r, for distributions
+// serving them from a registry of their own. The default registry,
+// metrics.Registry, is always populated.
+func registerMetrics(r prometheus.Registerer) error {
+	if r == nil {
+		return nil
+	}
+	for _, c := range metrics.Collectors() {
+		if err := r.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				return err
+			}
+		}
+	}
+	return nil
+}
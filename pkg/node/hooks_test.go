@@ -0,0 +1,76 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+type supportCodes struct{}
+
+func (supportCodes) TranslateError(method string, err error) error {
+	s := status.Convert(err)
+	return status.Errorf(s.Code(), "[%s-%s] %s", method, s.Code(), s.Message())
+}
+
+func TestTranslateError(t *testing.T) {
+	cases := map[string]struct {
+		translator ErrorTranslator
+		err        error
+		want       error
+	}{
+		"NoTranslator": {
+			err:  status.Error(codes.NotFound, "gone"),
+			want: status.Error(codes.NotFound, "gone"),
+		},
+		"NoError": {
+			translator: supportCodes{},
+		},
+		"Translated": {
+			translator: supportCodes{},
+			err:        status.Error(codes.NotFound, "gone"),
+			want:       status.Error(codes.NotFound, "[NodePublishVolume-NotFound] gone"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := translateError(tc.translator, "NodePublishVolume", tc.err)
+			if diff := cmp.Diff(tc.want, got, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	r := prometheus.NewRegistry()
+	if err := registerMetrics(r); err != nil {
+		t.Fatal(err)
+	}
+	// Several servers may share a registry.
+	if err := registerMetrics(r); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics.UnpublishUnknownVolume.Inc()
+	families, err := r.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, f := range families {
+		if f.GetName() == "cosi_csi_adapter_unpublish_unknown_volume_total" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("adapter metrics not registered with the custom registry")
+	}
+}
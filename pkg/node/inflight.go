@@ -0,0 +1,45 @@
+package node
+
+import (
+	"fmt"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// inFlight tracks the publishes and unpublishes in progress, by volume ID.
+// kubelet retries a call that timed out while the first one still runs; rather
+// than queueing behind it on the volume lock, the retry fails with Aborted as
+// the CSI spec recommends, and kubelet retries it again with backoff.
+//
+// A nil *inFlight admits every call.
+type inFlight struct {
+	mu  sync.Mutex
+	ops map[string]string
+}
+
+func newInFlight() *inFlight {
+	return &inFlight{ops: map[string]string{}}
+}
+
+// begin records op as in flight for volID and returns the function ending it.
+// It returns an Aborted error if an operation on volID is already in flight.
+func (f *inFlight) begin(volID, op string) (func(), error) {
+	if f == nil {
+		return func() {}, nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if running, ok := f.ops[volID]; ok {
+		return nil, status.Error(codes.Aborted, fmt.Sprintf(util.ErrorTemplateOperationInFlight, running, volID))
+	}
+	f.ops[volID] = op
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.ops, volID)
+	}, nil
+}
@@ -0,0 +1,48 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestInFlight(t *testing.T) {
+	f := newInFlight()
+
+	done, err := f.begin("vol-1", "publish")
+	if err != nil {
+		t.Fatalf("begin(vol-1, publish): %v", err)
+	}
+
+	want := status.Error(codes.Aborted, fmt.Sprintf(util.ErrorTemplateOperationInFlight, "publish", "vol-1"))
+	if _, err := f.begin("vol-1", "unpublish"); cmp.Diff(want, err, util.EquateErrors()) != "" {
+		t.Errorf("begin(vol-1, unpublish) while publishing: want %v, got %v", want, err)
+	}
+	if _, err := f.begin("vol-1", "publish"); status.Code(err) != codes.Aborted {
+		t.Errorf("begin(vol-1, publish) while publishing: want code %v, got %v", codes.Aborted, err)
+	}
+
+	other, err := f.begin("vol-2", "publish")
+	if err != nil {
+		t.Errorf("begin(vol-2, publish): %v", err)
+	} else {
+		other()
+	}
+
+	done()
+	if again, err := f.begin("vol-1", "unpublish"); err != nil {
+		t.Errorf("begin(vol-1, unpublish) after publish ended: %v", err)
+	} else {
+		again()
+	}
+
+	var none *inFlight
+	if _, err := none.begin("vol-1", "publish"); err != nil {
+		t.Errorf("nil inFlight: begin(vol-1, publish): %v", err)
+	}
+}
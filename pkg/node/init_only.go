@@ -0,0 +1,173 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// initOnlyPollInterval is the time between two checks of whether the pod of
+// an init-only volume runs.
+const initOnlyPollInterval = 5 * time.Second
+
+// initOnlyWatches wipes the credentials of init-only volumes once their pod
+// leaves the Pending phase, which it does after its init containers have run,
+// shrinking the window in which the credentials can be read from the node.
+//
+// A nil *initOnlyWatches watches nothing.
+type initOnlyWatches struct {
+	interval time.Duration
+	getPod   func(ctx context.Context, podName, podNs string) (*v1.Pod, error)
+	wipe     func(ctx context.Context, volID string) error
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func newInitOnlyWatches(interval time.Duration, getPod func(ctx context.Context, podName, podNs string) (*v1.Pod, error), wipe func(ctx context.Context, volID string) error) *initOnlyWatches {
+	return &initOnlyWatches{interval: interval, getPod: getPod, wipe: wipe, cancels: map[string]context.CancelFunc{}}
+}
+
+// watch wipes volID once the pod podNs/podName runs, replacing the previous
+// watch of volID.
+func (w *initOnlyWatches) watch(volID, podNs, podName string) {
+	if w == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.mu.Lock()
+	if c, ok := w.cancels[volID]; ok {
+		c()
+	}
+	w.cancels[volID] = cancel
+	w.mu.Unlock()
+
+	go func() {
+		defer w.cancel(volID)
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			if w.poll(ctx, volID, podNs, podName) {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// poll checks the pod of volID once, wiping the volume if it runs. It returns
+// true once there is nothing left to watch.
+func (w *initOnlyWatches) poll(ctx context.Context, volID, podNs, podName string) bool {
+	pod, err := w.getPod(ctx, podName, podNs)
+	if apierrors.IsNotFound(err) {
+		return true
+	}
+	if err != nil {
+		klog.V(4).InfoS("failed to check the phase of the pod of init-only volume", "volumeID", volID, "err", err)
+		return false
+	}
+	if pod.Status.Phase == v1.PodPending {
+		return false
+	}
+	if err := w.wipe(ctx, volID); err != nil {
+		klog.ErrorS(err, "failed to remove the credentials of init-only volume, retrying", "volumeID", volID)
+		return false
+	}
+	return true
+}
+
+// cancel stops watching volID.
+func (w *initOnlyWatches) cancel(volID string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if c, ok := w.cancels[volID]; ok {
+		c()
+		delete(w.cancels, volID)
+	}
+}
+
+// wipeCredentials rewrites the files of an init-only volume without its
+// credentials. The volume stays mounted with the protocol file and the
+// formats writing no credentials, and is no longer refreshed.
+func (n *NodeServer) wipeCredentials(ctx context.Context, volID string) error {
+	defer n.locks.lock(volID)()
+
+	data, err := n.provisioner.readFileFromVolume(volID, metadataFilename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToReadMetadataFile)
+	}
+	meta := Metadata{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToUnmarshalMetadata)
+	}
+	if meta.CredentialsWiped || meta.VolumeContext == nil {
+		return nil
+	}
+
+	plan, err := n.resolve(ctx, volID, meta.VolumeContext, true)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWipeCredentials)
+	}
+	if meta.MountGroup != nil {
+		plan.gid = *meta.MountGroup
+	}
+	if err := plan.wipe(meta.VolumeContext); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWipeCredentials)
+	}
+	projected := plan.withRendered([]render.File{{Name: plan.protocolFile, Data: plan.protocolConnection}})
+
+	dirs := append([]string{""}, plan.subdirs...)
+	prev, err := n.provisioner.readManifest(volID)
+	if err != nil {
+		return err
+	}
+	if err := n.provisioner.writeProjected(volID, atomicFiles(dirs, projected), plan.fileMode, plan.gid); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWriteProjected)
+	}
+	meta.Files = fileDigests(dirs, projected)
+	if err := n.recordManifest(volID, prev, meta.Files); err != nil {
+		return err
+	}
+
+	// The volume no longer needs the minted secret. Failing to release it
+	// leaves the volume to be wiped again.
+	if err := n.releaseSecretFinalizer(ctx, meta, meta.Secret, meta.SecretFinalizerAdded); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToRemoveSecretFinalizer)
+	}
+	meta.CredentialsWiped = true
+	meta.Secret = ""
+	meta.SecretFinalizerAdded = false
+	meta.Expiration = nil
+	if data, err = json.Marshal(meta); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToMarshalMetadata)
+	}
+	if err := n.provisioner.replaceFileInVolume(data, volID, metadataFilename); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWriteMetadata)
+	}
+	n.accounting.add(volID, meta.materialized())
+	n.renewals.cancel(volID)
+
+	klog.InfoS("removed the credentials of init-only volume", "volumeID", volID)
+	util.EmitNormalEvent(n.cosiClient.Recorder(), plan.pod, util.InitOnlyCredentialsWiped)
+	return nil
+}
@@ -0,0 +1,73 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestInitOnlyPoll(t *testing.T) {
+	type want struct {
+		done  bool
+		wiped bool
+	}
+
+	cases := map[string]struct {
+		phase   v1.PodPhase
+		podErr  error
+		wipeErr error
+		want
+	}{
+		"InitContainersRunning": {
+			phase: v1.PodPending,
+		},
+		"Running": {
+			phase: v1.PodRunning,
+			want:  want{done: true, wiped: true},
+		},
+		"Succeeded": {
+			phase: v1.PodSucceeded,
+			want:  want{done: true, wiped: true},
+		},
+		"PodDeleted": {
+			podErr: apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "pod"),
+			want:   want{done: true},
+		},
+		"PodUnreadable": {
+			podErr: errBoom,
+		},
+		"WipeFailed": {
+			phase:   v1.PodRunning,
+			wipeErr: errBoom,
+			want:    want{wiped: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := want{}
+			w := newInitOnlyWatches(time.Hour,
+				func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+					pod := testutils.GetPod()
+					pod.Status.Phase = tc.phase
+					return pod, tc.podErr
+				},
+				func(ctx context.Context, volID string) error {
+					got.wiped = true
+					return tc.wipeErr
+				})
+
+			got.done = w.poll(context.Background(), "vol", testutils.Namespace, "pod")
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Keys of a minted secret carrying Kerberos credentials, as used by RGW
+// deployments integrated with Keystone or Active Directory.
+const (
+	KeytabSecretKey    = "keytab"
+	Krb5ConfSecretKey  = "krb5.conf"
+	PrincipalSecretKey = "principal"
+
+	keytabFileName         = "krb5.keytab"
+	krb5ConfFileName       = "krb5.conf"
+	kerberosConfigFileName = "kerberos.json"
+)
+
+// kerberosConfig references the projected Kerberos files relative to the
+// directory they are mounted in, since the pod's mount path is not known here.
+type kerberosConfig struct {
+	Principal  string `json:"principal,omitempty"`
+	Keytab     string `json:"keytab"`
+	Krb5Config string `json:"krb5Config,omitempty"`
+	Endpoint   string `json:"endpoint,omitempty"`
+}
+
+func hasKerberosCredentials(secret *v1.Secret) bool {
+	_, ok := secret.Data[KeytabSecretKey]
+	return ok
+}
+
+// kerberosFiles validates the Kerberos material of secret and returns the files
+// to project for it. The secret is returned without the Kerberos keys, so the
+// binary keytab does not end up in the JSON credentials file.
+func kerberosFiles(secret *v1.Secret, bkt *v1alpha1.Bucket) ([]render.File, *v1.Secret, error) {
+	keytab := secret.Data[KeytabSecretKey]
+	if err := validateKeytab(keytab); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := kerberosConfig{
+		Principal: string(secret.Data[PrincipalSecretKey]),
+		Keytab:    keytabFileName,
+	}
+	if bkt.Spec.Protocol.S3 != nil {
+		cfg.Endpoint = bkt.Spec.Protocol.S3.Endpoint
+	}
+
+	files := []render.File{
+		{Name: keytabFileName, Data: keytab, Mode: 0400},
+	}
+
+	if krb5Conf, ok := secret.Data[Krb5ConfSecretKey]; ok {
+		if err := validateKrb5Conf(krb5Conf); err != nil {
+			return nil, nil, err
+		}
+		cfg.Krb5Config = krb5ConfFileName
+		files = append(files, render.File{Name: krb5ConfFileName, Data: krb5Conf, Mode: 0444})
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, util.WrapErrorFailedToMarshalKerberosConfig)
+	}
+	files = append(files, render.File{Name: kerberosConfigFileName, Data: data, Mode: 0444})
+
+	remaining := secret.DeepCopy()
+	delete(remaining.Data, KeytabSecretKey)
+	delete(remaining.Data, Krb5ConfSecretKey)
+	return files, remaining, nil
+}
+
+// validateKeytab checks for the MIT keytab file format header: a 0x05 marker
+// followed by format version 1 or 2.
+func validateKeytab(data []byte) error {
+	if len(data) < 2 || data[0] != 0x05 || (data[1] != 0x01 && data[1] != 0x02) {
+		return util.ErrorInvalidKeytab
+	}
+	return nil
+}
+
+func validateKrb5Conf(data []byte) error {
+	if !bytes.Contains(data, []byte("[libdefaults]")) && !bytes.Contains(data, []byte("[realms]")) {
+		return util.ErrorInvalidKrb5Conf
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestKerberosFiles(t *testing.T) {
+	validKeytab := []byte{0x05, 0x02, 0x00, 0x00}
+	validConf := []byte("[libdefaults]\n  default_realm = EXAMPLE.COM\n")
+
+	type want struct {
+		names     []string
+		remaining map[string][]byte
+		err       error
+	}
+
+	cases := map[string]struct {
+		data map[string][]byte
+		want
+	}{
+		"KeytabOnly": {
+			data: map[string][]byte{
+				KeytabSecretKey:    validKeytab,
+				PrincipalSecretKey: []byte("app@EXAMPLE.COM"),
+			},
+			want: want{
+				names: []string{keytabFileName, kerberosConfigFileName},
+				remaining: map[string][]byte{
+					PrincipalSecretKey: []byte("app@EXAMPLE.COM"),
+				},
+			},
+		},
+		"KeytabAndConf": {
+			data: map[string][]byte{
+				KeytabSecretKey:   validKeytab,
+				Krb5ConfSecretKey: validConf,
+			},
+			want: want{
+				names:     []string{keytabFileName, krb5ConfFileName, kerberosConfigFileName},
+				remaining: map[string][]byte{},
+			},
+		},
+		"InvalidKeytab": {
+			data: map[string][]byte{
+				KeytabSecretKey: []byte("not a keytab"),
+			},
+			want: want{
+				err: util.ErrorInvalidKeytab,
+			},
+		},
+		"InvalidConf": {
+			data: map[string][]byte{
+				KeytabSecretKey:   validKeytab,
+				Krb5ConfSecretKey: []byte("garbage"),
+			},
+			want: want{
+				err: util.ErrorInvalidKrb5Conf,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			secret := testutils.GetSecret()
+			secret.Data = tc.data
+
+			files, remaining, err := kerberosFiles(secret, testutils.GetB())
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			var names []string
+			for _, f := range files {
+				names = append(names, f.Name)
+			}
+			if diff := cmp.Diff(tc.want.names, names); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			var remainingData map[string][]byte
+			if remaining != nil {
+				remainingData = remaining.Data
+			}
+			if diff := cmp.Diff(tc.want.remaining, remainingData); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// LayoutFileName is the manifest recording the layout version of the data
+	// directory. Volume IDs are never dot files, so it cannot collide with one.
+	LayoutFileName = ".layout.json"
+
+	// LayoutVersion is the layout written by this adapter: one directory per
+	// volume ID holding metadata.json and the bucket directory that is bind
+	// mounted into the pod.
+	LayoutVersion = 1
+)
+
+// Layout is the manifest stored at the root of the data directory.
+type Layout struct {
+	Version int `json:"version"`
+}
+
+// EnsureLayout prepares dataRoot for use, creating it if needed, and returns
+// its layout. Data directories written before the manifest existed are in
+// the first layout and adopted as is. A directory written by a newer adapter
+// is rejected rather than misread.
+func EnsureLayout(dataRoot string) (Layout, error) {
+	if err := os.MkdirAll(dataRoot, 0750); err != nil {
+		return Layout{}, errors.Wrap(err, util.WrapErrorMkdirFailed)
+	}
+
+	path := filepath.Join(dataRoot, LayoutFileName)
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		layout := Layout{Version: LayoutVersion}
+		klog.InfoS("initializing data directory layout", "path", dataRoot, "version", layout.Version)
+		return layout, writeLayout(path, layout)
+	case err != nil:
+		return Layout{}, errors.Wrap(err, util.WrapErrorFailedToReadLayout)
+	}
+
+	var layout Layout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return Layout{}, errors.Wrap(err, util.WrapErrorFailedToReadLayout)
+	}
+	if layout.Version < 1 || layout.Version > LayoutVersion {
+		return Layout{}, fmt.Errorf(util.ErrorTemplateUnsupportedLayout, layout.Version, LayoutVersion)
+	}
+	return layout, nil
+}
+
+// writeLayout replaces the manifest atomically so a crash never leaves a
+// partial one behind.
+func writeLayout(path string, layout Layout) error {
+	data, err := json.Marshal(layout)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0640); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWriteLayout)
+	}
+	if err := fsyncFile(tmp); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWriteLayout)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWriteLayout)
+	}
+	return errors.Wrap(fsyncFile(filepath.Dir(path)), util.WrapErrorFailedToWriteLayout)
+}
@@ -0,0 +1,78 @@
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestEnsureLayout(t *testing.T) {
+	type want struct {
+		layout Layout
+		err    error
+	}
+
+	cases := map[string]struct {
+		manifest string
+		want
+	}{
+		"Fresh": {
+			want: want{layout: Layout{Version: LayoutVersion}},
+		},
+		"Current": {
+			manifest: `{"version":1}`,
+			want:     want{layout: Layout{Version: 1}},
+		},
+		"Newer": {
+			manifest: `{"version":2}`,
+			want:     want{err: fmt.Errorf(util.ErrorTemplateUnsupportedLayout, 2, LayoutVersion)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "layout")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			root := filepath.Join(dir, "data")
+			if tc.manifest != "" {
+				if err := os.MkdirAll(root, 0750); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(root, LayoutFileName), []byte(tc.manifest), 0640); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			layout, err := EnsureLayout(root)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.layout, layout); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+
+			// The manifest survives for the next start.
+			again, err := EnsureLayout(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(layout, again); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package node
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// manifestFilename lists the files the adapter created in a volume. It
+	// lives next to the metadata file, out of the pod's sight.
+	manifestFilename = ".cosi-adapter-manifest"
+	manifestVersion  = 1
+
+	// atomicWriterPrefix starts the names of the directories and symlinks
+	// WriteAtomic keeps in a volume besides the files.
+	atomicWriterPrefix = ".."
+)
+
+// manifest marks the files of a volume owned by the adapter, so unpublish
+// only deletes those and anything else found in the volume is reported.
+type manifest struct {
+	Version int `json:"version"`
+	// Generation counts the writes of the volume's files, starting at 1.
+	Generation int            `json:"generation"`
+	Files      []manifestFile `json:"files"`
+}
+
+type manifestFile struct {
+	// Path is relative to the volume root.
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	// Generation is the write that last changed the file.
+	Generation int `json:"generation"`
+}
+
+// nextManifest returns the manifest of the files with digests, written after
+// those of prev, which is nil for a new volume.
+func nextManifest(prev *manifest, digests map[string]string) manifest {
+	m := manifest{Version: manifestVersion, Generation: 1}
+	old := map[string]manifestFile{}
+	if prev != nil {
+		m.Generation = prev.Generation + 1
+		for _, f := range prev.Files {
+			old[f.Path] = f
+		}
+	}
+	for path, sum := range digests {
+		f := manifestFile{Path: path, SHA256: sum, Generation: m.Generation}
+		if o, ok := old[path]; ok && o.SHA256 == sum {
+			f.Generation = o.Generation
+		}
+		m.Files = append(m.Files, f)
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m
+}
+
+// renames returns the files of m no longer written in next, by path, mapped to
+// the path of the file of next with the same content, if any.
+func (m manifest) renames(next manifest) map[string]string {
+	written := map[string]bool{}
+	bySum := map[string]string{}
+	for _, f := range next.Files {
+		written[f.Path] = true
+		if _, ok := bySum[f.SHA256]; !ok {
+			bySum[f.SHA256] = f.Path
+		}
+	}
+	renames := map[string]string{}
+	for _, f := range m.Files {
+		if !written[f.Path] {
+			renames[f.Path] = bySum[f.SHA256]
+		}
+	}
+	return renames
+}
+
+// owns reports whether name, a top level entry of the volume, was created by
+// the adapter.
+func (m manifest) owns(name string) bool {
+	if strings.HasPrefix(name, atomicWriterPrefix) {
+		return true
+	}
+	for _, f := range m.Files {
+		if strings.SplitN(f.Path, string(filepath.Separator), 2)[0] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readManifest returns the manifest of volID, nil for volumes published
+// before manifests were written.
+func (p Provisioner) readManifest(volID string) (*manifest, error) {
+	data, err := p.readFileFromVolume(volID, manifestFilename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorFailedToReadManifest)
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorFailedToReadManifest)
+	}
+	if m.Version != manifestVersion {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// writeManifest records m as the manifest of volID, replacing the previous one
+// atomically unless m is the first.
+func (p Provisioner) writeManifest(volID string, m manifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWriteManifest)
+	}
+	write := p.replaceFileInVolume
+	if m.Generation == 1 {
+		write = p.writeFileToVolume
+	}
+	return errors.Wrap(write(data, volID, manifestFilename), util.WrapErrorFailedToWriteManifest)
+}
+
+// recordManifest writes the manifest of volID after its files were written
+// with digests, following prev, and logs the files no longer written.
+func (n *NodeServer) recordManifest(volID string, prev *manifest, digests map[string]string) error {
+	next := nextManifest(prev, digests)
+	if prev != nil {
+		for old, renamed := range prev.renames(next) {
+			klog.InfoS("file no longer written to volume", "volumeID", volID, "file", old, "sameContentAs", renamed)
+		}
+	}
+	return n.provisioner.writeManifest(volID, next)
+}
+
+// removeOwnedDir removes the files of volID listed in its manifest. Entries of
+// the volume the adapter did not create are left in place, with the volume
+// directory, and returned. Volumes without a manifest are removed whole.
+func (p Provisioner) removeOwnedDir(volID string) ([]string, error) {
+	m, err := p.readManifest(volID)
+	if err != nil || m == nil {
+		return nil, p.removeDir(volID)
+	}
+	if err := p.unmountTmpfs(volID); err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorUnmountTmpfsFailed)
+	}
+
+	entries, err := ioutil.ReadDir(p.bucketPath(volID))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var unexpected []string
+	for _, e := range entries {
+		if !m.owns(e.Name()) {
+			unexpected = append(unexpected, e.Name())
+			continue
+		}
+		if err := p.pclient.RemoveAll(filepath.Join(p.bucketPath(volID), e.Name())); err != nil {
+			return nil, err
+		}
+	}
+	if len(unexpected) == 0 {
+		return nil, p.removeDir(volID)
+	}
+
+	// The volume is no longer published, whatever is left in it.
+	for _, name := range []string{metadataFilename, manifestFilename} {
+		if err := p.pclient.RemoveAll(filepath.Join(p.volPath(volID), name)); err != nil {
+			return nil, err
+		}
+	}
+	return unexpected, nil
+}
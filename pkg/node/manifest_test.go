@@ -0,0 +1,94 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNextManifest(t *testing.T) {
+	type want struct {
+		m       manifest
+		renames map[string]string
+	}
+
+	cases := map[string]struct {
+		prev    *manifest
+		digests map[string]string
+		want
+	}{
+		"New": {
+			digests: map[string]string{"protocolConn.json": "a", "credentials": "b"},
+			want: want{
+				m: manifest{Version: manifestVersion, Generation: 1, Files: []manifestFile{
+					{Path: "credentials", SHA256: "b", Generation: 1},
+					{Path: "protocolConn.json", SHA256: "a", Generation: 1},
+				}},
+			},
+		},
+		"Changed": {
+			prev: &manifest{Version: manifestVersion, Generation: 2, Files: []manifestFile{
+				{Path: "credentials", SHA256: "b", Generation: 2},
+				{Path: "protocolConn.json", SHA256: "a", Generation: 1},
+			}},
+			digests: map[string]string{"protocolConn.json": "a", "credentials": "c"},
+			want: want{
+				m: manifest{Version: manifestVersion, Generation: 3, Files: []manifestFile{
+					{Path: "credentials", SHA256: "c", Generation: 3},
+					{Path: "protocolConn.json", SHA256: "a", Generation: 1},
+				}},
+				renames: map[string]string{},
+			},
+		},
+		"Renamed": {
+			prev: &manifest{Version: manifestVersion, Generation: 1, Files: []manifestFile{
+				{Path: "credentials", SHA256: "b", Generation: 1},
+				{Path: "config", SHA256: "d", Generation: 1},
+			}},
+			digests: map[string]string{"aws/credentials": "b"},
+			want: want{
+				m: manifest{Version: manifestVersion, Generation: 2, Files: []manifestFile{
+					{Path: "aws/credentials", SHA256: "b", Generation: 2},
+				}},
+				renames: map[string]string{"credentials": "aws/credentials", "config": ""},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := want{m: nextManifest(tc.prev, tc.digests)}
+			if tc.prev != nil {
+				got.renames = tc.prev.renames(got.m)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestManifestOwns(t *testing.T) {
+	m := manifest{Files: []manifestFile{
+		{Path: "protocolConn.json"},
+		{Path: "aws/credentials"},
+	}}
+
+	cases := map[string]struct {
+		name string
+		want bool
+	}{
+		"File":        {name: "protocolConn.json", want: true},
+		"Subdir":      {name: "aws", want: true},
+		"AtomicWrite": {name: "..data", want: true},
+		"Unexpected":  {name: "cache", want: false},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, m.owns(tc.name)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+//go:build linux
+// +build linux
+
+package node
+
+import (
+	"fmt"
+	"os/exec"
+
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// mountHelpers are the executables mount-utils runs to mount and unmount.
+var mountHelpers = []string{"mount", "umount"}
+
+// checkMountHelpers verifies that the mount helpers are installed, so a broken
+// image fails at startup instead of on every publish.
+func checkMountHelpers() error {
+	for _, name := range mountHelpers {
+		if _, err := exec.LookPath(name); err != nil {
+			return fmt.Errorf(util.ErrorTemplateMountHelperMissing, name)
+		}
+	}
+	return nil
+}
+
+// newMounter returns the mounter used to bind the credential directory into the
+// pod's target path.
+func newMounter() mount.Interface {
+	return mount.New("")
+}
+
+// bindMountOptions returns the mount options used to project a plugin owned
+// directory into a target path. mount-utils applies "ro" to a bind mount with
+// a remount, as the kernel ignores it on the bind itself.
+func bindMountOptions(readOnly bool) []string {
+	if readOnly {
+		return []string{"bind", "ro"}
+	}
+	return []string{"bind"}
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+// +build !linux
+
+package node
+
+import (
+	"fmt"
+	"runtime"
+
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// newMounter returns an in-memory mounter on platforms without bind mount
+// support. This keeps the adapter buildable and testable on developer machines;
+// a real port (e.g. Windows) should provide its own implementation here.
+func newMounter() mount.Interface {
+	return mount.NewFakeMounter([]mount.MountPoint{})
+}
+
+// checkMountHelpers fails, so the adapter refuses to start rather than report
+// publishes that mounted nothing.
+func checkMountHelpers() error {
+	return fmt.Errorf(util.ErrorTemplateMountUnsupported, runtime.GOOS)
+}
+
+func bindMountOptions(readOnly bool) []string {
+	if readOnly {
+		return []string{"bind", "ro"}
+	}
+	return []string{"bind"}
+}
@@ -3,34 +3,192 @@ package node
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
-	"k8s.io/mount-utils"
 
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/features"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/rotation"
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
 )
 
 var _ csi.NodeServer = &NodeServer{}
 
 const (
-	credsFileName    = "credentials"
-	protocolFileName = "protocolConn.json"
+	credsFileName    = adapter.CredentialsFileName
+	protocolFileName = adapter.ProtocolFileName
 	metadataFilename = "metadata.json"
 )
 
-func NewNodeServerOrDie(driverName, nodeID, dataRoot string, volumeLimit int64) csi.NodeServer {
-	cosiClient := client.NewClientOrDie(driverName, nodeID)
-	return &NodeServer{
+// Options holds the optional behaviour of the NodeServer.
+type Options struct {
+	// FailureVerbosityThreshold is the number of consecutive publish failures
+	// after which logging for a volume is elevated. Zero disables elevation.
+	FailureVerbosityThreshold int
+	Revocation                RevocationConfig
+	// AllowPodScopedCredentials permits volumes to request a BucketAccess
+	// minted for the pod alone via the credential-scope volume attribute.
+	AllowPodScopedCredentials bool
+	// AccessMonitor, when set, records opens of the projected credential files.
+	AccessMonitor AccessMonitor
+	// LoadSheddingThreshold is the average API server latency above which
+	// optional work is skipped. Zero disables load shedding.
+	LoadSheddingThreshold time.Duration
+	// RotationDefaults applies to volumes that do not override the rotation
+	// policy through their attributes.
+	RotationDefaults rotation.Policy
+	// ReadinessWait bounds how long publish waits for the BucketAccessRequest,
+	// BucketAccess and Bucket to become ready.
+	ReadinessWait client.ReadinessWait
+	// ErrorTranslator, when set, rewrites errors returned to kubelet.
+	ErrorTranslator ErrorTranslator
+	// MetricsRegisterer, when set, additionally receives the adapter metrics.
+	MetricsRegisterer prometheus.Registerer
+	// FeatureGates enables optional behaviour.
+	FeatureGates features.Gates
+	// FileSyncer, when set, makes writes to the data directory durable
+	// according to its policy.
+	FileSyncer *FileSyncer
+	// ClockSkewThreshold is the offset of the node clock from the API server
+	// clock above which expiring credentials raise a warning. Zero disables
+	// the clock skew check.
+	ClockSkewThreshold time.Duration
+	// Hardened creates volume directories without group or world access by
+	// default, refuses directory modes granting world access, and fails the
+	// publish if any projected file is accessible to other users. Callers
+	// should also call HardenUmask.
+	Hardened bool
+	// MemoryBackedVolumes mounts a tmpfs for every volume before writing to
+	// it, so credentials never touch persistent node storage.
+	MemoryBackedVolumes bool
+	// CredentialHelper is the path of the credential helper executable
+	// projected into exec delivery volumes. Empty disables exec delivery.
+	CredentialHelper string
+	// DefaultFormats, when not nil, are the formats of volumes selecting none
+	// whose BucketClass has no default either.
+	DefaultFormats []string
+	// FailureInjectionNamespaces are the namespaces whose pods may request
+	// simulated failures with FailureInjectionAnnotation, given the
+	// FailureInjection feature gate.
+	FailureInjectionNamespaces []string
+	// Canary, when it names a BucketAccessRequest, periodically publishes a
+	// volume for it to check the credentials flow end to end.
+	Canary CanaryConfig
+	// NamespaceRateLimits limits the publishes of each namespace, by name or
+	// AnyNamespace. Empty disables rate limiting.
+	NamespaceRateLimits map[string]RateLimit
+	// ServiceAccountCheck verifies pods run as the service account of their
+	// BucketAccessRequest. Empty disables the check.
+	ServiceAccountCheck ServiceAccountCheck
+	// KubeletDir is the root directory of kubelet, whose pods directory must
+	// hold the target path of every publish. Empty accepts any target path.
+	KubeletDir string
+}
+
+// NewNodeServer returns a NodeServer reaching the API server through config.
+func NewNodeServer(driverName, nodeID, dataRoot string, volumeLimit int64, config *rest.Config, opts Options) (*NodeServer, error) {
+	if err := registerMetrics(opts.MetricsRegisterer); err != nil {
+		return nil, err
+	}
+	cosiClient, err := client.NewClient(driverName, nodeID, config, opts.ReadinessWait)
+	if err != nil {
+		return nil, err
+	}
+	provisioner := NewProvisioner(dataRoot, newMounter(), client.NewProvisionerClient())
+	provisioner.syncer = opts.FileSyncer
+	provisioner.memoryBacked = opts.MemoryBackedVolumes
+	accounting := newAccounting()
+	if err := checkMountHelpers(); err != nil {
+		return nil, err
+	}
+	if opts.DefaultFormats != nil {
+		if _, err := render.ResolveFormats(map[string]string{render.FormatKey: strings.Join(opts.DefaultFormats, ",")}); err != nil {
+			return nil, err
+		}
+	}
+	helper := newCredentialHelper(opts.CredentialHelper, opts.Hardened, cosiClient, provisioner.pclient)
+	helper.check()
+
+	var consumers *consumerCounter
+	if opts.FeatureGates.Enabled(features.BucketConsumerCount) {
+		consumers = newConsumerCounter(cosiClient, accounting, nodeID)
+	}
+
+	var skew *clockSkew
+	if opts.ClockSkewThreshold > 0 {
+		serverTime, err := client.NewServerClock(config)
+		if err != nil {
+			return nil, err
+		}
+		skew = newClockSkew(serverTime, opts.ClockSkewThreshold)
+	}
+
+	n := &NodeServer{
 		name:        driverName,
 		nodeID:      nodeID,
 		volumeLimit: volumeLimit,
 		cosiClient:  cosiClient,
-		provisioner: NewProvisioner(dataRoot, mount.New(""), client.NewProvisionerClient()),
+		provisioner: provisioner,
+		failures:    newFailureTracker(opts.FailureVerbosityThreshold),
+		revoker:     newRevoker(opts.Revocation),
+		shedder:     newLoadShedder(opts.LoadSheddingThreshold),
+		accounting:  accounting,
+		consumers:   consumers,
+		skew:        skew,
+		helper:      helper,
+		translator:  opts.ErrorTranslator,
+		locks:       newVolumeLocks(),
+		inFlight:    newInFlight(),
+		sts:         client.NewSTSClient(client.DefaultSTSTimeout),
+		limiter:     newNamespaceLimiter(opts.NamespaceRateLimits),
+
+		allowPodScopedCredentials: opts.AllowPodScopedCredentials,
+		accessMonitor:             opts.AccessMonitor,
+		rotationDefaults:          opts.RotationDefaults,
+		hardened:                  opts.Hardened,
+		defaultFormats:            opts.DefaultFormats,
+		protocolFormats:           opts.FeatureGates.Enabled(features.ProtocolDefaultFormats),
+		serviceAccountCheck:       opts.ServiceAccountCheck,
+	}
+	n.canary = newCanary(n, dataRoot, opts.Canary)
+	n.targetPaths = newTargetPaths(opts.KubeletDir)
+	if n.targetPaths != nil && n.canary != nil {
+		n.targetPaths.canary = n.canary.targetPath
+	}
+	n.initOnly = newInitOnlyWatches(initOnlyPollInterval, cosiClient.GetPod, n.wipeCredentials)
+	if opts.FeatureGates.Enabled(features.FailureInjection) {
+		n.failureInjection = map[string]bool{}
+		for _, ns := range opts.FailureInjectionNamespaces {
+			n.failureInjection[ns] = true
+		}
 	}
+	if opts.FeatureGates.Enabled(features.CredentialHotReload) {
+		n.refresher = rotation.NewProcessor(rotation.DefaultWorkers, n.refreshVolume)
+		n.renewals = newRenewals(n.refresher.Enqueue)
+	}
+	return n, nil
 }
 
 // NodeServer implements the NodePublishVolume and NodeUnpublishVolume methods
@@ -42,90 +200,341 @@ type NodeServer struct {
 	volumeLimit int64
 	cosiClient  client.NodeClient
 	provisioner Provisioner
+	failures    *failureTracker
+	revoker     *revoker
+	shedder     *loadShedder
+	accounting  *accounting
+	consumers   *consumerCounter
+	skew        *clockSkew
+	helper      *credentialHelper
+	translator  ErrorTranslator
+	refresher   *rotation.Processor
+	renewals    *renewals
+	locks       *volumeLocks
+	inFlight    *inFlight
+	canary      *canary
+	sts         *client.STSClient
+	limiter     *namespaceLimiter
+	initOnly    *initOnlyWatches
+	targetPaths *targetPaths
+
+	allowPodScopedCredentials bool
+	accessMonitor             AccessMonitor
+	rotationDefaults          rotation.Policy
+	hardened                  bool
+	defaultFormats            []string
+	protocolFormats           bool
+	failureInjection          map[string]bool
+	serviceAccountCheck       ServiceAccountCheck
 }
 
-func (n *NodeServer) NodePublishVolume(ctx context.Context, request *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+// CredentialDeliveries returns the credential deliveries volumes may request
+// on this node.
+func (n *NodeServer) CredentialDeliveries() []string {
+	deliveries := []string{string(adapter.CredentialDeliveryFiles)}
+	if n.helper.usable() == nil {
+		deliveries = append(deliveries, string(adapter.CredentialDeliveryExec))
+	}
+	return deliveries
+}
+
+// Start runs the background work of the NodeServer until ctx is done.
+func (n *NodeServer) Start(ctx context.Context) error {
+	var wg sync.WaitGroup
+	run := func(start func(context.Context) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = start(ctx)
+		}()
+	}
+	if n.consumers != nil {
+		run(n.consumers.Start)
+	}
+	if n.skew != nil {
+		run(n.skew.Start)
+	}
+	if n.refresher != nil {
+		n.cosiClient.OnSecretChange(n.secretChanged)
+		n.accounting.watchSecrets(n.cosiClient.WatchSecrets)
+		run(n.refresher.Run)
+	}
+	if n.canary != nil {
+		run(n.canary.Start)
+	}
+	run(func(ctx context.Context) error {
+		n.collectOrphans(ctx)
+		return nil
+	})
+
+	<-ctx.Done()
+	n.helper.close()
+	wg.Wait()
+	return nil
+}
+
+func (n *NodeServer) NodePublishVolume(ctx context.Context, request *csi.NodePublishVolumeRequest) (_ *csi.NodePublishVolumeResponse, err error) {
 	klog.Infof("NodePublishVolume: volId: %v, targetPath: %v\n", request.GetVolumeId(), request.GetTargetPath())
 
-	barName, podName, podNs, err := client.ParseVolumeContext(request.GetVolumeContext())
+	ctx, writes := client.WithWriteCount(ctx)
+	defer func(start time.Time) {
+		metrics.ObserveNodeOperation("publish", start, err)
+		metrics.PublishAPIWrites.Observe(float64(writes()))
+		err = translateError(n.translator, "NodePublishVolume", err)
+	}(time.Now())
+
+	done, err := n.inFlight.begin(request.GetVolumeId(), "publish")
 	if err != nil {
+		return nil, err
+	}
+	defer done()
+	defer n.locks.lock(request.GetVolumeId())()
+
+	defer func() {
+		if n.failures.observe(request.GetVolumeId(), err) && !n.shedder.shed("resolution-snapshot") {
+			n.logResolutionSnapshot(ctx, request.GetVolumeId(), request.GetVolumeContext(), err)
+		}
+	}()
+
+	if !validVolumeID(request.GetVolumeId()) {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf(util.ErrorTemplateInvalidVolumeID, request.GetVolumeId()))
+	}
+	if err := n.targetPaths.check(request.GetTargetPath()); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	bkt, ba, secret, pod, err := n.cosiClient.GetResources(ctx, barName, podName, podNs)
-	if err != nil {
-		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	if err := n.rateLimit(request.GetVolumeContext()); err != nil {
+		return nil, err
 	}
 
-	protocolConnection, err := client.GetProtocol(bkt)
-	if err != nil {
-		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	if republished, err := n.republish(ctx, request); republished {
+		if err != nil {
+			return nil, err
+		}
+		return &csi.NodePublishVolumeResponse{}, nil
 	}
 
-	klog.Infof("bucket %q has protocol %q", bkt.Name, bkt.Spec.Protocol)
+	plan, err := n.resolve(ctx, request.GetVolumeId(), request.GetVolumeContext(), false)
+	if plan != nil && plan.podBA != "" {
+		// Nothing else would delete the pod's BucketAccess, and the
+		// credentials minted for it, when the publish fails.
+		defer func() {
+			if err == nil {
+				return
+			}
+			if err := n.cosiClient.DeleteBA(context.Background(), plan.podBA); err != nil {
+				klog.ErrorS(err, "failed to delete the pod scoped bucketAccess of a failed publish", "volumeID", request.GetVolumeId(), "bucketAccess", plan.podBA)
+			}
+		}()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if gid, ok, err := volumeMountGroup(request.GetVolumeCapability()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	} else if ok {
+		plan.gid = gid
+	}
+	failures, err := n.injectedFailures(plan.pod)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := failures.beforePublish(ctx); err != nil {
+		return nil, err
+	}
+	snapshots := n.snapshots(plan.ba, plan.bkt, plan.secret)
 
-	if err := n.provisioner.createDir(request.GetVolumeId()); err != nil {
+	provisioner := n.provisioner
+	if plan.exec {
+		// The helper must be executable, which the noexec tmpfs forbids, and
+		// the volume holds no credentials to keep off the disk.
+		provisioner.memoryBacked = false
+	}
+	if err := provisioner.createDir(request.GetVolumeId(), plan.dirMode, plan.gid); err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	cleanup := func(err error, errWrap string) (*csi.NodePublishVolumeResponse, error) {
+	cleanupWithCode := func(code codes.Code, err error, errWrap string) (*csi.NodePublishVolumeResponse, error) {
+		n.helper.stop(request.GetVolumeId())
 		rmErr := errors.Wrap(n.provisioner.removeDir(request.GetVolumeId()), util.WrapErrorFailedRemoveDirectory)
 		if rmErr != nil {
 			return nil, status.Error(codes.Internal, errors.Wrap(rmErr, errWrap).Error())
 		}
-		return nil, status.Error(codes.Internal, errors.Wrap(err, errWrap).Error())
+		return nil, status.Error(code, errors.Wrap(err, errWrap).Error())
+	}
+	cleanup := func(err error, errWrap string) (*csi.NodePublishVolumeResponse, error) {
+		return cleanupWithCode(codes.Internal, err, errWrap)
 	}
 
-	creds, err := util.ParseData(secret)
-	if err != nil {
-		return cleanup(err, util.WrapErrorFailedToParseSecret)
+	projected := []render.File{{Name: plan.protocolFile, Data: plan.protocolConnection}}
+	if plan.exec {
+		helper, err := n.helper.binary()
+		if err != nil {
+			return cleanup(err, util.WrapErrorCredentialHelperFailed)
+		}
+		projected = append(projected, helper)
+	} else {
+		creds, err := plan.credentialFiles()
+		if err != nil {
+			return cleanup(err, util.WrapErrorFailedToParseSecret)
+		}
+		projected = append(projected, creds...)
+	}
+	projected = plan.withRendered(projected)
+
+	dirs := append([]string{""}, plan.subdirs...)
+	if err := n.provisioner.writeProjected(request.GetVolumeId(), atomicFiles(dirs, projected), plan.fileMode, plan.gid); err != nil {
+		return cleanup(err, util.WrapErrorFailedToWriteProjected)
 	}
 
-	if err := n.provisioner.writeFileToVolumeMount(protocolConnection, request.GetVolumeId(), protocolFileName); err != nil {
-		return cleanup(err, util.WrapErrorFailedToWriteProtocol)
+	if plan.exec {
+		source := credentialSource{barName: plan.barName, podName: plan.podName, podNamespace: plan.podNs, baName: plan.ba.Name, podScoped: plan.podScoped}
+		if err := n.helper.serve(request.GetVolumeId(), n.provisioner.bucketPath(request.GetVolumeId()), source, plan.gid); err != nil {
+			return cleanup(err, util.WrapErrorCredentialHelperFailed)
+		}
 	}
 
-	if err := n.provisioner.writeFileToVolumeMount(creds, request.GetVolumeId(), credsFileName); err != nil {
-		return cleanup(err, util.WrapErrorFailedToWriteCredentials)
+	if n.hardened {
+		if err := verifyNotWorldAccessible(n.provisioner.volPath(request.GetVolumeId())); err != nil {
+			return cleanup(err, util.WrapErrorPermissionVerificationFailed)
+		}
 	}
 
-	util.EmitNormalEvent(n.cosiClient.Recorder(), pod, util.CredentialsWritten)
+	util.EmitNormalEvent(n.cosiClient.Recorder(), plan.pod, util.CredentialsWritten)
+
+	if n.accessMonitor != nil {
+		if err := n.accessMonitor.Watch(request.GetVolumeId(), filepath.Join(n.provisioner.bucketPath(request.GetVolumeId()), client.DataDirName)); err != nil {
+			klog.ErrorS(err, "failed to monitor credential access", "volumeID", request.GetVolumeId())
+		}
+	}
 
-	err = n.provisioner.mountDir(request.GetVolumeId(), request.GetTargetPath())
+	err = n.provisioner.mountDir(request.GetVolumeId(), request.GetTargetPath(), plan.dirMode, request.GetReadonly())
 	if err != nil {
 		return cleanup(err, util.WrapErrorFailedToMountVolume)
 	}
 
+	var podUID types.UID
+	if plan.pod != nil {
+		podUID = plan.pod.UID
+	}
 	meta := Metadata{
-		BaName:       ba.Name,
-		PodName:      podName,
-		PodNamespace: podNs,
+		BaName:       plan.ba.Name,
+		PodName:      plan.podName,
+		PodNamespace: plan.podNs,
+		PodUID:       podUID,
+		TargetPath:   request.GetTargetPath(),
+		Bucket:       plan.bkt.Name,
+		Secret:       plan.secretRef(),
+		BarName:      plan.barName,
+		PodScoped:    plan.podScoped,
+		Rotation:     &plan.rotationPolicy,
+		Files:        fileDigests(dirs, projected),
+		Formats:      plan.formats,
+		FormatSource: plan.formatSource,
+
+		VolumeContext: request.GetVolumeContext(),
+
+		FinalizerPrefix: finalizerPrefix(n.name),
+		Snapshots:       snapshots,
+		InitOnly:        plan.initOnly,
+	}
+	if podUID != "" {
+		meta.Finalizer = podFinalizer(meta.FinalizerPrefix, podUID)
+	}
+	if expiry, ok := render.CredentialExpiration(plan.secret); ok {
+		meta.Expiration = &metav1.Time{Time: expiry}
+	}
+	if plan.exec {
+		meta.CredentialDelivery = plan.delivery
+		if plan.gid != noGroup {
+			meta.MountGroup = &plan.gid
+		}
 	}
 
-	err = n.cosiClient.AddBAFinalizer(ctx, ba, meta.finalizer())
+	err = n.cosiClient.AddBAFinalizer(ctx, plan.ba, meta.finalizer())
 	if err != nil {
-		return cleanup(err, util.WrapErrorFailedToAddFinalizer)
+		return cleanupWithCode(finalizerErrorCode(err), err, util.WrapErrorFailedToAddFinalizer)
+	}
+	// Until the metadata is written, nothing else knows of the finalizers, so
+	// a publish failing from here on releases them itself.
+	releaseWithCode := func(code codes.Code, err error, errWrap string) (*csi.NodePublishVolumeResponse, error) {
+		if relErr := n.releaseSecretFinalizer(ctx, meta, meta.Secret, meta.SecretFinalizerAdded); relErr != nil {
+			klog.ErrorS(relErr, "failed to release the minted secret of a failed publish", "volumeID", request.GetVolumeId(), "secret", meta.Secret)
+		}
+		if relErr := n.cosiClient.RemoveBAFinalizer(ctx, plan.ba, meta.finalizer()); relErr != nil {
+			klog.ErrorS(relErr, "failed to release the bucketAccess of a failed publish", "volumeID", request.GetVolumeId(), "bucketAccess", plan.ba.Name)
+		}
+		return cleanupWithCode(code, err, errWrap)
+	}
+	release := func(err error, errWrap string) (*csi.NodePublishVolumeResponse, error) {
+		return releaseWithCode(codes.Internal, err, errWrap)
+	}
+	// The minted secret is protected in lockstep, so neither a refresh nor a
+	// publish after a restart of kubelet finds it gone while the pod runs.
+	if plan.secret.Name != "" {
+		if err := n.cosiClient.AddSecretFinalizer(ctx, plan.secret, meta.finalizer()); err != nil {
+			return releaseWithCode(finalizerErrorCode(err), err, util.WrapErrorFailedToAddSecretFinalizer)
+		}
+		meta.SecretFinalizerAdded = true
 	}
 
 	data, err := json.Marshal(meta)
 	if err != nil {
-		return cleanup(err, util.WrapErrorFailedToMarshalMetadata)
+		return release(err, util.WrapErrorFailedToMarshalMetadata)
 	}
 
 	// Write the BA.name to a metadata file in our volume, this is not mounted to the app pod
 	if err := n.provisioner.writeFileToVolume(data, request.GetVolumeId(), metadataFilename); err != nil {
-		return cleanup(err, util.WrapErrorFailedToWriteMetadata)
+		return release(err, util.WrapErrorFailedToWriteMetadata)
+	}
+	if err := n.recordManifest(request.GetVolumeId(), nil, meta.Files); err != nil {
+		return release(err, util.WrapErrorFailedToWriteMetadata)
+	}
+
+	n.accounting.add(request.GetVolumeId(), meta.materialized())
+	if n.refresher != nil && meta.refreshable() {
+		n.scheduleRefresh(request.GetVolumeId(), plan.rotationPolicy, meta.Expiration)
+	}
+	if plan.initOnly {
+		n.initOnly.watch(request.GetVolumeId(), plan.podNs, plan.podName)
+	}
+	if failures.revokeAfter > 0 {
+		volID := request.GetVolumeId()
+		time.AfterFunc(failures.revokeAfter, func() {
+			if err := n.revokeInjected(context.Background(), volID); err != nil {
+				klog.ErrorS(err, "failed to revoke credentials by failure injection", "volumeID", volID)
+			}
+		})
 	}
 
-	util.EmitNormalEvent(n.cosiClient.Recorder(), pod, util.SuccessfullyPublishedVolume)
+	util.EmitNormalEvent(n.cosiClient.Recorder(), plan.pod, util.SuccessfullyPublishedVolume)
 
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
-func (n *NodeServer) NodeUnpublishVolume(ctx context.Context, request *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
+func (n *NodeServer) NodeUnpublishVolume(ctx context.Context, request *csi.NodeUnpublishVolumeRequest) (_ *csi.NodeUnpublishVolumeResponse, err error) {
 	klog.Infof("NodeUnpublishVolume: volId: %v, targetPath: %v\n", request.GetVolumeId(), request.GetTargetPath())
 
+	defer func(start time.Time) {
+		metrics.ObserveNodeOperation("unpublish", start, err)
+		err = translateError(n.translator, "NodeUnpublishVolume", err)
+	}(time.Now())
+
+	done, err := n.inFlight.begin(request.GetVolumeId(), "unpublish")
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	defer n.locks.lock(request.GetVolumeId())()
+
+	if !validVolumeID(request.GetVolumeId()) {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf(util.ErrorTemplateInvalidVolumeID, request.GetVolumeId()))
+	}
+
 	data, err := n.provisioner.readFileFromVolume(request.GetVolumeId(), metadataFilename)
+	if os.IsNotExist(err) {
+		return n.unpublishUnknownVolume(request)
+	}
 	if err != nil {
 		return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToReadMetadataFile).Error())
 	}
@@ -136,15 +545,31 @@ func (n *NodeServer) NodeUnpublishVolume(ctx context.Context, request *csi.NodeU
 		return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToUnmarshalMetadata).Error())
 	}
 
-	klog.InfoS("read metadata file", "metadata", meta)
+	logged := meta
+	logged.VolumeContext = adapter.RedactVolumeContext(meta.VolumeContext)
+	klog.InfoS("read metadata file", "metadata", logged)
 
+	start := time.Now()
 	pod, err := n.cosiClient.GetPod(ctx, meta.PodName, meta.PodNamespace)
+	if apierrors.IsNotFound(err) {
+		// The pod can be gone by now, after a forced deletion or while the
+		// adapter was down. The metadata still says what to release.
+		klog.InfoS("unpublishing volume of a deleted pod", "volumeID", request.GetVolumeId(), "pod", klog.KRef(meta.PodNamespace, meta.PodName))
+		pod, err = meta.pod(), nil
+	}
 	if err != nil {
+		n.shedder.observe(time.Since(start))
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	ba, err := n.cosiClient.GetBA(ctx, pod, meta.BaName)
-	if err != nil {
+	if n.shedder.observe(time.Since(start)) {
+		util.EmitWarningEvent(n.cosiClient.Recorder(), pod, util.LoadSheddingStarted)
+	}
+	// A retry finds the pod's own BucketAccess gone once it was deleted, and
+	// nothing is left to release from a deleted BucketAccess.
+	baGone := apierrors.IsNotFound(errors.Cause(err))
+	if err != nil && !baGone {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
@@ -153,29 +578,226 @@ func (n *NodeServer) NodeUnpublishVolume(ctx context.Context, request *csi.NodeU
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	err = n.provisioner.removeDir(request.GetVolumeId())
+	if n.accessMonitor != nil {
+		if err := n.accessMonitor.Unwatch(request.GetVolumeId(), filepath.Join(n.provisioner.bucketPath(request.GetVolumeId()), client.DataDirName)); err != nil {
+			klog.ErrorS(err, "failed to stop monitoring credential access", "volumeID", request.GetVolumeId())
+		}
+	}
+
+	n.helper.stop(request.GetVolumeId())
+	n.initOnly.cancel(request.GetVolumeId())
+
+	// The finalizers and the pod's own BucketAccess are released before the
+	// metadata is removed, so a retried unpublish still knows what to release.
+	if !baGone {
+		ba = n.revoker.release(ctx, n.cosiClient, ba, pod, ReleaseEvent{
+			BucketAccess: ba.Name,
+			PodName:      meta.PodName,
+			PodNamespace: meta.PodNamespace,
+			PodUID:       string(meta.podUID(pod)),
+			NodeID:       n.nodeID,
+			VolumeID:     request.GetVolumeId(),
+			ReleasedAt:   time.Now().UTC(),
+		})
+
+		err = n.cosiClient.RemoveBAFinalizer(ctx, ba, meta.finalizer())
+		if err != nil {
+			return nil, status.Error(finalizerErrorCode(err), errors.Wrap(err, util.WrapErrorFailedToRemoveFinalizer).Error())
+		}
+	}
+	if err := n.releaseSecretFinalizer(ctx, meta, meta.Secret, meta.SecretFinalizerAdded); err != nil {
+		return nil, status.Error(finalizerErrorCode(err), errors.Wrap(err, util.WrapErrorFailedToRemoveSecretFinalizer).Error())
+	}
+
+	if meta.PodScoped && !baGone {
+		if err := n.cosiClient.DeleteBA(ctx, ba.Name); err != nil {
+			return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToDeletePodBA).Error())
+		}
+	}
+
+	unexpected, err := n.provisioner.removeOwnedDir(request.GetVolumeId())
 	if err != nil {
 		return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToRemoveDir).Error())
 	}
+	if len(unexpected) > 0 {
+		klog.InfoS("left files the adapter did not create in the volume", "volumeID", request.GetVolumeId(), "files", unexpected)
+		util.EmitWarningEvent(n.cosiClient.Recorder(), pod, util.UnexpectedVolumeFiles)
+	}
+	n.accounting.remove(request.GetVolumeId())
+	if n.refresher != nil {
+		n.renewals.cancel(request.GetVolumeId())
+		n.refresher.Forget(request.GetVolumeId())
+	}
+
+	n.failures.forget(request.GetVolumeId())
+	util.EmitNormalEvent(n.cosiClient.Recorder(), pod, util.SuccessfullyUnpublishedVolume)
+
+	return &csi.NodeUnpublishVolumeResponse{}, nil
+}
 
-	err = n.cosiClient.RemoveBAFinalizer(ctx, ba, meta.finalizer())
+// republish handles a publish of a volume already mounted at its target path,
+// which kubelet repeats periodically for CSIDrivers with requiresRepublish,
+// passing fresh service account tokens. The new volume context is recorded and
+// the files are rendered again from it; a repeat with the same volume context
+// changes nothing. It reports false for volumes not published yet.
+func (n *NodeServer) republish(ctx context.Context, request *csi.NodePublishVolumeRequest) (bool, error) {
+	if mounted, err := n.provisioner.isMounted(request.GetTargetPath()); err != nil || !mounted {
+		return false, nil
+	}
+	data, err := n.provisioner.readFileFromVolume(request.GetVolumeId(), metadataFilename)
 	if err != nil {
-		return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToRemoveFinalizer).Error())
+		return false, nil
+	}
+	meta := Metadata{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return true, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToUnmarshalMetadata).Error())
 	}
 
-	util.EmitNormalEvent(n.cosiClient.Recorder(), pod, util.SuccessfullyUnpublishedVolume)
+	// A repeated publish of the same volume is a no-op. Republishes for fresh
+	// service account tokens carry a new volume context.
+	if reflect.DeepEqual(meta.VolumeContext, request.GetVolumeContext()) {
+		klog.V(4).InfoS("volume already published", "volumeID", request.GetVolumeId())
+		return true, nil
+	}
+	meta.VolumeContext = request.GetVolumeContext()
+	if data, err = json.Marshal(meta); err != nil {
+		return true, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToMarshalMetadata).Error())
+	}
+	if err := n.provisioner.replaceFileInVolume(data, request.GetVolumeId(), metadataFilename); err != nil {
+		return true, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToWriteMetadata).Error())
+	}
+	if err := n.refreshLocked(ctx, request.GetVolumeId()); err != nil {
+		// Failed resolutions carry their own code.
+		if _, ok := status.FromError(err); ok {
+			return true, err
+		}
+		return true, status.Error(codes.Internal, err.Error())
+	}
+	klog.V(4).InfoS("republished volume", "volumeID", request.GetVolumeId())
+	return true, nil
+}
+
+// unpublishUnknownVolume handles an unpublish for a volume this node holds no
+// metadata for. The CSI spec requires this to succeed, but it is counted since
+// it usually means kubelet and the adapter disagree about what is published.
+func (n *NodeServer) unpublishUnknownVolume(request *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
+	klog.V(4).InfoS("unpublish of unknown volume, nothing to clean up", "volumeID", request.GetVolumeId(), "targetPath", request.GetTargetPath())
+	metrics.UnpublishUnknownVolume.Inc()
 
+	if err := n.provisioner.removeMount(request.GetTargetPath()); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
+// podScopedCredentials reports whether the volume asks for its own pod-scoped
+// BucketAccess rather than sharing the one granted to the BucketAccessRequest.
+func (n *NodeServer) podScopedCredentials(volCtx map[string]string) (bool, error) {
+	scope, err := adapter.ParseCredentialScope(volCtx[adapter.CredentialScopeKey])
+	if err != nil {
+		return false, err
+	}
+	if scope != adapter.CredentialScopePod {
+		return false, nil
+	}
+	if !n.allowPodScopedCredentials {
+		return false, util.ErrorPodScopedCredentialsDisabled
+	}
+	return true, nil
+}
+
+// snapshots describes the versions of the objects a volume is published from
+// and observes their age.
+func (n *NodeServer) snapshots(ba *v1alpha1.BucketAccess, bkt *v1alpha1.Bucket, secret *v1.Secret) map[string]client.Snapshot {
+	snapshots := map[string]client.Snapshot{
+		"bucketAccess": n.cosiClient.Snapshot(ba),
+		"bucket":       n.cosiClient.Snapshot(bkt),
+	}
+	// Identity access has no minted secret.
+	if secret.Name != "" {
+		snapshots["secret"] = n.cosiClient.Snapshot(secret)
+	}
+	for kind, s := range snapshots {
+		metrics.SnapshotAge.WithLabelValues(kind, s.Source).Observe(s.AgeSeconds)
+	}
+	return snapshots
+}
+
+// logResolutionSnapshot resolves every object involved in publishing volID one
+// step at a time and logs what it finds. It is called once per failure streak,
+// when the volume first crosses the failure threshold.
+func (n *NodeServer) logResolutionSnapshot(ctx context.Context, volID string, volCtx map[string]string, publishErr error) {
+	snapshot := []interface{}{"volumeID", volID, "error", publishErr.Error(), "volumeContext", adapter.RedactVolumeContext(volCtx)}
+	defer func() {
+		klog.InfoS("volume failing repeatedly, captured resolution snapshot", snapshot...)
+	}()
+
+	barName, podName, podNs, err := client.ParseVolumeContext(volCtx)
+	if err != nil {
+		return
+	}
+
+	pod, err := n.cosiClient.GetPod(ctx, podName, podNs)
+	if err != nil {
+		snapshot = append(snapshot, "pod", err.Error())
+		return
+	}
+	snapshot = append(snapshot, "pod", klog.KObj(pod), "podPhase", pod.Status.Phase)
+
+	bar, err := n.cosiClient.GetBAR(ctx, pod, barName, podNs)
+	if err != nil {
+		snapshot = append(snapshot, "bucketAccessRequest", err.Error())
+		return
+	}
+	snapshot = append(snapshot, "bucketAccessRequest", klog.KObj(bar), "barAccessGranted", bar.Status.AccessGranted)
+
+	ba, err := n.cosiClient.GetBA(ctx, pod, bar.Status.BucketAccessName)
+	if err != nil {
+		snapshot = append(snapshot, "bucketAccess", err.Error())
+		return
+	}
+	snapshot = append(snapshot, "bucketAccess", klog.KObj(ba), "mintedSecret", ba.Status.MintedSecret, "baFinalizers", ba.Finalizers)
+
+	bkt, err := n.cosiClient.GetB(ctx, pod, ba.Spec.BucketName)
+	if err != nil {
+		snapshot = append(snapshot, "bucket", err.Error())
+		return
+	}
+	snapshot = append(snapshot, "bucket", klog.KObj(bkt), "bucketAvailable", bkt.Status.BucketAvailable)
+}
+
+// NodeGetInfo reports the node, the volume limit and the zone and region
+// labels of the Node object as accessible topology. kubelet records the
+// response once, at registration, so a failure to read the Node is returned
+// for kubelet to retry rather than registering without topology.
 func (n *NodeServer) NodeGetInfo(ctx context.Context, request *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
+	node, err := n.cosiClient.GetNode(ctx, n.nodeID)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, errors.Wrap(err, util.WrapErrorGetNodeFailed).Error())
+	}
+
 	resp := &csi.NodeGetInfoResponse{
 		NodeId:            n.nodeID,
 		MaxVolumesPerNode: n.volumeLimit,
 	}
+	if segments := topologySegments(node); segments != nil {
+		resp.AccessibleTopology = &csi.Topology{Segments: segments}
+	}
 	return resp, nil
 }
 
 func (n *NodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
-	return &csi.NodeGetCapabilitiesResponse{}, nil
+	var capabilities []*csi.NodeServiceCapability
+	for _, c := range []csi.NodeServiceCapability_RPC_Type{
+		csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+		csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+		csi.NodeServiceCapability_RPC_VOLUME_MOUNT_GROUP,
+	} {
+		capabilities = append(capabilities, &csi.NodeServiceCapability{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{Type: c},
+			},
+		})
+	}
+	return &csi.NodeGetCapabilitiesResponse{Capabilities: capabilities}, nil
 }
@@ -12,6 +12,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
 	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
 
@@ -98,6 +99,12 @@ func TestNodePublishVolume(t *testing.T) {
 						MockWriteFile: func(data []byte, filepath string) error {
 							return nil
 						},
+						MockSetOwnership: func(path string, mode os.FileMode, gid int) error {
+							return nil
+						},
+						MockWriteAtomic: func(dir string, files []client.AtomicFile, gid int) error {
+							return nil
+						},
 						MockRemoveAll: func(path string) error {
 							return nil
 						},
@@ -113,7 +120,7 @@ func TestNodePublishVolume(t *testing.T) {
 						}
 						return bkt, ba, secret, testutils.GetPod(), nil
 					},
-					MockAddBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
+					MockAddBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error {
 						return nil
 					},
 				},
@@ -222,7 +229,10 @@ func TestNodePublishVolume(t *testing.T) {
 						MockMkdirAll: func(path string, perm os.FileMode) error {
 							return nil
 						},
-						MockWriteFile: func(data []byte, filepath string) error {
+						MockSetOwnership: func(path string, mode os.FileMode, gid int) error {
+							return nil
+						},
+						MockWriteAtomic: func(dir string, files []client.AtomicFile, gid int) error {
 							return errBoom
 						},
 						MockRemoveAll: func(path string) error {
@@ -250,7 +260,7 @@ func TestNodePublishVolume(t *testing.T) {
 			},
 			want: want{
 				response: nil,
-				err:      genRPCError(codes.Internal, testutils.MultipleWrap(errBoom, util.WrapErrorFailedToCreateBucketFile, util.WrapErrorFailedToWriteProtocol)),
+				err:      genRPCError(codes.Internal, testutils.MultipleWrap(errBoom, util.WrapErrorFailedToCreateBucketFile, util.WrapErrorFailedToWriteProjected)),
 			},
 		},
 		"ErrorFailedToCreateFileRmFailed": {
@@ -260,7 +270,10 @@ func TestNodePublishVolume(t *testing.T) {
 						MockMkdirAll: func(path string, perm os.FileMode) error {
 							return nil
 						},
-						MockWriteFile: func(data []byte, filepath string) error {
+						MockSetOwnership: func(path string, mode os.FileMode, gid int) error {
+							return nil
+						},
+						MockWriteAtomic: func(dir string, files []client.AtomicFile, gid int) error {
 							return errBoom
 						},
 						MockRemoveAll: func(path string) error {
@@ -288,7 +301,7 @@ func TestNodePublishVolume(t *testing.T) {
 			},
 			want: want{
 				response: nil,
-				err:      genRPCError(codes.Internal, testutils.MultipleWrap(errBoom, util.WrapErrorFailedRemoveDirectory, util.WrapErrorFailedToWriteProtocol)),
+				err:      genRPCError(codes.Internal, testutils.MultipleWrap(errBoom, util.WrapErrorFailedRemoveDirectory, util.WrapErrorFailedToWriteProjected)),
 			},
 		},
 		"ErrorFailedToMountDirMkdir": {
@@ -304,6 +317,12 @@ func TestNodePublishVolume(t *testing.T) {
 						MockWriteFile: func(data []byte, filepath string) error {
 							return nil
 						},
+						MockSetOwnership: func(path string, mode os.FileMode, gid int) error {
+							return nil
+						},
+						MockWriteAtomic: func(dir string, files []client.AtomicFile, gid int) error {
+							return nil
+						},
 						MockRemoveAll: func(path string) error {
 							return nil
 						},
@@ -342,6 +361,12 @@ func TestNodePublishVolume(t *testing.T) {
 						MockWriteFile: func(data []byte, filepath string) error {
 							return nil
 						},
+						MockSetOwnership: func(path string, mode os.FileMode, gid int) error {
+							return nil
+						},
+						MockWriteAtomic: func(dir string, files []client.AtomicFile, gid int) error {
+							return nil
+						},
 						MockRemoveAll: func(path string) error {
 							return nil
 						},
@@ -382,6 +407,12 @@ func TestNodePublishVolume(t *testing.T) {
 						MockWriteFile: func(data []byte, filepath string) error {
 							return nil
 						},
+						MockSetOwnership: func(path string, mode os.FileMode, gid int) error {
+							return nil
+						},
+						MockWriteAtomic: func(dir string, files []client.AtomicFile, gid int) error {
+							return nil
+						},
 						MockRemoveAll: func(path string) error {
 							return nil
 						},
@@ -394,7 +425,7 @@ func TestNodePublishVolume(t *testing.T) {
 						secret = testutils.GetSecret()
 						return
 					},
-					MockAddBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
+					MockAddBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error {
 						return errBoom
 					},
 				},
@@ -426,6 +457,12 @@ func TestNodePublishVolume(t *testing.T) {
 							}
 							return nil
 						},
+						MockSetOwnership: func(path string, mode os.FileMode, gid int) error {
+							return nil
+						},
+						MockWriteAtomic: func(dir string, files []client.AtomicFile, gid int) error {
+							return nil
+						},
 						MockRemoveAll: func(path string) error {
 							return nil
 						},
@@ -438,7 +475,13 @@ func TestNodePublishVolume(t *testing.T) {
 						secret = testutils.GetSecret()
 						return
 					},
-					MockAddBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
+					MockAddBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error {
+						return nil
+					},
+					MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error {
+						return nil
+					},
+					MockRemoveSecretFinalizer: func(ctx context.Context, namespace, name string, f client.Finalizer) error {
 						return nil
 					},
 				},
@@ -457,6 +500,60 @@ func TestNodePublishVolume(t *testing.T) {
 				err:      genRPCError(codes.Internal, testutils.MultipleWrap(errBoom, util.WrapErrorFailedToCreateVolumeFile, util.WrapErrorFailedToWriteMetadata)),
 			},
 		},
+		"ErrorFailedToAddSecretFinalizer": {
+			args: args{
+				provisioner: getTestProvisioner(
+					&fake.MockProvisionerClient{
+						MockMkdirAll: func(path string, perm os.FileMode) error {
+							return nil
+						},
+						MockWriteFile: func(data []byte, fp string) error {
+							return nil
+						},
+						MockSetOwnership: func(path string, mode os.FileMode, gid int) error {
+							return nil
+						},
+						MockWriteAtomic: func(dir string, files []client.AtomicFile, gid int) error {
+							return nil
+						},
+						MockRemoveAll: func(path string) error {
+							return nil
+						},
+					},
+				),
+				nclient: &fake.FakeNodeClient{
+					MockGetResources: func(ctx context.Context, barName, podName, podNs string) (bkt *v1alpha1.Bucket, ba *v1alpha1.BucketAccess, secret *v1.Secret, pod *v1.Pod, err error) {
+						bkt = testutils.GetB()
+						ba = testutils.GetBA()
+						secret = testutils.GetSecret()
+						return
+					},
+					MockAddBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error {
+						return nil
+					},
+					MockAddSecretFinalizer: func(ctx context.Context, secret *v1.Secret, f client.Finalizer) error {
+						return errBoom
+					},
+					// The finalizer added to the BucketAccess is released.
+					MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error {
+						return nil
+					},
+				},
+				request: &csi.NodePublishVolumeRequest{
+					VolumeContext: map[string]string{
+						client.BarNameKey:      testutils.GetBAR().Name,
+						client.PodNameKey:      podName,
+						client.PodNamespaceKey: testutils.Namespace,
+					},
+					VolumeId:   provVolumeId,
+					TargetPath: provTargetPath,
+				},
+			},
+			want: want{
+				response: nil,
+				err:      genRPCError(codes.Internal, errors.Wrap(errBoom, util.WrapErrorFailedToAddSecretFinalizer)),
+			},
+		},
 	}
 
 	for name, tc := range cases {
@@ -527,9 +624,92 @@ func TestNodeUnpublishVolume(t *testing.T) {
 						}
 						return nil, errBoom
 					},
-					MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
+					MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error {
+						return nil
+					},
+					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+						return testutils.GetPod(), nil
+					},
+				},
+				request: &csi.NodeUnpublishVolumeRequest{
+					VolumeId:   provVolumeId,
+					TargetPath: provTargetPath,
+				},
+			},
+			want: want{
+				response: &csi.NodeUnpublishVolumeResponse{},
+				err:      nil,
+			},
+		},
+		"SuccessfulPodDeleted": {
+			args: args{
+				provisioner: getTestProvisioner(
+					&fake.MockProvisionerClient{
+						MockRemoveAll: func(path string) error {
+							return nil
+						},
+						MockReadFile: func(filename string) ([]byte, error) {
+							meta := Metadata{
+								BaName:       "bucketAccessName",
+								PodName:      podName,
+								PodNamespace: testutils.Namespace,
+								PodUID:       "deleted-pod-uid",
+								TargetPath:   provTargetPath,
+							}
+							return json.Marshal(meta)
+						},
+					}, withMountPoints([]mount.MountPoint{
+						{
+							Path: provTargetPath,
+						},
+					}),
+				),
+				nclient: &fake.FakeNodeClient{
+					MockGetBA: func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
+						if pod.Name != podName || pod.UID != "deleted-pod-uid" {
+							return nil, errBoom
+						}
+						return testutils.GetBA(), nil
+					},
+					MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error {
 						return nil
 					},
+					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+						return nil, apierrors.NewNotFound(v1.Resource("pods"), podName)
+					},
+				},
+				request: &csi.NodeUnpublishVolumeRequest{
+					VolumeId:   provVolumeId,
+					TargetPath: provTargetPath,
+				},
+			},
+			want: want{
+				response: &csi.NodeUnpublishVolumeResponse{},
+				err:      nil,
+			},
+		},
+		"SuccessfulPodBADeleted": {
+			args: args{
+				provisioner: getTestProvisioner(
+					&fake.MockProvisionerClient{
+						MockRemoveAll: func(path string) error {
+							return nil
+						},
+						MockReadFile: func(filename string) ([]byte, error) {
+							meta := Metadata{
+								BaName:       "bucketAccessName",
+								PodName:      podName,
+								PodNamespace: testutils.Namespace,
+								PodScoped:    true,
+							}
+							return json.Marshal(meta)
+						},
+					},
+				),
+				nclient: &fake.FakeNodeClient{
+					MockGetBA: func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
+						return nil, errors.Wrap(apierrors.NewNotFound(v1alpha1.SchemeGroupVersion.WithResource("bucketaccesses").GroupResource(), baName), util.WrapErrorGetBAFailed)
+					},
 					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
 						return testutils.GetPod(), nil
 					},
@@ -564,6 +744,26 @@ func TestNodeUnpublishVolume(t *testing.T) {
 				err:      genRPCError(codes.Internal, errors.Wrap(errBoom, util.WrapErrorFailedToReadMetadataFile)),
 			},
 		},
+		"SuccessfulUnknownVolume": {
+			args: args{
+				provisioner: getTestProvisioner(
+					&fake.MockProvisionerClient{
+						MockReadFile: func(filename string) ([]byte, error) {
+							return nil, os.ErrNotExist
+						},
+					},
+				),
+				nclient: &fake.FakeNodeClient{},
+				request: &csi.NodeUnpublishVolumeRequest{
+					VolumeId:   provVolumeId,
+					TargetPath: provTargetPath,
+				},
+			},
+			want: want{
+				response: &csi.NodeUnpublishVolumeResponse{},
+				err:      nil,
+			},
+		},
 		"FailedToUnmarshalMetadataFile": {
 			args: args{
 				provisioner: getTestProvisioner(
@@ -679,6 +879,9 @@ func TestNodeUnpublishVolume(t *testing.T) {
 					MockGetBA: func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
 						return testutils.GetBA(), nil
 					},
+					MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error {
+						return nil
+					},
 					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
 						return testutils.GetPod(), nil
 					},
@@ -714,7 +917,7 @@ func TestNodeUnpublishVolume(t *testing.T) {
 					MockGetBA: func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
 						return testutils.GetBA(), nil
 					},
-					MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer string) error {
+					MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, BAFinalizer client.Finalizer) error {
 						return errBoom
 					},
 					MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
@@ -0,0 +1,122 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+// collectOrphans unpublishes the volumes whose pod no longer exists, or was
+// recreated under the same name. kubelet
+// forgets the volumes of pods deleted while the node rebooted or the adapter
+// was down, so without this their credentials stay on the node and their
+// finalizers keep the BucketAccesses from being deleted. It runs once at
+// start, after Adopt, and returns the number of volumes collected.
+//
+// Volumes are unpublished through NodeUnpublishVolume, so a concurrent
+// unpublish by kubelet is aborted or finds the volume gone.
+//
+// Volumes are found by their metadata: a kubelet target directory whose volume
+// has none cannot be tied to a pod or BucketAccess, so it is left to kubelet.
+// The target directories only provide the target paths metadata is missing.
+func (n *NodeServer) collectOrphans(ctx context.Context) int {
+	entries, err := ioutil.ReadDir(n.provisioner.dataPath)
+	if err != nil {
+		klog.ErrorS(err, "failed to list volumes for orphans")
+		return 0
+	}
+	targets := n.kubeletTargets()
+
+	collected := 0
+	for _, entry := range entries {
+		volID := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(volID, ".") {
+			continue
+		}
+		data, err := n.provisioner.readFileFromVolume(volID, metadataFilename)
+		if err != nil {
+			continue
+		}
+		meta := Metadata{}
+		if err := json.Unmarshal(data, &meta); err != nil {
+			continue
+		}
+
+		pod, err := n.cosiClient.GetPod(ctx, meta.PodName, meta.PodNamespace)
+		if err != nil && !apierrors.IsNotFound(err) {
+			klog.ErrorS(err, "failed to check whether the pod of a volume exists", "volumeID", volID, "pod", klog.KRef(meta.PodNamespace, meta.PodName))
+			continue
+		}
+		// A pod recreated under the same name, as by a StatefulSet, shares
+		// the finalizer of volumes keyed by the pod name, so those are left
+		// to kubelet.
+		if err == nil && (meta.Finalizer == "" || pod.UID == meta.PodUID) {
+			continue
+		}
+
+		target := meta.TargetPath
+		if target == "" {
+			target = targets[volID]
+		}
+		if target == "" {
+			// Otherwise, volumes published before the target path was
+			// recorded are found by their bind mount, if it survived.
+			refs, err := n.provisioner.mounter.GetMountRefs(n.provisioner.bucketPath(volID))
+			if err != nil || len(refs) == 0 {
+				klog.InfoS("not collecting orphaned volume without a known target path", "volumeID", volID, "pod", klog.KRef(meta.PodNamespace, meta.PodName))
+				continue
+			}
+			target = refs[0]
+		}
+
+		klog.InfoS("collecting orphaned volume", "volumeID", volID, "pod", klog.KRef(meta.PodNamespace, meta.PodName), "targetPath", target)
+		if _, err := n.NodeUnpublishVolume(ctx, &csi.NodeUnpublishVolumeRequest{VolumeId: volID, TargetPath: target}); err != nil {
+			klog.ErrorS(err, "failed to collect orphaned volume", "volumeID", volID)
+			continue
+		}
+		collected++
+	}
+	klog.InfoS("collected orphaned volumes", "count", collected)
+	return collected
+}
+
+// kubeletVolumeData is the part of the vol_data.json file kubelet keeps next
+// to the target path of every CSI volume that tells its driver and volume ID.
+type kubeletVolumeData struct {
+	DriverName   string `json:"driverName"`
+	VolumeHandle string `json:"volumeHandle"`
+}
+
+// kubeletTargets returns the target paths, by volume ID, of the volumes of
+// this driver found in the pods directory of kubelet, or nil if the kubelet
+// directory is not known.
+func (n *NodeServer) kubeletTargets() map[string]string {
+	if n.targetPaths == nil {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(n.targetPaths.podsDir, "*", "volumes", "kubernetes.io~csi", "*", "vol_data.json"))
+	if err != nil {
+		klog.ErrorS(err, "failed to list kubelet volumes for orphans")
+		return nil
+	}
+
+	targets := map[string]string{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		volData := kubeletVolumeData{}
+		if err := json.Unmarshal(data, &volData); err != nil || volData.DriverName != n.name || volData.VolumeHandle == "" {
+			continue
+		}
+		targets[volData.VolumeHandle] = filepath.Join(filepath.Dir(file), "mount")
+	}
+	return targets
+}
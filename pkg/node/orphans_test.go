@@ -0,0 +1,105 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/mount-utils"
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestCollectOrphans(t *testing.T) {
+	dataRoot := t.TempDir()
+	if _, err := EnsureLayout(dataRoot); err != nil {
+		t.Fatal(err)
+	}
+	volumes := map[string]Metadata{
+		// The pod was deleted while the adapter was down.
+		"vol-deleted": {BaName: "ba", PodName: "deleted", PodNamespace: "ns", PodUID: "uid-1", TargetPath: filepath.Join(dataRoot, "target")},
+		// The pod was recreated under the same name, and the finalizer is
+		// its own.
+		"vol-recreated": {BaName: "ba", PodName: "running", PodNamespace: "ns", PodUID: "uid-2", TargetPath: filepath.Join(dataRoot, "target"), Finalizer: podFinalizer(finalizer, "uid-2")},
+		// Keyed by the pod name, the finalizer is shared with the new pod.
+		"vol-running": {BaName: "ba", PodName: "running", PodNamespace: "ns", PodUID: "uid-3", TargetPath: filepath.Join(dataRoot, "target")},
+		// Published before the target path was recorded, and not mounted.
+		"vol-unknown-target": {BaName: "ba", PodName: "deleted", PodNamespace: "ns"},
+		// Published before the target path was recorded, which kubelet
+		// still has.
+		"vol-kubelet-target": {BaName: "ba", PodName: "deleted", PodNamespace: "ns", PodUID: "uid-5"},
+	}
+	for volID, meta := range volumes {
+		if err := os.MkdirAll(filepath.Join(dataRoot, volID, "bucket"), 0750); err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dataRoot, volID, metadataFilename), data, 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kubeletDir := t.TempDir()
+	for volID, driver := range map[string]string{"vol-kubelet-target": name, "vol-unknown-target": "other.driver"} {
+		dir := filepath.Join(kubeletDir, "pods", "uid-5", "volumes", "kubernetes.io~csi", volID)
+		if err := os.MkdirAll(filepath.Join(dir, "mount"), 0750); err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(kubeletVolumeData{DriverName: driver, VolumeHandle: volID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "vol_data.json"), data, 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var removed []client.Finalizer
+	n := &NodeServer{
+		name:        name,
+		targetPaths: newTargetPaths(kubeletDir),
+		provisioner: NewProvisioner(dataRoot, mount.NewFakeMounter(nil), client.NewProvisionerClient()),
+		cosiClient: &fake.FakeNodeClient{
+			MockGetPod: func(ctx context.Context, podName, podNs string) (*v1.Pod, error) {
+				if podName == "deleted" {
+					return nil, apierrors.NewNotFound(v1.Resource("pods"), podName)
+				}
+				pod := testutils.GetPod()
+				pod.UID = "uid-4"
+				return pod, nil
+			},
+			MockGetBA: func(ctx context.Context, pod *v1.Pod, baName string) (*v1alpha1.BucketAccess, error) {
+				return testutils.GetBA(), nil
+			},
+			MockRemoveBAFinalizer: func(ctx context.Context, ba *v1alpha1.BucketAccess, f client.Finalizer) error {
+				removed = append(removed, f)
+				return nil
+			},
+		},
+	}
+
+	if diff := cmp.Diff(3, n.collectOrphans(context.Background())); diff != "" {
+		t.Errorf("collectOrphans(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]client.Finalizer{volumes["vol-deleted"].finalizer(), volumes["vol-kubelet-target"].finalizer(), volumes["vol-recreated"].finalizer()}, removed); diff != "" {
+		t.Errorf("removed finalizers: -want, +got:\n%s", diff)
+	}
+	for volID, want := range map[string]bool{"vol-deleted": false, "vol-kubelet-target": false, "vol-recreated": false, "vol-running": true, "vol-unknown-target": true} {
+		_, err := os.Stat(filepath.Join(dataRoot, volID))
+		if got := err == nil; got != want {
+			t.Errorf("%s exists: want %t, got %t", volID, want, got)
+		}
+	}
+}
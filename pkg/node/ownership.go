@@ -0,0 +1,65 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// FileModeKey sets the permissions, in octal, of every projected file, such as
+// "0444" for containers running outside the pod's fsGroup.
+const FileModeKey = adapter.FileModeKey
+
+// noGroup leaves the group of the volume's files as the adapter's.
+const noGroup = -1
+
+// parseFileMode returns the file permissions requested by the volume context,
+// or zero when every file keeps its own default. In hardened mode modes
+// granting access to other users are rejected.
+func parseFileMode(volCtx map[string]string, hardened bool) (os.FileMode, error) {
+	value, ok := volCtx[FileModeKey]
+	if !ok {
+		return 0, nil
+	}
+
+	mode, err := adapter.ParseFileMode(value)
+	if err != nil {
+		return 0, err
+	}
+	if hardened && mode&0007 != 0 {
+		return 0, fmt.Errorf(util.ErrorTemplateHardenedFileMode, value)
+	}
+	return mode, nil
+}
+
+// mountGroup returns the group owning the volume's directories and files: the
+// pod's fsGroup, or noGroup if it has none. Kubelet does not apply fsGroup to
+// the inline ephemeral volumes of this driver, so without it containers not
+// running as root cannot read the projected files.
+func mountGroup(pod *v1.Pod) int {
+	if pod == nil || pod.Spec.SecurityContext == nil || pod.Spec.SecurityContext.FSGroup == nil {
+		return noGroup
+	}
+	return int(*pod.Spec.SecurityContext.FSGroup)
+}
+
+// volumeMountGroup returns the group kubelet passes in the volume capability
+// when the driver advertises VOLUME_MOUNT_GROUP, which takes precedence over
+// the fsGroup of the pod. ok is false if kubelet passes none.
+func volumeMountGroup(capability *csi.VolumeCapability) (gid int, ok bool, err error) {
+	group := capability.GetMount().GetVolumeMountGroup()
+	if group == "" {
+		return noGroup, false, nil
+	}
+	gid, err = strconv.Atoi(group)
+	if err != nil || gid < 0 {
+		return noGroup, false, fmt.Errorf(util.ErrorTemplateInvalidMountGroup, group)
+	}
+	return gid, true, nil
+}
@@ -0,0 +1,147 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestParseFileMode(t *testing.T) {
+	type want struct {
+		mode os.FileMode
+		err  error
+	}
+
+	cases := map[string]struct {
+		volCtx   map[string]string
+		hardened bool
+		want
+	}{
+		"Default": {
+			want: want{mode: 0},
+		},
+		"Attribute": {
+			volCtx: map[string]string{FileModeKey: "0444"},
+			want:   want{mode: 0444},
+		},
+		"NotOctal": {
+			volCtx: map[string]string{FileModeKey: "r"},
+			want:   want{err: fmt.Errorf(util.ErrorTemplateInvalidFileMode, "r")},
+		},
+		"HardenedGroupReadable": {
+			volCtx:   map[string]string{FileModeKey: "0440"},
+			hardened: true,
+			want:     want{mode: 0440},
+		},
+		"HardenedWorldReadable": {
+			volCtx:   map[string]string{FileModeKey: "0444"},
+			hardened: true,
+			want:     want{err: fmt.Errorf(util.ErrorTemplateHardenedFileMode, "0444")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mode, err := parseFileMode(tc.volCtx, tc.hardened)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.mode, mode); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMountGroup(t *testing.T) {
+	fsGroup := int64(2000)
+
+	cases := map[string]struct {
+		pod  *v1.Pod
+		want int
+	}{
+		"NoPod": {
+			want: noGroup,
+		},
+		"NoSecurityContext": {
+			pod:  &v1.Pod{},
+			want: noGroup,
+		},
+		"FSGroup": {
+			pod:  &v1.Pod{Spec: v1.PodSpec{SecurityContext: &v1.PodSecurityContext{FSGroup: &fsGroup}}},
+			want: 2000,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, mountGroup(tc.pod)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestVolumeMountGroup(t *testing.T) {
+	type want struct {
+		gid int
+		ok  bool
+		err error
+	}
+
+	mount := func(group string) *csi.VolumeCapability {
+		return &csi.VolumeCapability{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{VolumeMountGroup: group},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		capability *csi.VolumeCapability
+		want
+	}{
+		"NoCapability": {
+			want: want{gid: noGroup},
+		},
+		"NoGroup": {
+			capability: mount(""),
+			want:       want{gid: noGroup},
+		},
+		"Group": {
+			capability: mount("3000"),
+			want:       want{gid: 3000, ok: true},
+		},
+		"NotNumeric": {
+			capability: mount("users"),
+			want:       want{gid: noGroup, err: fmt.Errorf(util.ErrorTemplateInvalidMountGroup, "users")},
+		},
+		"Negative": {
+			capability: mount("-1"),
+			want:       want{gid: noGroup, err: fmt.Errorf(util.ErrorTemplateInvalidMountGroup, "-1")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gid, ok, err := volumeMountGroup(tc.capability)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.gid, gid); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.ok, ok); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
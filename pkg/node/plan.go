@@ -0,0 +1,381 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/rotation"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// publishPlan is everything a volume is published from, resolved and validated
+// before anything is written to the node.
+type publishPlan struct {
+	barName, podName, podNs string
+	podScoped               bool
+	delivery                adapter.CredentialDelivery
+	exec                    bool
+	// podBA is the name of the pod's own BucketAccess, once ensured.
+	podBA string
+	// identityAccess is set when the BucketAccess grants access to the pod's
+	// identity and mints no secret, so no credentials are projected unless
+	// exchanged for web identity credentials.
+	identityAccess bool
+	// anonymous is set when the bucket is open to anonymous access, so the
+	// minted secret is not read and no credentials are projected.
+	anonymous bool
+	// initOnly is set when only the init containers of the pod need the
+	// credentials, and wiped once they are left out of the volume.
+	initOnly bool
+	wiped    bool
+
+	bkt    *v1alpha1.Bucket
+	ba     *v1alpha1.BucketAccess
+	secret *v1.Secret
+	pod    *v1.Pod
+
+	protocolConnection []byte
+	protocolFile       string
+	credsFile          string
+	subdirs            []string
+	formats            []string
+	formatSource       render.FormatSource
+	checksum           render.Checksummer
+	projection         adapter.Projection
+	rotationPolicy     rotation.Policy
+	dirMode, fileMode  os.FileMode
+	gid                int
+
+	kerberos []render.File
+	rendered []render.File
+	keys     []render.File
+}
+
+// resolve resolves the objects a volume is published from and validates its
+// attributes against them, returning gRPC status errors. A dry run has no side
+// effects: it emits no events and, for pod scoped credentials, neither creates
+// the pod's BucketAccess nor waits for it, resolving the shared one instead.
+// Once the pod's BucketAccess was ensured, the plan is returned along with any
+// error, so a failed publish can delete it.
+func (n *NodeServer) resolve(ctx context.Context, volID string, volCtx map[string]string, dryRun bool) (plan *publishPlan, err error) {
+	p := &publishPlan{}
+	defer func() {
+		if err != nil && p.podBA != "" {
+			plan = p
+		}
+	}()
+
+	p.barName, p.podName, p.podNs, err = client.ParseVolumeContext(volCtx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	p.podScoped, err = n.podScopedCredentials(volCtx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	p.delivery, err = adapter.ParseCredentialDelivery(volCtx[adapter.CredentialDeliveryKey])
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	p.exec = p.delivery == adapter.CredentialDeliveryExec
+	if p.exec {
+		if err := n.helper.usable(); err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+	}
+
+	anonymous, err := adapter.ParseAnonymous(volCtx[adapter.AnonymousKey])
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	p.initOnly, err = adapter.ParseInitOnly(volCtx[adapter.InitOnlyKey])
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if p.initOnly && p.exec {
+		return nil, status.Error(codes.InvalidArgument, util.ErrorInitOnlyExec.Error())
+	}
+	scope, delivery := adapter.CredentialScopeShared, p.delivery
+	if p.podScoped {
+		scope = adapter.CredentialScopePod
+	}
+	if anonymous {
+		if err := adapter.ValidateAnonymous(volCtx, scope, delivery); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		ctx = client.WithAnonymousAccess(ctx)
+	}
+
+	start := time.Now()
+	p.bkt, p.ba, p.secret, p.pod, err = n.cosiClient.GetResources(ctx, p.barName, p.podName, p.podNs)
+	if n.shedder.observe(time.Since(start)) && p.pod != nil && !dryRun {
+		util.EmitWarningEvent(n.cosiClient.Recorder(), p.pod, util.LoadSheddingStarted)
+	}
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err := n.checkServiceAccount(ctx, p, dryRun); err != nil {
+		return nil, err
+	}
+
+	if p.podScoped && !dryRun {
+		if p.ba, err = n.cosiClient.EnsurePodBA(ctx, p.ba, p.pod); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		p.podBA = p.ba.Name
+		if p.ba, p.secret, err = n.cosiClient.WaitForPodBA(ctx, p.pod, p.podBA); err != nil {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+	}
+
+	p.identityAccess = client.IdentityAccess(p.ba) && p.ba.Status.MintedSecret == nil
+	if p.identityAccess && p.exec {
+		return nil, status.Error(codes.FailedPrecondition, util.ErrorIdentityAccessExec.Error())
+	}
+	// GetResources returns an empty secret for anonymous access, requested by
+	// the volume or by annotations of the BucketAccessRequest or BucketAccess.
+	p.anonymous = !p.identityAccess && p.secret.Name == ""
+	if p.anonymous {
+		if err := adapter.ValidateAnonymous(volCtx, scope, delivery); err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+	}
+
+	if p.bkt, err = render.NormalizeBucket(p.bkt); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	p.protocolConnection, err = client.GetProtocol(p.bkt)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	klog.Infof("bucket %q has protocol %q", p.bkt.Name, p.bkt.Spec.Protocol)
+	n.failures.v(volID, 5).InfoS("resolved bucket resources",
+		"volumeID", volID, "bucket", p.bkt.Name, "bucketAccess", p.ba.Name, "secret", p.secret.Name)
+
+	p.subdirs, err = parseSubdirs(volCtx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if p.formats, p.formatSource, err = n.selectFormats(ctx, volCtx, p.bkt); err != nil {
+		return nil, err
+	}
+	if (p.exec || p.credentialless()) && p.formatSource != render.FormatSourceVolume {
+		p.formats = credentialFreeFormats(p.formats)
+	}
+	if p.exec {
+		if p.formats, err = execFormats(p.formats); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	p.checksum, err = render.ResolveChecksum(volCtx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	p.protocolFile, p.credsFile, err = adapter.ParseFileNames(volCtx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var keyPaths map[string]string
+	p.projection, keyPaths, err = adapter.ParseProjection(volCtx)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if p.exec && p.projection == adapter.ProjectionKeys {
+		return nil, status.Error(codes.InvalidArgument, util.ErrorExecKeysProjection.Error())
+	}
+
+	p.rotationPolicy, err = rotation.ParsePolicy(volCtx, n.rotationDefaults)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	p.dirMode, err = parseDirMode(volCtx, n.hardened)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	p.fileMode, err = parseFileMode(volCtx, n.hardened)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	p.gid = mountGroup(p.pod)
+
+	if err := n.exchangeWebIdentity(ctx, p, volCtx, dryRun); err != nil {
+		return nil, err
+	}
+	if expiry, ok := render.CredentialExpiration(p.secret); ok {
+		if n.skew.check(expiry) && !dryRun {
+			klog.InfoS("expiring credentials are affected by clock skew", "volumeID", volID, "expiry", expiry, "skew", n.skew.current())
+			util.EmitWarningEvent(n.cosiClient.Recorder(), p.pod, util.ClockSkewDetected)
+		}
+		p.rotationPolicy = padRefresh(p.rotationPolicy, expiry, time.Now(), n.skew.current())
+	}
+
+	if !p.exec && hasKerberosCredentials(p.secret) {
+		if p.kerberos, p.secret, err = kerberosFiles(p.secret, p.bkt); err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+	}
+
+	p.rendered, err = render.Render(p.formats, render.Input{Bucket: p.bkt, Secret: p.secret, Attributes: volCtx})
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if err := p.checkFileNames(volCtx); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if p.projection == adapter.ProjectionKeys {
+		taken := append(append([]render.File{{Name: p.protocolFile}}, p.kerberos...), p.rendered...)
+		if p.keys, err = keyFiles(p.secret, keyPaths, taken); err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+	}
+	return p, nil
+}
+
+// credentialFiles returns the files holding the minted credentials, as
+// projected by files delivery.
+func (p *publishPlan) credentialFiles() ([]render.File, error) {
+	if p.credentialless() {
+		return nil, nil
+	}
+	if p.projection == adapter.ProjectionKeys {
+		return append(append([]render.File{}, p.keys...), p.kerberos...), nil
+	}
+	creds, err := util.ParseData(p.secret)
+	if err != nil {
+		return nil, err
+	}
+	return append([]render.File{{Name: p.credsFile, Data: creds}}, p.kerberos...), nil
+}
+
+// credentialless reports whether the volume has no credentials to project.
+func (p *publishPlan) credentialless() bool {
+	return p.identityAccess || p.anonymous || p.wiped
+}
+
+// wipe leaves the credentials out of the files of p, keeping those the pod
+// may still read once its init containers have run.
+func (p *publishPlan) wipe(volCtx map[string]string) (err error) {
+	p.wiped = true
+	p.rendered, err = render.Render(credentialFreeFormats(p.formats), render.Input{Bucket: p.bkt, Secret: &v1.Secret{}, Attributes: volCtx})
+	return err
+}
+
+// secretRef returns the "namespace/name" of the secret the credentials were
+// read from, empty for identity and anonymous access.
+func (p *publishPlan) secretRef() string {
+	if p.secret.Name == "" {
+		return ""
+	}
+	return p.secret.Namespace + "/" + p.secret.Name
+}
+
+// withRendered returns projected followed by the rendered files and, when the
+// volume asks for one, the checksum file of them all.
+func (p *publishPlan) withRendered(projected []render.File) []render.File {
+	rendered := p.rendered
+	if p.checksum != nil {
+		rendered = append(append([]render.File{}, rendered...), p.checksum(append(projected, rendered...)))
+	}
+	return append(projected, rendered...)
+}
+
+// atomicFiles returns the files written to project files into each of dirs.
+func atomicFiles(dirs []string, files []render.File) []client.AtomicFile {
+	atomic := make([]client.AtomicFile, 0, len(dirs)*len(files))
+	for _, dir := range dirs {
+		for _, f := range files {
+			atomic = append(atomic, client.AtomicFile{Path: filepath.Join(dir, f.Name), Data: f.Data, Mode: f.Mode})
+		}
+	}
+	return atomic
+}
+
+// checkFileNames fails if a file name set in volCtx collides with a file
+// projected by a format, the Kerberos configuration or the checksum file.
+func (p *publishPlan) checkFileNames(volCtx map[string]string) error {
+	taken := append(append([]render.File{}, p.kerberos...), p.rendered...)
+	if p.checksum != nil {
+		taken = append(taken, p.checksum(nil))
+	}
+	for _, key := range []string{adapter.ProtocolFileNameKey, adapter.CredentialsFileNameKey} {
+		name, ok := volCtx[key]
+		if !ok {
+			continue
+		}
+		for _, f := range taken {
+			if f.Name == name {
+				return fmt.Errorf(util.ErrorTemplateFileNameConflict, name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// selectFormats returns the formats of the volume, by precedence: those of the
+// volume attributes, of the Bucket's class, of the adapter, then of the
+// protocol of bkt.
+func (n *NodeServer) selectFormats(ctx context.Context, volCtx map[string]string, bkt *v1alpha1.Bucket) ([]string, render.FormatSource, error) {
+	defaults := render.FormatDefaults{Adapter: n.defaultFormats, Protocol: n.protocolFormats}
+	_, hasFormat := volCtx[render.FormatKey]
+	_, hasProfile := volCtx[render.ProfileKey]
+	if !hasFormat && !hasProfile && bkt.Spec.BucketClassName != "" {
+		class, err := n.cosiClient.GetBucketClass(ctx, bkt.Spec.BucketClassName)
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			return nil, "", status.Error(codes.Unavailable, errors.Wrap(err, util.WrapErrorGetBucketClassFailed).Error())
+		default:
+			if v, ok := class.Parameters[render.BucketClassFormatParameter]; ok {
+				defaults.BucketClass = &v
+			}
+		}
+	}
+
+	formats, source, err := render.SelectFormats(volCtx, bkt, defaults)
+	if err != nil {
+		if source == render.FormatSourceVolume {
+			return nil, "", status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, "", status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return formats, source, nil
+}
+
+// credentialFreeFormats drops the formats writing the minted secret, which
+// exec delivery volumes only refuse when they request them.
+func credentialFreeFormats(formats []string) []string {
+	var free []string
+	for _, name := range formats {
+		if render.CredentialFree(name) {
+			free = append(free, name)
+		}
+	}
+	return free
+}
@@ -0,0 +1,53 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// keyFiles returns a file per key of secret, for adapter.ProjectionKeys. Keys
+// are named after paths when mapped there, and after themselves otherwise, in
+// the manner of a Secret volume. Every mapped key must be present, and no file
+// may collide with one of taken, the other files projected into the volume.
+//
+// Files are left without a mode so they get the volume's file mode.
+func keyFiles(secret *v1.Secret, paths map[string]string, taken []render.File) ([]render.File, error) {
+	for key := range paths {
+		if _, ok := secret.Data[key]; !ok {
+			return nil, fmt.Errorf(util.ErrorTemplateKeyNotInSecret, key)
+		}
+	}
+
+	keys := make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	used := map[string]bool{}
+	for _, f := range taken {
+		used[f.Name] = true
+	}
+	files := make([]render.File, 0, len(keys))
+	for _, key := range keys {
+		name, ok := paths[key]
+		if !ok {
+			name = key
+		}
+		if !adapter.ValidKeyPath(name) {
+			return nil, fmt.Errorf(util.ErrorTemplateInvalidKeyPath, name, key)
+		}
+		if used[name] {
+			return nil, fmt.Errorf(util.ErrorTemplateKeyFileConflict, name, key)
+		}
+		used[name] = true
+		files = append(files, render.File{Name: name, Data: secret.Data[key]})
+	}
+	return files, nil
+}
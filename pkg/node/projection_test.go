@@ -0,0 +1,79 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestKeyFiles(t *testing.T) {
+	secret := &v1.Secret{Data: map[string][]byte{
+		"accessKeyID":     []byte("AKIAEXAMPLE"),
+		"accessSecretKey": []byte("secret"),
+	}}
+
+	type want struct {
+		files []render.File
+		err   error
+	}
+
+	cases := map[string]struct {
+		paths map[string]string
+		taken []render.File
+		want
+	}{
+		"KeyNames": {
+			want: want{
+				files: []render.File{
+					{Name: "accessKeyID", Data: []byte("AKIAEXAMPLE")},
+					{Name: "accessSecretKey", Data: []byte("secret")},
+				},
+			},
+		},
+		"Mapped": {
+			paths: map[string]string{"accessKeyID": "access_key_id", "accessSecretKey": "secret_access_key"},
+			want: want{
+				files: []render.File{
+					{Name: "access_key_id", Data: []byte("AKIAEXAMPLE")},
+					{Name: "secret_access_key", Data: []byte("secret")},
+				},
+			},
+		},
+		"MappedKeyMissing": {
+			paths: map[string]string{"sessionToken": "session_token"},
+			want: want{
+				err: fmt.Errorf(util.ErrorTemplateKeyNotInSecret, "sessionToken"),
+			},
+		},
+		"TwoKeysOneFile": {
+			paths: map[string]string{"accessSecretKey": "accessKeyID"},
+			want: want{
+				err: fmt.Errorf(util.ErrorTemplateKeyFileConflict, "accessKeyID", "accessSecretKey"),
+			},
+		},
+		"TakenByFormat": {
+			paths: map[string]string{"accessKeyID": "credentials"},
+			taken: []render.File{{Name: "credentials"}},
+			want: want{
+				err: fmt.Errorf(util.ErrorTemplateKeyFileConflict, "credentials", "accessKeyID"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			files, err := keyFiles(secret, tc.paths, tc.taken)
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.files, files); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
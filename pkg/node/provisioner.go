@@ -7,20 +7,49 @@ import (
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
 
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2"
 	"k8s.io/mount-utils"
 
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
 	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/rotation"
 )
 
 const (
 	finalizer = "cosi.objectstorage.k8s.io/bucketaccess-protection"
+
+	// DefaultDriverName is the name the adapter registers under by default.
+	// Adapters running under another name prefix their finalizers with it, so
+	// several deployments can share BucketAccesses without removing each
+	// other's finalizers.
+	DefaultDriverName = "objectstorage.k8s.io"
 )
 
+// finalizerPrefix returns the prefix of the finalizers placed by the driver
+// named driverName.
+func finalizerPrefix(driverName string) string {
+	if driverName == "" || driverName == DefaultDriverName {
+		return finalizer
+	}
+	return driverName + "/bucketaccess-protection"
+}
+
+// tmpfsSize bounds the memory a memory backed volume may use. Projected files
+// are a few kilobytes; the limit only guards against runaway writes.
+const tmpfsSize = "4m"
+
 type Provisioner struct {
 	dataPath string
 	mounter  mount.Interface
 	pclient  client.ProvisionerClient
+	syncer   *FileSyncer
+	// memoryBacked mounts a tmpfs on each volume's directory before any file
+	// is written, so credentials never reach the disk of the node.
+	memoryBacked bool
 }
 
 func NewProvisioner(dataPath string, p mount.Interface, pc client.ProvisionerClient) Provisioner {
@@ -39,27 +68,59 @@ func (p Provisioner) bucketPath(volID string) string {
 	return filepath.Join(p.dataPath, volID, "bucket")
 }
 
-func (p Provisioner) createDir(volID string) error {
-	if err := p.pclient.MkdirAll(p.bucketPath(volID), 0750); err != nil {
+// createDir creates the volume's directory with mode, whatever the umask, and
+// owned by group gid unless it is noGroup.
+func (p Provisioner) createDir(volID string, mode os.FileMode, gid int) error {
+	if err := p.pclient.MkdirAll(p.bucketPath(volID), mode); err != nil {
 		return errors.Wrap(err, util.WrapErrorMkdirFailed)
 	}
+	if !p.memoryBacked {
+		return errors.Wrap(p.pclient.SetOwnership(p.bucketPath(volID), mode, gid), util.WrapErrorMkdirFailed)
+	}
+	opts := []string{"nosuid", "nodev", "noexec", fmt.Sprintf("mode=%#o", mode), "size=" + tmpfsSize}
+	if gid != noGroup {
+		opts = append(opts, fmt.Sprintf("gid=%d", gid))
+	}
+	if err := p.mounter.Mount("tmpfs", p.bucketPath(volID), "tmpfs", opts); err != nil {
+		return errors.Wrap(err, util.WrapErrorMountTmpfsFailed)
+	}
 	return nil
 }
 
 func (p Provisioner) removeDir(volID string) error {
+	if err := p.unmountTmpfs(volID); err != nil {
+		return errors.Wrap(err, util.WrapErrorUnmountTmpfsFailed)
+	}
 	if err := p.pclient.RemoveAll(p.volPath(volID)); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 	return nil
 }
 
-func (p Provisioner) mountDir(volID, targetPath string) error {
+// unmountTmpfs unmounts the tmpfs of a memory backed volume. The check does not
+// depend on memoryBacked, so volumes published before the option was turned off
+// are still cleaned up.
+func (p Provisioner) unmountTmpfs(volID string) error {
+	notMnt, err := p.mounter.IsLikelyNotMountPoint(p.bucketPath(volID))
+	if os.IsNotExist(err) || (err == nil && notMnt) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return p.mounter.Unmount(p.bucketPath(volID))
+}
+
+// mountDir bind mounts the volume's directory, which stays in the plugin owned
+// data path, onto targetPath. A read-only mount keeps the pod from modifying
+// or deleting the projected files; the adapter still rewrites them in place.
+func (p Provisioner) mountDir(volID, targetPath string, mode os.FileMode, readOnly bool) error {
 	// Check if the target path is already mounted. Prevent remounting.
 	notMnt, err := mount.IsNotMountPoint(p.mounter, targetPath)
 	if err != nil {
 		klog.Error(err)
 		if os.IsNotExist(err) {
-			if err = p.pclient.MkdirAll(targetPath, 0750); err != nil {
+			if err = p.pclient.MkdirAll(targetPath, mode); err != nil {
 				return errors.Wrap(err, util.WrapErrorFailedToMkdirForMount)
 			}
 			notMnt = true
@@ -72,32 +133,73 @@ func (p Provisioner) mountDir(volID, targetPath string) error {
 		return fmt.Errorf(util.ErrorTemplateVolumeAlreadyMounted, targetPath)
 	}
 
-	if err := p.mounter.Mount(p.bucketPath(volID), targetPath, "", []string{"bind"}); err != nil {
+	if err := p.mounter.Mount(p.bucketPath(volID), targetPath, "", bindMountOptions(readOnly)); err != nil {
 		return errors.Wrap(err, fmt.Sprintf(util.ErrorTemplateMountFailed, p.bucketPath(volID), targetPath))
 	}
 	return nil
 }
 
-func (p Provisioner) writeFileToVolumeMount(data []byte, volID, fileName string) error {
-	err := p.pclient.WriteFile(data, filepath.Join(p.bucketPath(volID), fileName))
-	if err != nil {
+// projectedFileMode is the mode of projected files that do not set their own.
+const projectedFileMode os.FileMode = 0440
+
+// writeProjected atomically replaces the files visible in the volume's
+// directory with files, so the pod never reads a partially written set. A
+// non-zero mode overrides the read and write permissions of every file, and
+// the files are owned by group gid unless it is noGroup.
+func (p Provisioner) writeProjected(volID string, files []client.AtomicFile, mode os.FileMode, gid int) error {
+	for i := range files {
+		switch {
+		case mode != 0:
+			files[i].Mode = mode | files[i].Mode&0111
+		case files[i].Mode == 0:
+			files[i].Mode = projectedFileMode
+		}
+	}
+	if err := p.pclient.WriteAtomic(p.bucketPath(volID), files, gid); err != nil {
 		return errors.Wrap(err, util.WrapErrorFailedToCreateBucketFile)
 	}
+	for _, f := range files {
+		if err := p.syncer.written(filepath.Join(p.bucketPath(volID), f.Path), false); err != nil {
+			return errors.Wrap(err, util.WrapErrorFailedToSyncFile)
+		}
+	}
 	return nil
 }
 
 func (p Provisioner) writeFileToVolume(data []byte, volID, fileName string) error {
-	err := p.pclient.WriteFile(data, filepath.Join(p.volPath(volID), fileName))
-	if err != nil {
+	path := filepath.Join(p.volPath(volID), fileName)
+	if err := p.pclient.WriteFile(data, path); err != nil {
 		return errors.Wrap(err, util.WrapErrorFailedToCreateVolumeFile)
 	}
-	return nil
+	// The volume metadata is what unpublish and stats rely on after a crash.
+	return errors.Wrap(p.syncer.written(path, true), util.WrapErrorFailedToSyncFile)
+}
+
+// replaceFileInVolume is writeFileToVolume for a file that may exist.
+func (p Provisioner) replaceFileInVolume(data []byte, volID, fileName string) error {
+	path := filepath.Join(p.volPath(volID), fileName)
+	if err := p.pclient.ReplaceFile(data, path); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToCreateVolumeFile)
+	}
+	return errors.Wrap(p.syncer.written(path, true), util.WrapErrorFailedToSyncFile)
 }
 
 func (p Provisioner) readFileFromVolume(volID, fileName string) ([]byte, error) {
 	return p.pclient.ReadFile(filepath.Join(p.volPath(volID), fileName))
 }
 
+func (p Provisioner) readFileFromVolumeMount(volID, fileName string) ([]byte, error) {
+	return p.pclient.ReadFile(filepath.Join(p.bucketPath(volID), fileName))
+}
+
+func (p Provisioner) isMounted(targetPath string) (bool, error) {
+	notMnt, err := mount.IsNotMountPoint(p.mounter, targetPath)
+	if err != nil {
+		return false, err
+	}
+	return !notMnt, nil
+}
+
 func (p Provisioner) removeMount(path string) error {
 	err := mount.CleanupMountPoint(path, p.mounter, true)
 	if err != nil && !os.IsNotExist(err) {
@@ -111,8 +213,108 @@ type Metadata struct {
 	BaName       string `json:"baName"`
 	PodName      string `json:"podName"`
 	PodNamespace string `json:"podNamespace"`
+	// PodUID and TargetPath record where the volume was published, so it can
+	// be unpublished after its pod was deleted.
+	PodUID     types.UID `json:"podUID,omitempty"`
+	TargetPath string    `json:"targetPath,omitempty"`
+	// SecretFinalizerAdded is set once the finalizer of the volume was placed
+	// on Secret too. Volumes always hold the finalizer on BaName.
+	SecretFinalizerAdded bool `json:"secretFinalizerAdded,omitempty"`
+	// Bucket and Secret name the Bucket and minted secret ("namespace/name")
+	// materialized by the volume, so a restarted adapter can account for it.
+	Bucket string `json:"bucket,omitempty"`
+	Secret string `json:"secret,omitempty"`
+	// BarName, CredentialDelivery and MountGroup let a restarted adapter serve
+	// the credential helper socket of exec delivery volumes again.
+	BarName            string                     `json:"barName,omitempty"`
+	CredentialDelivery adapter.CredentialDelivery `json:"credentialDelivery,omitempty"`
+	MountGroup         *int                       `json:"mountGroup,omitempty"`
+	// PodScoped is set when BaName was created for this pod alone and must be
+	// deleted on unpublish.
+	PodScoped bool `json:"podScoped,omitempty"`
+	// Rotation is the volume's rotation policy, resolved at publish time.
+	Rotation *rotation.Policy `json:"rotation,omitempty"`
+	// Expiration is when the credentials projected into the volume expire,
+	// if they are temporary.
+	Expiration *metav1.Time `json:"expiration,omitempty"`
+	// Files maps each file projected into the mount, relative to the mounted
+	// directory, to the hex SHA-256 of its content.
+	Files map[string]string `json:"files,omitempty"`
+	// Formats are the formats rendered into the volume and FormatSource the
+	// setting that selected them, for debugging.
+	Formats      []string            `json:"formats,omitempty"`
+	FormatSource render.FormatSource `json:"formatSource,omitempty"`
+	// VolumeContext is the volume context the volume was published with, from
+	// which its files are rendered again when its credentials change.
+	VolumeContext map[string]string `json:"volumeContext,omitempty"`
+	// FinalizerPrefix is the prefix of the finalizer placed on BaName. Volumes
+	// published before it was recorded used the default prefix.
+	FinalizerPrefix string `json:"finalizerPrefix,omitempty"`
+	// Finalizer is the finalizer placed on BaName, keyed by the UID of the
+	// pod. Volumes published before it was recorded used one keyed by the pod
+	// name instead.
+	Finalizer client.Finalizer `json:"finalizer,omitempty"`
+	// Snapshots describes the versions of the BucketAccess, Bucket and minted
+	// secret the volume was published from, keyed by kind.
+	Snapshots map[string]client.Snapshot `json:"snapshots,omitempty"`
+	// InitOnly is set when the credentials are only needed by the init
+	// containers of the pod, and CredentialsWiped once they were removed.
+	InitOnly         bool `json:"initOnly,omitempty"`
+	CredentialsWiped bool `json:"credentialsWiped,omitempty"`
+}
+
+// finalizer returns the finalizer the volume placed on its BucketAccess.
+func (m Metadata) finalizer() client.Finalizer {
+	if m.Finalizer != "" {
+		return m.Finalizer
+	}
+	prefix := m.FinalizerPrefix
+	if prefix == "" {
+		prefix = finalizer
+	}
+	return client.Finalizer(fmt.Sprintf("%s-%s-%s", prefix, m.PodNamespace, m.PodName))
+}
+
+// podFinalizer returns the finalizer protecting a BucketAccess for the pod with
+// uid. Pods on other nodes, and a pod recreated under the same name, use
+// finalizers of their own, so the BucketAccess stays protected until its last
+// consumer is unpublished. Unlike the pod name, the UID always fits a
+// qualified name.
+func podFinalizer(prefix string, uid types.UID) client.Finalizer {
+	return client.Finalizer(fmt.Sprintf("%s-%s", prefix, uid))
+}
+
+// pod returns a stand-in for the pod of the volume, for unpublishing it once
+// the pod is gone. Events on it are still found by the pod name.
+func (m Metadata) pod() *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: m.PodName, Namespace: m.PodNamespace, UID: m.PodUID}}
+}
+
+// podUID returns the UID of the pod the volume was published for. Metadata
+// written before it was recorded falls back to pod, which a pod recreated
+// under the same name gets wrong.
+func (m Metadata) podUID(pod *v1.Pod) types.UID {
+	if m.PodUID != "" {
+		return m.PodUID
+	}
+	return pod.UID
+}
+
+// credentialSource returns where an exec delivery volume reads credentials from.
+func (m Metadata) credentialSource() credentialSource {
+	return credentialSource{
+		barName:      m.BarName,
+		podName:      m.PodName,
+		podNamespace: m.PodNamespace,
+		baName:       m.BaName,
+		podScoped:    m.PodScoped,
+	}
 }
 
-func (m Metadata) finalizer() string {
-	return fmt.Sprintf("%s-%s-%s", finalizer, m.PodNamespace, m.PodName)
+// mountGroup returns the group recorded for the volume, or noGroup.
+func (m Metadata) mountGroup() int {
+	if m.MountGroup == nil {
+		return noGroup
+	}
+	return *m.MountGroup
 }
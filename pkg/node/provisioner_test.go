@@ -3,6 +3,7 @@ package node
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -26,11 +27,13 @@ func TestMountDir(t *testing.T) {
 		rclient    client.ProvisionerClient
 		volId      string
 		targetPath string
-		mp         mount.Interface
+		readOnly   bool
+		mp         *mount.FakeMounter
 	}
 
 	type want struct {
-		err error
+		err  error
+		opts []string
 	}
 
 	cases := map[string]struct {
@@ -51,7 +54,27 @@ func TestMountDir(t *testing.T) {
 				},
 			},
 			want: want{
-				err: nil,
+				err:  nil,
+				opts: []string{"bind"},
+			},
+		},
+		"SuccessfulReadOnly": {
+			args: args{
+				mp: &mount.FakeMounter{
+					MountPoints: []mount.MountPoint{},
+				},
+				volId:      volumeId,
+				targetPath: targetPath,
+				readOnly:   true,
+				rclient: &fake.MockProvisionerClient{
+					MockMkdirAll: func(path string, perm os.FileMode) error {
+						return nil
+					},
+				},
+			},
+			want: want{
+				err:  nil,
+				opts: []string{"bind", "ro"},
 			},
 		},
 		"SuccessfulNotMount": {
@@ -127,11 +150,127 @@ func TestMountDir(t *testing.T) {
 				pclient:  tc.rclient,
 			}
 
-			err := p.mountDir(tc.volId, tc.targetPath)
+			err := p.mountDir(tc.volId, tc.targetPath, defaultDirMode, tc.readOnly)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if tc.want.opts == nil {
+				return
+			}
+			var opts []string
+			for _, mp := range tc.mp.MountPoints {
+				if mp.Path == tc.targetPath {
+					opts = mp.Opts
+				}
+			}
+			if diff := cmp.Diff(tc.want.opts, opts); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCreateDir(t *testing.T) {
+	type want struct {
+		err    error
+		mounts []mount.MountPoint
+	}
+
+	cases := map[string]struct {
+		memoryBacked bool
+		gid          int
+		mounter      *mount.FakeMounter
+		want
+	}{
+		"OnDisk": {
+			gid:     noGroup,
+			mounter: &mount.FakeMounter{},
+			want:    want{},
+		},
+		"MemoryBacked": {
+			memoryBacked: true,
+			gid:          noGroup,
+			mounter:      &mount.FakeMounter{},
+			want: want{
+				mounts: []mount.MountPoint{{
+					Device: "tmpfs",
+					Path:   filepath.Join(volumeId, "bucket"),
+					Type:   "tmpfs",
+					Opts:   []string{"nosuid", "nodev", "noexec", "mode=0700", "size=" + tmpfsSize},
+				}},
+			},
+		},
+		"MemoryBackedWithGroup": {
+			memoryBacked: true,
+			gid:          2000,
+			mounter:      &mount.FakeMounter{},
+			want: want{
+				mounts: []mount.MountPoint{{
+					Device: "tmpfs",
+					Path:   filepath.Join(volumeId, "bucket"),
+					Type:   "tmpfs",
+					Opts:   []string{"nosuid", "nodev", "noexec", "mode=0700", "size=" + tmpfsSize, "gid=2000"},
+				}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := &Provisioner{
+				mounter: tc.mounter,
+				pclient: &fake.MockProvisionerClient{
+					MockMkdirAll: func(path string, perm os.FileMode) error {
+						return nil
+					},
+					MockSetOwnership: func(path string, mode os.FileMode, gid int) error {
+						return nil
+					},
+				},
+				memoryBacked: tc.memoryBacked,
+			}
+
+			err := p.createDir(volumeId, hardenedDirMode, tc.gid)
 
 			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
 				t.Errorf("r: -want, +got:\n%s", diff)
 			}
+			if diff := cmp.Diff(tc.want.mounts, tc.mounter.MountPoints); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMetadataFinalizer(t *testing.T) {
+	cases := map[string]struct {
+		meta Metadata
+		want client.Finalizer
+	}{
+		"PublishedBeforePrefixWasRecorded": {
+			meta: Metadata{PodName: "pod", PodNamespace: "ns"},
+			want: "cosi.objectstorage.k8s.io/bucketaccess-protection-ns-pod",
+		},
+		"DefaultDriverName": {
+			meta: Metadata{PodName: "pod", PodNamespace: "ns", FinalizerPrefix: finalizerPrefix(DefaultDriverName)},
+			want: "cosi.objectstorage.k8s.io/bucketaccess-protection-ns-pod",
+		},
+		"CustomDriverName": {
+			meta: Metadata{PodName: "pod", PodNamespace: "ns", FinalizerPrefix: finalizerPrefix("staging.objectstorage.example.com")},
+			want: "staging.objectstorage.example.com/bucketaccess-protection-ns-pod",
+		},
+		"PodUID": {
+			meta: Metadata{PodName: "pod", PodNamespace: "ns", FinalizerPrefix: finalizerPrefix(DefaultDriverName), Finalizer: podFinalizer(finalizerPrefix(DefaultDriverName), "8d4f4a4e-4c1b-4a4f-9a57-2f6e1c0f3b1d")},
+			want: "cosi.objectstorage.k8s.io/bucketaccess-protection-8d4f4a4e-4c1b-4a4f-9a57-2f6e1c0f3b1d",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.meta.finalizer()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
 		})
 	}
 }
@@ -0,0 +1,144 @@
+package node
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// AnyNamespace keys the rate limit of the namespaces without their own.
+const AnyNamespace = "*"
+
+// RateLimit is a token bucket refilled with QPS tokens a second, holding up
+// to Burst.
+type RateLimit struct {
+	QPS   float32
+	Burst int
+}
+
+// ParseNamespaceRateLimits parses limits of the form namespace=qps:burst, where
+// namespace may be AnyNamespace.
+func ParseNamespaceRateLimits(limits []string) (map[string]RateLimit, error) {
+	parsed := map[string]RateLimit{}
+	for _, l := range limits {
+		ns, limit, ok := splitRateLimit(l)
+		if !ok {
+			return nil, fmt.Errorf(util.ErrorTemplateInvalidRateLimit, l)
+		}
+		parsed[ns] = limit
+	}
+	return parsed, nil
+}
+
+func splitRateLimit(s string) (string, RateLimit, bool) {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return "", RateLimit{}, false
+	}
+	qb := strings.SplitN(kv[1], ":", 2)
+	if len(qb) != 2 {
+		return "", RateLimit{}, false
+	}
+	qps, err := strconv.ParseFloat(qb[0], 32)
+	if err != nil || qps <= 0 {
+		return "", RateLimit{}, false
+	}
+	burst, err := strconv.Atoi(qb[1])
+	if err != nil || burst < 1 {
+		return "", RateLimit{}, false
+	}
+	return kv[0], RateLimit{QPS: float32(qps), Burst: burst}, true
+}
+
+// namespaceLimiter rate limits publishes by the namespace of the pod, so a
+// storm of pods in one namespace cannot starve the others of adapter workers
+// and API server budget. Every namespace has its own bucket, sized by its
+// limit or by the AnyNamespace limit; namespaces with neither are not
+// limited.
+type namespaceLimiter struct {
+	limits map[string]RateLimit
+
+	mu      sync.Mutex
+	buckets map[string]flowcontrol.RateLimiter
+	// throttled holds the namespaces whose last publish was refused.
+	throttled map[string]bool
+}
+
+// newNamespaceLimiter returns nil, which limits nothing, if limits is empty.
+func newNamespaceLimiter(limits map[string]RateLimit) *namespaceLimiter {
+	if len(limits) == 0 {
+		return nil
+	}
+	return &namespaceLimiter{
+		limits:    limits,
+		buckets:   map[string]flowcontrol.RateLimiter{},
+		throttled: map[string]bool{},
+	}
+}
+
+// allow takes a token for a publish in namespace ns. It returns whether the
+// publish may proceed and, when it may not, whether it is the first refused
+// since the namespace was last allowed one.
+func (l *namespaceLimiter) allow(ns string) (allowed, started bool) {
+	if l == nil {
+		return true, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[ns]
+	if !ok {
+		limit, ok := l.limits[ns]
+		if !ok {
+			if limit, ok = l.limits[AnyNamespace]; !ok {
+				return true, false
+			}
+		}
+		bucket = flowcontrol.NewTokenBucketRateLimiter(limit.QPS, limit.Burst)
+		l.buckets[ns] = bucket
+	}
+
+	if bucket.TryAccept() {
+		if l.throttled[ns] {
+			delete(l.throttled, ns)
+			klog.InfoS("publishes no longer rate limited", "namespace", ns)
+		}
+		return true, false
+	}
+	metrics.ThrottledPublishes.WithLabelValues(ns).Inc()
+	if l.throttled[ns] {
+		return false, false
+	}
+	l.throttled[ns] = true
+	klog.InfoS("rate limiting publishes", "namespace", ns)
+	return false, true
+}
+
+// rateLimit refuses the publish of volCtx with ResourceExhausted, which kubelet
+// retries with backoff, when the namespace of its pod is over its limit. It
+// runs before any API call, so the pod the event is emitted on is only
+// referenced by name.
+func (n *NodeServer) rateLimit(volCtx map[string]string) error {
+	ns := volCtx[client.PodNamespaceKey]
+	allowed, started := n.limiter.allow(ns)
+	if allowed {
+		return nil
+	}
+	if started {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: volCtx[client.PodNameKey], Namespace: ns}}
+		util.EmitWarningEvent(n.cosiClient.Recorder(), pod, util.PublishRateLimited)
+	}
+	return status.Error(codes.ResourceExhausted, fmt.Sprintf(util.ErrorTemplatePublishRateLimited, ns))
+}
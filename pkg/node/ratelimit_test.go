@@ -0,0 +1,100 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseNamespaceRateLimits(t *testing.T) {
+	type want struct {
+		limits map[string]RateLimit
+		err    bool
+	}
+
+	cases := map[string]struct {
+		limits []string
+		want
+	}{
+		"None": {
+			want: want{limits: map[string]RateLimit{}},
+		},
+		"Valid": {
+			limits: []string{"team-a=0.5:10", "*=5:20"},
+			want: want{limits: map[string]RateLimit{
+				"team-a":     {QPS: 0.5, Burst: 10},
+				AnyNamespace: {QPS: 5, Burst: 20},
+			}},
+		},
+		"MissingBurst": {
+			limits: []string{"team-a=5"},
+			want:   want{err: true},
+		},
+		"ZeroQPS": {
+			limits: []string{"team-a=0:10"},
+			want:   want{err: true},
+		},
+		"MissingNamespace": {
+			limits: []string{"=5:10"},
+			want:   want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			limits, err := ParseNamespaceRateLimits(tc.limits)
+			got := want{limits: limits, err: err != nil}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNamespaceLimiter(t *testing.T) {
+	type call struct {
+		allowed, started bool
+	}
+
+	// The limits refill too slowly to matter during a test.
+	cases := map[string]struct {
+		limits     map[string]RateLimit
+		namespaces []string
+		want       []call
+	}{
+		"Disabled": {
+			namespaces: []string{"team-a", "team-a"},
+			want:       []call{{allowed: true}, {allowed: true}},
+		},
+		"Burst": {
+			limits:     map[string]RateLimit{"team-a": {QPS: 0.001, Burst: 2}},
+			namespaces: []string{"team-a", "team-a", "team-a", "team-a"},
+			want:       []call{{allowed: true}, {allowed: true}, {started: true}, {}},
+		},
+		"Isolated": {
+			limits:     map[string]RateLimit{AnyNamespace: {QPS: 0.001, Burst: 1}},
+			namespaces: []string{"team-a", "team-a", "team-b"},
+			want:       []call{{allowed: true}, {started: true}, {allowed: true}},
+		},
+		"Unlisted": {
+			limits:     map[string]RateLimit{"team-a": {QPS: 0.001, Burst: 1}},
+			namespaces: []string{"team-b", "team-b"},
+			want:       []call{{allowed: true}, {allowed: true}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := newNamespaceLimiter(tc.limits)
+
+			var got []call
+			for _, ns := range tc.namespaces {
+				allowed, started := l.allow(ns)
+				got = append(got, call{allowed: allowed, started: started})
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(call{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
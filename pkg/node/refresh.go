@@ -0,0 +1,201 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// refreshable reports whether the files of the volume are rewritten when its
+// minted secret changes. Exec delivery volumes hold no credentials, nor do
+// init-only volumes once wiped, and volumes published before the volume
+// context was recorded cannot be rendered again.
+func (m Metadata) refreshable() bool {
+	if m.VolumeContext == nil || m.CredentialDelivery == adapter.CredentialDeliveryExec || m.CredentialsWiped {
+		return false
+	}
+	return m.Rotation == nil || m.Rotation.Rotates()
+}
+
+// secretChanged queues the volumes holding the credentials of the secret
+// namespace/name for a refresh.
+func (n *NodeServer) secretChanged(namespace, name string) {
+	for _, volID := range n.accounting.secretConsumers(namespace + "/" + name) {
+		n.refresher.Enqueue(volID)
+	}
+}
+
+// refreshVolume renders the files of a published volume again from its
+// current minted secret and swaps them in atomically, so the pod picks up
+// rotated credentials without a restart, then schedules the next refresh.
+// Volumes unpublished meanwhile, or now resolving to another BucketAccess than
+// the one holding their finalizer, are left alone.
+func (n *NodeServer) refreshVolume(ctx context.Context, volID string) error {
+	defer n.locks.lock(volID)()
+	return n.refreshLocked(ctx, volID)
+}
+
+// refreshLocked is refreshVolume for callers holding the lock of volID.
+func (n *NodeServer) refreshLocked(ctx context.Context, volID string) (err error) {
+	data, err := n.provisioner.readFileFromVolume(volID, metadataFilename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToReadMetadataFile)
+	}
+	meta := Metadata{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToUnmarshalMetadata)
+	}
+	if !meta.refreshable() {
+		return nil
+	}
+
+	result := "unchanged"
+	defer func() {
+		if err != nil {
+			result = "failed"
+			if meta.Expiration != nil {
+				n.renewalFailed(ctx, meta)
+			}
+		}
+		metrics.CredentialRefreshes.WithLabelValues(result).Inc()
+	}()
+
+	plan, err := n.resolve(ctx, volID, meta.VolumeContext, false)
+	if err != nil {
+		return err
+	}
+	// Keep the group of the publish, which may come from its volume capability.
+	if meta.MountGroup != nil {
+		plan.gid = *meta.MountGroup
+	}
+	if plan.ba.Name != meta.BaName {
+		klog.InfoS("not refreshing volume resolving to another BucketAccess", "volumeID", volID, "published", meta.BaName, "resolved", plan.ba.Name)
+		return nil
+	}
+	meta.Rotation = &plan.rotationPolicy
+	meta.Expiration = nil
+	if expiry, ok := render.CredentialExpiration(plan.secret); ok {
+		meta.Expiration = &metav1.Time{Time: expiry}
+	}
+
+	creds, err := plan.credentialFiles()
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToParseSecret)
+	}
+	projected := plan.withRendered(append([]render.File{{Name: plan.protocolFile, Data: plan.protocolConnection}}, creds...))
+	dirs := append([]string{""}, plan.subdirs...)
+	digests := fileDigests(dirs, projected)
+	if reflect.DeepEqual(digests, meta.Files) {
+		if meta.Expiration != nil {
+			n.checkRenewed(ctx, volID, meta, meta.Expiration.Time)
+		}
+		n.scheduleRefresh(volID, *meta.Rotation, meta.Expiration)
+		return nil
+	}
+
+	// A rotated minted secret is protected before the volume projects it,
+	// and the previous one released once it no longer does.
+	oldSecret, oldProtected := meta.Secret, meta.SecretFinalizerAdded
+	secretChanged := plan.secretRef() != oldSecret
+	if plan.secret.Name != "" && (secretChanged || !oldProtected) {
+		if err := n.cosiClient.AddSecretFinalizer(ctx, plan.secret, meta.finalizer()); err != nil {
+			return errors.Wrap(err, util.WrapErrorFailedToAddSecretFinalizer)
+		}
+	}
+
+	prev, err := n.provisioner.readManifest(volID)
+	if err != nil {
+		klog.ErrorS(err, "rewriting the manifest of volume", "volumeID", volID)
+	}
+	if err := n.provisioner.writeProjected(volID, atomicFiles(dirs, projected), plan.fileMode, plan.gid); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWriteProjected)
+	}
+	if err := n.recordManifest(volID, prev, digests); err != nil {
+		return err
+	}
+	if n.hardened {
+		if err := verifyNotWorldAccessible(n.provisioner.volPath(volID)); err != nil {
+			return errors.Wrap(err, util.WrapErrorPermissionVerificationFailed)
+		}
+	}
+
+	meta.Secret = plan.secretRef()
+	meta.SecretFinalizerAdded = plan.secret.Name != ""
+	meta.Files = digests
+	meta.Snapshots = n.snapshots(plan.ba, plan.bkt, plan.secret)
+	if data, err = json.Marshal(meta); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToMarshalMetadata)
+	}
+	if err := n.provisioner.replaceFileInVolume(data, volID, metadataFilename); err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToWriteMetadata)
+	}
+	if secretChanged {
+		if err := n.releaseSecretFinalizer(ctx, meta, oldSecret, oldProtected); err != nil {
+			klog.ErrorS(err, "failed to release the previous minted secret of volume", "volumeID", volID, "secret", oldSecret)
+		}
+	}
+	n.accounting.add(volID, meta.materialized())
+	n.scheduleRefresh(volID, *meta.Rotation, meta.Expiration)
+
+	result = "rewritten"
+	klog.InfoS("rewrote volume files from its current minted secret", "volumeID", volID, "secret", meta.Secret)
+	util.EmitNormalEvent(n.cosiClient.Recorder(), plan.pod, util.CredentialsRefreshed)
+	return nil
+}
+
+// volumeLocks serializes the calls changing the files of a volume, so a
+// refresh never writes into a volume being published or unpublished.
+//
+// A nil *volumeLocks never blocks.
+type volumeLocks struct {
+	mu    sync.Mutex
+	locks map[string]*volumeLock
+}
+
+type volumeLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newVolumeLocks() *volumeLocks {
+	return &volumeLocks{locks: map[string]*volumeLock{}}
+}
+
+// lock locks volID and returns the function unlocking it.
+func (l *volumeLocks) lock(volID string) func() {
+	if l == nil {
+		return func() {}
+	}
+	l.mu.Lock()
+	vl, ok := l.locks[volID]
+	if !ok {
+		vl = &volumeLock{}
+		l.locks[volID] = vl
+	}
+	vl.refs++
+	l.mu.Unlock()
+
+	vl.Lock()
+	return func() {
+		vl.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if vl.refs--; vl.refs == 0 {
+			delete(l.locks, volID)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/rotation"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestRefreshVolume(t *testing.T) {
+	type want struct {
+		// creds is the credentials file in the volume, empty if not written.
+		creds string
+		files int
+	}
+
+	ba := testutils.GetBA()
+	volCtx := map[string]string{
+		client.BarNameKey:      testutils.GetBAR().Name,
+		client.PodNameKey:      testutils.GetPod().Name,
+		client.PodNamespaceKey: testutils.GetPod().Namespace,
+	}
+	published := func(mod func(m *Metadata)) *Metadata {
+		m := &Metadata{BaName: ba.Name, Secret: "ns/old", VolumeContext: volCtx, Files: map[string]string{"credentials.json": "stale"}}
+		if mod != nil {
+			mod(m)
+		}
+		return m
+	}
+
+	cases := map[string]struct {
+		meta *Metadata
+		want
+	}{
+		"SecretChanged": {
+			meta: published(nil),
+			want: want{creds: `{"credentials":"rotated"}`, files: 2},
+		},
+		"Unpublished": {},
+		"RotationDisabled": {
+			meta: published(func(m *Metadata) { m.Rotation = &rotation.Policy{Mode: rotation.ModeDisabled} }),
+			want: want{files: 1},
+		},
+		"ExecDelivery": {
+			meta: published(func(m *Metadata) { m.CredentialDelivery = adapter.CredentialDeliveryExec }),
+			want: want{files: 1},
+		},
+		"PublishedBeforeVolumeContext": {
+			meta: published(func(m *Metadata) { m.VolumeContext = nil }),
+			want: want{files: 1},
+		},
+		"OtherBucketAccess": {
+			meta: published(func(m *Metadata) { m.BaName = "other" }),
+			want: want{files: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dataRoot := t.TempDir()
+			volID := "vol-1"
+			n := &NodeServer{
+				provisioner: NewProvisioner(dataRoot, mount.NewFakeMounter(nil), client.NewProvisionerClient()),
+				accounting:  newAccounting(),
+				failures:    newFailureTracker(0),
+				shedder:     newLoadShedder(0),
+				cosiClient: &fake.FakeNodeClient{
+					MockGetResources: func(ctx context.Context, barName, podName, podNs string) (*v1alpha1.Bucket, *v1alpha1.BucketAccess, *v1.Secret, *v1.Pod, error) {
+						secret := testutils.GetSecret()
+						secret.Data = map[string][]byte{"credentials": []byte("rotated")}
+						return testutils.GetB(), testutils.GetBA(), secret, testutils.GetPod(), nil
+					},
+				},
+			}
+			if tc.meta != nil {
+				if err := os.MkdirAll(n.provisioner.bucketPath(volID), 0750); err != nil {
+					t.Fatal(err)
+				}
+				data, err := json.Marshal(tc.meta)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(dataRoot, volID, metadataFilename), data, 0640); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := n.refreshVolume(context.Background(), volID); err != nil {
+				t.Fatal(err)
+			}
+
+			got := want{}
+			if data, err := ioutil.ReadFile(filepath.Join(n.provisioner.bucketPath(volID), credsFileName)); err == nil {
+				got.creds = string(data)
+			}
+			if data, err := ioutil.ReadFile(filepath.Join(dataRoot, volID, metadataFilename)); err == nil {
+				meta := Metadata{}
+				if err := json.Unmarshal(data, &meta); err != nil {
+					t.Fatal(err)
+				}
+				got.files = len(meta.Files)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRenewals(t *testing.T) {
+	enqueued := make(chan string, 2)
+	r := newRenewals(func(volID string) { enqueued <- volID })
+
+	r.schedule("vol-1", time.Millisecond)
+	r.schedule("vol-2", 20*time.Millisecond)
+	r.cancel("vol-2")
+
+	select {
+	case volID := <-enqueued:
+		if diff := cmp.Diff("vol-1", volID); diff != "" {
+			t.Errorf("r: -want, +got:\n%s", diff)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("vol-1 was not refreshed")
+	}
+	select {
+	case volID := <-enqueued:
+		t.Errorf("%s was refreshed after its renewal was cancelled", volID)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,94 @@
+package node
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/rotation"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// renewals keeps one timer per volume refreshing it once its refresh interval
+// passed, which for temporary credentials is shortly before they expire.
+//
+// A nil *renewals schedules nothing.
+type renewals struct {
+	enqueue func(volID string)
+
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+func newRenewals(enqueue func(volID string)) *renewals {
+	return &renewals{enqueue: enqueue, timers: map[string]*time.Timer{}}
+}
+
+// schedule refreshes volID after d, replacing the refresh scheduled before.
+func (r *renewals) schedule(volID string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.timers[volID]; ok {
+		t.Stop()
+	}
+	r.timers[volID] = time.AfterFunc(d, func() {
+		r.mu.Lock()
+		delete(r.timers, volID)
+		r.mu.Unlock()
+		r.enqueue(volID)
+	})
+}
+
+// cancel drops the refresh scheduled for volID.
+func (r *renewals) cancel(volID string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.timers[volID]; ok {
+		t.Stop()
+		delete(r.timers, volID)
+	}
+}
+
+// scheduleRefresh arms the refresh of volID after the refresh interval of
+// policy, brought forward for credentials expiring at expiry sooner.
+func (n *NodeServer) scheduleRefresh(volID string, policy rotation.Policy, expiry *metav1.Time) {
+	if expiry != nil {
+		policy = padRefresh(policy, expiry.Time, time.Now(), n.skew.current())
+	}
+	if interval, ok := policy.Refreshes(); ok {
+		klog.V(4).InfoS("scheduled volume refresh", "volumeID", volID, "after", interval)
+		n.renewals.schedule(volID, interval)
+	}
+}
+
+// checkRenewed warns when the credentials of a volume refreshed for their
+// expiry still expire before the next refresh could replace them: the
+// provisioner did not renew the minted secret in time.
+func (n *NodeServer) checkRenewed(ctx context.Context, volID string, meta Metadata, expiry time.Time) {
+	left := time.Until(expiry)
+	if left > expiryMargin+abs(n.skew.current()) {
+		return
+	}
+	klog.InfoS("temporary credentials were not renewed before their expiry", "volumeID", volID, "secret", meta.Secret, "expiry", expiry)
+	n.renewalFailed(ctx, meta)
+}
+
+// renewalFailed emits a warning on the pod of a volume whose credentials
+// could not be renewed.
+func (n *NodeServer) renewalFailed(ctx context.Context, meta Metadata) {
+	pod, err := n.cosiClient.GetPod(ctx, meta.PodName, meta.PodNamespace)
+	if err != nil {
+		klog.V(4).InfoS("not reporting failed renewal of missing pod", "pod", meta.PodNamespace+"/"+meta.PodName, "err", err)
+		return
+	}
+	util.EmitWarningEvent(n.cosiClient.Recorder(), pod, util.CredentialRenewalFailed)
+}
@@ -0,0 +1,142 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// ReleasedAnnotation is set on a BucketAccess when a pod stops using its
+	// credentials, so provisioners minting per-pod credentials can revoke them.
+	// Each pod gets a key of its own, ReleasedAnnotation.<pod UID>, so the
+	// releases of pods sharing a BucketAccess do not overwrite each other.
+	ReleasedAnnotation = "cosi.objectstorage.k8s.io/released"
+
+	defaultRevocationTimeout = 5 * time.Second
+)
+
+// RevocationConfig configures how the adapter signals that a pod's use of a
+// BucketAccess has ended. Both mechanisms are optional and may be combined.
+type RevocationConfig struct {
+	// WebhookURL receives a POST with a ReleaseEvent body on unpublish.
+	WebhookURL string
+	// Annotate sets the released annotation of the pod on the BucketAccess
+	// on unpublish.
+	Annotate bool
+	Timeout  time.Duration
+}
+
+func (c RevocationConfig) enabled() bool {
+	return c.WebhookURL != "" || c.Annotate
+}
+
+// ReleaseEvent describes a single pod releasing the credentials of a BucketAccess.
+type ReleaseEvent struct {
+	BucketAccess string    `json:"bucketAccess"`
+	PodName      string    `json:"podName"`
+	PodNamespace string    `json:"podNamespace"`
+	PodUID       string    `json:"podUID"`
+	NodeID       string    `json:"nodeID"`
+	VolumeID     string    `json:"volumeID"`
+	ReleasedAt   time.Time `json:"releasedAt"`
+}
+
+type revoker struct {
+	config     RevocationConfig
+	httpClient *http.Client
+}
+
+func newRevoker(config RevocationConfig) *revoker {
+	if !config.enabled() {
+		return nil
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultRevocationTimeout
+	}
+	return &revoker{
+		config:     config,
+		httpClient: &http.Client{Timeout: config.Timeout},
+	}
+}
+
+// release signals the end of pod's use of ba. It returns the BucketAccess as
+// stored after any annotation update so callers can keep modifying it without
+// a conflict. Failures are reported as events and never fail the unpublish.
+func (r *revoker) release(ctx context.Context, nc client.NodeClient, ba *v1alpha1.BucketAccess, pod *v1.Pod, event ReleaseEvent) *v1alpha1.BucketAccess {
+	if r == nil {
+		return ba
+	}
+
+	if r.config.WebhookURL != "" {
+		if err := r.callWebhook(ctx, event); err != nil {
+			klog.ErrorS(err, "revocation webhook failed", "bucketAccess", ba.Name, "pod", klog.KObj(pod))
+			util.EmitWarningEvent(nc.Recorder(), pod, util.RevocationFailed)
+		}
+	}
+
+	if r.config.Annotate {
+		data, err := json.Marshal(event)
+		if err != nil {
+			klog.ErrorS(err, "failed to marshal release event", "bucketAccess", ba.Name)
+			return ba
+		}
+		updated, err := nc.AddBAAnnotation(ctx, ba, releasedAnnotation(event.PodUID), string(data))
+		if err != nil {
+			klog.ErrorS(err, "failed to annotate bucketAccess with release", "bucketAccess", ba.Name, "pod", klog.KObj(pod))
+			util.EmitWarningEvent(nc.Recorder(), pod, util.RevocationFailed)
+			return ba
+		}
+		return updated
+	}
+	return ba
+}
+
+// releasedAnnotation returns the key of the release of the pod with podUID.
+// Volumes of deleted pods published before the pod UID was recorded fall back
+// to the shared key.
+func releasedAnnotation(podUID string) string {
+	if podUID == "" {
+		return ReleasedAnnotation
+	}
+	return ReleasedAnnotation + "." + podUID
+}
+
+func (r *revoker) callWebhook(ctx context.Context, event ReleaseEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorRevocationWebhookFailed)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorRevocationWebhookFailed)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf(util.ErrorTemplateRevocationWebhookStatus, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReleasedAnnotation(t *testing.T) {
+	cases := map[string]struct {
+		podUID string
+		want   string
+	}{
+		"PodUID": {
+			podUID: "0c5a9f5e-2b1d-4f7e-9a7b-3c1f2d4e5f60",
+			want:   "cosi.objectstorage.k8s.io/released.0c5a9f5e-2b1d-4f7e-9a7b-3c1f2d4e5f60",
+		},
+		"UnknownPodUID": {
+			want: ReleasedAnnotation,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, releasedAnnotation(tc.podUID)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
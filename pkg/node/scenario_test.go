@@ -0,0 +1,91 @@
+package node
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/testutil"
+)
+
+// TestScenarios publishes a volume for every scenario in testdata/scenarios,
+// against fake clientsets seeded with its objects. See testutil.Scenario for
+// the file format.
+func TestScenarios(t *testing.T) {
+	scenarios, err := testutil.LoadScenarios(filepath.Join("testdata", "scenarios"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range scenarios {
+		s := s
+		t.Run(s.Name, func(t *testing.T) {
+			g, err := s.Graph()
+			if err != nil {
+				t.Fatal(err)
+			}
+			nc, kube, cosi := testutil.FakeNodeClient(g)
+			testutil.ServeMetadataApplies(kube.(testutil.FakeClientset))
+			testutil.ServeMetadataApplies(cosi)
+
+			var mu sync.Mutex
+			written := map[string]string{}
+			ns := &NodeServer{
+				name:   name,
+				nodeID: nodeId,
+				provisioner: getTestProvisioner(&fake.MockProvisionerClient{
+					MockMkdirAll:     func(path string, perm os.FileMode) error { return nil },
+					MockWriteFile:    func(data []byte, filepath string) error { return nil },
+					MockSetOwnership: func(path string, mode os.FileMode, gid int) error { return nil },
+					MockRemoveAll:    func(path string) error { return nil },
+					MockWriteAtomic: func(dir string, files []client.AtomicFile, gid int) error {
+						mu.Lock()
+						defer mu.Unlock()
+						for _, f := range files {
+							written[f.Path] = string(f.Data)
+						}
+						return nil
+					},
+				}),
+				cosiClient:  nc,
+				volumeLimit: volLimit,
+			}
+
+			_, err = ns.NodePublishVolume(ctx, &csi.NodePublishVolumeRequest{
+				VolumeContext: s.Attributes(g),
+				VolumeId:      provVolumeId,
+				TargetPath:    provTargetPath,
+			})
+
+			code := s.Expect.Code
+			if code == "" {
+				code = codes.OK.String()
+			}
+			if diff := cmp.Diff(code, status.Code(err).String()); diff != "" {
+				t.Errorf("%s\ncode: -want, +got:\n%s\nerror: %v", s.Description, diff, err)
+			}
+			if s.Expect.Error != "" && (err == nil || !strings.Contains(err.Error(), s.Expect.Error)) {
+				t.Errorf("%s\nerror %v does not contain %q", s.Description, err, s.Expect.Error)
+			}
+			for file, want := range s.Expect.Files {
+				got, ok := written[file]
+				if !ok {
+					t.Errorf("%s\nfile %q was not written", s.Description, file)
+					continue
+				}
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("%s\n%s: -want, +got:\n%s", s.Description, file, diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// ServiceAccountCheck selects what publish does when the pod does not run as
+// the service account its BucketAccessRequest was made for.
+type ServiceAccountCheck string
+
+const (
+	// ServiceAccountCheckOff skips the check.
+	ServiceAccountCheckOff ServiceAccountCheck = "off"
+	// ServiceAccountCheckWarn publishes the volume, logging the mismatch and
+	// emitting a warning event on the pod.
+	ServiceAccountCheckWarn ServiceAccountCheck = "warn"
+	// ServiceAccountCheckEnforce fails the publish.
+	ServiceAccountCheckEnforce ServiceAccountCheck = "enforce"
+)
+
+// defaultServiceAccount runs pods that name no service account.
+const defaultServiceAccount = "default"
+
+// ParseServiceAccountCheck validates the name of a ServiceAccountCheck.
+func ParseServiceAccountCheck(s string) (ServiceAccountCheck, error) {
+	switch c := ServiceAccountCheck(s); c {
+	case ServiceAccountCheckOff, ServiceAccountCheckWarn, ServiceAccountCheckEnforce:
+		return c, nil
+	}
+	return "", fmt.Errorf(util.ErrorTemplateUnknownServiceAccountCheck, s)
+}
+
+// checkServiceAccount verifies the pod of p runs as the service account its
+// BucketAccessRequest names, so referencing the name of a BucketAccessRequest
+// in a volume is not enough to be handed its credentials. A request naming no
+// service account is usable by any pod of its namespace.
+func (n *NodeServer) checkServiceAccount(ctx context.Context, p *publishPlan, dryRun bool) error {
+	if n.serviceAccountCheck == "" || n.serviceAccountCheck == ServiceAccountCheckOff {
+		return nil
+	}
+	bar, err := n.cosiClient.GetBAR(ctx, p.pod, p.barName, p.podNs)
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	want := bar.Spec.ServiceAccountName
+	got := p.pod.Spec.ServiceAccountName
+	if got == "" {
+		got = defaultServiceAccount
+	}
+	if want == "" || want == got {
+		return nil
+	}
+
+	err = fmt.Errorf(util.ErrorTemplateServiceAccountMismatch, got, p.podNs, p.barName, want)
+	klog.InfoS("pod does not run as the service account of its bucketAccessRequest", "pod", p.podNs+"/"+p.podName,
+		"serviceAccount", got, "bucketAccessRequest", p.barName, "want", want, "check", n.serviceAccountCheck)
+	if !dryRun {
+		util.EmitWarningEvent(n.cosiClient.Recorder(), p.pod, util.ServiceAccountMismatch)
+	}
+	if n.serviceAccountCheck == ServiceAccountCheckEnforce {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestCheckServiceAccount(t *testing.T) {
+	mismatch := fmt.Errorf(util.ErrorTemplateServiceAccountMismatch, "intruder", testutils.Namespace, "bar", "app")
+
+	cases := map[string]struct {
+		check      ServiceAccountCheck
+		barAccount string
+		podAccount string
+		want       error
+	}{
+		"Off": {
+			check:      ServiceAccountCheckOff,
+			barAccount: "app",
+			podAccount: "intruder",
+		},
+		"Match": {
+			check:      ServiceAccountCheckEnforce,
+			barAccount: "app",
+			podAccount: "app",
+		},
+		"DefaultServiceAccount": {
+			check:      ServiceAccountCheckEnforce,
+			barAccount: defaultServiceAccount,
+		},
+		"UnnamedServiceAccount": {
+			check:      ServiceAccountCheckEnforce,
+			podAccount: "intruder",
+		},
+		"MismatchWarned": {
+			check:      ServiceAccountCheckWarn,
+			barAccount: "app",
+			podAccount: "intruder",
+		},
+		"MismatchEnforced": {
+			check:      ServiceAccountCheckEnforce,
+			barAccount: "app",
+			podAccount: "intruder",
+			want:       status.Error(codes.PermissionDenied, mismatch.Error()),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			n := &NodeServer{
+				serviceAccountCheck: tc.check,
+				cosiClient: &fake.FakeNodeClient{
+					MockGetBAR: func(ctx context.Context, pod *v1.Pod, barName, barNs string) (*v1alpha1.BucketAccessRequest, error) {
+						bar := testutils.GetBAR()
+						bar.Spec.ServiceAccountName = tc.barAccount
+						return bar, nil
+					},
+				},
+			}
+			pod := testutils.GetPod()
+			pod.Spec.ServiceAccountName = tc.podAccount
+			p := &publishPlan{barName: "bar", podName: pod.Name, podNs: testutils.Namespace, pod: pod}
+
+			err := n.checkServiceAccount(context.Background(), p, true)
+			if diff := cmp.Diff(tc.want, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/metrics"
+)
+
+// latencyWeight is the weight of the newest sample in the moving average. At
+// 0.2 a sustained brownout trips the threshold within a handful of calls while
+// a single slow request does not.
+const latencyWeight = 0.2
+
+// loadShedder tracks an exponentially weighted moving average of API server
+// latency and reports degraded mode while it is above threshold. Work that is
+// not needed to publish or unpublish a volume, such as diagnostic snapshots,
+// checks shed() and is skipped while degraded.
+//
+// Degraded mode is left once the average falls below half the threshold, so
+// latency hovering around the threshold does not flap the mode.
+type loadShedder struct {
+	threshold time.Duration
+
+	mu       sync.Mutex
+	average  time.Duration
+	degraded bool
+}
+
+// newLoadShedder returns nil, which never sheds, if threshold is zero.
+func newLoadShedder(threshold time.Duration) *loadShedder {
+	if threshold <= 0 {
+		return nil
+	}
+	return &loadShedder{threshold: threshold}
+}
+
+// observe records the latency of an API call. It returns true when the call
+// moved the shedder into degraded mode.
+func (l *loadShedder) observe(latency time.Duration) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.average == 0 {
+		l.average = latency
+	} else {
+		l.average = time.Duration(latencyWeight*float64(latency) + (1-latencyWeight)*float64(l.average))
+	}
+
+	switch {
+	case !l.degraded && l.average > l.threshold:
+		l.degraded = true
+		metrics.DegradedMode.Set(1)
+		klog.InfoS("API server latency above threshold, shedding optional work", "averageLatency", l.average, "threshold", l.threshold)
+		return true
+	case l.degraded && l.average < l.threshold/2:
+		l.degraded = false
+		metrics.DegradedMode.Set(0)
+		klog.InfoS("API server latency recovered, resuming optional work", "averageLatency", l.average)
+	}
+	return false
+}
+
+// shed reports whether optional work named kind should be skipped.
+func (l *loadShedder) shed(kind string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.degraded {
+		metrics.ShedWork.WithLabelValues(kind).Inc()
+	}
+	return l.degraded
+}
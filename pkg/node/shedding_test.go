@@ -0,0 +1,80 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadShedder(t *testing.T) {
+	type want struct {
+		entered []bool
+		shed    bool
+	}
+
+	cases := map[string]struct {
+		threshold time.Duration
+		latencies []time.Duration
+		want
+	}{
+		"Disabled": {
+			threshold: 0,
+			latencies: []time.Duration{time.Minute},
+			want: want{
+				entered: []bool{false},
+				shed:    false,
+			},
+		},
+		"SingleSlowCall": {
+			threshold: time.Second,
+			latencies: []time.Duration{100 * time.Millisecond, 3 * time.Second},
+			want: want{
+				entered: []bool{false, false},
+				shed:    false,
+			},
+		},
+		"SustainedBrownout": {
+			threshold: time.Second,
+			latencies: []time.Duration{100 * time.Millisecond, 3 * time.Second, 3 * time.Second, 3 * time.Second, 3 * time.Second},
+			want: want{
+				entered: []bool{false, false, true, false, false},
+				shed:    true,
+			},
+		},
+		"Hysteresis": {
+			threshold: time.Second,
+			latencies: []time.Duration{2 * time.Second, 800 * time.Millisecond},
+			want: want{
+				entered: []bool{true, false},
+				shed:    true,
+			},
+		},
+		"Recovered": {
+			threshold: time.Second,
+			latencies: []time.Duration{2 * time.Second, 0, 0, 0, 0, 0, 0, 0},
+			want: want{
+				entered: []bool{true, false, false, false, false, false, false, false},
+				shed:    false,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := newLoadShedder(tc.threshold)
+
+			var entered []bool
+			for _, latency := range tc.latencies {
+				entered = append(entered, l.observe(latency))
+			}
+
+			if diff := cmp.Diff(tc.want.entered, entered); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.shed, l.shed("test")); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
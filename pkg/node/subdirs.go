@@ -0,0 +1,45 @@
+package node
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// SubdirsKey lists additional directories, relative to the volume root, that
+// receive a copy of every projected file. Containers of the same pod that expect
+// credentials at different conventional locations can then each mount the volume
+// with their own subPath (e.g. "cosi" and "etc/bucket").
+//
+// The files are copied rather than bind mounted: kubelet binds the target path
+// into containers without recursion, so nested mounts below it would appear
+// empty inside the pod.
+const SubdirsKey = adapter.SubdirsKey
+
+// parseSubdirs returns the cleaned subdirectories requested by the volume
+// context. Leading slashes are accepted and treated as relative to the volume
+// root; paths escaping the volume are rejected.
+func parseSubdirs(volCtx map[string]string) ([]string, error) {
+	value, ok := volCtx[SubdirsKey]
+	if !ok || strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	seen := map[string]bool{}
+	var subdirs []string
+	for _, raw := range strings.Split(value, ",") {
+		dir := filepath.Clean(strings.TrimLeft(strings.TrimSpace(raw), "/"))
+		if dir == "." || dir == ".." || strings.HasPrefix(dir, "../") {
+			return nil, fmt.Errorf(util.ErrorTemplateInvalidSubdir, raw)
+		}
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		subdirs = append(subdirs, dir)
+	}
+	return subdirs, nil
+}
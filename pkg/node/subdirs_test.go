@@ -0,0 +1,65 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestParseSubdirs(t *testing.T) {
+	type want struct {
+		subdirs []string
+		err     error
+	}
+
+	cases := map[string]struct {
+		value string
+		want
+	}{
+		"Unset": {
+			value: "",
+			want:  want{},
+		},
+		"AbsoluteAndRelative": {
+			value: "/var/run/secrets/cosi, etc/bucket",
+			want: want{
+				subdirs: []string{"var/run/secrets/cosi", "etc/bucket"},
+			},
+		},
+		"Duplicates": {
+			value: "cosi,/cosi/",
+			want: want{
+				subdirs: []string{"cosi"},
+			},
+		},
+		"Traversal": {
+			value: "cosi,../../etc",
+			want: want{
+				err: fmt.Errorf(util.ErrorTemplateInvalidSubdir, "../../etc"),
+			},
+		},
+		"VolumeRoot": {
+			value: "/",
+			want: want{
+				err: fmt.Errorf(util.ErrorTemplateInvalidSubdir, "/"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			subdirs, err := parseSubdirs(map[string]string{SubdirsKey: tc.value})
+
+			if diff := cmp.Diff(tc.want.subdirs, subdirs); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// DefaultKubeletDir is the root directory of kubelet. The target path of every
+// volume kubelet publishes is below its pods directory.
+const DefaultKubeletDir = "/var/lib/kubelet"
+
+// targetPaths confines publishes to target paths below the pods directory of
+// kubelet, so a crafted request cannot have credentials written or mounted
+// elsewhere on the node. The canary, which kubelet does not publish, keeps its
+// own target path.
+type targetPaths struct {
+	podsDir string
+	canary  string
+}
+
+// newTargetPaths returns nil, which accepts any target path, if kubeletDir is
+// empty.
+func newTargetPaths(kubeletDir string) *targetPaths {
+	if kubeletDir == "" {
+		return nil
+	}
+	return &targetPaths{podsDir: filepath.Join(filepath.Clean(kubeletDir), "pods")}
+}
+
+// check returns an error unless target is a clean absolute path below the pods
+// directory that stays below it once the symlinks of its existing part are
+// resolved.
+func (t *targetPaths) check(target string) error {
+	if t == nil || (t.canary != "" && target == t.canary) {
+		return nil
+	}
+	if !filepath.IsAbs(target) || filepath.Clean(target) != target || !below(t.podsDir, target) {
+		return fmt.Errorf(util.ErrorTemplateTargetPathOutsideKubelet, target, t.podsDir)
+	}
+	podsDir, err := filepath.EvalSymlinks(t.podsDir)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToResolveTargetPath)
+	}
+	resolved, err := resolveExisting(target)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorFailedToResolveTargetPath)
+	}
+	if !below(podsDir, resolved) {
+		return fmt.Errorf(util.ErrorTemplateTargetPathEscapes, target, resolved, t.podsDir)
+	}
+	return nil
+}
+
+// resolveExisting resolves the symlinks of the longest existing prefix of path
+// and appends the rest, which holds no symlinks as it does not exist yet. A
+// dangling symlink exists, so it fails to resolve rather than being skipped.
+func resolveExisting(path string) (string, error) {
+	var missing []string
+	for p := path; ; p = filepath.Dir(p) {
+		_, err := os.Lstat(p)
+		if err == nil {
+			resolved, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return "", err
+			}
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) || p == filepath.Dir(p) {
+			return "", err
+		}
+		missing = append([]string{filepath.Base(p)}, missing...)
+	}
+}
+
+// below reports whether path is strictly below dir. Both must be clean.
+func below(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// validVolumeID reports whether id names a single directory below the data
+// path, as volume IDs are joined to it.
+func validVolumeID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.Contains(id, "/")
+}
@@ -0,0 +1,116 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestTargetPathsCheck(t *testing.T) {
+	root := t.TempDir()
+	kubeletDir := filepath.Join(root, "kubelet")
+	podsDir := filepath.Join(kubeletDir, "pods")
+	volumes := filepath.Join(podsDir, "uid", "volumes", "kubernetes.io~csi")
+	outside := filepath.Join(root, "etc")
+	for _, dir := range []string{volumes, outside} {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(volumes, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "missing"), filepath.Join(volumes, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+	// The temporary directory may itself be behind a symlink.
+	resolvedOutside, err := filepath.EvalSymlinks(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canary := filepath.Join(root, "data", canaryTargetName)
+
+	cases := map[string]struct {
+		kubeletDir string
+		target     string
+		want       error
+	}{
+		"Disabled": {
+			target: "/etc",
+		},
+		"BelowPods": {
+			kubeletDir: kubeletDir,
+			target:     filepath.Join(volumes, "pvc", "mount"),
+		},
+		"Canary": {
+			kubeletDir: kubeletDir,
+			target:     canary,
+		},
+		"Outside": {
+			kubeletDir: kubeletDir,
+			target:     outside,
+			want:       fmt.Errorf(util.ErrorTemplateTargetPathOutsideKubelet, outside, podsDir),
+		},
+		"PodsDir": {
+			kubeletDir: kubeletDir,
+			target:     podsDir,
+			want:       fmt.Errorf(util.ErrorTemplateTargetPathOutsideKubelet, podsDir, podsDir),
+		},
+		"Relative": {
+			kubeletDir: kubeletDir,
+			target:     "pods/uid/volumes",
+			want:       fmt.Errorf(util.ErrorTemplateTargetPathOutsideKubelet, "pods/uid/volumes", podsDir),
+		},
+		"Traversal": {
+			kubeletDir: kubeletDir,
+			target:     volumes + "/../../../../etc",
+			want:       fmt.Errorf(util.ErrorTemplateTargetPathOutsideKubelet, volumes+"/../../../../etc", podsDir),
+		},
+		"SymlinkEscape": {
+			kubeletDir: kubeletDir,
+			target:     filepath.Join(volumes, "escape", "mount"),
+			want:       fmt.Errorf(util.ErrorTemplateTargetPathEscapes, filepath.Join(volumes, "escape", "mount"), filepath.Join(resolvedOutside, "mount"), podsDir),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			paths := newTargetPaths(tc.kubeletDir)
+			if paths != nil {
+				paths.canary = canary
+			}
+			err := paths.check(tc.target)
+			if diff := cmp.Diff(tc.want, err, util.EquateErrors()); diff != "" {
+				t.Errorf("check(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("DanglingSymlink", func(t *testing.T) {
+		if err := newTargetPaths(kubeletDir).check(filepath.Join(volumes, "dangling", "mount")); err == nil {
+			t.Error("check(...): want error for a dangling symlink, got nil")
+		}
+	})
+}
+
+func TestValidVolumeID(t *testing.T) {
+	cases := map[string]bool{
+		"csi-0123456789abcdef": true,
+		canaryVolumeID:         true,
+		"":                     false,
+		".":                    false,
+		"..":                   false,
+		"../etc":               false,
+		"a/b":                  false,
+	}
+	for id, want := range cases {
+		if got := validVolumeID(id); got != want {
+			t.Errorf("validVolumeID(%q): want %t, got %t", id, want, got)
+		}
+	}
+}
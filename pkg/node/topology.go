@@ -0,0 +1,40 @@
+package node
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// Node labels reported as the node's accessible topology. No bucket access is
+// topology aware yet; reporting the segments now lets kubelet record them in
+// the CSINode object so provisioners can start relying on them.
+const (
+	TopologyZoneKey   = v1.LabelTopologyZone
+	TopologyRegionKey = v1.LabelTopologyRegion
+)
+
+// legacyTopologyKeys maps the deprecated failure-domain labels, still set by
+// older cloud providers, to the labels that replaced them.
+var legacyTopologyKeys = map[string]string{
+	v1.LabelFailureDomainBetaZone:   TopologyZoneKey,
+	v1.LabelFailureDomainBetaRegion: TopologyRegionKey,
+}
+
+// topologySegments returns the zone and region of node, keyed by the current
+// topology labels. Nodes without either label have no segments.
+func topologySegments(node *v1.Node) map[string]string {
+	segments := map[string]string{}
+	for legacy, key := range legacyTopologyKeys {
+		if v, ok := node.Labels[legacy]; ok {
+			segments[key] = v
+		}
+	}
+	for _, key := range []string{TopologyZoneKey, TopologyRegionKey} {
+		if v, ok := node.Labels[key]; ok {
+			segments[key] = v
+		}
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+	return segments
+}
@@ -0,0 +1,98 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestNodeGetInfo(t *testing.T) {
+	type want struct {
+		response *csi.NodeGetInfoResponse
+		err      error
+	}
+
+	nodeWithLabels := func(labels map[string]string) func(ctx context.Context, nodeName string) (*v1.Node, error) {
+		return func(ctx context.Context, nodeName string) (*v1.Node, error) {
+			return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName, Labels: labels}}, nil
+		}
+	}
+
+	cases := map[string]struct {
+		getNode func(ctx context.Context, nodeName string) (*v1.Node, error)
+		want
+	}{
+		"NoTopology": {
+			getNode: nodeWithLabels(nil),
+			want: want{
+				response: &csi.NodeGetInfoResponse{NodeId: nodeId, MaxVolumesPerNode: volLimit},
+			},
+		},
+		"ZoneAndRegion": {
+			getNode: nodeWithLabels(map[string]string{
+				v1.LabelTopologyZone:   "us-east-1a",
+				v1.LabelTopologyRegion: "us-east-1",
+				"unrelated":            "label",
+			}),
+			want: want{
+				response: &csi.NodeGetInfoResponse{
+					NodeId:            nodeId,
+					MaxVolumesPerNode: volLimit,
+					AccessibleTopology: &csi.Topology{Segments: map[string]string{
+						TopologyZoneKey:   "us-east-1a",
+						TopologyRegionKey: "us-east-1",
+					}},
+				},
+			},
+		},
+		"LegacyLabels": {
+			getNode: nodeWithLabels(map[string]string{
+				v1.LabelFailureDomainBetaZone: "old-zone",
+				v1.LabelTopologyZone:          "new-zone",
+			}),
+			want: want{
+				response: &csi.NodeGetInfoResponse{
+					NodeId:             nodeId,
+					MaxVolumesPerNode:  volLimit,
+					AccessibleTopology: &csi.Topology{Segments: map[string]string{TopologyZoneKey: "new-zone"}},
+				},
+			},
+		},
+		"GetNodeFailed": {
+			getNode: func(ctx context.Context, nodeName string) (*v1.Node, error) {
+				return nil, errBoom
+			},
+			want: want{
+				err: genRPCError(codes.Unavailable, errors.Wrap(errBoom, util.WrapErrorGetNodeFailed)),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ns := &NodeServer{
+				nodeID:      nodeId,
+				volumeLimit: volLimit,
+				cosiClient:  &fake.FakeNodeClient{MockGetNode: tc.getNode},
+			}
+
+			response, err := ns.NodeGetInfo(context.Background(), &csi.NodeGetInfoRequest{})
+
+			if diff := cmp.Diff(tc.want.response, response); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
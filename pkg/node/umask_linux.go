@@ -0,0 +1,12 @@
+//go:build linux
+// +build linux
+
+package node
+
+import "syscall"
+
+// HardenUmask sets a restrictive umask for the whole process, so no file or
+// directory the adapter creates is accessible to other users.
+func HardenUmask() {
+	syscall.Umask(hardenedUmask)
+}
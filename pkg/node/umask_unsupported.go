@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package node
+
+// HardenUmask is a no-op where the adapter does not publish volumes; the
+// permission verification after publish still applies.
+func HardenUmask() {}
@@ -0,0 +1,131 @@
+package node
+
+import (
+	"context"
+
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+)
+
+// ValidationServiceName is the gRPC service serving ValidateVolumeContext next
+// to the CSI services. It has no .proto of its own: requests and responses are
+// google.protobuf.Struct messages, so any gRPC client can call it.
+const ValidationServiceName = "objectstorage.k8s.io.csi.Validation"
+
+// ValidationServer validates volume contexts with the resolution NodePublishVolume
+// runs, without writing anything to the node or the cluster.
+type ValidationServer struct {
+	n *NodeServer
+}
+
+// NewValidationServer returns a ValidationServer resolving volumes through n.
+func NewValidationServer(n *NodeServer) *ValidationServer {
+	return &ValidationServer{n: n}
+}
+
+// RegisterValidationServer registers v on s under ValidationServiceName.
+func RegisterValidationServer(s *grpc.Server, v *ValidationServer) {
+	s.RegisterService(&validationServiceDesc, v)
+}
+
+// ValidateVolumeContext takes the volume attributes kubelet would pass to
+// NodePublishVolume as the string fields of req. It fails with the status
+// NodePublishVolume would fail with, and otherwise returns the resolved
+// bucket, bucketAccess and formats and the files that would be projected.
+// Pod scoped credentials are validated against the shared BucketAccess, as
+// the pod's own is only created on publish.
+func (v *ValidationServer) ValidateVolumeContext(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	volCtx := make(map[string]string, len(req.GetFields()))
+	for k, val := range req.GetFields() {
+		s, ok := val.GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "volume attribute %q must be a string", k)
+		}
+		volCtx[k] = s.StringValue
+	}
+
+	plan, err := v.n.resolve(ctx, "", volCtx, true)
+	if err != nil {
+		return nil, err
+	}
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		"bucket":       stringValue(plan.bkt.Name),
+		"bucketAccess": stringValue(plan.ba.Name),
+		"podScoped":    {Kind: &structpb.Value_BoolValue{BoolValue: plan.podScoped}},
+		"formats":      listValue(plan.formats),
+		"files":        listValue(plan.fileNames()),
+	}}, nil
+}
+
+// fileNames returns the names of the files publishing the plan projects into
+// the root of the volume, in the order NodePublishVolume writes them.
+func (p *publishPlan) fileNames() []string {
+	files := []render.File{{Name: p.protocolFile}}
+	switch {
+	case p.exec:
+		files = append(files, render.File{Name: adapter.CredentialHelperFileName})
+	case p.projection == adapter.ProjectionKeys:
+		files = append(append(files, p.keys...), p.kerberos...)
+	default:
+		files = append(append(files, render.File{Name: p.credsFile}), p.kerberos...)
+	}
+	files = append(files, p.rendered...)
+	if p.checksum != nil {
+		files = append(files, p.checksum(files))
+	}
+
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func stringValue(s string) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: s}}
+}
+
+func listValue(ss []string) *structpb.Value {
+	values := make([]*structpb.Value, 0, len(ss))
+	for _, s := range ss {
+		values = append(values, stringValue(s))
+	}
+	return &structpb.Value{Kind: &structpb.Value_ListValue{ListValue: &structpb.ListValue{Values: values}}}
+}
+
+type validationService interface {
+	ValidateVolumeContext(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+}
+
+var validationServiceDesc = grpc.ServiceDesc{
+	ServiceName: ValidationServiceName,
+	HandlerType: (*validationService)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "ValidateVolumeContext",
+		Handler:    validateVolumeContextHandler,
+	}},
+	Streams: []grpc.StreamDesc{},
+}
+
+func validateVolumeContextHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(structpb.Struct)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(validationService).ValidateVolumeContext(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + ValidationServiceName + "/ValidateVolumeContext",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(validationService).ValidateVolumeContext(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, req, info, handler)
+}
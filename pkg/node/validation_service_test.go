@@ -0,0 +1,125 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/testutil"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestValidateVolumeContext(t *testing.T) {
+	type want struct {
+		code  codes.Code
+		err   string
+		files []string
+	}
+
+	cases := map[string]struct {
+		attrs     map[string]string
+		class     map[string]string
+		nonString bool
+		want
+	}{
+		"Default": {
+			want: want{code: codes.OK, files: []string{protocolFileName, credsFileName}},
+		},
+		"Formats": {
+			attrs: map[string]string{render.FormatKey: render.FormatAWS},
+			want:  want{code: codes.OK, files: []string{protocolFileName, credsFileName, "aws_config", "aws_credentials"}},
+		},
+		"BucketClassFormats": {
+			class: map[string]string{render.BucketClassFormatParameter: render.FormatAWS},
+			want:  want{code: codes.OK, files: []string{protocolFileName, credsFileName, "aws_config", "aws_credentials"}},
+		},
+		"VolumeOverridesBucketClass": {
+			attrs: map[string]string{render.FormatKey: ""},
+			class: map[string]string{render.BucketClassFormatParameter: render.FormatAWS},
+			want:  want{code: codes.OK, files: []string{protocolFileName, credsFileName}},
+		},
+		"UnknownFormat": {
+			attrs: map[string]string{render.FormatKey: "toml"},
+			want: want{code: codes.InvalidArgument,
+				err: fmt.Sprintf(util.ErrorTemplateUnknownFormat, "toml", strings.Join(render.Formats(), ", "))},
+		},
+		"FileNames": {
+			attrs: map[string]string{adapter.ProtocolFileNameKey: "protocol.json", adapter.CredentialsFileNameKey: "aws.json"},
+			want:  want{code: codes.OK, files: []string{"protocol.json", "aws.json"}},
+		},
+		"FileNameTakenByFormat": {
+			attrs: map[string]string{render.FormatKey: render.FormatAWS, adapter.CredentialsFileNameKey: "aws_config"},
+			want: want{code: codes.InvalidArgument,
+				err: fmt.Sprintf(util.ErrorTemplateFileNameConflict, "aws_config", adapter.CredentialsFileNameKey)},
+		},
+		"PodScopedIsNotCreated": {
+			attrs: map[string]string{adapter.CredentialScopeKey: string(adapter.CredentialScopePod)},
+			want:  want{code: codes.OK, files: []string{protocolFileName, credsFileName}},
+		},
+		"NonStringAttribute": {
+			nonString: true,
+			want:      want{code: codes.InvalidArgument, err: `volume attribute "readonly" must be a string`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := testutil.NewGraph("ns", "app")
+			nc, _, cosi := testutil.FakeNodeClient(g)
+			v := NewValidationServer(&NodeServer{cosiClient: nc, allowPodScopedCredentials: true})
+
+			req := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+			for k, val := range g.VolumeContext() {
+				req.Fields[k] = stringValue(val)
+			}
+			for k, val := range tc.attrs {
+				req.Fields[k] = stringValue(val)
+			}
+			if tc.class != nil {
+				class := &v1alpha1.BucketClass{ObjectMeta: metav1.ObjectMeta{Name: g.Bucket.Spec.BucketClassName}, Parameters: tc.class}
+				if err := cosi.Tracker().Add(class); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.nonString {
+				req.Fields["readonly"] = &structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: true}}
+			}
+			cosi.ClearActions()
+
+			resp, err := v.ValidateVolumeContext(ctx, req)
+
+			if diff := cmp.Diff(tc.want.code, status.Code(err)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if err != nil {
+				if diff := cmp.Diff(tc.want.err, status.Convert(err).Message()); diff != "" {
+					t.Errorf("r: -want, +got:\n%s", diff)
+				}
+				return
+			}
+
+			var files []string
+			for _, f := range resp.GetFields()["files"].GetListValue().GetValues() {
+				files = append(files, f.GetStringValue())
+			}
+			if diff := cmp.Diff(tc.want.files, files); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			for _, action := range cosi.Actions() {
+				if action.GetVerb() != "get" && action.GetVerb() != "list" && action.GetVerb() != "watch" {
+					t.Errorf("validation called %s on %s", action.GetVerb(), action.GetResource().Resource)
+				}
+			}
+		})
+	}
+}
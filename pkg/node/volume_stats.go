@@ -0,0 +1,133 @@
+package node
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/render"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// NodeGetVolumeStats reports the bytes projected into the volume and, through
+// VolumeCondition, whether the volume is still usable: mounted, with its files
+// as written, and backed by a BucketAccess that still grants access.
+func (n *NodeServer) NodeGetVolumeStats(ctx context.Context, request *csi.NodeGetVolumeStatsRequest) (_ *csi.NodeGetVolumeStatsResponse, err error) {
+	defer func() {
+		err = translateError(n.translator, "NodeGetVolumeStats", err)
+	}()
+
+	if request.GetVolumeId() == "" || request.GetVolumePath() == "" {
+		return nil, status.Error(codes.InvalidArgument, util.ErrorVolumeStatsArgs.Error())
+	}
+
+	data, err := n.provisioner.readFileFromVolume(request.GetVolumeId(), metadataFilename)
+	if os.IsNotExist(err) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToReadMetadataFile).Error())
+	}
+	meta := Metadata{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, status.Error(codes.Internal, errors.Wrap(err, util.WrapErrorFailedToUnmarshalMetadata).Error())
+	}
+
+	var problems []string
+	if mounted, err := n.provisioner.isMounted(request.GetVolumePath()); err != nil || !mounted {
+		problems = append(problems, fmt.Sprintf("volume is not mounted at %s", request.GetVolumePath()))
+	}
+
+	used, drifted := n.checkFiles(request.GetVolumeId(), meta.Files)
+	problems = append(problems, drifted...)
+
+	if !n.shedder.shed("volume-stats") {
+		if revoked := n.checkAccess(ctx, meta); revoked != "" {
+			problems = append(problems, revoked)
+		}
+	}
+
+	condition := &csi.VolumeCondition{Abnormal: len(problems) > 0, Message: strings.Join(problems, "; ")}
+	if condition.Abnormal {
+		klog.V(4).InfoS("volume is abnormal", "volumeID", request.GetVolumeId(), "message", condition.Message)
+	}
+
+	return &csi.NodeGetVolumeStatsResponse{
+		Usage: []*csi.VolumeUsage{{
+			Unit: csi.VolumeUsage_BYTES,
+			Used: used,
+		}},
+		VolumeCondition: condition,
+	}, nil
+}
+
+// checkFiles compares the projected files against the digests recorded at
+// publish time and returns their total size along with any drift found.
+func (n *NodeServer) checkFiles(volID string, files map[string]string) (int64, []string) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var used int64
+	var drifted []string
+	for _, name := range names {
+		data, err := n.provisioner.readFileFromVolumeMount(volID, name)
+		if err != nil {
+			drifted = append(drifted, fmt.Sprintf("file %s is missing", name))
+			continue
+		}
+		used += int64(len(data))
+		if digest(data) != files[name] {
+			drifted = append(drifted, fmt.Sprintf("file %s was modified", name))
+		}
+	}
+	return used, drifted
+}
+
+// checkAccess reports when the volume's BucketAccess was deleted or stopped
+// granting access. Transient API errors are not reported, as they say nothing
+// about the volume.
+func (n *NodeServer) checkAccess(ctx context.Context, meta Metadata) string {
+	ba, err := n.cosiClient.LookupBA(ctx, meta.BaName)
+	switch {
+	case apierrors.IsNotFound(err):
+		return fmt.Sprintf("bucketAccess %s no longer exists", meta.BaName)
+	case err != nil:
+		klog.V(4).InfoS("failed to look up bucketAccess for volume condition", "bucketAccess", meta.BaName, "err", err)
+		return ""
+	case !ba.Status.AccessGranted:
+		return fmt.Sprintf("bucketAccess %s no longer grants access", meta.BaName)
+	}
+	return ""
+}
+
+// fileDigests returns the digest of every file as projected into each of dirs.
+func fileDigests(dirs []string, files []render.File) map[string]string {
+	digests := map[string]string{}
+	for _, dir := range dirs {
+		for _, f := range files {
+			digests[filepath.Join(dir, f.Name)] = digest(f.Data)
+		}
+	}
+	return digests
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
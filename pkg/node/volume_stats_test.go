@@ -0,0 +1,148 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client/fake"
+	testutils "sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util/test"
+)
+
+func TestNodeGetVolumeStats(t *testing.T) {
+	creds := []byte(`{"accessKeyID":"AKIAEXAMPLE"}`)
+	meta := Metadata{
+		BaName: testutils.GetBA().Name,
+		Files:  map[string]string{credsFileName: digest(creds)},
+	}
+
+	type want struct {
+		code     codes.Code
+		abnormal bool
+		message  string
+		used     int64
+	}
+
+	cases := map[string]struct {
+		metadata *Metadata
+		files    map[string][]byte
+		mounted  bool
+		ba       func() (*v1alpha1.BucketAccess, error)
+		want
+	}{
+		"Healthy": {
+			metadata: &meta,
+			files:    map[string][]byte{credsFileName: creds},
+			mounted:  true,
+			ba:       func() (*v1alpha1.BucketAccess, error) { return testutils.GetBA(), nil },
+			want: want{
+				used: int64(len(creds)),
+			},
+		},
+		"UnknownVolume": {
+			want: want{code: codes.NotFound},
+		},
+		"NotMounted": {
+			metadata: &meta,
+			files:    map[string][]byte{credsFileName: creds},
+			ba:       func() (*v1alpha1.BucketAccess, error) { return testutils.GetBA(), nil },
+			want: want{
+				abnormal: true,
+				message:  "volume is not mounted at TARGET",
+				used:     int64(len(creds)),
+			},
+		},
+		"Drifted": {
+			metadata: &meta,
+			files:    map[string][]byte{credsFileName: []byte("tampered")},
+			mounted:  true,
+			ba:       func() (*v1alpha1.BucketAccess, error) { return testutils.GetBA(), nil },
+			want: want{
+				abnormal: true,
+				message:  "file credentials was modified",
+				used:     int64(len("tampered")),
+			},
+		},
+		"Revoked": {
+			metadata: &meta,
+			files:    map[string][]byte{credsFileName: creds},
+			mounted:  true,
+			ba: func() (*v1alpha1.BucketAccess, error) {
+				return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "bucketaccesses"}, meta.BaName)
+			},
+			want: want{
+				abnormal: true,
+				message:  "bucketAccess " + meta.BaName + " no longer exists",
+				used:     int64(len(creds)),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			target := t.TempDir()
+			var mountPoints []mount.MountPoint
+			if tc.mounted {
+				mountPoints = append(mountPoints, mount.MountPoint{Path: target})
+			}
+
+			n := &NodeServer{
+				provisioner: Provisioner{
+					mounter: mount.NewFakeMounter(mountPoints),
+					pclient: &fake.MockProvisionerClient{
+						MockReadFile: func(fp string) ([]byte, error) {
+							if filepath.Base(fp) == metadataFilename && tc.metadata != nil {
+								return json.Marshal(tc.metadata)
+							}
+							if data, ok := tc.files[filepath.Base(fp)]; ok && filepath.Base(filepath.Dir(fp)) == "bucket" {
+								return data, nil
+							}
+							return nil, os.ErrNotExist
+						},
+					},
+				},
+				cosiClient: &fake.FakeNodeClient{
+					MockLookupBA: func(ctx context.Context, baName string) (*v1alpha1.BucketAccess, error) {
+						return tc.ba()
+					},
+				},
+			}
+
+			resp, err := n.NodeGetVolumeStats(context.Background(), &csi.NodeGetVolumeStatsRequest{
+				VolumeId:   provVolumeId,
+				VolumePath: target,
+			})
+
+			if diff := cmp.Diff(tc.want.code, status.Code(err)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+
+			if diff := cmp.Diff(tc.want.abnormal, resp.GetVolumeCondition().GetAbnormal()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			message := strings.ReplaceAll(resp.GetVolumeCondition().GetMessage(), target, "TARGET")
+			if diff := cmp.Diff(tc.want.message, message); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.used, resp.GetUsage()[0].GetUsed()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
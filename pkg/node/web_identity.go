@@ -0,0 +1,106 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Keys of the secret holding web identity credentials, as read by the formats.
+const (
+	webIdentityAccessKeyIDKey     = "accessKeyID"
+	webIdentitySecretAccessKeyKey = "accessSecretKey"
+	webIdentitySessionTokenKey    = "sessionToken"
+	webIdentityExpirationKey      = "expiration"
+)
+
+// maxSessionNameLength is the longest RoleSessionName STS accepts.
+const maxSessionNameLength = 64
+
+var sessionNameInvalid = regexp.MustCompile(`[^\w+=,.@-]`)
+
+// sessionName names the STS session of the pod podNs/podName, so the audit
+// trail of the store shows which pod used the credentials.
+func sessionName(podNs, podName string) string {
+	name := sessionNameInvalid.ReplaceAllString(fmt.Sprintf("cosi-%s-%s", podNs, podName), "-")
+	if len(name) > maxSessionNameLength {
+		name = name[:maxSessionNameLength]
+	}
+	return name
+}
+
+// exchangeWebIdentity replaces the minted secret of p by the temporary
+// credentials the STS issues for the service account token kubelet passed in
+// volCtx, when the volume sets a web identity role. The credentials expire, so
+// the volume is refreshed before they do like any other temporary credentials.
+//
+// Kubelet passes tokens to publishes only, so a dry run without them keeps the
+// minted secret.
+func (n *NodeServer) exchangeWebIdentity(ctx context.Context, p *publishPlan, volCtx map[string]string, dryRun bool) error {
+	v, ok := volCtx[adapter.WebIdentityRoleARNKey]
+	if !ok {
+		return nil
+	}
+	roleARN, err := adapter.ParseWebIdentityRoleARN(v)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if p.exec {
+		return status.Error(codes.InvalidArgument, util.ErrorWebIdentityExec.Error())
+	}
+	if p.bkt.Spec.Protocol.S3 == nil {
+		return status.Error(codes.FailedPrecondition, util.ErrorWebIdentityNotS3.Error())
+	}
+	endpoint := p.bkt.Spec.Protocol.S3.Endpoint
+	if v, ok := volCtx[adapter.STSEndpointKey]; ok {
+		if endpoint, err = adapter.ParseSTSEndpoint(v); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	audience := adapter.DefaultWebIdentityAudience
+	if v, ok := volCtx[adapter.WebIdentityAudienceKey]; ok {
+		audience = v
+	}
+
+	v, ok = volCtx[adapter.ServiceAccountTokensKey]
+	if !ok && dryRun {
+		return nil
+	}
+	tokens, err := adapter.ParseServiceAccountTokens(v)
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	token, ok := tokens[audience]
+	if !ok {
+		return status.Error(codes.FailedPrecondition, fmt.Sprintf(util.ErrorTemplateMissingServiceAccountToken, audience))
+	}
+
+	creds, err := n.sts.AssumeRoleWithWebIdentity(ctx, endpoint, roleARN, sessionName(p.podNs, p.podName), token.Token)
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	klog.InfoS("exchanged service account token for temporary credentials", "pod", p.podNs+"/"+p.podName, "role", roleARN, "expiration", creds.Expiration)
+
+	secret := p.secret.DeepCopy()
+	secret.Data = map[string][]byte{
+		webIdentityAccessKeyIDKey:     []byte(creds.AccessKeyID),
+		webIdentitySecretAccessKeyKey: []byte(creds.SecretAccessKey),
+	}
+	if creds.SessionToken != "" {
+		secret.Data[webIdentitySessionTokenKey] = []byte(creds.SessionToken)
+	}
+	if !creds.Expiration.IsZero() {
+		secret.Data[webIdentityExpirationKey] = []byte(creds.Expiration.UTC().Format(time.RFC3339))
+	}
+	p.secret = secret
+	p.identityAccess = false
+	return nil
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+)
+
+// The protocols of objectstorage.k8s.io/v1alpha1, in the order the adapter
+// has always picked the protocol file from.
+func init() {
+	Register(Serializer{
+		Name:     adapter.ConnectionProtocolS3,
+		FileName: adapter.S3ProtocolFileName,
+		Get: func(p v1alpha1.Protocol) (interface{}, bool) {
+			return p.S3, p.S3 != nil
+		},
+	})
+	Register(Serializer{
+		Name:     adapter.ConnectionProtocolAzureBlob,
+		FileName: adapter.AzureProtocolFileName,
+		Get: func(p v1alpha1.Protocol) (interface{}, bool) {
+			return p.AzureBlob, p.AzureBlob != nil
+		},
+	})
+	Register(Serializer{
+		Name:     adapter.ConnectionProtocolGCS,
+		FileName: adapter.GCSProtocolFileName,
+		Get: func(p v1alpha1.Protocol) (interface{}, bool) {
+			return p.GCS, p.GCS != nil
+		},
+	})
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package protocol is the registry of the bucket protocols the adapter
+// projects. Supporting a protocol the COSI API gains takes registering a
+// Serializer for it; formats rendering its native configuration are added to
+// the render package as usual.
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Serializer describes a protocol a Bucket may define.
+type Serializer struct {
+	// Name identifies the protocol in adapter.Connection and the protocol
+	// manifest.
+	Name string
+	// FileName is the file holding the protocol when the Bucket defines
+	// several.
+	FileName string
+	// Get returns the protocol's block of p, and whether p defines it.
+	Get func(p v1alpha1.Protocol) (interface{}, bool)
+}
+
+// Block is a protocol defined by a Bucket.
+type Block struct {
+	Serializer
+	Value interface{}
+}
+
+// Marshal serializes the block as the content of adapter.ProtocolFileName.
+func (b Block) Marshal() ([]byte, error) {
+	data, err := json.Marshal(b.Value)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorMarshalProtocolFailed)
+	}
+	return data, nil
+}
+
+var serializers []Serializer
+
+// Register adds s to the registry. Protocols registered first take precedence
+// in First. It panics if the name of s is taken.
+func Register(s Serializer) {
+	for _, existing := range serializers {
+		if existing.Name == s.Name {
+			panic(fmt.Sprintf("protocol: %q registered twice", s.Name))
+		}
+	}
+	serializers = append(serializers, s)
+}
+
+// Defined returns the blocks of every registered protocol bkt defines, in
+// registration order.
+func Defined(bkt *v1alpha1.Bucket) []Block {
+	var blocks []Block
+	for _, s := range serializers {
+		if v, ok := s.Get(bkt.Spec.Protocol); ok {
+			blocks = append(blocks, Block{Serializer: s, Value: v})
+		}
+	}
+	return blocks
+}
+
+// First returns the block of the first registered protocol bkt defines. It
+// fails with util.ErrorInvalidProtocol if bkt defines none.
+func First(bkt *v1alpha1.Bucket) (Block, error) {
+	blocks := Defined(bkt)
+	if len(blocks) == 0 {
+		return Block{}, util.ErrorInvalidProtocol
+	}
+	return blocks[0], nil
+}
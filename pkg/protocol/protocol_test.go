@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protocol
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestDefined(t *testing.T) {
+	type want struct {
+		names []string
+		first string
+		data  string
+		err   error
+	}
+
+	cases := map[string]struct {
+		protocol v1alpha1.Protocol
+		want
+	}{
+		"None": {
+			want: want{err: util.ErrorInvalidProtocol},
+		},
+		"GCS": {
+			protocol: v1alpha1.Protocol{GCS: &v1alpha1.GCSProtocol{BucketName: "app", PrivateKeyName: "key", ProjectID: "proj", ServiceAccount: "sa"}},
+			want: want{names: []string{adapter.ConnectionProtocolGCS}, first: adapter.ConnectionProtocolGCS,
+				data: `{"bucketName":"app","privateKeyName":"key","projectID":"proj","serviceAccount":"sa"}`},
+		},
+		"S3TakesPrecedence": {
+			protocol: v1alpha1.Protocol{
+				AzureBlob: &v1alpha1.AzureProtocol{ContainerName: "app", StorageAccount: "acct"},
+				S3:        &v1alpha1.S3Protocol{Endpoint: "https://s3.example.com", BucketName: "app", Region: "us-east-1", SignatureVersion: "S3V4"},
+			},
+			want: want{names: []string{adapter.ConnectionProtocolS3, adapter.ConnectionProtocolAzureBlob}, first: adapter.ConnectionProtocolS3,
+				data: `{"endpoint":"https://s3.example.com","bucketName":"app","region":"us-east-1","signatureVersion":"S3V4"}`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			bkt := &v1alpha1.Bucket{Spec: v1alpha1.BucketSpec{Protocol: tc.protocol}}
+
+			var names []string
+			for _, b := range Defined(bkt) {
+				names = append(names, b.Name)
+			}
+			if diff := cmp.Diff(tc.want.names, names); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			first, err := First(bkt)
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.first, first.Name); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			data, err := first.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.data, string(data)); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a protocol twice did not panic")
+		}
+	}()
+	Register(Serializer{Name: adapter.ConnectionProtocolS3})
+}
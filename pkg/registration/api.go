@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registration
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+)
+
+// The messages and service below mirror k8s.io/kubelet/pkg/apis/pluginregistration/v1.
+// They are declared here, rather than imported, to keep the kubelet module out
+// of the adapter's dependencies; the wire format is defined by the field tags.
+
+// InfoRequest is sent by kubelet to discover the plugin.
+type InfoRequest struct{}
+
+func (m *InfoRequest) Reset()         { *m = InfoRequest{} }
+func (m *InfoRequest) String() string { return proto.CompactTextString(m) }
+func (*InfoRequest) ProtoMessage()    {}
+
+// PluginInfo describes the plugin to kubelet.
+type PluginInfo struct {
+	// Type is the plugin type, always CSIPlugin for the adapter.
+	Type string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	// Name is the CSI driver name.
+	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// Endpoint is the CSI socket as seen from the host.
+	Endpoint          string   `protobuf:"bytes,3,opt,name=endpoint,proto3" json:"endpoint,omitempty"`
+	SupportedVersions []string `protobuf:"bytes,4,rep,name=supported_versions,json=supportedVersions,proto3" json:"supported_versions,omitempty"`
+}
+
+func (m *PluginInfo) Reset()         { *m = PluginInfo{} }
+func (m *PluginInfo) String() string { return proto.CompactTextString(m) }
+func (*PluginInfo) ProtoMessage()    {}
+
+// RegistrationStatus is sent by kubelet once it has acted on PluginInfo.
+type RegistrationStatus struct {
+	PluginRegistered bool   `protobuf:"varint,1,opt,name=plugin_registered,json=pluginRegistered,proto3" json:"plugin_registered,omitempty"`
+	Error            string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *RegistrationStatus) Reset()         { *m = RegistrationStatus{} }
+func (m *RegistrationStatus) String() string { return proto.CompactTextString(m) }
+func (*RegistrationStatus) ProtoMessage()    {}
+
+// RegistrationStatusResponse is the empty reply to RegistrationStatus.
+type RegistrationStatusResponse struct{}
+
+func (m *RegistrationStatusResponse) Reset()         { *m = RegistrationStatusResponse{} }
+func (m *RegistrationStatusResponse) String() string { return proto.CompactTextString(m) }
+func (*RegistrationStatusResponse) ProtoMessage()    {}
+
+// RegistrationServer is the service kubelet calls on the registration socket.
+type RegistrationServer interface {
+	GetInfo(context.Context, *InfoRequest) (*PluginInfo, error)
+	NotifyRegistrationStatus(context.Context, *RegistrationStatus) (*RegistrationStatusResponse, error)
+}
+
+const serviceName = "pluginregistration.Registration"
+
+func registerRegistrationServer(s *grpc.Server, srv RegistrationServer) {
+	s.RegisterService(&registrationServiceDesc, srv)
+}
+
+var registrationServiceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*RegistrationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetInfo",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(InfoRequest)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(RegistrationServer).GetInfo(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/GetInfo"}
+				return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(RegistrationServer).GetInfo(ctx, req.(*InfoRequest))
+				})
+			},
+		},
+		{
+			MethodName: "NotifyRegistrationStatus",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(RegistrationStatus)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(RegistrationServer).NotifyRegistrationStatus(ctx, in)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/NotifyRegistrationStatus"}
+				return interceptor(ctx, in, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(RegistrationServer).NotifyRegistrationStatus(ctx, req.(*RegistrationStatus))
+				})
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package registration registers the adapter with kubelet's plugin watcher
+// from within the adapter process, replacing the node-driver-registrar sidecar.
+package registration
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"k8s.io/klog/v2"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/handover"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const (
+	// DefaultRegistrationDir is where the kubelet plugins_registry directory is
+	// mounted in the adapter container.
+	DefaultRegistrationDir = "/registration"
+
+	csiPluginType = "CSIPlugin"
+)
+
+var supportedVersions = []string{"1.0.0"}
+
+// Registrar serves the kubelet plugin registration API on a socket in the
+// plugin registration directory, which kubelet watches for new plugins.
+type Registrar struct {
+	driverName      string
+	endpoint        string
+	registrationDir string
+
+	status chan *RegistrationStatus
+}
+
+// NewRegistrar returns a Registrar announcing driverName served at endpoint,
+// the CSI socket path as seen by kubelet on the host.
+func NewRegistrar(driverName, endpoint, registrationDir string) *Registrar {
+	return &Registrar{
+		driverName:      driverName,
+		endpoint:        endpoint,
+		registrationDir: registrationDir,
+		status:          make(chan *RegistrationStatus, 1),
+	}
+}
+
+func (r *Registrar) socketPath() string {
+	return filepath.Join(r.registrationDir, fmt.Sprintf("%s-reg.sock", r.driverName))
+}
+
+// Start serves the registration socket until ctx is done. Like the sidecar, it
+// fails if kubelet reports that registration failed, so the pod restarts and
+// retries rather than running unregistered.
+//
+// The socket is taken over from a previous adapter on the node rather than
+// replaced, and left in place on exit if a newer adapter has taken it over,
+// so kubelet never sees the plugin disappear during an upgrade.
+func (r *Registrar) Start(ctx context.Context) error {
+	path := r.socketPath()
+	lis, err := handover.Listen("unix", path)
+	if err != nil {
+		return errors.Wrap(err, util.WrapErrorRegistrationSocketFailed)
+	}
+	defer lis.Remove()
+
+	s := grpc.NewServer()
+	registerRegistrationServer(s, r)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(lis)
+	}()
+	klog.InfoS("serving kubelet plugin registration", "socket", path, "endpoint", r.endpoint)
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.GracefulStop()
+			return nil
+		case err := <-served:
+			return err
+		case st := <-r.status:
+			if !st.PluginRegistered {
+				// The status arrives before its RPC returns: let kubelet
+				// have the response before the socket goes away.
+				s.GracefulStop()
+				return fmt.Errorf(util.ErrorTemplateRegistrationFailed, st.Error)
+			}
+			klog.InfoS("registered with kubelet", "driver", r.driverName)
+		}
+	}
+}
+
+func (r *Registrar) GetInfo(ctx context.Context, req *InfoRequest) (*PluginInfo, error) {
+	klog.V(4).InfoS("kubelet requested plugin info")
+	return &PluginInfo{
+		Type:              csiPluginType,
+		Name:              r.driverName,
+		Endpoint:          r.endpoint,
+		SupportedVersions: supportedVersions,
+	}, nil
+}
+
+func (r *Registrar) NotifyRegistrationStatus(ctx context.Context, st *RegistrationStatus) (*RegistrationStatusResponse, error) {
+	select {
+	case r.status <- st:
+	default:
+		// A previous status is still pending; kubelet only ever sends one.
+	}
+	return &RegistrationStatusResponse{}, nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registration
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestRegistrar(t *testing.T) {
+	type want struct {
+		info *PluginInfo
+		err  error
+	}
+
+	cases := map[string]struct {
+		status *RegistrationStatus
+		want
+	}{
+		"Registered": {
+			status: &RegistrationStatus{PluginRegistered: true},
+			want: want{
+				info: &PluginInfo{Type: csiPluginType, Name: "cosi", Endpoint: "/var/lib/kubelet/plugins/cosi/csi.sock", SupportedVersions: supportedVersions},
+			},
+		},
+		"Rejected": {
+			status: &RegistrationStatus{PluginRegistered: false, Error: "boom"},
+			want: want{
+				info: &PluginInfo{Type: csiPluginType, Name: "cosi", Endpoint: "/var/lib/kubelet/plugins/cosi/csi.sock", SupportedVersions: supportedVersions},
+				err:  fmt.Errorf(util.ErrorTemplateRegistrationFailed, "boom"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := NewRegistrar("cosi", "/var/lib/kubelet/plugins/cosi/csi.sock", t.TempDir())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() { done <- r.Start(ctx) }()
+
+			dialCtx, dialCancel := context.WithTimeout(ctx, 10*time.Second)
+			defer dialCancel()
+			conn, err := grpc.DialContext(dialCtx, r.socketPath(), grpc.WithInsecure(), grpc.WithBlock(),
+				grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+				}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			info := &PluginInfo{}
+			if err := conn.Invoke(ctx, "/"+serviceName+"/GetInfo", &InfoRequest{}, info); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want.info, info); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			if err := conn.Invoke(ctx, "/"+serviceName+"/NotifyRegistrationStatus", tc.status, &RegistrationStatusResponse{}); err != nil {
+				t.Fatal(err)
+			}
+			if tc.status.PluginRegistered {
+				cancel()
+			}
+
+			if diff := cmp.Diff(tc.want.err, <-done, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// ChecksumsKey is the volume attribute selecting the algorithm of the integrity
+// file written next to the rendered files.
+const ChecksumsKey = adapter.ChecksumsKey
+
+type checksumAlgorithm struct {
+	fileName string
+	newHash  func() hash.Hash
+}
+
+var checksumAlgorithms = map[string]checksumAlgorithm{
+	"sha256": {fileName: "SHA256SUMS", newHash: sha256.New},
+	"sha512": {fileName: "SHA512SUMS", newHash: sha512.New},
+}
+
+// Checksummer builds an integrity file covering files.
+type Checksummer func(files []File) File
+
+// ResolveChecksum returns the Checksummer requested by the volume attributes,
+// or nil if none was requested.
+func ResolveChecksum(attrs map[string]string) (Checksummer, error) {
+	name := attrs[ChecksumsKey]
+	if name == "" {
+		return nil, nil
+	}
+	algorithm, ok := checksumAlgorithms[name]
+	if !ok {
+		names := make([]string, 0, len(checksumAlgorithms))
+		for n := range checksumAlgorithms {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf(util.ErrorTemplateUnknownChecksum, name, strings.Join(names, ", "))
+	}
+	return algorithm.sums, nil
+}
+
+// sums writes files in the format of coreutils sha256sum and friends, sorted by
+// name, so "sha256sum -c SHA256SUMS" in the mount directory verifies them.
+func (a checksumAlgorithm) sums(files []File) File {
+	sorted := append([]File(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var b strings.Builder
+	for _, f := range sorted {
+		h := a.newHash()
+		h.Write(f.Data)
+		fmt.Fprintf(&b, "%s  %s\n", hex.EncodeToString(h.Sum(nil)), f.Name)
+	}
+	return File{Name: a.fileName, Data: []byte(b.String()), Mode: configFileMode}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestChecksum(t *testing.T) {
+	files := []File{
+		{Name: "protocolConn.json", Data: []byte("{}")},
+		{Name: "credentials", Data: []byte("")},
+	}
+
+	type want struct {
+		file *File
+		err  error
+	}
+
+	cases := map[string]struct {
+		attrs map[string]string
+		want
+	}{
+		"Unset": {
+			attrs: map[string]string{},
+		},
+		"SHA256": {
+			attrs: map[string]string{ChecksumsKey: "sha256"},
+			want: want{file: &File{
+				Name: "SHA256SUMS",
+				Data: []byte("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  credentials\n" +
+					"44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a  protocolConn.json\n"),
+				Mode: configFileMode,
+			}},
+		},
+		"Unknown": {
+			attrs: map[string]string{ChecksumsKey: "md5"},
+			want:  want{err: fmt.Errorf(util.ErrorTemplateUnknownChecksum, "md5", "sha256, sha512")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			checksum, err := ResolveChecksum(tc.attrs)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			var got *File
+			if checksum != nil {
+				f := checksum(files)
+				got = &f
+			}
+			if diff := cmp.Diff(tc.want.file, got); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Secret keys under which provisioners commonly store credentials, in order of
+// preference.
+var (
+	accessKeyIDKeys     = []string{"accessKeyID", "accessKeyId", "AWS_ACCESS_KEY_ID", "aws_access_key_id"}
+	secretAccessKeyKeys = []string{"accessSecretKey", "secretAccessKey", "AWS_SECRET_ACCESS_KEY", "aws_secret_access_key"}
+	sessionTokenKeys    = []string{"sessionToken", "AWS_SESSION_TOKEN", "aws_session_token"}
+
+	connectionStringKeys = []string{"connectionString", "AZURE_STORAGE_CONNECTION_STRING"}
+	accountKeyKeys       = []string{"accountKey", "AZURE_STORAGE_KEY"}
+	sasTokenKeys         = []string{"sasToken", "AZURE_STORAGE_SAS_TOKEN"}
+
+	// serviceAccountKeyKeys hold a GCP service account key in its JSON form.
+	serviceAccountKeyKeys = []string{"serviceAccountKey", "service_account.json", "GOOGLE_APPLICATION_CREDENTIALS_JSON"}
+	// externalAccountKeys hold a workload identity federation credential
+	// configuration, which Google client libraries load like a key.
+	externalAccountKeys = []string{"externalAccount", "credential_configuration.json"}
+
+	// expirationKeys hold the RFC 3339 time at which temporary credentials,
+	// such as STS or Vault issued ones, stop working.
+	expirationKeys = []string{"expiration", "Expiration", "AWS_SESSION_EXPIRATION", "aws_session_expiration"}
+)
+
+const (
+	secretFileMode = 0440
+	configFileMode = 0444
+)
+
+// accessKey is an S3 style HMAC key pair, as also used by GCS interoperability.
+type accessKey struct {
+	id, secret, sessionToken string
+}
+
+func lookup(secret *v1.Secret, keys []string) string {
+	for _, k := range keys {
+		if v, ok := secret.Data[k]; ok && len(v) > 0 {
+			return string(v)
+		}
+	}
+	return ""
+}
+
+func accessKeyFrom(secret *v1.Secret) (accessKey, error) {
+	key := accessKey{
+		id:           lookup(secret, accessKeyIDKeys),
+		secret:       lookup(secret, secretAccessKeyKeys),
+		sessionToken: lookup(secret, sessionTokenKeys),
+	}
+	if key.id == "" || key.secret == "" {
+		return accessKey{}, util.ErrorMissingAccessKey
+	}
+	return key, nil
+}
+
+// ExpirationAnnotation on a minted secret holds the RFC 3339 time at which its
+// credentials expire, for provisioners that keep it out of the data.
+const ExpirationAnnotation = "cosi.objectstorage.k8s.io/credential-expiration"
+
+// CredentialExpiration returns when the credentials in secret expire, if they
+// are temporary and the provisioner recorded it: under one of expirationKeys,
+// in ExpirationAnnotation, or as the signed expiry (se) of an Azure SAS token.
+func CredentialExpiration(secret *v1.Secret) (time.Time, bool) {
+	v := lookup(secret, expirationKeys)
+	if v == "" {
+		v = secret.Annotations[ExpirationAnnotation]
+	}
+	if v == "" {
+		if sas, err := url.ParseQuery(strings.TrimPrefix(lookup(secret, sasTokenKeys), "?")); err == nil {
+			v = sas.Get("se")
+		}
+	}
+	if v == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// ProcessCredentials returns the credentials in secret as printed by an AWS
+// credential_process.
+func ProcessCredentials(secret *v1.Secret) (adapter.ProcessCredentials, error) {
+	key, err := accessKeyFrom(secret)
+	if err != nil {
+		return adapter.ProcessCredentials{}, err
+	}
+	creds := adapter.ProcessCredentials{
+		Version:         1,
+		AccessKeyID:     key.id,
+		SecretAccessKey: key.secret,
+		SessionToken:    key.sessionToken,
+	}
+	if expiry, ok := CredentialExpiration(secret); ok {
+		creds.Expiration = expiry.UTC().Format(time.RFC3339)
+	}
+	return creds, nil
+}
+
+// writeSection appends an INI section to b. Keys with empty values are
+// omitted; kv alternates keys and values.
+func writeSection(b *strings.Builder, name string, kv ...string) {
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(b, "[%s]\n", name)
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] != "" {
+			fmt.Fprintf(b, "%s = %s\n", kv[i], kv[i+1])
+		}
+	}
+}
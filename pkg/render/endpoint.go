@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+const s3EndpointField = "spec.protocol.s3.endpoint"
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// NormalizeBucket returns a copy of bkt with its endpoint normalized, or an
+// error naming the field at fault. Provisioners and administrators write
+// endpoints in many shapes, while SDKs are strict about what they parse.
+func NormalizeBucket(bkt *v1alpha1.Bucket) (*v1alpha1.Bucket, error) {
+	if bkt.Spec.Protocol.S3 == nil || bkt.Spec.Protocol.S3.Endpoint == "" {
+		return bkt, nil
+	}
+
+	endpoint, err := normalizeEndpoint(bkt.Spec.Protocol.S3.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf(util.ErrorTemplateInvalidEndpoint, bkt.Name, s3EndpointField, bkt.Spec.Protocol.S3.Endpoint, err)
+	}
+
+	normalized := bkt.DeepCopy()
+	normalized.Spec.Protocol.S3.Endpoint = endpoint
+	return normalized, nil
+}
+
+// normalizeEndpoint infers https for scheme-less endpoints, drops default ports
+// and trailing slashes, and rejects endpoints that are not plain http(s)
+// origins or that embed credentials.
+func normalizeEndpoint(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if _, ok := defaultPorts[u.Scheme]; !ok {
+		return "", util.ErrorEndpointScheme
+	}
+	if u.User != nil {
+		return "", util.ErrorEndpointCredentials
+	}
+	if u.Hostname() == "" {
+		return "", util.ErrorEndpointNoHost
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", util.ErrorEndpointQuery
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != defaultPorts[u.Scheme] {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		// Keep IPv6 literals bracketed.
+		host = "[" + host + "]"
+	}
+
+	return u.Scheme + "://" + host + strings.TrimRight(u.EscapedPath(), "/"), nil
+}
@@ -0,0 +1,653 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Built in formats.
+const (
+	FormatAWS    = "aws"
+	FormatSpark  = "spark"
+	FormatRclone = "rclone"
+	FormatBoto   = "boto"
+	FormatAzure  = "azure"
+	FormatEnv    = "env"
+	FormatS3cmd  = "s3cmd"
+	FormatYAML   = "yaml"
+
+	// FormatCombined writes the connection and credentials as one document.
+	FormatCombined = "combined"
+
+	// FormatGoogleCredentials writes the file GOOGLE_APPLICATION_CREDENTIALS
+	// points Google client libraries at.
+	FormatGoogleCredentials = "google-credentials"
+
+	// FormatAWSProcess writes an AWS config whose credential_process runs the
+	// credential helper projected by exec credential delivery.
+	FormatAWSProcess = "aws-credential-process"
+)
+
+const (
+	awsCredentialsFileName      = "aws_credentials"
+	awsConfigFileName           = "aws_config"
+	sparkDefaultsFileName       = "spark-defaults.conf"
+	rcloneConfigFileName        = "rclone.conf"
+	botoConfigFileName          = ".boto"
+	gcsServiceAccountFileName   = "gcs_service_account.json"
+	googleCredentialsFileName   = "application_default_credentials.json"
+	azureConnectionFileName     = "azure_connection_string"
+	azureAccountKeyFileName     = "azure_account_key"
+	azureSASTokenFileName       = "azure_sas_token"
+	envFileName                 = "cosi.env"
+	s3cmdConfigFileName         = ".s3cfg"
+	s3cmdDefaultHost            = "s3.amazonaws.com"
+	rcloneRemoteName            = "cosi"
+	gcsInteropEndpoint          = "https://storage.googleapis.com"
+	azureDefaultEndpointsSuffix = "core.windows.net"
+)
+
+func init() {
+	Register(FormatAWS, renderAWS)
+	Register(FormatSpark, renderSpark)
+	Register(FormatRclone, renderRclone)
+	Register(FormatBoto, renderBoto)
+	Register(FormatAzure, renderAzure)
+	Register(FormatEnv, renderEnv)
+	Register(FormatS3cmd, renderS3cmd)
+	Register(FormatGoogleCredentials, renderGoogleCredentials)
+	Register(FormatYAML, renderYAML)
+	Register(FormatCombined, renderCombined)
+	Register(FormatAWSProcess, renderAWSProcess)
+}
+
+// credentialFree are the formats that never write the minted secret.
+var credentialFree = map[string]bool{
+	FormatAWSProcess: true,
+}
+
+// CredentialFree reports whether format name never writes the minted secret,
+// so it may be used with exec credential delivery.
+func CredentialFree(name string) bool {
+	return credentialFree[name]
+}
+
+// renderAWS writes the shared credentials and config files read by the AWS CLI
+// and SDKs through AWS_SHARED_CREDENTIALS_FILE and AWS_CONFIG_FILE. The config
+// names the endpoint, which SDKs honor since endpoint_url support was added to
+// the shared config, so S3 compatible stores need no further settings.
+func renderAWS(in Input) ([]File, error) {
+	s3 := in.Bucket.Spec.Protocol.S3
+	if s3 == nil {
+		return nil, fmt.Errorf(util.ErrorTemplateProtocolMismatch, "s3")
+	}
+	profile, err := adapter.ParseAWSProfile(in.Attributes[adapter.AWSProfileKey])
+	if err != nil {
+		return nil, err
+	}
+	key, err := accessKeyFrom(in.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var creds, config strings.Builder
+	writeSection(&creds, profile,
+		"aws_access_key_id", key.id,
+		"aws_secret_access_key", key.secret,
+		"aws_session_token", key.sessionToken)
+	writeSection(&config, awsConfigSection(profile),
+		"region", s3.Region,
+		"endpoint_url", s3.Endpoint)
+
+	return []File{
+		{Name: awsCredentialsFileName, Data: []byte(creds.String()), Mode: secretFileMode},
+		{Name: awsConfigFileName, Data: []byte(config.String()), Mode: configFileMode},
+	}, nil
+}
+
+// renderAWSProcess writes an AWS config, read through AWS_CONFIG_FILE, that
+// leaves fetching credentials to the credential helper in the volume.
+func renderAWSProcess(in Input) ([]File, error) {
+	s3 := in.Bucket.Spec.Protocol.S3
+	if s3 == nil {
+		return nil, fmt.Errorf(util.ErrorTemplateProtocolMismatch, "s3")
+	}
+	mountPath, err := adapter.ParseMountPath(in.Attributes[adapter.MountPathKey])
+	if err != nil {
+		return nil, err
+	}
+	profile, err := adapter.ParseAWSProfile(in.Attributes[adapter.AWSProfileKey])
+	if err != nil {
+		return nil, err
+	}
+
+	var config strings.Builder
+	writeSection(&config, awsConfigSection(profile),
+		"region", s3.Region,
+		"endpoint_url", s3.Endpoint,
+		"credential_process", fmt.Sprintf("%s --socket %s",
+			path.Join(mountPath, adapter.CredentialHelperFileName),
+			path.Join(mountPath, adapter.CredentialHelperSocketName)))
+
+	return []File{
+		{Name: awsConfigFileName, Data: []byte(config.String()), Mode: configFileMode},
+	}, nil
+}
+
+// awsConfigSection returns the config file section of profile. Unlike in the
+// credentials file, profiles other than the default one are prefixed there.
+func awsConfigSection(profile string) string {
+	if profile == "default" {
+		return profile
+	}
+	return "profile " + profile
+}
+
+// renderSpark writes hadoop-aws (s3a) properties in spark-defaults.conf syntax,
+// to be loaded with spark-submit --properties-file.
+func renderSpark(in Input) ([]File, error) {
+	s3 := in.Bucket.Spec.Protocol.S3
+	if s3 == nil {
+		return nil, fmt.Errorf(util.ErrorTemplateProtocolMismatch, "s3")
+	}
+	key, err := accessKeyFrom(in.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	property := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "spark.hadoop.fs.s3a.%s %s\n", name, value)
+		}
+	}
+	property("access.key", key.id)
+	property("secret.key", key.secret)
+	if key.sessionToken != "" {
+		property("session.token", key.sessionToken)
+		property("aws.credentials.provider", "org.apache.hadoop.fs.s3a.TemporaryAWSCredentialsProvider")
+	}
+	property("endpoint", s3.Endpoint)
+	property("endpoint.region", s3.Region)
+	if s3.Endpoint != "" {
+		// S3 compatible stores rarely support virtual hosted style addressing.
+		property("path.style.access", "true")
+	}
+
+	return []File{{Name: sparkDefaultsFileName, Data: []byte(b.String()), Mode: secretFileMode}}, nil
+}
+
+// renderRclone writes an rclone.conf with a single remote named "cosi", of the
+// rclone backend matching the bucket protocol.
+func renderRclone(in Input) ([]File, error) {
+	var kv []string
+	var err error
+	switch proto := in.Bucket.Spec.Protocol; {
+	case proto.S3 != nil:
+		kv, err = rcloneS3(in)
+	case proto.AzureBlob != nil:
+		kv, err = rcloneAzure(in)
+	case proto.GCS != nil:
+		kv, err = rcloneGCS(in)
+	default:
+		err = fmt.Errorf(util.ErrorTemplateProtocolMismatch, "s3, azureBlob or gcs")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	writeSection(&b, rcloneRemoteName, kv...)
+	return []File{{Name: rcloneConfigFileName, Data: []byte(b.String()), Mode: secretFileMode}}, nil
+}
+
+func rcloneS3(in Input) ([]string, error) {
+	s3 := in.Bucket.Spec.Protocol.S3
+	key, err := accessKeyFrom(in.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"type", "s3",
+		"provider", "Other",
+		"access_key_id", key.id,
+		"secret_access_key", key.secret,
+		"session_token", key.sessionToken,
+		"endpoint", s3.Endpoint,
+		"region", s3.Region,
+	}, nil
+}
+
+// rcloneAzure configures the azureblob backend, which takes the account key or
+// SAS but no connection string; one in the secret is split into its parts.
+func rcloneAzure(in Input) ([]string, error) {
+	azure := in.Bucket.Spec.Protocol.AzureBlob
+	conn := parseConnectionString(lookup(in.Secret, connectionStringKeys))
+
+	account := conn["AccountName"]
+	if account == "" {
+		account = azure.StorageAccount
+	}
+	endpoint := strings.TrimSuffix(conn["BlobEndpoint"], "/")
+	key := conn["AccountKey"]
+	if key == "" {
+		key = lookup(in.Secret, accountKeyKeys)
+	}
+	sas := conn["SharedAccessSignature"]
+	if sas == "" {
+		sas = lookup(in.Secret, sasTokenKeys)
+	}
+
+	kv := []string{"type", "azureblob", "account", account, "endpoint", endpoint}
+	switch {
+	case key != "":
+		return append(kv, "key", key), nil
+	case sas != "":
+		base := endpoint
+		if base == "" {
+			base = fmt.Sprintf("https://%s.blob.%s", account, azureDefaultEndpointsSuffix)
+		}
+		// rclone only accepts container level SAS URLs.
+		return append(kv, "sas_url", fmt.Sprintf("%s/%s?%s", base, azure.ContainerName, strings.TrimPrefix(sas, "?"))), nil
+	default:
+		return nil, util.ErrorMissingAzureKey
+	}
+}
+
+// rcloneGCS configures the google cloud storage backend when the secret holds a
+// service account key. HMAC keys only work through the S3 interoperability
+// API, so they get an s3 remote for GCS instead.
+func rcloneGCS(in Input) ([]string, error) {
+	gcs := in.Bucket.Spec.Protocol.GCS
+	if sa := lookup(in.Secret, serviceAccountKeyKeys); sa != "" {
+		// rclone.conf values end at the line, so the key must be on one.
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, []byte(sa)); err != nil {
+			return nil, util.ErrorInvalidServiceAccountKey
+		}
+		return []string{
+			"type", "google cloud storage",
+			"project_number", gcs.ProjectID,
+			"service_account_credentials", compact.String(),
+			"bucket_policy_only", "true",
+		}, nil
+	}
+
+	key, err := accessKeyFrom(in.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"type", "s3",
+		"provider", "GCS",
+		"access_key_id", key.id,
+		"secret_access_key", key.secret,
+		"endpoint", gcsInteropEndpoint,
+	}, nil
+}
+
+// parseConnectionString splits an Azure Storage connection string into its
+// key=value settings.
+func parseConnectionString(conn string) map[string]string {
+	settings := map[string]string{}
+	for _, part := range strings.Split(conn, ";") {
+		if i := strings.Index(part, "="); i > 0 {
+			settings[strings.TrimSpace(part[:i])] = strings.TrimSpace(part[i+1:])
+		}
+	}
+	return settings
+}
+
+// renderS3cmd writes an .s3cfg for s3cmd, to be run with -c. Buckets on custom
+// endpoints are addressed path style, which S3 compatible stores support far
+// more widely than virtual hosted style.
+func renderS3cmd(in Input) ([]File, error) {
+	s3 := in.Bucket.Spec.Protocol.S3
+	if s3 == nil {
+		return nil, fmt.Errorf(util.ErrorTemplateProtocolMismatch, "s3")
+	}
+	key, err := accessKeyFrom(in.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	hostBase, hostBucket, useHTTPS := s3cmdDefaultHost, "%(bucket)s."+s3cmdDefaultHost, "True"
+	if s3.Endpoint != "" {
+		// NormalizeBucket leaves endpoints as scheme://host[:port].
+		u, err := url.Parse(s3.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		hostBase, hostBucket = u.Host, u.Host
+		if u.Scheme == "http" {
+			useHTTPS = "False"
+		}
+	}
+	signatureV2 := "False"
+	if strings.EqualFold(string(s3.SignatureVersion), "S3V2") {
+		signatureV2 = "True"
+	}
+
+	var b strings.Builder
+	writeSection(&b, "default",
+		"access_key", key.id,
+		"secret_key", key.secret,
+		"access_token", key.sessionToken,
+		"host_base", hostBase,
+		"host_bucket", hostBucket,
+		"bucket_location", s3.Region,
+		"use_https", useHTTPS,
+		"signature_v2", signatureV2)
+
+	return []File{{Name: s3cmdConfigFileName, Data: []byte(b.String()), Mode: secretFileMode}}, nil
+}
+
+// renderBoto writes a .boto file for gsutil. A service account key in the
+// secret is written next to it and referenced by absolute path, since boto
+// resolves gs_service_key_file against the working directory; that takes
+// MountPathKey. Otherwise the .boto holds the HMAC credentials.
+func renderBoto(in Input) ([]File, error) {
+	gcs := in.Bucket.Spec.Protocol.GCS
+	if gcs == nil {
+		return nil, fmt.Errorf(util.ErrorTemplateProtocolMismatch, "gcs")
+	}
+
+	var (
+		b     strings.Builder
+		files []File
+	)
+	if sa := lookup(in.Secret, serviceAccountKeyKeys); sa != "" {
+		if !json.Valid([]byte(sa)) {
+			return nil, util.ErrorInvalidServiceAccountKey
+		}
+		mountPath, err := adapter.ParseMountPath(in.Attributes[adapter.MountPathKey])
+		if err != nil {
+			return nil, err
+		}
+		writeSection(&b, "Credentials",
+			"gs_service_key_file", path.Join(mountPath, gcsServiceAccountFileName))
+		files = append(files, File{Name: gcsServiceAccountFileName, Data: []byte(sa), Mode: secretFileMode})
+	} else {
+		key, err := accessKeyFrom(in.Secret)
+		if err != nil {
+			return nil, err
+		}
+		writeSection(&b, "Credentials",
+			"gs_access_key_id", key.id,
+			"gs_secret_access_key", key.secret)
+	}
+	writeSection(&b, "GSUtil",
+		"default_project_id", gcs.ProjectID)
+
+	return append(files, File{Name: botoConfigFileName, Data: []byte(b.String()), Mode: secretFileMode}), nil
+}
+
+// googleCredentialTypes are the credential files Google client libraries load
+// from GOOGLE_APPLICATION_CREDENTIALS without further configuration.
+var googleCredentialTypes = map[string]bool{
+	"service_account":  true,
+	"external_account": true,
+}
+
+// renderGoogleCredentials writes the service account key, or else the external
+// account configuration, of the secret verbatim for
+// GOOGLE_APPLICATION_CREDENTIALS. External account configurations name their
+// own token source, which must be reachable from the workload.
+func renderGoogleCredentials(in Input) ([]File, error) {
+	if in.Bucket.Spec.Protocol.GCS == nil {
+		return nil, fmt.Errorf(util.ErrorTemplateProtocolMismatch, "gcs")
+	}
+	creds := lookup(in.Secret, serviceAccountKeyKeys)
+	if creds == "" {
+		creds = lookup(in.Secret, externalAccountKeys)
+	}
+	if creds == "" {
+		return nil, util.ErrorMissingGoogleCredentials
+	}
+
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(creds), &header); err != nil {
+		return nil, util.ErrorInvalidServiceAccountKey
+	}
+	if !googleCredentialTypes[header.Type] {
+		return nil, fmt.Errorf(util.ErrorTemplateGoogleCredentialType, header.Type)
+	}
+	return []File{{Name: googleCredentialsFileName, Data: []byte(creds), Mode: secretFileMode}}, nil
+}
+
+// renderCombined writes an adapter.Connection, for applications that would
+// rather parse one file than the protocol and credentials files.
+func renderCombined(in Input) ([]File, error) {
+	conn := adapter.Connection{
+		Version:     adapter.ConnectionVersion,
+		Credentials: adapter.Credentials{},
+	}
+	switch p := in.Bucket.Spec.Protocol; {
+	case p.S3 != nil:
+		conn.Protocol = adapter.ConnectionProtocolS3
+		conn.Bucket = p.S3.BucketName
+		conn.Endpoint = p.S3.Endpoint
+		conn.Region = p.S3.Region
+		conn.Parameters = parameters("signatureVersion", string(p.S3.SignatureVersion))
+	case p.AzureBlob != nil:
+		conn.Protocol = adapter.ConnectionProtocolAzureBlob
+		conn.Bucket = p.AzureBlob.ContainerName
+		if p.AzureBlob.StorageAccount != "" {
+			conn.Endpoint = fmt.Sprintf("https://%s.blob.%s", p.AzureBlob.StorageAccount, azureDefaultEndpointsSuffix)
+		}
+		conn.Parameters = parameters("storageAccount", p.AzureBlob.StorageAccount)
+	case p.GCS != nil:
+		conn.Protocol = adapter.ConnectionProtocolGCS
+		conn.Bucket = p.GCS.BucketName
+		conn.Endpoint = gcsInteropEndpoint
+		conn.Parameters = parameters("projectID", p.GCS.ProjectID)
+	default:
+		return nil, util.ErrorInvalidProtocol
+	}
+	for k, v := range in.Secret.Data {
+		conn.Credentials[k] = string(v)
+	}
+
+	data, err := json.Marshal(conn)
+	if err != nil {
+		return nil, err
+	}
+	return []File{{Name: adapter.ConnectionFileName, Data: data, Mode: secretFileMode}}, nil
+}
+
+// parameters returns the non-empty values of kv as a map, or nil if there are
+// none.
+func parameters(kv ...string) map[string]string {
+	var m map[string]string
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] == "" {
+			continue
+		}
+		if m == nil {
+			m = map[string]string{}
+		}
+		m[kv[i]] = kv[i+1]
+	}
+	return m
+}
+
+// renderAzure writes an Azure Storage connection string. A connection string in
+// the secret is used verbatim, otherwise one is built from the storage account
+// and an account key or SAS token. The account key and SAS token are also
+// written to files of their own, for SDKs and tools taking them separately;
+// those in a connection string are split out of it.
+func renderAzure(in Input) ([]File, error) {
+	azure := in.Bucket.Spec.Protocol.AzureBlob
+	if azure == nil {
+		return nil, fmt.Errorf(util.ErrorTemplateProtocolMismatch, "azureBlob")
+	}
+	conn, err := azureConnectionString(in)
+	if err != nil {
+		return nil, err
+	}
+	files := []File{{Name: azureConnectionFileName, Data: []byte(conn), Mode: secretFileMode}}
+
+	settings := parseConnectionString(conn)
+	if key := settings["AccountKey"]; key != "" {
+		files = append(files, File{Name: azureAccountKeyFileName, Data: []byte(key), Mode: secretFileMode})
+	}
+	if sas := settings["SharedAccessSignature"]; sas != "" {
+		files = append(files, File{Name: azureSASTokenFileName, Data: []byte(sas), Mode: secretFileMode})
+	}
+	return files, nil
+}
+
+func azureConnectionString(in Input) (string, error) {
+	azure := in.Bucket.Spec.Protocol.AzureBlob
+	switch {
+	case lookup(in.Secret, connectionStringKeys) != "":
+		return lookup(in.Secret, connectionStringKeys), nil
+	case lookup(in.Secret, accountKeyKeys) != "":
+		return fmt.Sprintf("DefaultEndpointsProtocol=https;AccountName=%s;AccountKey=%s;EndpointSuffix=%s",
+			azure.StorageAccount, lookup(in.Secret, accountKeyKeys), azureDefaultEndpointsSuffix), nil
+	case lookup(in.Secret, sasTokenKeys) != "":
+		return fmt.Sprintf("BlobEndpoint=https://%s.blob.%s;SharedAccessSignature=%s",
+			azure.StorageAccount, azureDefaultEndpointsSuffix, strings.TrimPrefix(lookup(in.Secret, sasTokenKeys), "?")), nil
+	default:
+		return "", util.ErrorMissingAzureKey
+	}
+}
+
+// renderEnv writes the credentials and connection details as KEY=VALUE lines
+// named after the variables the AWS and Azure SDKs read, for env_file loaders
+// and shells to source. Values are single quoted only when they contain
+// characters a shell would interpret, so plain keys also load verbatim with
+// loaders that do not unquote. With EnvKeyPrefixKey, every key of the minted
+// secret follows, and the Bucket may have any protocol.
+func renderEnv(in Input) ([]File, error) {
+	prefix, exportKeys := in.Attributes[adapter.EnvKeyPrefixKey]
+	if exportKeys {
+		var err error
+		if prefix, err = adapter.ParseEnvKeyPrefix(prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	var kv []string
+	switch proto := in.Bucket.Spec.Protocol; {
+	case proto.S3 != nil:
+		key, err := accessKeyFrom(in.Secret)
+		if err != nil {
+			return nil, err
+		}
+		kv = []string{
+			"AWS_ACCESS_KEY_ID", key.id,
+			"AWS_SECRET_ACCESS_KEY", key.secret,
+			"AWS_SESSION_TOKEN", key.sessionToken,
+			"AWS_ENDPOINT_URL", proto.S3.Endpoint,
+			"AWS_REGION", proto.S3.Region,
+			"AWS_DEFAULT_REGION", proto.S3.Region,
+			"COSI_BUCKET_NAME", proto.S3.BucketName,
+		}
+	case proto.AzureBlob != nil:
+		conn, err := azureConnectionString(in)
+		if err != nil {
+			return nil, err
+		}
+		kv = []string{
+			"AZURE_STORAGE_CONNECTION_STRING", conn,
+			"AZURE_STORAGE_ACCOUNT", proto.AzureBlob.StorageAccount,
+			"COSI_BUCKET_NAME", proto.AzureBlob.ContainerName,
+		}
+	case !exportKeys:
+		return nil, fmt.Errorf(util.ErrorTemplateProtocolMismatch, "s3 or azureBlob")
+	}
+
+	if exportKeys {
+		set := map[string]bool{}
+		for i := 0; i+1 < len(kv); i += 2 {
+			if kv[i+1] != "" {
+				set[kv[i]] = true
+			}
+		}
+		keys := make([]string, 0, len(in.Secret.Data))
+		for key := range in.Secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := prefix + envName(key)
+			if set[name] {
+				return nil, fmt.Errorf(util.ErrorTemplateEnvNameConflict, key, name)
+			}
+			set[name] = true
+			kv = append(kv, name, string(in.Secret.Data[key]))
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] != "" {
+			fmt.Fprintf(&b, "%s=%s\n", kv[i], envQuote(kv[i+1]))
+		}
+	}
+	return []File{{Name: envFileName, Data: []byte(b.String()), Mode: secretFileMode}}, nil
+}
+
+// envName turns a secret key into the rest of an environment variable name:
+// words of camel case keys are separated by _, other characters than letters
+// and digits replaced by _, and the result upper cased. accessKeyID becomes
+// ACCESS_KEY_ID and azure.sas-token AZURE_SAS_TOKEN.
+func envName(key string) string {
+	var b strings.Builder
+	var prev rune
+	for _, r := range key {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			if prev >= 'a' && prev <= 'z' || prev >= '0' && prev <= '9' {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		case r >= 'a' && r <= 'z':
+			b.WriteRune(r - 'a' + 'A')
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+// envQuote single quotes v unless it only holds characters a shell leaves alone.
+func envQuote(v string) string {
+	safe := strings.IndexFunc(v, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-.,:/+=@%", r))
+	}) < 0
+	if safe {
+		return v
+	}
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Bucket annotations carrying lifecycle hints. Provisioners or administrators
+// set them; the adapter only passes them on.
+const (
+	VersioningAnnotation      = "cosi.objectstorage.k8s.io/versioning"
+	RetentionClassAnnotation  = "cosi.objectstorage.k8s.io/retention-class"
+	RetentionPeriodAnnotation = "cosi.objectstorage.k8s.io/retention-period"
+
+	// BucketMetadataFileName is the pod visible metadata file. It lives in the
+	// mounted directory, unlike the adapter's own metadata file next to it.
+	BucketMetadataFileName = adapter.BucketMetadataFileName
+)
+
+// Bucket annotations describing the event notifications of the bucket.
+// NotificationEventsAnnotation is a comma separated list.
+const (
+	NotificationTypeAnnotation     = "cosi.objectstorage.k8s.io/notification-type"
+	NotificationEndpointAnnotation = "cosi.objectstorage.k8s.io/notification-endpoint"
+	NotificationTopicAnnotation    = "cosi.objectstorage.k8s.io/notification-topic"
+	NotificationEventsAnnotation   = "cosi.objectstorage.k8s.io/notification-events"
+)
+
+// Lifecycle holds hints about how the bucket treats objects.
+type Lifecycle = adapter.Lifecycle
+
+// Notifications describes where the bucket publishes its events.
+type Notifications = adapter.Notifications
+
+// BucketMetadata is the content of BucketMetadataFileName.
+type BucketMetadata = adapter.BucketMetadata
+
+// metadataFile returns the pod visible metadata file, or nil if the bucket
+// carries neither lifecycle hints nor notification settings.
+func metadataFile(bkt *v1alpha1.Bucket) (*File, error) {
+	meta := BucketMetadata{Bucket: bkt.Name}
+	lc := Lifecycle{
+		Versioning:      bkt.Annotations[VersioningAnnotation],
+		RetentionClass:  bkt.Annotations[RetentionClassAnnotation],
+		RetentionPeriod: bkt.Annotations[RetentionPeriodAnnotation],
+	}
+	if lc != (Lifecycle{}) {
+		meta.Lifecycle = &lc
+	}
+	meta.Notifications = BucketNotifications(bkt)
+	if meta.Lifecycle == nil && meta.Notifications == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorFailedToMarshalBucketMetadata)
+	}
+	return &File{Name: BucketMetadataFileName, Data: data, Mode: configFileMode}, nil
+}
+
+// BucketNotifications returns the notification settings of bkt, or nil if it
+// has no endpoint or topic.
+func BucketNotifications(bkt *v1alpha1.Bucket) *Notifications {
+	n := &Notifications{
+		Type:     bkt.Annotations[NotificationTypeAnnotation],
+		Endpoint: bkt.Annotations[NotificationEndpointAnnotation],
+		Topic:    bkt.Annotations[NotificationTopicAnnotation],
+	}
+	if n.Endpoint == "" && n.Topic == "" {
+		return nil
+	}
+	for _, event := range strings.Split(bkt.Annotations[NotificationEventsAnnotation], ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			n.Events = append(n.Events, event)
+		}
+	}
+	return n
+}
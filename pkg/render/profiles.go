@@ -0,0 +1,46 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import "sort"
+
+// profiles maps a tool to the formats it needs to work from the mounted volume
+// without further configuration.
+var profiles = map[string][]string{
+	// AWS CLI and SDKs: point AWS_SHARED_CREDENTIALS_FILE and AWS_CONFIG_FILE at the mount.
+	"awscli": {FormatAWS},
+	// Spark with hadoop-aws: load spark-defaults.conf via --properties-file.
+	"spark": {FormatSpark},
+	// rclone: run with --config pointing at rclone.conf.
+	"rclone": {FormatRclone},
+	// s3cmd: run with -c pointing at .s3cfg.
+	"s3cmd": {FormatS3cmd},
+	// gsutil: point BOTO_CONFIG at .boto.
+	"gsutil": {FormatBoto},
+	// azcopy and Azure SDKs: read the connection string file.
+	"azcopy": {FormatAzure},
+}
+
+// Profiles returns the names of all profiles.
+func Profiles() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
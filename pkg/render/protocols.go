@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// protocolFiles writes every protocol of bkt to a file of its own, with a
+// manifest listing them, when bkt defines more than one. A Bucket served by a
+// gateway may, and the protocol file only holds the first.
+func protocolFiles(bkt *v1alpha1.Bucket) ([]File, error) {
+	blocks := protocol.Defined(bkt)
+	if len(blocks) < 2 {
+		return nil, nil
+	}
+
+	manifest := adapter.ProtocolManifest{}
+	files := make([]File, 0, len(blocks)+1)
+	for _, b := range blocks {
+		data, err := b.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Name: b.FileName, Data: data, Mode: configFileMode})
+		manifest.Protocols = append(manifest.Protocols, adapter.ProtocolManifestEntry{Protocol: b.Name, File: b.FileName})
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, errors.Wrap(err, util.WrapErrorMarshalProtocolFailed)
+	}
+	return append(files, File{Name: adapter.ProtocolManifestFileName, Data: data, Mode: configFileMode}), nil
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package render turns a Bucket and its minted secret into files in the
+// configuration formats of common object storage tools.
+//
+// Rendering is deterministic: unchanged inputs produce byte identical files in
+// the same order on every publish. Formats must not embed timestamps or depend
+// on map iteration order, so that checksums can be used to detect drift and a
+// rotation that changes nothing does not make applications reload.
+package render
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// Volume attributes selecting additional output formats.
+const (
+	// FormatKey is a comma separated list of formats to render.
+	FormatKey = adapter.FormatKey
+	// ProfileKey selects a curated set of formats for a common tool.
+	ProfileKey = adapter.ProfileKey
+)
+
+// Input is everything a format may render from.
+type Input struct {
+	Bucket *v1alpha1.Bucket
+	Secret *v1.Secret
+	// Attributes are the volume attributes of the publish request.
+	Attributes map[string]string
+}
+
+// File is a single rendered file, named relative to the volume root.
+type File struct {
+	Name string
+	Data []byte
+	Mode os.FileMode
+}
+
+// Format renders input into one or more files.
+type Format func(in Input) ([]File, error)
+
+var formats = map[string]Format{}
+
+// Register makes a format available under name. It panics if name is taken.
+func Register(name string, f Format) {
+	if _, ok := formats[name]; ok {
+		panic(fmt.Sprintf("render: format %q registered twice", name))
+	}
+	formats[name] = f
+}
+
+// Formats returns the names of all registered formats.
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveFormats returns the formats requested by the volume attributes: those
+// of the profile, if any, followed by those listed explicitly.
+func ResolveFormats(attrs map[string]string) ([]string, error) {
+	var requested []string
+	if name := attrs[ProfileKey]; name != "" {
+		profile, ok := profiles[name]
+		if !ok {
+			return nil, fmt.Errorf(util.ErrorTemplateUnknownProfile, name, strings.Join(Profiles(), ", "))
+		}
+		requested = append(requested, profile...)
+	}
+	for _, name := range strings.Split(attrs[FormatKey], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			requested = append(requested, name)
+		}
+	}
+
+	seen := map[string]bool{}
+	var resolved []string
+	for _, name := range requested {
+		if seen[name] {
+			continue
+		}
+		if _, ok := formats[name]; !ok {
+			return nil, fmt.Errorf(util.ErrorTemplateUnknownFormat, name, strings.Join(Formats(), ", "))
+		}
+		seen[name] = true
+		resolved = append(resolved, name)
+	}
+	return resolved, nil
+}
+
+// Render renders every named format, along with the bucket metadata file when
+// the bucket carries lifecycle hints and a file per protocol when it defines
+// several, and returns the files sorted by name. Two
+// formats producing the same file name is an error rather than a silent
+// overwrite.
+func Render(names []string, in Input) ([]File, error) {
+	var files []File
+	owner := map[string]string{}
+
+	meta, err := metadataFile(in.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if meta != nil {
+		files = append(files, *meta)
+		owner[meta.Name] = "metadata"
+	}
+
+	protocols, err := protocolFiles(in.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range protocols {
+		files = append(files, file)
+		owner[file.Name] = "protocols"
+	}
+
+	for _, name := range names {
+		f, ok := formats[name]
+		if !ok {
+			return nil, fmt.Errorf(util.ErrorTemplateUnknownFormat, name, strings.Join(Formats(), ", "))
+		}
+		rendered, err := f(in)
+		if err != nil {
+			return nil, fmt.Errorf(util.ErrorTemplateRenderFailed, name, err)
+		}
+		for _, file := range rendered {
+			if prev, ok := owner[file.Name]; ok {
+				return nil, fmt.Errorf(util.ErrorTemplateFileConflict, file.Name, prev, name)
+			}
+			owner[file.Name] = name
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
@@ -0,0 +1,563 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/testutil"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestResolveFormats(t *testing.T) {
+	type want struct {
+		formats []string
+		err     error
+	}
+
+	cases := map[string]struct {
+		attrs map[string]string
+		want
+	}{
+		"None": {
+			attrs: map[string]string{},
+		},
+		"Profile": {
+			attrs: map[string]string{ProfileKey: "gsutil"},
+			want:  want{formats: []string{FormatBoto}},
+		},
+		"ProfileAndFormats": {
+			attrs: map[string]string{ProfileKey: "awscli", FormatKey: "rclone, aws,spark"},
+			want:  want{formats: []string{FormatAWS, FormatRclone, FormatSpark}},
+		},
+		"UnknownProfile": {
+			attrs: map[string]string{ProfileKey: "s3fs"},
+			want:  want{err: fmt.Errorf(util.ErrorTemplateUnknownProfile, "s3fs", "awscli, azcopy, gsutil, rclone, s3cmd, spark")},
+		},
+		"UnknownFormat": {
+			attrs: map[string]string{FormatKey: "toml"},
+			want:  want{err: fmt.Errorf(util.ErrorTemplateUnknownFormat, "toml", strings.Join(Formats(), ", "))},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			formats, err := ResolveFormats(tc.attrs)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.formats, formats); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSelectFormats(t *testing.T) {
+	type want struct {
+		formats []string
+		source  FormatSource
+		err     error
+	}
+
+	class, empty, unknown := "rclone", "", "toml"
+	azure := testutil.WithProtocol(v1alpha1.Protocol{AzureBlob: &v1alpha1.AzureProtocol{ContainerName: "container"}})
+
+	cases := map[string]struct {
+		attrs    map[string]string
+		opts     []testutil.GraphOption
+		defaults FormatDefaults
+		want
+	}{
+		"Volume": {
+			attrs:    map[string]string{FormatKey: FormatSpark},
+			defaults: FormatDefaults{BucketClass: &class, Adapter: []string{FormatEnv}, Protocol: true},
+			want:     want{formats: []string{FormatSpark}, source: FormatSourceVolume},
+		},
+		"VolumeOptsOut": {
+			attrs:    map[string]string{FormatKey: ""},
+			defaults: FormatDefaults{BucketClass: &class, Protocol: true},
+			want:     want{source: FormatSourceVolume},
+		},
+		"BucketClass": {
+			defaults: FormatDefaults{BucketClass: &class, Adapter: []string{FormatEnv}, Protocol: true},
+			want:     want{formats: []string{FormatRclone}, source: FormatSourceBucketClass},
+		},
+		"EmptyBucketClass": {
+			defaults: FormatDefaults{BucketClass: &empty, Adapter: []string{FormatEnv}},
+			want:     want{source: FormatSourceBucketClass},
+		},
+		"UnknownBucketClassFormat": {
+			defaults: FormatDefaults{BucketClass: &unknown},
+			want: want{source: FormatSourceBucketClass,
+				err: fmt.Errorf(util.ErrorTemplateUnknownFormat, "toml", "aws, aws-credential-process, azure, boto, combined, env, google-credentials, rclone, s3cmd, spark, yaml")},
+		},
+		"Adapter": {
+			defaults: FormatDefaults{Adapter: []string{FormatEnv}, Protocol: true},
+			want:     want{formats: []string{FormatEnv}, source: FormatSourceAdapter},
+		},
+		"ProtocolS3": {
+			defaults: FormatDefaults{Protocol: true},
+			want:     want{formats: []string{FormatAWS}, source: FormatSourceProtocol},
+		},
+		"ProtocolAzure": {
+			opts:     []testutil.GraphOption{azure},
+			defaults: FormatDefaults{Protocol: true},
+			want:     want{formats: []string{FormatAzure}, source: FormatSourceProtocol},
+		},
+		"ProtocolDisabled": {
+			want: want{source: FormatSourceProtocol},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := testutil.NewGraph("ns", "app", tc.opts...)
+
+			formats, source, err := SelectFormats(tc.attrs, g.Bucket, tc.defaults)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.formats, formats); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.source, source); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	type want struct {
+		files map[string]string
+		err   error
+	}
+
+	cases := map[string]struct {
+		formats []string
+		graph   *testutil.Graph
+		attrs   map[string]string
+		want
+	}{
+		"AWS": {
+			formats: []string{FormatAWS},
+			graph:   testutil.NewGraph("ns", "app"),
+			want: want{files: map[string]string{
+				awsCredentialsFileName: "[default]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = secret\n",
+				awsConfigFileName:      "[default]\nregion = us-east-1\nendpoint_url = https://s3.example.com\n",
+			}},
+		},
+		"AWSNamedProfile": {
+			formats: []string{FormatAWS},
+			graph:   testutil.NewGraph("ns", "app"),
+			attrs:   map[string]string{adapter.AWSProfileKey: "cosi"},
+			want: want{files: map[string]string{
+				awsCredentialsFileName: "[cosi]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = secret\n",
+				awsConfigFileName:      "[profile cosi]\nregion = us-east-1\nendpoint_url = https://s3.example.com\n",
+			}},
+		},
+		"AWSInvalidProfile": {
+			formats: []string{FormatAWS},
+			graph:   testutil.NewGraph("ns", "app"),
+			attrs:   map[string]string{adapter.AWSProfileKey: "a]\n[b"},
+			want: want{err: fmt.Errorf(util.ErrorTemplateRenderFailed, FormatAWS,
+				fmt.Errorf(util.ErrorTemplateInvalidAWSProfile, "a]\n[b"))},
+		},
+		"AWSCredentialProcess": {
+			formats: []string{FormatAWSProcess},
+			graph:   testutil.NewGraph("ns", "app"),
+			attrs:   map[string]string{adapter.MountPathKey: "/var/run/cosi/"},
+			want: want{files: map[string]string{
+				awsConfigFileName: "[default]\nregion = us-east-1\nendpoint_url = https://s3.example.com\ncredential_process = /var/run/cosi/cosi-credential-helper --socket /var/run/cosi/credential-helper.sock\n",
+			}},
+		},
+		"AWSCredentialProcessRelativeMountPath": {
+			formats: []string{FormatAWSProcess},
+			graph:   testutil.NewGraph("ns", "app"),
+			attrs:   map[string]string{adapter.MountPathKey: "cosi"},
+			want: want{err: fmt.Errorf(util.ErrorTemplateRenderFailed, FormatAWSProcess,
+				fmt.Errorf(util.ErrorTemplateInvalidMountPath, "cosi"))},
+		},
+		"Rclone": {
+			formats: []string{FormatRclone},
+			graph:   testutil.NewGraph("ns", "app"),
+			want: want{files: map[string]string{
+				rcloneConfigFileName: "[cosi]\ntype = s3\nprovider = Other\naccess_key_id = AKIAEXAMPLE\nsecret_access_key = secret\nendpoint = https://s3.example.com\nregion = us-east-1\n",
+			}},
+		},
+		"S3cmd": {
+			formats: []string{FormatS3cmd},
+			graph: testutil.NewGraph("ns", "app", func(g *testutil.Graph) {
+				g.Bucket.Spec.Protocol.S3.Endpoint = "http://minio.example.com:9000"
+				g.Bucket.Spec.Protocol.S3.SignatureVersion = "S3V2"
+			}),
+			want: want{files: map[string]string{
+				s3cmdConfigFileName: "[default]\naccess_key = AKIAEXAMPLE\nsecret_key = secret\nhost_base = minio.example.com:9000\nhost_bucket = minio.example.com:9000\nbucket_location = us-east-1\nuse_https = False\nsignature_v2 = True\n",
+			}},
+		},
+		"S3cmdAWS": {
+			formats: []string{FormatS3cmd},
+			graph: testutil.NewGraph("ns", "app", func(g *testutil.Graph) {
+				g.Bucket.Spec.Protocol.S3.Endpoint = ""
+			}),
+			want: want{files: map[string]string{
+				s3cmdConfigFileName: "[default]\naccess_key = AKIAEXAMPLE\nsecret_key = secret\nhost_base = s3.amazonaws.com\nhost_bucket = %(bucket)s.s3.amazonaws.com\nbucket_location = us-east-1\nuse_https = True\nsignature_v2 = False\n",
+			}},
+		},
+		"RcloneAzureConnectionString": {
+			formats: []string{FormatRclone},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{AzureBlob: &v1alpha1.AzureProtocol{StorageAccount: "acct", ContainerName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"connectionString": []byte("BlobEndpoint=https://acct.blob.core.windows.net/;SharedAccessSignature=sv=2020&sig=abc")})),
+			want: want{files: map[string]string{
+				rcloneConfigFileName: "[cosi]\ntype = azureblob\naccount = acct\nendpoint = https://acct.blob.core.windows.net\nsas_url = https://acct.blob.core.windows.net/app?sv=2020&sig=abc\n",
+			}},
+		},
+		"RcloneGCSServiceAccount": {
+			formats: []string{FormatRclone},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{GCS: &v1alpha1.GCSProtocol{ProjectID: "proj", BucketName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"serviceAccountKey": []byte("{\n  \"type\": \"service_account\"\n}\n")})),
+			want: want{files: map[string]string{
+				rcloneConfigFileName: "[cosi]\ntype = google cloud storage\nproject_number = proj\nservice_account_credentials = {\"type\":\"service_account\"}\nbucket_policy_only = true\n",
+			}},
+		},
+		"RcloneGCSHMAC": {
+			formats: []string{FormatRclone},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{GCS: &v1alpha1.GCSProtocol{ProjectID: "proj", BucketName: "app"}})),
+			want: want{files: map[string]string{
+				rcloneConfigFileName: "[cosi]\ntype = s3\nprovider = GCS\naccess_key_id = AKIAEXAMPLE\nsecret_access_key = secret\nendpoint = https://storage.googleapis.com\n",
+			}},
+		},
+		"BotoHMAC": {
+			formats: []string{FormatBoto},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{GCS: &v1alpha1.GCSProtocol{ProjectID: "proj", BucketName: "app"}})),
+			want: want{files: map[string]string{
+				botoConfigFileName: "[Credentials]\ngs_access_key_id = AKIAEXAMPLE\ngs_secret_access_key = secret\n\n[GSUtil]\ndefault_project_id = proj\n",
+			}},
+		},
+		"BotoServiceAccount": {
+			formats: []string{FormatBoto},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{GCS: &v1alpha1.GCSProtocol{ProjectID: "proj", BucketName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"serviceAccountKey": []byte(`{"type":"service_account"}`)})),
+			attrs: map[string]string{adapter.MountPathKey: "/cosi"},
+			want: want{files: map[string]string{
+				gcsServiceAccountFileName: `{"type":"service_account"}`,
+				botoConfigFileName:        "[Credentials]\ngs_service_key_file = /cosi/gcs_service_account.json\n\n[GSUtil]\ndefault_project_id = proj\n",
+			}},
+		},
+		"BotoServiceAccountWithoutMountPath": {
+			formats: []string{FormatBoto},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{GCS: &v1alpha1.GCSProtocol{ProjectID: "proj", BucketName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"serviceAccountKey": []byte(`{"type":"service_account"}`)})),
+			want: want{err: fmt.Errorf(util.ErrorTemplateRenderFailed, FormatBoto,
+				fmt.Errorf(util.ErrorTemplateInvalidMountPath, ""))},
+		},
+		"GoogleCredentialsServiceAccount": {
+			formats: []string{FormatGoogleCredentials},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{GCS: &v1alpha1.GCSProtocol{ProjectID: "proj", BucketName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"serviceAccountKey": []byte(`{"type":"service_account"}`)})),
+			want: want{files: map[string]string{
+				googleCredentialsFileName: `{"type":"service_account"}`,
+			}},
+		},
+		"GoogleCredentialsExternalAccount": {
+			formats: []string{FormatGoogleCredentials},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{GCS: &v1alpha1.GCSProtocol{ProjectID: "proj", BucketName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"externalAccount": []byte(`{"type":"external_account"}`)})),
+			want: want{files: map[string]string{
+				googleCredentialsFileName: `{"type":"external_account"}`,
+			}},
+		},
+		"GoogleCredentialsWrongType": {
+			formats: []string{FormatGoogleCredentials},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{GCS: &v1alpha1.GCSProtocol{ProjectID: "proj", BucketName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"serviceAccountKey": []byte(`{"type":"authorized_user"}`)})),
+			want: want{err: fmt.Errorf(util.ErrorTemplateRenderFailed, FormatGoogleCredentials,
+				fmt.Errorf(util.ErrorTemplateGoogleCredentialType, "authorized_user"))},
+		},
+		"CombinedS3": {
+			formats: []string{FormatCombined},
+			graph:   testutil.NewGraph("ns", "app"),
+			want: want{files: map[string]string{
+				adapter.ConnectionFileName: `{"version":"v1","protocol":"s3","bucket":"app","endpoint":"https://s3.example.com","region":"us-east-1","credentials":{"accessKeyID":"AKIAEXAMPLE","accessSecretKey":"secret"}}`,
+			}},
+		},
+		"CombinedAzure": {
+			formats: []string{FormatCombined},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{AzureBlob: &v1alpha1.AzureProtocol{StorageAccount: "acct", ContainerName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"accountKey": []byte("key")})),
+			want: want{files: map[string]string{
+				adapter.ConnectionFileName: `{"version":"v1","protocol":"azureBlob","bucket":"app","endpoint":"https://acct.blob.core.windows.net","parameters":{"storageAccount":"acct"},"credentials":{"accountKey":"key"}}`,
+			}},
+		},
+		"YAML": {
+			formats: []string{FormatYAML},
+			graph: testutil.NewGraph("ns", "app", testutil.WithSecretData(map[string][]byte{
+				"accessKeyID":     []byte("AKIAEXAMPLE"),
+				"accessSecretKey": []byte("no"),
+			}), func(g *testutil.Graph) {
+				g.Bucket.Spec.Protocol.S3.SignatureVersion = "S3V4"
+			}),
+			want: want{files: map[string]string{
+				adapter.ProtocolYAMLFileName:    "\"bucketName\": \"app\"\n\"endpoint\": \"https://s3.example.com\"\n\"region\": \"us-east-1\"\n\"signatureVersion\": \"S3V4\"\n",
+				adapter.CredentialsYAMLFileName: "\"accessKeyID\": \"AKIAEXAMPLE\"\n\"accessSecretKey\": \"no\"\n",
+			}},
+		},
+		"AzureAccountKey": {
+			formats: []string{FormatAzure},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{AzureBlob: &v1alpha1.AzureProtocol{StorageAccount: "acct", ContainerName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"accountKey": []byte("key")})),
+			want: want{files: map[string]string{
+				azureConnectionFileName: "DefaultEndpointsProtocol=https;AccountName=acct;AccountKey=key;EndpointSuffix=core.windows.net",
+				azureAccountKeyFileName: "key",
+			}},
+		},
+		"AzureSASToken": {
+			formats: []string{FormatAzure},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{AzureBlob: &v1alpha1.AzureProtocol{StorageAccount: "acct", ContainerName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"sasToken": []byte("?sv=2020&sig=abc")})),
+			want: want{files: map[string]string{
+				azureConnectionFileName: "BlobEndpoint=https://acct.blob.core.windows.net;SharedAccessSignature=sv=2020&sig=abc",
+				azureSASTokenFileName:   "sv=2020&sig=abc",
+			}},
+		},
+		"AzureConnectionStringSplit": {
+			formats: []string{FormatAzure},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{AzureBlob: &v1alpha1.AzureProtocol{StorageAccount: "acct", ContainerName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"connectionString": []byte("DefaultEndpointsProtocol=https;AccountName=acct;AccountKey=a2V5==;EndpointSuffix=core.windows.net")})),
+			want: want{files: map[string]string{
+				azureConnectionFileName: "DefaultEndpointsProtocol=https;AccountName=acct;AccountKey=a2V5==;EndpointSuffix=core.windows.net",
+				azureAccountKeyFileName: "a2V5==",
+			}},
+		},
+		"EnvS3": {
+			formats: []string{FormatEnv},
+			graph:   testutil.NewGraph("ns", "app"),
+			want: want{files: map[string]string{
+				envFileName: "AWS_ACCESS_KEY_ID=AKIAEXAMPLE\nAWS_SECRET_ACCESS_KEY=secret\nAWS_ENDPOINT_URL=https://s3.example.com\nAWS_REGION=us-east-1\nAWS_DEFAULT_REGION=us-east-1\nCOSI_BUCKET_NAME=app\n",
+			}},
+		},
+		"EnvAzureQuoted": {
+			formats: []string{FormatEnv},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{AzureBlob: &v1alpha1.AzureProtocol{StorageAccount: "acct", ContainerName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"accountKey": []byte("key")})),
+			want: want{files: map[string]string{
+				envFileName: "AZURE_STORAGE_CONNECTION_STRING='DefaultEndpointsProtocol=https;AccountName=acct;AccountKey=key;EndpointSuffix=core.windows.net'\nAZURE_STORAGE_ACCOUNT=acct\nCOSI_BUCKET_NAME=app\n",
+			}},
+		},
+		"EnvKeyPrefix": {
+			formats: []string{FormatEnv},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithSecretData(map[string][]byte{"accessKeyID": []byte("AKIAEXAMPLE"), "accessSecretKey": []byte("secret"), "vendor.tier": []byte("gold")})),
+			attrs: map[string]string{adapter.EnvKeyPrefixKey: "COSI_"},
+			want: want{files: map[string]string{
+				envFileName: "AWS_ACCESS_KEY_ID=AKIAEXAMPLE\nAWS_SECRET_ACCESS_KEY=secret\nAWS_ENDPOINT_URL=https://s3.example.com\nAWS_REGION=us-east-1\nAWS_DEFAULT_REGION=us-east-1\nCOSI_BUCKET_NAME=app\n" +
+					"COSI_ACCESS_KEY_ID=AKIAEXAMPLE\nCOSI_ACCESS_SECRET_KEY=secret\nCOSI_VENDOR_TIER=gold\n",
+			}},
+		},
+		"EnvKeyPrefixAnyProtocol": {
+			formats: []string{FormatEnv},
+			graph: testutil.NewGraph("ns", "app",
+				testutil.WithProtocol(v1alpha1.Protocol{GCS: &v1alpha1.GCSProtocol{ProjectID: "proj", BucketName: "app"}}),
+				testutil.WithSecretData(map[string][]byte{"serviceAccount": []byte("sa@proj")})),
+			attrs: map[string]string{adapter.EnvKeyPrefixKey: "GCS_"},
+			want: want{files: map[string]string{
+				envFileName: "GCS_SERVICE_ACCOUNT=sa@proj\n",
+			}},
+		},
+		"EnvKeyPrefixConflict": {
+			formats: []string{FormatEnv},
+			graph:   testutil.NewGraph("ns", "app"),
+			attrs:   map[string]string{adapter.EnvKeyPrefixKey: "AWS_"},
+			want: want{err: fmt.Errorf(util.ErrorTemplateRenderFailed, FormatEnv,
+				fmt.Errorf(util.ErrorTemplateEnvNameConflict, "accessKeyID", "AWS_ACCESS_KEY_ID"))},
+		},
+		"LifecycleHints": {
+			graph: testutil.NewGraph("ns", "app", func(g *testutil.Graph) {
+				g.Bucket.Annotations = map[string]string{
+					VersioningAnnotation:     "enabled",
+					RetentionClassAnnotation: "compliance",
+				}
+			}),
+			want: want{files: map[string]string{
+				BucketMetadataFileName: `{"bucket":"app","lifecycle":{"versioning":"enabled","retentionClass":"compliance"}}`,
+			}},
+		},
+		"Notifications": {
+			graph: testutil.NewGraph("ns", "app", func(g *testutil.Graph) {
+				g.Bucket.Annotations = map[string]string{
+					NotificationTypeAnnotation:   "sns",
+					NotificationTopicAnnotation:  "arn:aws:sns:us-east-1:123456789012:app-events",
+					NotificationEventsAnnotation: "s3:ObjectCreated:*, s3:ObjectRemoved:*",
+				}
+			}),
+			want: want{files: map[string]string{
+				BucketMetadataFileName: `{"bucket":"app","notifications":{"type":"sns","topic":"arn:aws:sns:us-east-1:123456789012:app-events","events":["s3:ObjectCreated:*","s3:ObjectRemoved:*"]}}`,
+			}},
+		},
+		"MultipleProtocols": {
+			graph: testutil.NewGraph("ns", "app", func(g *testutil.Graph) {
+				g.Bucket.Spec.Protocol.S3.SignatureVersion = "S3V4"
+				g.Bucket.Spec.Protocol.AzureBlob = &v1alpha1.AzureProtocol{ContainerName: "app", StorageAccount: "acct"}
+			}),
+			want: want{files: map[string]string{
+				adapter.S3ProtocolFileName:       `{"endpoint":"https://s3.example.com","bucketName":"app","region":"us-east-1","signatureVersion":"S3V4"}`,
+				adapter.AzureProtocolFileName:    `{"containerName":"app","storageAccount":"acct"}`,
+				adapter.ProtocolManifestFileName: `{"protocols":[{"protocol":"s3","file":"s3.json"},{"protocol":"azureBlob","file":"azure.json"}]}`,
+			}},
+		},
+		"ProtocolMismatch": {
+			formats: []string{FormatBoto},
+			graph:   testutil.NewGraph("ns", "app"),
+			want: want{err: fmt.Errorf(util.ErrorTemplateRenderFailed, FormatBoto,
+				fmt.Errorf(util.ErrorTemplateProtocolMismatch, "gcs"))},
+		},
+		"MissingKeys": {
+			formats: []string{FormatAWS},
+			graph:   testutil.NewGraph("ns", "app", testutil.WithSecretData(map[string][]byte{})),
+			want:    want{err: fmt.Errorf(util.ErrorTemplateRenderFailed, FormatAWS, util.ErrorMissingAccessKey)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			files, err := Render(tc.formats, Input{Bucket: tc.graph.Bucket, Secret: tc.graph.Secret, Attributes: tc.attrs})
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+
+			var got map[string]string
+			for _, f := range files {
+				if got == nil {
+					got = map[string]string{}
+				}
+				got[f.Name] = string(f.Data)
+			}
+			if diff := cmp.Diff(tc.want.files, got); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRenderIsStable(t *testing.T) {
+	graph := testutil.NewGraph("ns", "app", testutil.WithSecretData(map[string][]byte{
+		"accessKeyID":       []byte("AKIAEXAMPLE"),
+		"AWS_ACCESS_KEY_ID": []byte("AKIAOTHER"),
+		"accessSecretKey":   []byte("secret"),
+		"sessionToken":      []byte("token"),
+	}))
+	formats := []string{FormatSpark, FormatRclone, FormatAWS}
+
+	first, err := Render(formats, Input{Bucket: graph.Bucket, Secret: graph.Secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		got, err := Render(formats, Input{Bucket: graph.Bucket, Secret: graph.Secret.DeepCopy()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(first, got); diff != "" {
+			t.Errorf("r: -want, +got:\n%s", diff)
+		}
+	}
+
+	var names []string
+	for _, f := range first {
+		names = append(names, f.Name)
+	}
+	want := []string{awsConfigFileName, awsCredentialsFileName, rcloneConfigFileName, sparkDefaultsFileName}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("r: -want, +got:\n%s", diff)
+	}
+}
+
+func TestCredentialExpiration(t *testing.T) {
+	type want struct {
+		expiry string
+		ok     bool
+	}
+
+	cases := map[string]struct {
+		data        map[string][]byte
+		annotations map[string]string
+		want
+	}{
+		"Permanent": {
+			data: map[string][]byte{"accessKeyID": []byte("AKIAEXAMPLE")},
+		},
+		"DataKey": {
+			data:        map[string][]byte{"expiration": []byte("2021-06-01T12:00:00Z")},
+			annotations: map[string]string{ExpirationAnnotation: "2021-06-02T12:00:00Z"},
+			want:        want{expiry: "2021-06-01T12:00:00Z", ok: true},
+		},
+		"Annotation": {
+			annotations: map[string]string{ExpirationAnnotation: "2021-06-02T12:00:00Z"},
+			want:        want{expiry: "2021-06-02T12:00:00Z", ok: true},
+		},
+		"SASToken": {
+			data: map[string][]byte{"sasToken": []byte("?sv=2020-08-04&se=2021-06-03T12%3A00%3A00Z&sp=rl&sig=abc")},
+			want: want{expiry: "2021-06-03T12:00:00Z", ok: true},
+		},
+		"Unparsable": {
+			data: map[string][]byte{"expiration": []byte("tomorrow")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := testutil.NewGraph("ns", "app", testutil.WithSecretData(tc.data))
+			g.Secret.Annotations = tc.annotations
+
+			expiry, ok := CredentialExpiration(g.Secret)
+
+			got := want{ok: ok}
+			if ok {
+				got.expiry = expiry.UTC().Format(time.RFC3339)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
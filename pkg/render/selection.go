@@ -0,0 +1,96 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"strings"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+)
+
+// BucketClassFormatParameter is the BucketClass parameter listing, like
+// FormatKey, the formats of volumes whose attributes select none.
+const BucketClassFormatParameter = "csi-adapter.objectstorage.k8s.io/format"
+
+// FormatSource is the setting the formats of a volume were selected by.
+type FormatSource string
+
+// Format sources, from the highest precedence to the lowest.
+const (
+	FormatSourceVolume      FormatSource = "volume-attribute"
+	FormatSourceBucketClass FormatSource = "bucket-class"
+	FormatSourceAdapter     FormatSource = "adapter-config"
+	FormatSourceProtocol    FormatSource = "protocol-default"
+)
+
+// FormatDefaults are the formats of volumes that set neither FormatKey nor
+// ProfileKey.
+type FormatDefaults struct {
+	// BucketClass holds the BucketClassFormatParameter of the Bucket's class,
+	// if it has one.
+	BucketClass *string
+	// Adapter is the default of the adapter, if configured.
+	Adapter []string
+	// Protocol selects the formats of the native tools of the Bucket's
+	// protocol. Otherwise the protocol default is no formats.
+	Protocol bool
+}
+
+// protocolFormats are the protocol defaults. They only need the credentials
+// every provisioner mints for the protocol.
+var protocolFormats = map[string][]string{
+	adapter.ConnectionProtocolS3:        {FormatAWS},
+	adapter.ConnectionProtocolAzureBlob: {FormatAzure},
+	adapter.ConnectionProtocolGCS:       {FormatBoto},
+}
+
+// SelectFormats returns the formats of a volume and the source that selected
+// them: the volume attributes, then the BucketClass, the adapter and the
+// protocol of bkt, in that order. A source applies as soon as it is set, even
+// to no formats, so a volume setting an empty FormatKey opts out of every
+// default.
+func SelectFormats(attrs map[string]string, bkt *v1alpha1.Bucket, d FormatDefaults) ([]string, FormatSource, error) {
+	_, hasFormat := attrs[FormatKey]
+	_, hasProfile := attrs[ProfileKey]
+	switch {
+	case hasFormat || hasProfile:
+		formats, err := ResolveFormats(attrs)
+		return formats, FormatSourceVolume, err
+	case d.BucketClass != nil:
+		formats, err := ResolveFormats(map[string]string{FormatKey: *d.BucketClass})
+		return formats, FormatSourceBucketClass, err
+	case d.Adapter != nil:
+		formats, err := ResolveFormats(map[string]string{FormatKey: strings.Join(d.Adapter, ",")})
+		return formats, FormatSourceAdapter, err
+	case d.Protocol:
+		return protocolFormats[protocolName(bkt)], FormatSourceProtocol, nil
+	default:
+		return nil, FormatSourceProtocol, nil
+	}
+}
+
+// protocolName returns the name of the protocol of bkt, or "" if it has none.
+func protocolName(bkt *v1alpha1.Bucket) string {
+	block, err := protocol.First(bkt)
+	if err != nil {
+		return ""
+	}
+	return block.Name
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/protocol"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+// renderYAML writes the protocol connection and credentials files again as
+// YAML, for workloads templating their configuration from YAML documents.
+func renderYAML(in Input) ([]File, error) {
+	block, err := protocol.First(in.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	data, err := block.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	protocolYAML, err := jsonToYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := util.ParseData(in.Secret)
+	if err != nil {
+		return nil, err
+	}
+	credsYAML, err := jsonToYAML(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	return []File{
+		{Name: adapter.ProtocolYAMLFileName, Data: protocolYAML, Mode: configFileMode},
+		{Name: adapter.CredentialsYAMLFileName, Data: credsYAML, Mode: secretFileMode},
+	}, nil
+}
+
+// jsonToYAML converts a JSON document to block style YAML. Strings are always
+// double quoted, which YAML reads the same as JSON, so values such as "no" or
+// "0123" keep their type.
+func jsonToYAML(data []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	writeYAML(&b, v, 0)
+	return b.Bytes(), nil
+}
+
+func writeYAML(b *bytes.Buffer, v interface{}, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			b.WriteString(pad + "{}\n")
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteString(pad + yamlScalar(k) + ":")
+			writeYAMLValue(b, v[k], indent+1)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString(pad + "[]\n")
+			return
+		}
+		for _, e := range v {
+			b.WriteString(pad + "-")
+			writeYAMLValue(b, e, indent+1)
+		}
+	default:
+		b.WriteString(pad + yamlScalar(v) + "\n")
+	}
+}
+
+// writeYAMLValue writes v after a key or sequence entry already on the line.
+func writeYAMLValue(b *bytes.Buffer, v interface{}, indent int) {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		if len(c) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+	case []interface{}:
+		if len(c) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+	default:
+		b.WriteString(" " + yamlScalar(v) + "\n")
+		return
+	}
+	b.WriteString("\n")
+	writeYAML(b, v, indent)
+}
+
+func yamlScalar(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		q, _ := json.Marshal(v)
+		return string(q)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return "null"
+	}
+}
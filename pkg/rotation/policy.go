@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rotation
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+)
+
+// Volume attributes overriding the node's rotation defaults for one volume.
+const (
+	// ModeKey is one of "enabled", "disabled" or "on-change".
+	ModeKey = adapter.RotationKey
+	// RefreshIntervalKey is a duration, such as "15m", after which the volume's
+	// files are re-rendered even if no change was observed.
+	RefreshIntervalKey = adapter.RefreshIntervalKey
+
+	// MinRefreshInterval keeps a single volume from hammering the API server.
+	MinRefreshInterval = adapter.MinRefreshInterval
+)
+
+// Mode selects when the files of a published volume are rewritten.
+type Mode = adapter.RotationMode
+
+const (
+	// ModeEnabled rewrites files on any observed change and on every refresh interval.
+	ModeEnabled = adapter.RotationEnabled
+	// ModeDisabled never rewrites files after publish.
+	ModeDisabled = adapter.RotationDisabled
+	// ModeOnChange rewrites files only when the minted secret changes.
+	ModeOnChange = adapter.RotationOnChange
+)
+
+// Policy is the rotation behaviour of a single volume. It is stored in the
+// volume's metadata at publish time so later changes to the node defaults do
+// not alter volumes already in use.
+type Policy struct {
+	Mode            Mode            `json:"mode,omitempty"`
+	RefreshInterval metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// Rotates reports whether the volume's files may be rewritten after publish.
+func (p Policy) Rotates() bool {
+	return p.Mode != ModeDisabled
+}
+
+// Refreshes reports whether the volume is re-rendered on a timer, and how often.
+func (p Policy) Refreshes() (time.Duration, bool) {
+	if p.Mode != ModeEnabled || p.RefreshInterval.Duration == 0 {
+		return 0, false
+	}
+	return p.RefreshInterval.Duration, true
+}
+
+// ParsePolicy applies the volume attributes on top of defaults.
+func ParsePolicy(attrs map[string]string, defaults Policy) (Policy, error) {
+	p := defaults
+	if p.Mode == "" {
+		p.Mode = ModeEnabled
+	}
+
+	if v, ok := attrs[ModeKey]; ok {
+		mode, err := adapter.ParseRotationMode(v)
+		if err != nil {
+			return Policy{}, err
+		}
+		p.Mode = mode
+	}
+
+	if v, ok := attrs[RefreshIntervalKey]; ok {
+		d, err := adapter.ParseRefreshInterval(v)
+		if err != nil {
+			return Policy{}, err
+		}
+		p.RefreshInterval = metav1.Duration{Duration: d}
+	}
+
+	if p.Mode != ModeEnabled {
+		p.RefreshInterval = metav1.Duration{}
+	}
+	return p, nil
+}
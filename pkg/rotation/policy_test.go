@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rotation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestParsePolicy(t *testing.T) {
+	type want struct {
+		policy Policy
+		err    error
+	}
+
+	hourly := Policy{Mode: ModeEnabled, RefreshInterval: metav1.Duration{Duration: time.Hour}}
+
+	cases := map[string]struct {
+		attrs    map[string]string
+		defaults Policy
+		want
+	}{
+		"Defaults": {
+			attrs:    map[string]string{},
+			defaults: hourly,
+			want:     want{policy: hourly},
+		},
+		"UnsetDefaults": {
+			attrs: map[string]string{},
+			want:  want{policy: Policy{Mode: ModeEnabled}},
+		},
+		"Disabled": {
+			attrs:    map[string]string{ModeKey: "disabled"},
+			defaults: hourly,
+			want:     want{policy: Policy{Mode: ModeDisabled}},
+		},
+		"OnChange": {
+			attrs:    map[string]string{ModeKey: "on-change", RefreshIntervalKey: "5m"},
+			defaults: hourly,
+			want:     want{policy: Policy{Mode: ModeOnChange}},
+		},
+		"CustomInterval": {
+			attrs:    map[string]string{RefreshIntervalKey: "15m"},
+			defaults: hourly,
+			want:     want{policy: Policy{Mode: ModeEnabled, RefreshInterval: metav1.Duration{Duration: 15 * time.Minute}}},
+		},
+		"InvalidMode": {
+			attrs: map[string]string{ModeKey: "sometimes"},
+			want:  want{err: fmt.Errorf(util.ErrorTemplateInvalidRotationMode, "sometimes")},
+		},
+		"IntervalTooShort": {
+			attrs: map[string]string{RefreshIntervalKey: "1s"},
+			want:  want{err: fmt.Errorf(util.ErrorTemplateInvalidRefreshInterval, "1s", "must be at least 30s")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			policy, err := ParsePolicy(tc.attrs, tc.defaults)
+
+			if diff := cmp.Diff(tc.want.err, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.policy, policy); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
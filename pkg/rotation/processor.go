@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rotation processes credential changes for published volumes.
+package rotation
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// DefaultWorkers bounds how many volumes are refreshed at the same time.
+const DefaultWorkers = 4
+
+// Handler refreshes a single volume. It must read the current state itself, as
+// several change notifications for a volume may be coalesced into one call.
+type Handler func(ctx context.Context, volumeID string) error
+
+// Processor runs Handler for queued volumes on a bounded pool of workers.
+//
+// A volume is never processed by two workers at once, and a volume queued while
+// it is being processed is handled again once the current run completes. Volumes
+// are served in the order they were queued, so a burst of changes to many
+// volumes (e.g. a provider wide key rotation) is worked through fairly without
+// occupying more than the configured number of workers. Failed volumes are
+// retried with exponential backoff.
+type Processor struct {
+	queue   workqueue.RateLimitingInterface
+	handler Handler
+	workers int
+}
+
+func NewProcessor(workers int, handler Handler) *Processor {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	return &Processor{
+		queue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "rotation"),
+		handler: handler,
+		workers: workers,
+	}
+}
+
+// Enqueue schedules volumeID for processing. Repeated calls for a volume that
+// is still waiting are coalesced.
+func (p *Processor) Enqueue(volumeID string) {
+	p.queue.Add(volumeID)
+}
+
+// Forget drops any pending retries for volumeID, e.g. after it was unpublished.
+func (p *Processor) Forget(volumeID string) {
+	p.queue.Forget(volumeID)
+}
+
+// Run processes volumes until ctx is done, then waits for in-flight handlers.
+func (p *Processor) Run(ctx context.Context) error {
+	klog.InfoS("starting rotation processor", "workers", p.workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait.UntilWithContext(ctx, p.worker, 0)
+		}()
+	}
+
+	<-ctx.Done()
+	p.queue.ShutDown()
+	wg.Wait()
+	klog.InfoS("rotation processor stopped")
+	return nil
+}
+
+func (p *Processor) worker(ctx context.Context) {
+	for p.processNext(ctx) {
+	}
+}
+
+func (p *Processor) processNext(ctx context.Context) bool {
+	item, shutdown := p.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer p.queue.Done(item)
+
+	volumeID := item.(string)
+	if err := p.handler(ctx, volumeID); err != nil {
+		klog.ErrorS(err, "failed to refresh volume, will retry", "volumeID", volumeID, "retries", p.queue.NumRequeues(item))
+		p.queue.AddRateLimited(item)
+		return true
+	}
+	p.queue.Forget(item)
+	return true
+}
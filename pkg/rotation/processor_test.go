@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rotation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProcessorBoundsConcurrency(t *testing.T) {
+	const (
+		workers = 3
+		volumes = 20
+	)
+
+	var (
+		mu        sync.Mutex
+		running   int
+		maxSeen   int
+		perVolume = map[string]int{}
+		done      sync.WaitGroup
+	)
+
+	done.Add(volumes)
+	p := NewProcessor(workers, func(ctx context.Context, volumeID string) error {
+		mu.Lock()
+		running++
+		perVolume[volumeID]++
+		if running > maxSeen {
+			maxSeen = running
+		}
+		if perVolume[volumeID] > 1 {
+			t.Errorf("volume %s processed concurrently", volumeID)
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		perVolume[volumeID]--
+		mu.Unlock()
+		done.Done()
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		_ = p.Run(ctx)
+		close(stopped)
+	}()
+
+	for i := 0; i < volumes; i++ {
+		p.Enqueue(fmt.Sprintf("vol-%d", i))
+	}
+
+	done.Wait()
+	cancel()
+	<-stopped
+
+	if maxSeen > workers {
+		t.Errorf("saw %d concurrent handlers, want at most %d", maxSeen, workers)
+	}
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testutil builds consistent graphs of COSI objects for tests. Unlike
+// the fixtures used by this repository's own unit tests, every reference in a
+// Graph points at another object of the same Graph, so the objects can be fed
+// to fake clients (or a real cluster) and resolved end to end.
+package testutil
+
+import (
+	"encoding/json"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	cosifake "sigs.k8s.io/container-object-storage-interface-api/clientset/fake"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+)
+
+// Graph is a ready to publish set of objects: a pod referencing a granted
+// BucketAccessRequest, its BucketRequest, BucketAccess, Bucket and minted secret.
+type Graph struct {
+	Pod    *corev1.Pod
+	BAR    *v1alpha1.BucketAccessRequest
+	BR     *v1alpha1.BucketRequest
+	BA     *v1alpha1.BucketAccess
+	Bucket *v1alpha1.Bucket
+	Secret *corev1.Secret
+}
+
+// GraphOption mutates a Graph after the defaults have been filled in.
+type GraphOption func(g *Graph)
+
+// NewGraph returns a Graph whose objects are named after name and live in
+// namespace. Options are applied in order.
+func NewGraph(namespace, name string, opts ...GraphOption) *Graph {
+	g := &Graph{
+		Pod: &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				UID:       types.UID(name + "-uid"),
+			},
+			Spec: corev1.PodSpec{
+				ServiceAccountName: name,
+			},
+		},
+		BR: &v1alpha1.BucketRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: v1alpha1.BucketRequestSpec{
+				BucketClassName: name,
+			},
+			Status: v1alpha1.BucketRequestStatus{
+				BucketAvailable: true,
+				BucketName:      name,
+			},
+		},
+		BAR: &v1alpha1.BucketAccessRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: v1alpha1.BucketAccessRequestSpec{
+				BucketRequestName:     name,
+				BucketAccessClassName: name,
+				ServiceAccountName:    name,
+			},
+			Status: v1alpha1.BucketAccessRequestStatus{
+				AccessGranted:    true,
+				BucketAccessName: name,
+			},
+		},
+		BA: &v1alpha1.BucketAccess{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: v1alpha1.BucketAccessSpec{
+				BucketName: name,
+				BucketAccessRequest: &corev1.ObjectReference{
+					Name:      name,
+					Namespace: namespace,
+				},
+				ServiceAccount: &corev1.ObjectReference{
+					Name:      name,
+					Namespace: namespace,
+				},
+			},
+			Status: v1alpha1.BucketAccessStatus{
+				AccessGranted: true,
+				MintedSecret: &corev1.SecretReference{
+					Name:      name,
+					Namespace: namespace,
+				},
+				AccountID: name,
+			},
+		},
+		Bucket: &v1alpha1.Bucket{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: v1alpha1.BucketSpec{
+				Provisioner:     "test-provisioner",
+				BucketClassName: name,
+				BucketRequest: &corev1.ObjectReference{
+					Name:      name,
+					Namespace: namespace,
+				},
+				Protocol: v1alpha1.Protocol{
+					S3: &v1alpha1.S3Protocol{
+						Endpoint:   "https://s3.example.com",
+						BucketName: name,
+						Region:     "us-east-1",
+					},
+				},
+			},
+			Status: v1alpha1.BucketStatus{
+				BucketAvailable: true,
+				BucketID:        name,
+			},
+		},
+		Secret: &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Data: map[string][]byte{
+				"accessKeyID":     []byte("AKIAEXAMPLE"),
+				"accessSecretKey": []byte("secret"),
+			},
+			Type: corev1.SecretTypeOpaque,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// WithProtocol replaces the Bucket's protocol.
+func WithProtocol(proto v1alpha1.Protocol) GraphOption {
+	return func(g *Graph) {
+		g.Bucket.Spec.Protocol = proto
+	}
+}
+
+// WithSecretData replaces the minted secret's data.
+func WithSecretData(data map[string][]byte) GraphOption {
+	return func(g *Graph) {
+		g.Secret.Data = data
+	}
+}
+
+// WithAccessDenied leaves the BucketAccessRequest and BucketAccess ungranted,
+// as they are before the provisioner has acted.
+func WithAccessDenied() GraphOption {
+	return func(g *Graph) {
+		g.BAR.Status.AccessGranted = false
+		g.BA.Status.AccessGranted = false
+	}
+}
+
+// WithBucketUnavailable marks the Bucket and BucketRequest as not yet available.
+func WithBucketUnavailable() GraphOption {
+	return func(g *Graph) {
+		g.BR.Status.BucketAvailable = false
+		g.Bucket.Status.BucketAvailable = false
+	}
+}
+
+// VolumeContext returns the volume attributes kubelet passes for the Graph's pod.
+func (g *Graph) VolumeContext() map[string]string {
+	return map[string]string{
+		client.BarNameKey:      g.BAR.Name,
+		client.PodNameKey:      g.Pod.Name,
+		client.PodNamespaceKey: g.Pod.Namespace,
+	}
+}
+
+// KubeObjects returns the core objects of the Graph.
+func (g *Graph) KubeObjects() []runtime.Object {
+	return []runtime.Object{g.Pod, g.Secret}
+}
+
+// COSIObjects returns the objectstorage.k8s.io objects of the Graph.
+func (g *Graph) COSIObjects() []runtime.Object {
+	return []runtime.Object{g.BAR, g.BR, g.BA, g.Bucket}
+}
+
+// FakeClients returns fake clientsets seeded with the objects of every graph.
+func FakeClients(graphs ...*Graph) (*k8sfake.Clientset, *cosifake.Clientset) {
+	var kube, cosi []runtime.Object
+	for _, g := range graphs {
+		kube = append(kube, g.KubeObjects()...)
+		cosi = append(cosi, g.COSIObjects()...)
+	}
+	return k8sfake.NewSimpleClientset(kube...), cosifake.NewSimpleClientset(cosi...)
+}
+
+// FakeNodeClient returns a NodeClient backed by fake clientsets seeded with
+// graphs, along with the clientsets so tests can inspect or mutate state.
+func FakeNodeClient(graphs ...*Graph) (client.NodeClient, kubernetes.Interface, *cosifake.Clientset) {
+	kube, cosi := FakeClients(graphs...)
+	return client.NewNodeClient(kube, cosi.ObjectstorageV1alpha1(), record.NewFakeRecorder(100)), kube, cosi
+}
+
+// FakeClientset is implemented by the fake kube and COSI clientsets.
+type FakeClientset interface {
+	PrependReactor(verb, resource string, reaction k8stesting.ReactionFunc)
+	Tracker() k8stesting.ObjectTracker
+}
+
+// ServeMetadataApplies makes c accept the metadata server-side applies the
+// adapter makes, which fake clientsets reject. The applied finalizers and
+// annotations are merged into the stored object; as field managers are not
+// tracked, fields left out of an apply are never removed.
+func ServeMetadataApplies(c FakeClientset) {
+	c.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		var cfg metav1.PartialObjectMetadata
+		if err := json.Unmarshal(patch.GetPatch(), &cfg); err != nil {
+			return true, nil, apierrors.NewBadRequest(err.Error())
+		}
+
+		gvr := patch.GetResource()
+		stored, err := c.Tracker().Get(gvr, patch.GetNamespace(), patch.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		obj := stored.DeepCopyObject()
+		m, err := meta.Accessor(obj)
+		if err != nil {
+			return true, nil, err
+		}
+		if cfg.UID != "" && cfg.UID != m.GetUID() {
+			return true, nil, apierrors.NewConflict(gvr.GroupResource(), m.GetName(), errors.New("uid does not match"))
+		}
+
+		finalizers := m.GetFinalizers()
+		for _, f := range cfg.Finalizers {
+			if !contains(finalizers, f) {
+				finalizers = append(finalizers, f)
+			}
+		}
+		m.SetFinalizers(finalizers)
+		if len(cfg.Annotations) > 0 {
+			annotations := m.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			for k, v := range cfg.Annotations {
+				annotations[k] = v
+			}
+			m.SetAnnotations(annotations)
+		}
+		return true, obj, c.Tracker().Update(gvr, obj, patch.GetNamespace())
+	})
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/util"
+)
+
+func TestGraphResolves(t *testing.T) {
+	cases := map[string]struct {
+		graph   *Graph
+		wantErr error
+	}{
+		"Default": {
+			graph: NewGraph("ns", "app"),
+		},
+		"AccessDenied": {
+			graph:   NewGraph("ns", "app", WithAccessDenied()),
+			wantErr: util.ErrorBARNoAccess,
+		},
+		"BucketUnavailable": {
+			graph:   NewGraph("ns", "app", WithBucketUnavailable()),
+			wantErr: util.ErrorBNotAvailable,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nc, _, _ := FakeNodeClient(tc.graph)
+
+			bkt, ba, secret, _, err := nc.GetResources(context.Background(), tc.graph.BAR.Name, tc.graph.Pod.Name, tc.graph.Pod.Namespace)
+
+			if diff := cmp.Diff(tc.wantErr, err, util.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+
+			if diff := cmp.Diff(tc.graph.Bucket, bkt); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.graph.BA, ba); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.graph.Secret, secret); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
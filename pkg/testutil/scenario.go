@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Scenario is a regression case for the publish pipeline, read from a YAML
+// file. It starts from the Graph of NewGraph(namespace, name), overlays the
+// objects it sets, publishes a volume with the Graph's volume context and its
+// own attributes, and states what publishing must produce:
+//
+//	description: RGW endpoints are written without a scheme
+//	bucket:
+//	  spec:
+//	    protocol:
+//	      s3:
+//	        endpoint: rgw.example.com:7480
+//	volumeContext:
+//	  format: aws
+//	expect:
+//	  files:
+//	    aws_config: |
+//	      [default]
+//	      region = us-east-1
+//	      endpoint_url = https://rgw.example.com:7480
+//
+// Overlays are decoded into the default objects, so only the fields set are
+// replaced and maps are merged. Secret stringData is folded into data, as the
+// API server does. Unknown fields are an error, so a misspelled expectation
+// cannot pass unnoticed.
+type Scenario struct {
+	// Name is the file name without its extension.
+	Name string `json:"-"`
+
+	Description string `json:"description"`
+
+	// Namespace and Name of the Graph, "ns" and "app" when unset.
+	Namespace string `json:"namespace,omitempty"`
+	GraphName string `json:"name,omitempty"`
+
+	Pod    json.RawMessage `json:"pod,omitempty"`
+	BAR    json.RawMessage `json:"bucketAccessRequest,omitempty"`
+	BR     json.RawMessage `json:"bucketRequest,omitempty"`
+	BA     json.RawMessage `json:"bucketAccess,omitempty"`
+	Bucket json.RawMessage `json:"bucket,omitempty"`
+	Secret json.RawMessage `json:"secret,omitempty"`
+
+	// VolumeContext is added to the Graph's volume context.
+	VolumeContext map[string]string `json:"volumeContext,omitempty"`
+
+	Expect Expectation `json:"expect"`
+}
+
+// Expectation is the outcome of a Scenario.
+type Expectation struct {
+	// Code is the gRPC code name returned, such as FailedPrecondition. OK
+	// when unset.
+	Code string `json:"code,omitempty"`
+	// Error must be contained in the error message, if set.
+	Error string `json:"error,omitempty"`
+	// Files maps names, relative to the volume root, to their expected
+	// content. Files not listed are not checked.
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// LoadScenarios reads every .yaml file of dir, sorted by name.
+func LoadScenarios(dir string) ([]Scenario, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	scenarios := make([]Scenario, 0, len(paths))
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var s Scenario
+		if err := decodeStrict(data, &s); err != nil {
+			return nil, fmt.Errorf("scenario %s: %v", path, err)
+		}
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		scenarios = append(scenarios, s)
+	}
+	return scenarios, nil
+}
+
+// Graph returns the Graph of the scenario with its overlays applied.
+func (s Scenario) Graph() (*Graph, error) {
+	namespace, name := s.Namespace, s.GraphName
+	if namespace == "" {
+		namespace = "ns"
+	}
+	if name == "" {
+		name = "app"
+	}
+	g := NewGraph(namespace, name)
+
+	overlays := []struct {
+		field string
+		raw   json.RawMessage
+		obj   interface{}
+	}{
+		{"pod", s.Pod, g.Pod},
+		{"bucketAccessRequest", s.BAR, g.BAR},
+		{"bucketRequest", s.BR, g.BR},
+		{"bucketAccess", s.BA, g.BA},
+		{"bucket", s.Bucket, g.Bucket},
+		{"secret", s.Secret, g.Secret},
+	}
+	for _, o := range overlays {
+		if len(o.raw) == 0 {
+			continue
+		}
+		if err := decodeStrict(o.raw, o.obj); err != nil {
+			return nil, fmt.Errorf("%s: %v", o.field, err)
+		}
+	}
+
+	if g.Secret.Data == nil {
+		g.Secret.Data = map[string][]byte{}
+	}
+	for k, v := range g.Secret.StringData {
+		g.Secret.Data[k] = []byte(v)
+	}
+	g.Secret.StringData = nil
+	return g, nil
+}
+
+// Attributes returns the volume context to publish g with.
+func (s Scenario) Attributes(g *Graph) map[string]string {
+	attrs := g.VolumeContext()
+	for k, v := range s.VolumeContext {
+		attrs[k] = v
+	}
+	return attrs
+}
+
+// decodeStrict decodes YAML or JSON data into v, rejecting unknown fields.
+func decodeStrict(data []byte, v interface{}) error {
+	data, err := yaml.ToJSON(data)
+	if err != nil {
+		return err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	return d.Decode(v)
+}
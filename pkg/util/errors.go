@@ -8,19 +8,28 @@ const (
 	WrapErrorGetBRFailed  = "get bucketRequest failed"
 	WrapErrorGetBFailed   = "get bucket failed"
 
+	WrapErrorGetBucketClassFailed = "get bucketClass failed"
+
+	WrapErrorApplyBucketFailed = "apply bucket failed"
+
+	WrapErrorLoadKubeconfigFailed  = "failed to load kubeconfig"
+	WrapErrorInClusterConfigFailed = "failed to load in-cluster config, use --kubeconfig outside a cluster"
+	WrapErrorCreateClientFailed    = "failed to create API client"
+
 	WrapErrorGetSecretFailed = "failed to get minted secret from bucketAccess"
 
 	WrapErrorMarshalProtocolFailed = "failed to marshal bucket protocol"
 
 	WrapErrorMkdirFailed              = "failed to mkdir for bucketPath on publish"
 	WrapErrorFailedToCreateVolumeFile = "failed to create file in ephemeral volume"
+	WrapErrorFailedToSyncFile         = "failed to sync file in ephemeral volume"
+	WrapErrorFailedToReadLayout       = "failed to read data directory layout"
+	WrapErrorFailedToWriteLayout      = "failed to write data directory layout"
 	WrapErrorFailedToCreateBucketFile = "failed to create file in bucket mount folder"
 
-	WrapErrorFailedRemoveDirectory    = "failed to remove directory after error"
-	WrapErrorFailedToParseSecret      = "failed to parse secret"
-	WrapErrorFailedToWriteProtocol    = "failed to write protocolConnection to mount volume"
-	WrapErrorFailedToWriteCredentials = "failed to write credentials to mount volume"
-	WrapErrorFailedToMountVolume      = "failed to mount ephemeral volume to pod"
+	WrapErrorFailedRemoveDirectory = "failed to remove directory after error"
+	WrapErrorFailedToParseSecret   = "failed to parse secret"
+	WrapErrorFailedToMountVolume   = "failed to mount ephemeral volume to pod"
 
 	WrapErrorFailedToAddFinalizer    = "failed to add finalizer to bucketAccess"
 	WrapErrorFailedToMarshalMetadata = "failed to marshal Metadata struct"
@@ -35,6 +44,63 @@ const (
 
 	WrapErrorCreatingFile  = "error when creating file"
 	WrapErrorWritingToFile = "error when writing file"
+
+	WrapErrorRevocationWebhookFailed = "failed to call revocation webhook"
+
+	WrapErrorCreatePodBAFailed   = "failed to create pod scoped bucketAccess"
+	WrapErrorWaitPodBAFailed     = "pod scoped bucketAccess was not granted"
+	WrapErrorFailedToDeletePodBA = "failed to delete pod scoped bucketAccess"
+
+	WrapErrorFailedToMarshalKerberosConfig = "failed to marshal kerberos config"
+	WrapErrorFailedToMarshalBucketMetadata = "failed to marshal bucket metadata"
+
+	WrapErrorFanotifyInitFailed = "failed to initialize fanotify"
+	WrapErrorFanotifyMarkFailed = "failed to update fanotify mark"
+	WrapErrorFanotifyReadFailed = "failed to read fanotify events"
+
+	WrapErrorRegistrationSocketFailed = "failed to listen on kubelet plugin registration socket"
+
+	WrapErrorGetNodeFailed = "failed to get node"
+
+	WrapErrorPermissionVerificationFailed = "projected files failed permission verification"
+
+	WrapErrorMountTmpfsFailed   = "failed to mount tmpfs for the volume"
+	WrapErrorUnmountTmpfsFailed = "failed to unmount the tmpfs of the volume"
+
+	WrapErrorAtomicWriteFailed      = "failed to atomically update files"
+	WrapErrorFailedToWriteProjected = "failed to write projected files to mount volume"
+
+	WrapErrorHandoverListenFailed = "failed to take over the listening socket"
+	WrapErrorFailedToAdoptVolumes = "failed to adopt the volumes of a previous adapter"
+
+	WrapErrorSetOwnershipFailed = "failed to set file mode and group"
+
+	WrapErrorCredentialHelperFailed = "failed to serve the credential helper socket"
+
+	WrapErrorLogExportFailed = "failed to export logs to the collector"
+
+	WrapErrorDiscoverAPIFailed = "failed to discover the COSI API versions served by the cluster"
+
+	WrapErrorMigrationListFailed  = "failed to list the objects to migrate"
+	WrapErrorMigrationOwnerFailed = "failed to find the workload owning a pod to migrate"
+
+	WrapErrorCanaryPublishFailed   = "canary publish failed"
+	WrapErrorCanaryReadFailed      = "failed to read the files of the canary volume"
+	WrapErrorCanaryUnpublishFailed = "canary unpublish failed"
+
+	WrapErrorAssumeRoleFailed = "failed to exchange the service account token for temporary credentials"
+
+	WrapErrorFailedToReadManifest  = "failed to read the manifest of the volume"
+	WrapErrorFailedToWriteManifest = "failed to write the manifest of the volume"
+
+	WrapErrorFailedToWipeCredentials = "failed to remove the credentials of an init-only volume"
+
+	WrapErrorPermissionReviewFailed = "failed to review the permissions of the adapter"
+
+	WrapErrorFailedToResolveTargetPath = "failed to resolve the symlinks of the target path"
+
+	WrapErrorFailedToAddSecretFinalizer    = "failed to add finalizer to the minted secret"
+	WrapErrorFailedToRemoveSecretFinalizer = "failed to remove finalizer from the minted secret"
 )
 
 var (
@@ -51,10 +117,143 @@ var (
 	ErrorBNotAvailable = errors.New("bucket is not available yet")
 
 	ErrorInvalidProtocol = errors.New("unrecognized protocol, unable to extract connection data")
+
+	ErrorPodScopedCredentialsDisabled = errors.New("pod scoped credentials are not enabled on this node")
+
+	ErrorInvalidKeytab   = errors.New("minted secret keytab is not a valid keytab file")
+	ErrorInvalidKrb5Conf = errors.New("minted secret krb5.conf has neither a [libdefaults] nor a [realms] section")
+
+	ErrorAccessMonitorUnsupported = errors.New("credential access monitoring is only supported on linux")
+
+	ErrorVolumeStatsArgs = errors.New("volume id and volume path are required")
+
+	ErrorMissingAccessKey = errors.New("minted secret has no access key id and secret access key")
+	ErrorMissingAzureKey  = errors.New("minted secret has no connection string, account key or SAS token")
+
+	ErrorInvalidServiceAccountKey = errors.New("minted secret has a service account key that is not valid JSON")
+	ErrorMissingGoogleCredentials = errors.New("minted secret has no service account key or external account configuration")
+
+	ErrorEndpointScheme      = errors.New("scheme must be http or https")
+	ErrorEndpointCredentials = errors.New("must not embed credentials")
+	ErrorEndpointNoHost      = errors.New("host is empty")
+	ErrorEndpointQuery       = errors.New("must not have a query or fragment")
+
+	ErrorNodeIDUnset = errors.New("node id unset, pass --node-id or set $KUBE_NODE_NAME")
+
+	ErrorCredentialHelperUnset = errors.New("exec credential delivery is not enabled on this node, pass --credential-helper")
+	ErrorExecKeysProjection    = errors.New("projection keys writes the minted secret, which exec credential delivery forbids")
+
+	ErrorCOSIAPINotInstalled = errors.New("the cluster does not serve the objectstorage.k8s.io API group, install the COSI CRDs")
+
+	ErrorPublishFailureInjected = errors.New("publish failed by failure injection")
+
+	ErrorCanaryNotMounted = errors.New("canary volume was published but its target path is not mounted")
+
+	ErrorWebIdentityExec  = errors.New("web identity credentials are written into the volume, which exec credential delivery forbids")
+	ErrorWebIdentityNotS3 = errors.New("web identity credentials are only issued for buckets with the S3 protocol")
+	ErrorSTSNoCredentials = errors.New("STS response holds no credentials")
+
+	ErrorIdentityAccessExec = errors.New("bucketAccess grants identity access without credentials, which exec credential delivery cannot serve")
+
+	ErrorAnonymousPodScoped   = errors.New("anonymous volumes read no credentials, pod scoped credentials cannot be requested")
+	ErrorAnonymousExec        = errors.New("anonymous volumes read no credentials, which exec credential delivery cannot serve")
+	ErrorAnonymousWebIdentity = errors.New("anonymous volumes read no credentials, web identity credentials cannot be requested")
+
+	ErrorInitOnlyExec = errors.New("exec credential delivery writes no credentials into the volume, there are none to remove once init containers have run")
 )
 
 var (
 	ErrorTemplateVolCtxUnset          = "required volume context key unset: %v"
 	ErrorTemplateVolumeAlreadyMounted = "%s is already mounted"
 	ErrorTemplateMountFailed          = "failed to mount device: %s at %s"
+
+	ErrorTemplateRevocationWebhookStatus = "revocation webhook returned status %d"
+	ErrorTemplateInvalidCredentialScope  = "invalid credential scope %q"
+	ErrorTemplateInvalidSubdir           = "invalid subdirectory %q, must stay within the volume"
+
+	ErrorTemplateUnknownFormat    = "unknown format %q, must be one of: %s"
+	ErrorTemplateUnknownProfile   = "unknown profile %q, must be one of: %s"
+	ErrorTemplateRenderFailed     = "failed to render format %q: %v"
+	ErrorTemplateFileConflict     = "file %q is rendered by both format %q and format %q"
+	ErrorTemplateProtocolMismatch = "format requires the %s protocol"
+	ErrorTemplateInvalidEndpoint  = "bucket %q has an invalid %s %q: %v"
+	ErrorTemplateUnknownChecksum  = "unknown checksum algorithm %q, must be one of: %s"
+
+	ErrorTemplateInvalidRotationMode    = "invalid rotation mode %q, must be one of: enabled, disabled, on-change"
+	ErrorTemplateInvalidRefreshInterval = "invalid refresh interval %q: %v"
+
+	ErrorTemplateRegistrationFailed = "kubelet failed to register the plugin: %s"
+
+	ErrorTemplateUnsupportedLayout  = "data directory layout version %d is not supported, this adapter supports up to %d"
+	ErrorTemplateUnknownFeature     = "unknown feature gate %q, must be one of: %v"
+	ErrorTemplateInvalidFeatureGate = "invalid feature gate %q, must be Feature=true or Feature=false"
+	ErrorTemplateUnknownSyncPolicy  = "unknown fsync policy %q, must be one of: always, interval, on-critical, never"
+	ErrorTemplateNoServerDate       = "API server response to %s has no usable Date header"
+
+	ErrorTemplateInvalidFeatureLabel = "invalid value %q of node label %q, must be true or false"
+	ErrorTemplateNodeFeaturesChanged = "feature labels of node %q changed, restarting to apply them"
+
+	ErrorTemplateInvalidDirMode  = "invalid directory mode %q, must be octal permissions such as 0700"
+	ErrorTemplateHardenedDirMode = "directory mode %q grants access to other users, which hardened mode forbids"
+	ErrorTemplateWorldAccessible = "%s has mode %v, which grants access to other users"
+
+	ErrorTemplateInvalidFileMode  = "invalid file mode %q, must be octal permissions such as 0440"
+	ErrorTemplateHardenedFileMode = "file mode %q grants access to other users, which hardened mode forbids"
+
+	ErrorTemplateInvalidCredentialDelivery = "invalid credential delivery %q, must be one of: files, exec"
+	ErrorTemplateInvalidMountPath          = "invalid mount path %q, must be absolute"
+	ErrorTemplateExecFormat                = "format %q writes static credentials, which exec credential delivery forbids"
+
+	ErrorTemplateInvalidProjection  = "invalid projection %q, must be one of: json, keys"
+	ErrorTemplateKeyPathWithoutKeys = "attribute %q requires projection keys"
+	ErrorTemplateInvalidKeyPath     = "invalid file name %q for secret key %q, must not contain a slash or start with \"..\""
+	ErrorTemplateKeyNotInSecret     = "secret key %q is mapped to a file but missing from the minted secret"
+	ErrorTemplateKeyFileConflict    = "file %q of secret key %q is already projected"
+
+	ErrorTemplateInvalidFileName  = "invalid file name %q for attribute %q, must not contain a slash or start with \"..\""
+	ErrorTemplateFileNameConflict = "file %q named by attribute %q is already projected"
+
+	ErrorTemplateInvalidLogEndpoint = "invalid OTLP logs endpoint %q, must be an http or https URL"
+
+	ErrorTemplateInvalidAWSProfile = "invalid AWS profile %q, must only contain letters, digits and _.@+-"
+
+	ErrorTemplateCOSIAPIUnsupported = "the cluster serves objectstorage.k8s.io %s, but this adapter supports only %s"
+
+	ErrorTemplateGoogleCredentialType = "minted secret has Google credentials of type %q, must be service_account or external_account"
+
+	ErrorTemplateNotExecutable      = "%s is not an executable for %s"
+	ErrorTemplateExecutableArch     = "%s is built for %v, but the node runs %s; the image does not match the node architecture"
+	ErrorTemplateMountHelperMissing = "%s was not found in PATH, the adapter image must provide it to mount volumes"
+
+	ErrorTemplateInvalidEnvKeyPrefix = "invalid env key prefix %q, must start with a letter or _ and only contain letters, digits and _"
+	ErrorTemplateEnvNameConflict     = "secret key %q is exported as %s, which is already set"
+
+	ErrorTemplateInvalidInjectedFailure = "invalid injected failure %q, must be one of: slow-publish=<duration>, fail-publish, revoke-after=<duration>"
+
+	ErrorTemplateInvalidWebIdentityRoleARN   = "invalid web identity role ARN %q, must look like arn:aws:iam::123456789012:role/name"
+	ErrorTemplateInvalidSTSEndpoint          = "invalid STS endpoint %q, must be an http or https URL"
+	ErrorTemplateInvalidServiceAccountTokens = "invalid service account tokens passed by kubelet: %v"
+	ErrorTemplateMissingServiceAccountToken  = "kubelet passed no service account token for audience %q, add it to the tokenRequests of the CSIDriver"
+	ErrorTemplateSTSRejected                 = "STS rejected the web identity token: %s: %s"
+	ErrorTemplateSTSStatus                   = "STS returned status %d"
+
+	ErrorTemplateInvalidRateLimit   = "invalid rate limit %q, must be namespace=qps:burst with a positive qps and burst"
+	ErrorTemplatePublishRateLimited = "publishes in namespace %s are rate limited, retrying later"
+
+	ErrorTemplateInvalidAnonymous = "invalid anonymous %q, must be true or false"
+
+	ErrorTemplateUnknownServiceAccountCheck = "unknown service account check %q, must be one of: off, warn, enforce"
+	ErrorTemplateServiceAccountMismatch     = "pod runs as service account %q, but bucketAccessRequest %s/%s is for %q"
+
+	ErrorTemplateInvalidInitOnly = "invalid init-only %q, must be true or false"
+
+	ErrorTemplateInvalidVolumeID          = "invalid volume ID %q, must not be empty, contain a slash or be a dot path"
+	ErrorTemplateTargetPathOutsideKubelet = "target path %q must be a clean absolute path below the kubelet pods directory %s"
+	ErrorTemplateTargetPathEscapes        = "target path %q resolves to %s through symlinks, outside the kubelet pods directory %s"
+
+	ErrorTemplateOperationInFlight = "%s of volume %s is already in progress"
+
+	ErrorTemplateMountUnsupported = "mounting volumes is not supported on %s"
+
+	ErrorTemplateInvalidMountGroup = "invalid volume mount group %q, must be a numeric group ID"
 )
@@ -15,6 +15,16 @@ const (
 	ResourcesReady     = "ResourceReady"
 	WritingCredentials = "WritingCredentials"
 	SuccessfulPublish  = "Success"
+	CredentialsRotated = "CredentialsRotated"
+
+	RevocationNotSent = "RevocationNotSent"
+	DegradedMode      = "DegradedMode"
+	ClockSkew         = "ClockSkew"
+	RenewalFailed     = "CredentialRenewalFailed"
+	UnexpectedFiles   = "UnexpectedVolumeFiles"
+	Throttled         = "PublishThrottled"
+	SAMismatch        = "ServiceAccountMismatch"
+	WipedCredentials  = "CredentialsWiped"
 )
 
 var (
@@ -58,6 +68,51 @@ var (
 		reason:  BANotReady,
 		message: "Minted credentials secret not found",
 	}
+
+	PodBANotGranted = EventResource{
+		reason:  BANotReady,
+		message: "Pod scoped Bucket Access was not granted in time",
+	}
+
+	RevocationFailed = EventResource{
+		reason:  RevocationNotSent,
+		message: "Failed to signal credential release to the provisioner",
+	}
+
+	LoadSheddingStarted = EventResource{
+		reason:  DegradedMode,
+		message: "API server latency is high, COSI node adapter is skipping optional work",
+	}
+
+	CredentialRenewalFailed = EventResource{
+		reason:  RenewalFailed,
+		message: "Temporary credentials could not be renewed before they expire",
+	}
+
+	ClockSkewDetected = EventResource{
+		reason:  ClockSkew,
+		message: "Node clock is skewed against the API server, expiring credentials may appear to expire early; refreshes are scheduled earlier to compensate",
+	}
+
+	UnexpectedVolumeFiles = EventResource{
+		reason:  UnexpectedFiles,
+		message: "Volume holds files the COSI node adapter did not create, they were left on the node",
+	}
+
+	PublishRateLimited = EventResource{
+		reason:  Throttled,
+		message: "Publishes of bucket volumes in this namespace exceed its rate limit on the node, kubelet retries them later",
+	}
+
+	ServiceAccountMismatch = EventResource{
+		reason:  SAMismatch,
+		message: "Pod does not run as the service account its Bucket Access Request was made for",
+	}
+
+	InitOnlyCredentialsWiped = EventResource{
+		reason:  WipedCredentials,
+		message: "Pod is running, credentials only needed by its init containers were removed from the volume mount",
+	}
 )
 
 var (
@@ -80,6 +135,11 @@ var (
 		reason:  SuccessfulPublish,
 		message: "Volume successfully unpublished from pod",
 	}
+
+	CredentialsRefreshed = EventResource{
+		reason:  CredentialsRotated,
+		message: "Minted credentials changed, files rewritten in the volume mount",
+	}
 )
 
 type EventResource struct {
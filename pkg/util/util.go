@@ -8,6 +8,8 @@ import (
 	"k8s.io/klog/v2"
 )
 
+// ParseData returns the secret's data as a JSON object of strings. Keys are
+// sorted, so the output is stable for unchanged secrets.
 func ParseData(s *v1.Secret) ([]byte, error) {
 	output := make(map[string]string)
 	for key, value := range s.Data {
@@ -0,0 +1,295 @@
+//go:build e2e
+// +build e2e
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	"sigs.k8s.io/container-object-storage-interface-api/apis/objectstorage.k8s.io/v1alpha1"
+	cs "sigs.k8s.io/container-object-storage-interface-api/clientset/typed/objectstorage.k8s.io/v1alpha1"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/client"
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/node"
+)
+
+var (
+	kubeconfig        = flag.String("kubeconfig", "", "kubeconfig of the cluster under test, defaults to $KUBECONFIG")
+	namespace         = flag.String("namespace", "default", "namespace the test objects are created in, must be allowed by the bucket class")
+	driverName        = flag.String("driver-name", node.DefaultDriverName, "name the adapter under test is registered as")
+	bucketClass       = flag.String("bucket-class", "sample-bc", "BucketClass served by the sample provisioner")
+	bucketAccessClass = flag.String("bucket-access-class", "sample-bac", "BucketAccessClass served by the sample provisioner")
+	image             = flag.String("image", "busybox:1.33", "image of the pods reading the projected files")
+	podScoped         = flag.Bool("pod-scoped", false, "the adapter runs with --allow-pod-scoped-credentials")
+	timeout           = flag.Duration("timeout", 3*time.Minute, "how long to wait for each object to become ready")
+)
+
+// mountPath is where test pods mount the volume.
+const mountPath = "/cosi"
+
+var f *framework
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	config, err := client.RESTConfig(*kubeconfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	kube, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	cosi, err := cs.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	f = &framework{kube: kube, cosi: cosi, namespace: *namespace}
+
+	os.Exit(m.Run())
+}
+
+// framework creates the objects of a test case and waits on the cluster.
+type framework struct {
+	kube      kubernetes.Interface
+	cosi      cs.ObjectstorageV1alpha1Interface
+	namespace string
+}
+
+// bucketAccess requests a bucket and access to it from the sample provisioner
+// and waits until access is granted. It returns the BucketAccessRequest name.
+func (f *framework) bucketAccess(ctx context.Context, t *testing.T) string {
+	t.Helper()
+	name := "e2e-" + utilrand.String(6)
+
+	br := &v1alpha1.BucketRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: f.namespace},
+		Spec:       v1alpha1.BucketRequestSpec{BucketClassName: *bucketClass},
+	}
+	if _, err := f.cosi.BucketRequests(f.namespace).Create(ctx, br, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("creating bucketRequest: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = f.cosi.BucketRequests(f.namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+	})
+
+	bar := &v1alpha1.BucketAccessRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: f.namespace},
+		Spec: v1alpha1.BucketAccessRequestSpec{
+			BucketRequestName:     name,
+			BucketAccessClassName: *bucketAccessClass,
+			ServiceAccountName:    "default",
+		},
+	}
+	if _, err := f.cosi.BucketAccessRequests(f.namespace).Create(ctx, bar, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("creating bucketAccessRequest: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = f.cosi.BucketAccessRequests(f.namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+	})
+
+	err := wait.PollImmediate(2*time.Second, *timeout, func() (bool, error) {
+		bar, err := f.cosi.BucketAccessRequests(f.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return bar.Status.AccessGranted && bar.Status.BucketAccessName != "", nil
+	})
+	if err != nil {
+		t.Fatalf("waiting for access to be granted to %q: %v", name, err)
+	}
+	return name
+}
+
+// pod runs a single container with the volume mounted at mountPath, running
+// script with sh, and returns its name. The pod is deleted at cleanup.
+func (f *framework) pod(ctx context.Context, t *testing.T, attrs map[string]string, readOnly bool, script string) string {
+	t.Helper()
+	name := "e2e-" + utilrand.String(6)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: f.namespace},
+		Spec: v1.PodSpec{
+			RestartPolicy: v1.RestartPolicyNever,
+			Containers: []v1.Container{{
+				Name:         "reader",
+				Image:        *image,
+				Command:      []string{"sh", "-c", script},
+				VolumeMounts: []v1.VolumeMount{{Name: "cosi", MountPath: mountPath}},
+			}},
+			Volumes: []v1.Volume{{
+				Name: "cosi",
+				VolumeSource: v1.VolumeSource{CSI: &v1.CSIVolumeSource{
+					Driver:           *driverName,
+					ReadOnly:         &readOnly,
+					VolumeAttributes: attrs,
+				}},
+			}},
+		},
+	}
+	if _, err := f.kube.CoreV1().Pods(f.namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("creating pod: %v", err)
+	}
+	t.Cleanup(func() { f.deletePod(context.Background(), t, name) })
+	return name
+}
+
+// logs waits for pod to succeed and returns its output.
+func (f *framework) logs(ctx context.Context, t *testing.T, pod string) string {
+	t.Helper()
+	err := wait.PollImmediate(2*time.Second, *timeout, func() (bool, error) {
+		p, err := f.kube.CoreV1().Pods(f.namespace).Get(ctx, pod, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		switch p.Status.Phase {
+		case v1.PodSucceeded:
+			return true, nil
+		case v1.PodFailed:
+			return false, fmt.Errorf("pod %q failed", pod)
+		}
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("waiting for pod: %v", err)
+	}
+
+	out, err := f.kube.CoreV1().Pods(f.namespace).GetLogs(pod, &v1.PodLogOptions{}).DoRaw(ctx)
+	if err != nil {
+		t.Fatalf("reading pod logs: %v", err)
+	}
+	return string(out)
+}
+
+// mountFailure waits for kubelet to report a failed mount of pod's volume and
+// returns the event message.
+func (f *framework) mountFailure(ctx context.Context, t *testing.T, pod string) string {
+	t.Helper()
+	selector := fields.Set{
+		"involvedObject.kind": "Pod",
+		"involvedObject.name": pod,
+		"reason":              "FailedMount",
+	}.AsSelector().String()
+
+	var message string
+	err := wait.PollImmediate(2*time.Second, *timeout, func() (bool, error) {
+		events, err := f.kube.CoreV1().Events(f.namespace).List(ctx, metav1.ListOptions{FieldSelector: selector})
+		if err != nil {
+			return false, err
+		}
+		if len(events.Items) == 0 {
+			return false, nil
+		}
+		message = events.Items[0].Message
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("waiting for a FailedMount event: %v", err)
+	}
+	return message
+}
+
+// deletePod deletes pod and waits until the adapter has released the
+// BucketAccess, which it signals by removing the pod's finalizer.
+func (f *framework) deletePod(ctx context.Context, t *testing.T, pod string) {
+	t.Helper()
+	zero := int64(0)
+	err := f.kube.CoreV1().Pods(f.namespace).Delete(ctx, pod, metav1.DeleteOptions{GracePeriodSeconds: &zero})
+	if err != nil && !apierrors.IsNotFound(err) {
+		t.Errorf("deleting pod: %v", err)
+		return
+	}
+
+	err = wait.PollImmediate(2*time.Second, *timeout, func() (bool, error) {
+		_, err := f.kube.CoreV1().Pods(f.namespace).Get(ctx, pod, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		t.Errorf("waiting for pod deletion: %v", err)
+	}
+}
+
+// podFinalizers returns the finalizers the adapter holds on BucketAccesses for pod.
+func (f *framework) podFinalizers(ctx context.Context, t *testing.T, pod string) []string {
+	t.Helper()
+	bas, err := f.cosi.BucketAccesses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("listing bucketAccesses: %v", err)
+	}
+	suffix := fmt.Sprintf("-%s-%s", f.namespace, pod)
+	var found []string
+	for _, ba := range bas.Items {
+		for _, fin := range ba.Finalizers {
+			if strings.HasSuffix(fin, suffix) {
+				found = append(found, ba.Name+": "+fin)
+			}
+		}
+	}
+	return found
+}
+
+// catScript prints every file in names, relative to the mount, as a block
+// parsed by parseFiles.
+func catScript(names ...string) string {
+	var b bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&b, "echo '--- %s'; cat %s/%s || echo MISSING; echo; ", name, mountPath, name)
+	}
+	return b.String()
+}
+
+// parseFiles splits the output of catScript by file name.
+func parseFiles(out string) map[string]string {
+	files := map[string]string{}
+	var name string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "--- ") {
+			name = strings.TrimPrefix(line, "--- ")
+			continue
+		}
+		if name != "" {
+			files[name] += line + "\n"
+		}
+	}
+	for name, data := range files {
+		files[name] = strings.TrimSpace(data)
+	}
+	return files
+}
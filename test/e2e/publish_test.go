@@ -0,0 +1,195 @@
+//go:build e2e
+// +build e2e
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"sigs.k8s.io/container-object-storage-interface-csi-adapter/pkg/apis/adapter"
+)
+
+func TestPublish(t *testing.T) {
+	type want struct {
+		// files must be present and non-empty.
+		files []string
+		// json files must additionally parse as JSON objects.
+		json []string
+		// output must appear in the pod's output.
+		output string
+	}
+
+	cases := map[string]struct {
+		attrs    map[string]string
+		readOnly bool
+		script   string
+		skip     string
+		want
+	}{
+		"Shared": {
+			script: catScript(adapter.CredentialsFileName, adapter.ProtocolFileName),
+			want: want{
+				files: []string{adapter.CredentialsFileName, adapter.ProtocolFileName},
+				json:  []string{adapter.CredentialsFileName, adapter.ProtocolFileName},
+			},
+		},
+		"PodScoped": {
+			attrs:  map[string]string{adapter.CredentialScopeKey: string(adapter.CredentialScopePod)},
+			script: catScript(adapter.CredentialsFileName),
+			skip:   "the adapter under test does not allow pod scoped credentials, pass -pod-scoped",
+			want: want{
+				files: []string{adapter.CredentialsFileName},
+				json:  []string{adapter.CredentialsFileName},
+			},
+		},
+		"Format": {
+			attrs:  map[string]string{adapter.FormatKey: "aws"},
+			script: catScript("aws_credentials", "aws_config"),
+			want: want{
+				files: []string{"aws_credentials", "aws_config"},
+			},
+		},
+		"Profile": {
+			attrs:  map[string]string{adapter.ProfileKey: "rclone"},
+			script: catScript("rclone.conf"),
+			want: want{
+				files: []string{"rclone.conf"},
+			},
+		},
+		"Checksums": {
+			attrs:  map[string]string{adapter.ChecksumsKey: "sha256"},
+			script: "cd " + mountPath + " && sha256sum -c SHA256SUMS && echo VERIFIED",
+			want: want{
+				output: "VERIFIED",
+			},
+		},
+		"Subdirs": {
+			attrs:  map[string]string{adapter.SubdirsKey: "etc/bucket"},
+			script: catScript(adapter.CredentialsFileName, "etc/bucket/"+adapter.CredentialsFileName),
+			want: want{
+				files: []string{adapter.CredentialsFileName, "etc/bucket/" + adapter.CredentialsFileName},
+			},
+		},
+		"ReadOnly": {
+			readOnly: true,
+			script:   "rm " + mountPath + "/" + adapter.CredentialsFileName + " || echo REFUSED",
+			want: want{
+				output: "REFUSED",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			if tc.skip != "" && !*podScoped {
+				t.Skip(tc.skip)
+			}
+			ctx := context.Background()
+
+			attrs := map[string]string{adapter.BucketAccessRequestNameKey: f.bucketAccess(ctx, t)}
+			for k, v := range tc.attrs {
+				attrs[k] = v
+			}
+			pod := f.pod(ctx, t, attrs, tc.readOnly, tc.script)
+			out := f.logs(ctx, t, pod)
+
+			files := parseFiles(out)
+			for _, name := range tc.want.files {
+				if data := files[name]; data == "" || data == "MISSING" {
+					t.Errorf("%s: missing or empty, output:\n%s", name, out)
+				}
+			}
+			for _, name := range tc.want.json {
+				var obj map[string]interface{}
+				if err := json.Unmarshal([]byte(files[name]), &obj); err != nil {
+					t.Errorf("%s: not a JSON object: %v", name, err)
+				}
+			}
+			if !strings.Contains(out, tc.want.output) {
+				t.Errorf("output does not contain %q:\n%s", tc.want.output, out)
+			}
+
+			f.deletePod(ctx, t, pod)
+			if diff := cmp.Diff([]string(nil), f.podFinalizers(ctx, t, pod)); diff != "" {
+				t.Errorf("finalizers left after unpublish: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPublishFailure(t *testing.T) {
+	cases := map[string]struct {
+		attrs map[string]string
+		// existingBAR uses a granted BucketAccessRequest as bar-name.
+		existingBAR bool
+		// want must appear in the FailedMount event.
+		want string
+	}{
+		"MissingBucketAccessRequest": {
+			attrs: map[string]string{adapter.BucketAccessRequestNameKey: "does-not-exist"},
+			want:  "not found",
+		},
+		"InvalidRotation": {
+			attrs:       map[string]string{adapter.RotationKey: "sometimes"},
+			existingBAR: true,
+			want:        "invalid rotation mode",
+		},
+		"UnknownFormat": {
+			attrs:       map[string]string{adapter.FormatKey: "does-not-exist"},
+			existingBAR: true,
+			want:        "unknown format",
+		},
+		"InvalidSubdir": {
+			attrs:       map[string]string{adapter.SubdirsKey: "../escape"},
+			existingBAR: true,
+			want:        "must stay within the volume",
+		},
+	}
+
+	for name, tc := range cases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			attrs := map[string]string{}
+			if tc.existingBAR {
+				attrs[adapter.BucketAccessRequestNameKey] = f.bucketAccess(ctx, t)
+			}
+			for k, v := range tc.attrs {
+				attrs[k] = v
+			}
+			pod := f.pod(ctx, t, attrs, false, "true")
+
+			if msg := f.mountFailure(ctx, t, pod); !strings.Contains(msg, tc.want) {
+				t.Errorf("FailedMount event %q does not contain %q", msg, tc.want)
+			}
+
+			f.deletePod(ctx, t, pod)
+			if diff := cmp.Diff([]string(nil), f.podFinalizers(ctx, t, pod)); diff != "" {
+				t.Errorf("finalizers left after a failed publish: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}